@@ -107,38 +107,60 @@ func KeyOf(t types.Type) (Key, bool) {
 	return Key{mset}, true
 }
 
+// A TypeRelation is a set of relations between types: a bitwise
+// union of Subtype and Supertype.
+type TypeRelation int8
+
+const (
+	Subtype   TypeRelation = 1 << iota // types that implement the query type
+	Supertype                          // types that the query type implements
+)
+
 // A Result reports a matching type or method in a method-set search.
 type Result struct {
-	Location Location // location of the type or method
+	Location    Location     // location of the type or method
+	TypeName    string       // name of the matching type
+	IsInterface bool         // matching type is an interface
+	Relation    TypeRelation // relation(s) of the matching type to the query type
+
+	// PkgPath is the path of the package declaring the type, or,
+	// for methods, the package declaring the method (which may
+	// differ due to embedding).
+	PkgPath string
 
 	// methods only:
-	PkgPath    string          // path of declaring package (may differ due to embedding)
 	ObjectPath objectpath.Path // path of method within declaring package
 }
 
-// Search reports each type that implements (or is implemented by) the
-// type that produced the search key. If methodID is nonempty, only
-// that method of each type is reported.
+// Search reports each type that is related to the type that produced
+// the search key by one of the relations in want. Both relations hold
+// for two identical interface types. If method is non-nil, only that
+// method of each type is reported.
 //
 // The result does not include the error.Error method.
 // TODO(adonovan): give this special case a more systematic treatment.
-func (index *Index) Search(key Key, method *types.Func) []Result {
+func (index *Index) Search(key Key, want TypeRelation, method *types.Func) []Result {
 	var results []Result
 	for _, candidate := range index.pkg.MethodSets {
-		// Traditionally this feature doesn't report
-		// interface/interface elements of the relation.
-		// I think that's a mistake.
-		// TODO(adonovan): UX: change it, here and in the local implementation.
-		if candidate.IsInterface && key.mset.IsInterface {
-			continue
+		var rel TypeRelation
+		if want&Subtype != 0 && implements(candidate, key.mset) {
+			rel |= Subtype
 		}
-
-		if !implements(candidate, key.mset) && !implements(key.mset, candidate) {
+		if want&Supertype != 0 && implements(key.mset, candidate) {
+			rel |= Supertype
+		}
+		if rel == 0 {
 			continue
 		}
 
 		if method == nil {
-			results = append(results, Result{Location: index.location(candidate.Posn)})
+			results = append(results, Result{
+				Location:    index.location(candidate.Posn),
+				TypeName:    index.pkg.Strings[candidate.Name],
+				IsInterface: candidate.IsInterface,
+				Relation:    rel,
+				PkgPath:     index.pkg.Strings[index.pkg.PkgPath],
+			})
 		} else {
 			for _, m := range candidate.Methods {
 				if m.ID == method.Id() {
@@ -153,9 +175,12 @@ func (index *Index) Search(key Key, method *types.Func) []Result {
 					}
 
 					results = append(results, Result{
-						Location:   index.location(m.Posn),
-						PkgPath:    index.pkg.Strings[m.PkgPath],
-						ObjectPath: objectpath.Path(index.pkg.Strings[m.ObjectPath]),
+						Location:    index.location(m.Posn),
+						TypeName:    index.pkg.Strings[candidate.Name],
+						IsInterface: candidate.IsInterface,
+						Relation:    rel,
+						PkgPath:     index.pkg.Strings[m.PkgPath],
+						ObjectPath:  objectpath.Path(index.pkg.Strings[m.ObjectPath]),
 					})
 					break
 				}
@@ -226,6 +251,7 @@ type indexBuilder struct {
 // build adds to the index all package-level named types of the specified package.
 func (b *indexBuilder) build(fset *token.FileSet, pkg *types.Package) *Index {
 	_ = b.string("") // 0 => ""
+	b.PkgPath = b.string(pkg.Path())
 
 	objectPos := func(obj types.Object) gobPosition {
 		posn := safetoken.StartPosition(fset, obj.Pos())
@@ -285,6 +311,7 @@ func (b *indexBuilder) build(fset *token.FileSet, pkg *types.Package) *Index {
 		if tname, ok := scope.Lookup(name).(*types.TypeName); ok && !tname.IsAlias() {
 			if mset := methodSetInfo(tname.Type(), setIndexInfo); mset.Mask != 0 {
 				mset.Posn = objectPos(tname)
+				mset.Name = b.string(tname.Name())
 				// Only record types with non-trivial method sets.
 				b.MethodSets = append(b.MethodSets, mset)
 			}
@@ -364,12 +391,14 @@ var packageCodec = frob.CodecFor[gobPackage]()
 // A gobPackage records the method set of each package-level type for a single package.
 type gobPackage struct {
 	Strings    []string // index of strings used by gobPosition.File, gobMethod.{Pkg,Object}Path
+	PkgPath    int      // path of the indexed package
 	MethodSets []*gobMethodSet
 }
 
 // A gobMethodSet records the method set of a single type.
 type gobMethodSet struct {
 	Posn        gobPosition
+	Name        int // name of the type
 	IsInterface bool
 	Tricky      bool   // at least one method is tricky; fingerprint must be parsed + unified
 	Mask        uint64 // mask with 1 bit from each of methods[*].sum
@@ -293,6 +293,7 @@ func (app *Application) featureCommands() []tool.Application {
 		&highlight{app: app},
 		&implementation{app: app},
 		&imports{app: app},
+		&index{app: app},
 		newRemote(app, ""),
 		newRemote(app, "inspect"),
 		&links{app: app},
@@ -0,0 +1,417 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/golang"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/settings"
+	versionpkg "golang.org/x/tools/gopls/internal/version"
+	"golang.org/x/tools/internal/tool"
+)
+
+// index implements the index verb for gopls.
+type index struct {
+	Output string `flag:"o,output" help:"write the index to this file instead of the standard output"`
+
+	app *Application
+}
+
+func (i *index) Name() string      { return "index" }
+func (i *index) Parent() string    { return i.app.Name() }
+func (i *index) Usage() string     { return "[index-flags]" }
+func (i *index) ShortHelp() string { return "write an LSIF index of the workspace" }
+func (i *index) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+Index every Go file beneath the current directory, and write the
+result in LSIF (Language Server Index Format) form, one JSON element
+per line.
+
+The index records, for each symbol, its definition, references, hover
+documentation, monikers (see textDocument/moniker), and, for types and
+methods, implementations. Code browsers and other tools may use it to
+provide navigation without running a language server.
+
+Example:
+
+	$ gopls index -o dump.lsif
+
+index-flags:
+`)
+	printFlagDefaults(f)
+}
+
+// An indexSymbol records what the indexer knows about a symbol,
+// identified by the location of its definition.
+type indexSymbol struct {
+	def           protocol.Location
+	refs          []protocol.Location // all references, including the definition
+	implementable bool                // a type or method
+}
+
+func (i *index) Run(ctx context.Context, args ...string) error {
+	if len(args) != 0 {
+		return tool.CommandLineErrorf("index expects no arguments")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	conn, err := i.app.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	// Find the Go files of the workspace.
+	var uris []protocol.DocumentURI
+	err = filepath.WalkDir(wd, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != wd && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") {
+			uris = append(uris, protocol.URIFromPath(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Resolve the definitions of the identifiers using a session of
+	// our own, as the server offers no batch request for them.
+	session := cache.NewSession(ctx, cache.New(nil))
+	defer session.Shutdown(ctx)
+	root := protocol.URIFromPath(wd)
+	options := settings.DefaultOptions(i.app.options)
+	env, err := cache.FetchGoEnv(ctx, root, options)
+	if err != nil {
+		return err
+	}
+	_, _, release, err := session.NewView(ctx, &cache.Folder{
+		Dir:     root,
+		Name:    filepath.Base(wd),
+		Options: options,
+		Env:     *env,
+	})
+	if err != nil {
+		return err
+	}
+	release()
+
+	// Resolve the definition of each identifier.
+	var (
+		symbols = make(map[protocol.Location]*indexSymbol)
+		ranges  = make(map[protocol.DocumentURI][]protocol.Range)
+	)
+	for _, uri := range uris {
+		file := conn.client.openFile(uri)
+		if file.err != nil {
+			return file.err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, uri.Path(), file.mapper.Content, parser.SkipObjectResolution)
+		if f == nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", uri.Path(), err)
+			continue
+		}
+
+		// Note which identifiers declare types and methods.
+		tok := fset.File(f.FileStart)
+		implementable := make(map[protocol.Range]bool)
+		declares := func(id *ast.Ident) {
+			if rng, err := file.mapper.PosRange(tok, id.Pos(), id.End()); err == nil {
+				implementable[rng] = true
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				declares(n.Name)
+			case *ast.FuncDecl:
+				if n.Recv != nil {
+					declares(n.Name)
+				}
+			case *ast.InterfaceType:
+				for _, field := range n.Methods.List {
+					for _, name := range field.Names {
+						declares(name)
+					}
+				}
+			}
+			return true
+		})
+
+		// Resolve the definitions of all the identifiers of the
+		// file at once.
+		idents, err := identifiers(ctx, session, uri)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", uri.Path(), err)
+			continue
+		}
+		for _, id := range idents {
+			loc := protocol.Location{URI: uri, Range: id.Range}
+			def := id.Definition
+			sym := symbols[def]
+			if sym == nil {
+				sym = &indexSymbol{def: def}
+				symbols[def] = sym
+			}
+			sym.refs = append(sym.refs, loc)
+			if loc == def && implementable[id.Range] {
+				sym.implementable = true
+			}
+			ranges[uri] = append(ranges[uri], loc.Range)
+		}
+	}
+
+	out := os.Stdout
+	if i.Output != "" {
+		f, err := os.Create(i.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	if err := i.write(ctx, conn, w, root, ranges, symbols); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// identifiers returns the identifiers of the specified file and the
+// definitions of their symbols.
+func identifiers(ctx context.Context, session *cache.Session, uri protocol.DocumentURI) ([]golang.Identifier, error) {
+	snapshot, release, err := session.SnapshotOf(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	fh, err := snapshot.ReadFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return golang.Identifiers(ctx, snapshot, fh)
+}
+
+// write writes the LSIF index of the specified documents and symbols.
+func (i *index) write(ctx context.Context, conn *connection, out io.Writer, root protocol.DocumentURI, ranges map[protocol.DocumentURI][]protocol.Range, symbols map[protocol.Location]*indexSymbol) error {
+	e := &lsifEncoder{enc: json.NewEncoder(out)}
+
+	e.vertex("metaData", map[string]any{
+		"version":          "0.5.0",
+		"projectRoot":      root,
+		"positionEncoding": "utf-16",
+		"toolInfo":         map[string]any{"name": "gopls", "version": versionpkg.Version()},
+	})
+	project := e.vertex("project", map[string]any{"kind": "go"})
+
+	// Emit each document and its ranges.
+	uris := make([]protocol.DocumentURI, 0, len(ranges))
+	for uri := range ranges {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	var (
+		docIDs   = make(map[protocol.DocumentURI]int)
+		rangeIDs = make(map[protocol.Location]int)
+	)
+	for _, uri := range uris {
+		doc := e.vertex("document", map[string]any{"uri": uri, "languageId": "go"})
+		docIDs[uri] = doc
+		var ids []int
+		for _, rng := range ranges[uri] {
+			id := e.vertex("range", map[string]any{"start": rng.Start, "end": rng.End})
+			rangeIDs[protocol.Location{URI: uri, Range: rng}] = id
+			ids = append(ids, id)
+		}
+		e.edges("contains", doc, ids, nil)
+	}
+	docs := make([]int, len(uris))
+	for k, uri := range uris {
+		docs[k] = docIDs[uri]
+	}
+	e.edges("contains", project, docs, nil)
+
+	// items emits an item edge from result to the range of each
+	// known location, grouped by document.
+	items := func(result int, locs []protocol.Location, property string) {
+		byDoc := make(map[int][]int)
+		var docs []int
+		for _, loc := range locs {
+			if id, ok := rangeIDs[loc]; ok {
+				doc := docIDs[loc.URI]
+				if byDoc[doc] == nil {
+					docs = append(docs, doc)
+				}
+				byDoc[doc] = append(byDoc[doc], id)
+			}
+		}
+		sort.Ints(docs)
+		for _, doc := range docs {
+			extra := map[string]any{"document": doc}
+			if property != "" {
+				extra["property"] = property
+			}
+			e.edges("item", result, byDoc[doc], extra)
+		}
+	}
+
+	// Emit the result set of each symbol.
+	defs := make([]protocol.Location, 0, len(symbols))
+	for def := range symbols {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return protocol.CompareLocation(defs[i], defs[j]) < 0 })
+	packages := make(map[string]int) // packageInformation IDs, by module
+	for _, def := range defs {
+		sym := symbols[def]
+		resultSet := e.vertex("resultSet", nil)
+		for _, ref := range sym.refs {
+			e.edge("next", rangeIDs[ref], resultSet)
+		}
+
+		// Queries are made at the definition, if it was indexed,
+		// or else at the first reference.
+		at := protocol.LocationTextDocumentPositionParams(sym.refs[0])
+		if _, ok := rangeIDs[def]; ok {
+			at = protocol.LocationTextDocumentPositionParams(def)
+			result := e.vertex("definitionResult", nil)
+			e.edge("textDocument/definition", resultSet, result)
+			items(result, []protocol.Location{def}, "")
+		}
+
+		var refs []protocol.Location
+		for _, ref := range sym.refs {
+			if ref != def {
+				refs = append(refs, ref)
+			}
+		}
+		result := e.vertex("referenceResult", nil)
+		e.edge("textDocument/references", resultSet, result)
+		items(result, []protocol.Location{def}, "definitions")
+		items(result, refs, "references")
+
+		if hover, err := conn.Hover(ctx, &protocol.HoverParams{TextDocumentPositionParams: at}); err == nil && hover != nil {
+			result := e.vertex("hoverResult", map[string]any{
+				"result": map[string]any{"contents": hover.Contents},
+			})
+			e.edge("textDocument/hover", resultSet, result)
+		}
+
+		if sym.implementable {
+			impls, _ := conn.Implementation(ctx, &protocol.ImplementationParams{TextDocumentPositionParams: at})
+			impls = slices.DeleteFunc(impls, func(loc protocol.Location) bool {
+				_, ok := rangeIDs[loc]
+				return !ok // not indexed
+			})
+			if len(impls) > 0 {
+				result := e.vertex("implementationResult", nil)
+				e.edge("textDocument/implementation", resultSet, result)
+				items(result, impls, "")
+			}
+		}
+
+		monikers, err := conn.Moniker(ctx, &protocol.MonikerParams{TextDocumentPositionParams: at})
+		if err != nil {
+			continue
+		}
+		for _, m := range monikers {
+			fields := map[string]any{
+				"scheme":     m.Scheme,
+				"identifier": m.Identifier,
+				"unique":     m.Unique,
+			}
+			if m.Kind != nil {
+				fields["kind"] = *m.Kind
+			}
+			moniker := e.vertex("moniker", fields)
+			e.edge("moniker", resultSet, moniker)
+
+			// The first word of the identifier is module[@version].
+			module, _, _ := strings.Cut(m.Identifier, " ")
+			pkgInfo, ok := packages[module]
+			if !ok {
+				name, version, _ := strings.Cut(module, "@")
+				fields := map[string]any{"name": name, "manager": m.Scheme}
+				if version != "" {
+					fields["version"] = version
+				}
+				pkgInfo = e.vertex("packageInformation", fields)
+				packages[module] = pkgInfo
+			}
+			e.edge("packageInformation", moniker, pkgInfo)
+		}
+	}
+	return e.err
+}
+
+// An lsifEncoder writes a stream of LSIF vertices and edges.
+type lsifEncoder struct {
+	enc *json.Encoder
+	id  int
+	err error
+}
+
+// vertex emits a vertex with the given label and additional fields,
+// and returns its ID.
+func (e *lsifEncoder) vertex(label string, fields map[string]any) int {
+	return e.emit("vertex", label, fields)
+}
+
+// edge emits a one-to-one edge with the given label.
+func (e *lsifEncoder) edge(label string, outV, inV int) {
+	e.emit("edge", label, map[string]any{"outV": outV, "inV": inV})
+}
+
+// edges emits a one-to-many edge with the given label and additional fields.
+func (e *lsifEncoder) edges(label string, outV int, inVs []int, fields map[string]any) {
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	fields["outV"] = outV
+	fields["inVs"] = inVs
+	e.emit("edge", label, fields)
+}
+
+func (e *lsifEncoder) emit(typ, label string, fields map[string]any) int {
+	e.id++
+	elem := map[string]any{"id": e.id, "type": typ, "label": label}
+	for k, v := range fields {
+		elem[k] = v
+	}
+	if e.err == nil {
+		e.err = e.enc.Encode(elem)
+	}
+	return e.id
+}
@@ -35,6 +35,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 
@@ -560,6 +561,75 @@ func _() {
 	}
 }
 
+// TestIndex tests the 'index' subcommand (index.go).
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	tree := writeTree(t, `
+-- go.mod --
+module example.com
+go 1.18
+
+-- a/a.go --
+package a
+
+// T is a type.
+type T int
+
+func (T) String() string { return "" }
+
+type I interface{ String() string }
+
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+var _ a.I = a.T(0)
+`)
+	// arguments
+	{
+		res := gopls(t, tree, "index", "a")
+		res.checkExit(false)
+		res.checkStderr("expects no arguments")
+	}
+	// success
+	{
+		res := gopls(t, tree, "index")
+		res.checkExit(true)
+
+		// Decode the elements, and index the vertices by label.
+		byLabel := make(map[string][]map[string]any)
+		for _, line := range strings.Split(strings.TrimSpace(res.stdout), "\n") {
+			var elem map[string]any
+			if err := json.Unmarshal([]byte(line), &elem); err != nil {
+				t.Fatalf("invalid element %q: %v", line, err)
+			}
+			if elem["type"] == "vertex" {
+				label := elem["label"].(string)
+				byLabel[label] = append(byLabel[label], elem)
+			}
+		}
+		if n := len(byLabel["document"]); n != 2 {
+			t.Errorf("got %d documents, want 2", n)
+		}
+		for _, label := range []string{"metaData", "definitionResult", "referenceResult", "hoverResult", "implementationResult"} {
+			if len(byLabel[label]) == 0 {
+				t.Errorf("no %s in index", label)
+			}
+		}
+		var identifiers []string
+		for _, moniker := range byLabel["moniker"] {
+			identifiers = append(identifiers, moniker["identifier"].(string))
+		}
+		for _, want := range []string{"example.com example.com/a#T", "example.com example.com/a#I"} {
+			if !slices.Contains(identifiers, want) {
+				t.Errorf("no moniker %q in %q", want, identifiers)
+			}
+		}
+	}
+}
+
 // TestLinks tests the 'links' subcommand (links.go).
 func TestLinks(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,611 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the "Generalize F over T" code action, which
+// introduces a type parameter in place of a concrete type in the
+// signature of a function.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/imports"
+	"golang.org/x/tools/internal/versions"
+)
+
+// addTypeParamTarget returns the declaration of the function whose
+// signature encloses the selected type expression, and that
+// expression, if the function may be generalized over its type: it
+// must be a non-generic package-level function with a body, in a file
+// whose Go version supports generics. It returns nil if there is none.
+func addTypeParamTarget(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*ast.FuncDecl, ast.Expr) {
+	info := pkg.TypesInfo()
+	if versions.Before(versions.FileVersion(info, pgf.File), versions.Go1_18) {
+		return nil, nil
+	}
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	var texpr ast.Expr // innermost enclosing type expression
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if texpr == nil || n.Recv != nil || n.Type.TypeParams != nil || n.Body == nil ||
+				texpr.Pos() < n.Type.Params.Pos() || texpr.End() > n.Type.End() {
+				return nil, nil
+			}
+			if t := info.TypeOf(texpr); t == nil || t == types.Typ[types.Invalid] {
+				return nil, nil
+			}
+			return n, texpr
+		case *ast.FuncLit:
+			return nil, nil
+		case ast.Expr:
+			if tv, ok := info.Types[n]; ok && tv.IsType() && texpr == nil {
+				texpr = n
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Type sets of the constraints inferred by addTypeParam.
+const (
+	integerTypes = "~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr"
+	numberTypes  = integerTypes + " | ~float32 | ~float64"
+	orderedTypes = numberTypes + " | ~string"
+)
+
+// A typeParamCandidate is a candidate generalization of a function:
+// the occurrences of the concrete type to replace by the type
+// parameter, and its constraint.
+type typeParamCandidate struct {
+	occurrences []ast.Expr
+	constraint  string
+	useCmp      bool // constraint refers to package cmp
+}
+
+// addTypeParam generalizes the function whose signature encloses the
+// type expression at rng over a new type parameter, which replaces
+// that type (call it X) in the function's declaration.
+//
+// The constraint of the type parameter is inferred from the
+// operations the function applies to values of type X: comparison,
+// ordering, arithmetic, and method calls. Every occurrence of X in
+// the declaration is replaced if the function still type-checks;
+// otherwise only the selected one. The smallest constraint that
+// permits the function body is preferred; if it is insufficient, the
+// constraint ~U is used, where U is the underlying type of X.
+//
+// Calls to the function, and other references to it, rely on
+// type-argument inference, as the infertypeargs analyzer does: each
+// reference is type-checked against the generalized function, and
+// explicit type arguments [X] are added only to references for which
+// inference fails or infers a type other than X.
+func addTypeParam(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	decl, texpr := addTypeParamTarget(pkg, pgf, start, end)
+	if decl == nil {
+		return nil, fmt.Errorf("no type in a function signature selected")
+	}
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
+	}
+	info := pkg.TypesInfo()
+	fn := info.Defs[decl.Name].(*types.Func)
+	X := info.TypeOf(texpr)
+	qual := typesQualifier(pkg.Types(), pgf.File, info, nil)
+	xstr := types.TypeString(X, qual)
+
+	// Choose a name for the type parameter.
+	used := make(map[string]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	fileScope := info.Scopes[pgf.File]
+	tparam, _ := generateName(0, "T", func(name string) bool {
+		if used[name] {
+			return true
+		}
+		_, obj := fileScope.LookupParent(name, token.NoPos)
+		return obj != nil
+	})
+
+	// Find the occurrences of X in the declaration.
+	var all []ast.Expr
+	inParams := func(occurrences []ast.Expr) bool {
+		for _, e := range occurrences {
+			if decl.Type.Params.Pos() <= e.Pos() && e.End() <= decl.Type.Params.End() {
+				return true
+			}
+		}
+		return false
+	}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if e, ok := n.(ast.Expr); ok {
+			if tv, ok := info.Types[e]; ok && tv.IsType() && types.Identical(tv.Type, X) {
+				all = append(all, e)
+				return false
+			}
+		}
+		return true
+	})
+	var occurrenceSets [][]ast.Expr
+	if inParams(all) {
+		occurrenceSets = append(occurrenceSets, all)
+	}
+	if len(all) > 1 && inParams([]ast.Expr{texpr}) {
+		occurrenceSets = append(occurrenceSets, []ast.Expr{texpr})
+	}
+	if len(occurrenceSets) == 0 {
+		return nil, fmt.Errorf("cannot generalize %s over %s: the type parameter could not be inferred from the arguments of calls", fn.Name(), xstr)
+	}
+
+	// Can the constraint refer to cmp.Ordered?
+	var cmpPkg *types.Package
+	if versions.AtLeast(versions.FileVersion(info, pgf.File), versions.Go1_21) {
+		if _, obj := fileScope.LookupParent("cmp", token.NoPos); obj == nil {
+			pkgs, err := resolveImports(ctx, snapshot, pkg, pgf, []ast.Expr{&ast.SelectorExpr{X: ast.NewIdent("cmp"), Sel: ast.NewIdent("Ordered")}})
+			if err != nil {
+				return nil, err
+			}
+			if len(pkgs) == 1 && pkgs[0].Path() == "cmp" {
+				cmpPkg = pkgs[0]
+			}
+		} else if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Imported().Path() == "cmp" {
+			cmpPkg = pkgName.Imported()
+		}
+	}
+	var candidates []typeParamCandidate
+	for _, occurrences := range occurrenceSets {
+		for _, c := range typeConstraints(info, decl, X, qual, cmpPkg != nil) {
+			c.occurrences = occurrences
+			candidates = append(candidates, c)
+		}
+	}
+
+	// Find all packages that may refer to the function,
+	// and the variants of its package.
+	pkgs, err := typeCheckReverseDependencies(ctx, snapshot, pgf.URI, true)
+	if err != nil {
+		return nil, err
+	}
+	var variants, importers []*cache.Package
+	for _, p := range pkgs {
+		if p.Metadata().PkgPath == pkg.Metadata().PkgPath {
+			variants = append(variants, p)
+		} else if fn.Exported() {
+			importers = append(importers, p)
+		}
+	}
+
+	// Choose the first candidate for which each variant of the
+	// package type-checks, allowing errors outside the function,
+	// which are assumed to be failures of type inference at
+	// references to it.
+	declStart, declEnd, err := safetoken.Offsets(pgf.Tok, decl.Pos(), decl.End())
+	if err != nil {
+		return nil, err
+	}
+	logf := logger(ctx, "add type parameter", snapshot.Options().VerboseOutput)
+	type checked struct {
+		pkg   *cache.Package
+		tpkg  *types.Package
+		tinfo *types.Info
+		file  *ast.File // new declaring file
+	}
+	var (
+		chosen     *typeParamCandidate
+		declEdits  []diff.Edit // of the declaring file
+		checkEdits []diff.Edit // of the declaring file, as type-checked
+		results    []checked   // for each variant
+		firstErr   error
+	)
+	for i := range candidates {
+		c := &candidates[i]
+		declEdits = []diff.Edit{{Start: offsetOf(pgf, decl.Name.End()), End: offsetOf(pgf, decl.Name.End()), New: "[" + tparam + " " + c.constraint + "]"}}
+		for _, e := range c.occurrences {
+			declEdits = append(declEdits, diff.Edit{Start: offsetOf(pgf, e.Pos()), End: offsetOf(pgf, e.End()), New: tparam})
+		}
+		checkEdits = declEdits
+		var extra []*types.Package
+		if c.useCmp {
+			// (The import is added properly below.)
+			offset := offsetOf(pgf, pgf.File.Name.End())
+			checkEdits = append([]diff.Edit{{Start: offset, End: offset, New: `; import "cmp"`}}, declEdits...)
+			extra = append(extra, cmpPkg)
+		}
+		results = nil
+		for _, v := range variants {
+			src, err := diff.ApplyBytes(pgf.Src, checkEdits)
+			if err != nil {
+				return nil, bug.Errorf("applying edits: %v", err)
+			}
+			file, err := parser.ParseFile(v.FileSet(), pgf.URI.Path(), src, parser.ParseComments|parser.SkipObjectResolution)
+			if err != nil {
+				return nil, bug.Errorf("parsing generalized function: %v", err)
+			}
+			tok := v.FileSet().File(file.Pos())
+			newDeclStart, newDeclEnd := shiftOffset(checkEdits, declStart), shiftOffset(checkEdits, declEnd)
+			tpkg, tinfo, err := reTypeCheck(logf, v, map[protocol.DocumentURI]*ast.File{pgf.URI: file}, extra, func(err types.Error) bool {
+				offset, ok := offsetIn(tok, err.Pos)
+				return !ok || offset < newDeclStart || offset >= newDeclEnd
+			})
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				results = nil
+				break
+			}
+			results = append(results, checked{v, tpkg, tinfo, file})
+		}
+		if results != nil {
+			chosen = c
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("cannot generalize %s over %s: %v", fn.Name(), xstr, firstErr)
+	}
+
+	edits := map[protocol.DocumentURI][]diff.Edit{pgf.URI: declEdits}
+	if chosen.useCmp {
+		if _, obj := fileScope.LookupParent("cmp", token.NoPos); obj == nil {
+			importEdits, err := ComputeImportFixEdits(snapshot.Options().Local, pgf.Src, &imports.ImportFix{
+				StmtInfo: imports.ImportInfo{ImportPath: "cmp"},
+				FixType:  imports.AddImport,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range importEdits {
+				start, end, err := pgf.Mapper.RangeOffsets(e.Range)
+				if err != nil {
+					return nil, err
+				}
+				edits[pgf.URI] = append(edits[pgf.URI], diff.Edit{Start: start, End: end, New: e.NewText})
+			}
+		}
+	}
+
+	// Add explicit type arguments to the references for which
+	// inference does not yield X.
+	type ref struct {
+		uri    protocol.DocumentURI
+		offset int
+	}
+	seen := make(map[ref]bool) // files of several package variants
+	// instantiate adds the type argument to the reference at the
+	// given offset of refpgf, unless X is inferred.
+	instantiate := func(p *cache.Package, refpgf *parsego.File, offset int, inferred bool) error {
+		if seen[ref{refpgf.URI, offset}] {
+			return nil
+		}
+		seen[ref{refpgf.URI, offset}] = true
+		if inferred {
+			return nil
+		}
+		var missing *types.Package
+		text := types.TypeString(X, typesQualifier(p.Types(), refpgf.File, p.TypesInfo(), &missing))
+		if missing != nil {
+			return fmt.Errorf("cannot generalize %s over %s: the reference at %s requires an explicit type argument, but package %s is not imported",
+				fn.Name(), xstr, safetoken.StartPosition(p.FileSet(), refpgf.Tok.Pos(offset)), missing.Name())
+		}
+		end := offset + len(fn.Name())
+		edits[refpgf.URI] = append(edits[refpgf.URI], diff.Edit{Start: end, End: end, New: "[" + text + "]"})
+		return nil
+	}
+	// inferred reports whether the reference id to the generalized
+	// function, in a package whose type information is tinfo, is
+	// instantiated with X.
+	inferred := func(tinfo *types.Info, id *ast.Ident) bool {
+		inst, ok := tinfo.Instances[id]
+		return ok && inst.TypeArgs.Len() == 1 && identicalByPath(inst.TypeArgs.At(0), X)
+	}
+	for _, r := range results {
+		newFn := r.tpkg.Scope().Lookup(fn.Name())
+		for _, refpgf := range r.pkg.CompiledGoFiles() {
+			file := refpgf.File
+			if refpgf.URI == pgf.URI {
+				file = r.file
+			}
+			var ids []*ast.Ident
+			ast.Inspect(file, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && r.tinfo.Uses[id] == newFn {
+					ids = append(ids, id)
+				}
+				return true
+			})
+			tok := r.pkg.FileSet().File(file.Pos())
+			for _, id := range ids {
+				offset, _ := offsetIn(tok, id.Pos())
+				if file == r.file {
+					// Map the reference back to the original file.
+					if offset >= shiftOffset(checkEdits, declStart) && offset < shiftOffset(checkEdits, declEnd) {
+						continue // within the function (checked above)
+					}
+					offset = unshiftOffset(checkEdits, offset)
+				}
+				if err := instantiate(r.pkg, refpgf, offset, inferred(r.tinfo, id)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, p := range importers {
+		var (
+			newFn types.Object
+			tinfo *types.Info
+		)
+		declPkg := p.DependencyTypes(PackagePath(fn.Pkg().Path()))
+		for _, r := range results {
+			if r.pkg.Metadata().ID == p.Metadata().DepsByPkgPath[PackagePath(fn.Pkg().Path())] {
+				_, tinfo, err = reTypeCheck(logf, p, nil, []*types.Package{r.tpkg}, allErrors)
+				if err != nil {
+					return nil, err
+				}
+				newFn = r.tpkg.Scope().Lookup(fn.Name())
+			}
+		}
+		for _, refpgf := range p.CompiledGoFiles() {
+			var refErr error
+			ast.Inspect(refpgf.File, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && refErr == nil {
+					if obj, ok := p.TypesInfo().Uses[id].(*types.Func); ok && obj.Name() == fn.Name() && obj.Pkg() == declPkg {
+						refErr = instantiate(p, refpgf, offsetOf(refpgf, id.Pos()), tinfo != nil && tinfo.Uses[id] == newFn && inferred(tinfo, id))
+					}
+				}
+				return refErr == nil
+			})
+			if refErr != nil {
+				return nil, refErr
+			}
+		}
+	}
+
+	return diffEditsToDocumentChanges(ctx, snapshot, edits)
+}
+
+// typeConstraints returns candidate constraints for a type parameter
+// replacing type X in the function decl, in order of preference: the
+// smallest constraint permitting the operations that the function
+// applies to values of type X, and then the constraint ~U, where U is
+// the underlying type of X, if it differs.
+func typeConstraints(info *types.Info, decl *ast.FuncDecl, X types.Type, qual types.Qualifier, haveCmp bool) []typeParamCandidate {
+	var (
+		comparable, ordered, add, arith, integer bool
+		methods                                  []*types.Func
+	)
+	isX := func(e ast.Expr) bool {
+		t := info.TypeOf(e)
+		return t != nil && types.Identical(t, X)
+	}
+	classify := func(op token.Token) {
+		switch op {
+		case token.EQL, token.NEQ:
+			comparable = true
+		case token.LSS, token.LEQ, token.GTR, token.GEQ:
+			ordered = true
+		case token.ADD, token.ADD_ASSIGN:
+			add = true
+		case token.SUB, token.MUL, token.QUO, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN, token.INC, token.DEC:
+			arith = true
+		case token.REM, token.AND, token.OR, token.XOR, token.SHL, token.SHR, token.AND_NOT,
+			token.REM_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN, token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN:
+			integer = true
+		}
+	}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if isX(n.X) || isX(n.Y) {
+				classify(n.Op)
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 1 && isX(n.Lhs[0]) {
+				classify(n.Tok)
+			}
+		case *ast.IncDecStmt:
+			if isX(n.X) {
+				classify(n.Tok)
+			}
+		case *ast.UnaryExpr:
+			if isX(n.X) {
+				switch n.Op {
+				case token.SUB, token.ADD:
+					arith = true
+				case token.XOR:
+					integer = true
+				}
+			}
+		case *ast.SwitchStmt:
+			if n.Tag != nil && isX(n.Tag) {
+				comparable = true
+			}
+		case *ast.SelectorExpr:
+			if seln := info.Selections[n]; seln != nil && seln.Kind() == types.MethodVal && isX(n.X) {
+				m := seln.Obj().(*types.Func)
+				if !containsFunc(methods, m) {
+					methods = append(methods, m)
+				}
+			}
+		}
+		if e, ok := n.(ast.Expr); ok {
+			if m, ok := typeUnderlying(info.TypeOf(e)).(*types.Map); ok && types.Identical(m.Key(), X) {
+				comparable = true
+			}
+		}
+		return true
+	})
+
+	var typeSet string
+	switch {
+	case integer:
+		typeSet = integerTypes
+	case arith:
+		typeSet = numberTypes
+	case ordered || add:
+		typeSet = orderedTypes
+		if haveCmp {
+			typeSet = "cmp.Ordered"
+		}
+	case comparable:
+		typeSet = "comparable"
+	}
+
+	// constraint returns the constraint with the given type set,
+	// and the methods.
+	constraint := func(typeSet string) typeParamCandidate {
+		c := typeParamCandidate{useCmp: strings.HasPrefix(typeSet, "cmp.")}
+		if len(methods) == 0 {
+			c.constraint = typeSet
+			if typeSet == "" {
+				c.constraint = "any"
+			}
+			return c
+		}
+		var elems []string
+		if typeSet != "" {
+			elems = append(elems, typeSet)
+		}
+		for _, m := range methods {
+			var buf bytes.Buffer
+			buf.WriteString(m.Name())
+			types.WriteSignature(&buf, m.Signature(), qual)
+			elems = append(elems, buf.String())
+		}
+		c.constraint = "interface{ " + strings.Join(elems, "; ") + " }"
+		return c
+	}
+	candidates := []typeParamCandidate{constraint(typeSet)}
+	if basic, ok := X.Underlying().(*types.Basic); ok {
+		if tilde := "~" + basic.Name(); tilde != typeSet {
+			candidates = append(candidates, constraint(tilde))
+		}
+	}
+	return candidates
+}
+
+// containsFunc reports whether funcs contains a function named as f.
+func containsFunc(funcs []*types.Func, f *types.Func) bool {
+	for _, g := range funcs {
+		if g.Name() == f.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// typeUnderlying returns the underlying type of t, or nil.
+func typeUnderlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// typesQualifier returns a qualifier for types referenced from the
+// file of package pkg, using the local names of the file's imports.
+// If missing is non-nil, a package that is not imported by the file
+// is recorded in *missing.
+func typesQualifier(pkg *types.Package, file *ast.File, info *types.Info, missing **types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p.Path() == pkg.Path() {
+			return ""
+		}
+		for _, imp := range file.Imports {
+			if pkgName := info.PkgNameOf(imp); pkgName != nil && pkgName.Imported().Path() == p.Path() {
+				if pkgName.Name() == "." {
+					return ""
+				}
+				return pkgName.Name()
+			}
+		}
+		if missing != nil && *missing == nil {
+			*missing = p
+		}
+		return p.Name()
+	}
+}
+
+// identicalByPath reports whether types x and y, possibly from different
+// type-checker runs over the same packages, are the same type.
+func identicalByPath(x, y types.Type) bool {
+	qual := func(p *types.Package) string { return strconv.Quote(p.Path()) }
+	return types.TypeString(x, qual) == types.TypeString(y, qual)
+}
+
+// offsetOf returns the offset of pos within the file of pgf.
+func offsetOf(pgf *parsego.File, pos token.Pos) int {
+	offset, _ := safetoken.Offset(pgf.Tok, pos)
+	return offset
+}
+
+// offsetIn returns the offset of pos within the file tok, if it is
+// in that file.
+func offsetIn(tok *token.File, pos token.Pos) (int, bool) {
+	if tok == nil {
+		return 0, false
+	}
+	offset, err := safetoken.Offset(tok, pos)
+	return offset, err == nil
+}
+
+// shiftOffset returns the offset after applying edits of the original
+// offset, which must not be within an edited range.
+func shiftOffset(edits []diff.Edit, offset int) int {
+	delta := 0
+	for _, e := range sortedEdits(edits) {
+		if e.End > offset {
+			break
+		}
+		delta += len(e.New) - (e.End - e.Start)
+	}
+	return offset + delta
+}
+
+// unshiftOffset is the inverse of [shiftOffset].
+func unshiftOffset(edits []diff.Edit, offset int) int {
+	delta := 0
+	for _, e := range sortedEdits(edits) {
+		if e.Start+delta > offset {
+			break
+		}
+		delta += len(e.New) - (e.End - e.Start)
+	}
+	return offset - delta
+}
+
+func sortedEdits(edits []diff.Edit) []diff.Edit {
+	edits = append([]diff.Edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	return edits
+}
@@ -0,0 +1,682 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the behavior of the "Add fuzz test for FUNC" command.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/typesinternal"
+)
+
+const fuzzTmplString = `
+func {{.FuzzFuncName}}(f *{{.TestingPackageName}}.F) {
+	{{- range .Seeds}}
+	f.Add({{join . ", "}})
+	{{- else}}
+	// TODO: Add seed corpus entries with f.Add.
+	{{- end}}
+	f.Fuzz(func(t *{{.TestingPackageName}}.T{{range .Inputs}}, {{.Name}} {{.Type}}{{end}}) {
+		{{- if .HasResults}}
+		// TODO: check invariants of the results.
+		{{- end}}
+		{{.Call}}
+	})
+}
+`
+
+type fuzzInfo struct {
+	TestingPackageName string
+	FuzzFuncName       string
+	Seeds              [][]string // arguments of each f.Add call
+	Inputs             []field    // parameters of the fuzz function
+	Call               string     // call of the function under test
+	HasResults         bool
+}
+
+var fuzzTmpl = template.Must(template.New("fuzz").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(fuzzTmplString))
+
+// AddFuzzTestForFunc adds a fuzz test for the function enclosing the
+// given input range. It creates a _test.go file if one does not
+// already exist.
+func AddFuzzTestForFunc(ctx context.Context, snapshot *cache.Snapshot, loc protocol.Location) (changes []protocol.DocumentChange, _ error) {
+	return addToTestFile(ctx, snapshot, loc, func(pkg *cache.Package, decl *ast.FuncDecl, fn *types.Func, xtest bool, qual types.Qualifier) ([]byte, error) {
+		return addFuzzTestSource(ctx, snapshot, pkg, fn, xtest, qual)
+	})
+}
+
+// addFuzzTestSource returns a fuzz test of fn, seeded from the calls
+// to fn in the package and its tests.
+func addFuzzTestSource(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, fn *types.Func, xtest bool, qual types.Qualifier) ([]byte, error) {
+	if fn.Signature().Recv() != nil {
+		return nil, fmt.Errorf("cannot add fuzz test for method %s", fn.Name())
+	}
+	testName, err := testName(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	data := fuzzInfo{
+		TestingPackageName: qual(types.NewPackage("testing", "testing")),
+		FuzzFuncName:       "Fuzz" + strings.TrimPrefix(testName, "Test"),
+		HasResults:         fn.Signature().Results().Len() > 0,
+	}
+
+	inputs, call := fuzzInputs(pkg.Types(), fn, xtest, qual)
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("function %s has no parameters that can be built from fuzzable types", fn.Name())
+	}
+	data.Call = call
+	for _, in := range inputs {
+		data.Inputs = append(data.Inputs, field{
+			Name: in.name,
+			Type: types.TypeString(in.typ, qual),
+		})
+	}
+
+	if data.Seeds, err = fuzzSeeds(ctx, snapshot, pkg, fn, inputs, qual); err != nil {
+		return nil, err
+	}
+
+	var test bytes.Buffer
+	if err := fuzzTmpl.Execute(&test, data); err != nil {
+		return nil, err
+	}
+	return test.Bytes(), nil
+}
+
+// A fuzzInput is a parameter of the fuzz function: a value of a
+// fuzzable type from which all or part of an argument of the function
+// under test is built.
+type fuzzInput struct {
+	name  string
+	typ   types.Type // a fuzzable type: []byte, or a boolean, numeric, or string type
+	param int        // index of the parameter of the function under test
+	path  []int      // indices of the struct fields enclosing the input within the parameter
+}
+
+// fuzzInputs returns the inputs of the fuzz function for fn, and the
+// call of fn that builds its arguments from them.
+func fuzzInputs(pkg *types.Package, fn *types.Func, xtest bool, qual types.Qualifier) ([]fuzzInput, string) {
+	// The inputs must not shadow the package names used in the call,
+	// nor the parameter of the fuzz test or of its fuzz function.
+	// We learn of the package names only by building the call,
+	// so we build it again if any of them conflicts.
+	reserved := map[string]bool{"t": true, "f": true}
+	for {
+		var pkgNames []string
+		b := &fuzzBuilder{
+			pkg:   pkg,
+			xtest: xtest,
+			qual: func(p *types.Package) string {
+				name := qual(p)
+				if name != "" {
+					pkgNames = append(pkgNames, name)
+				}
+				return name
+			},
+			used: make(map[string]bool),
+		}
+		for name := range reserved {
+			b.used[name] = true
+		}
+		call := b.call(fn)
+
+		conflict := false
+		for _, name := range pkgNames {
+			if !reserved[name] && slices.ContainsFunc(b.inputs, func(in fuzzInput) bool { return in.name == name }) {
+				reserved[name] = true
+				conflict = true
+			}
+		}
+		if !conflict {
+			return b.inputs, call
+		}
+	}
+}
+
+// A fuzzBuilder builds the arguments of a call from fuzz inputs.
+type fuzzBuilder struct {
+	pkg    *types.Package // package under test
+	xtest  bool           // whether the fuzz test is in an external test package
+	qual   types.Qualifier
+	used   map[string]bool // names of the inputs, and reserved names
+	inputs []fuzzInput
+}
+
+// maxFuzzDepth bounds the nesting of struct fields built from fuzz inputs.
+const maxFuzzDepth = 2
+
+// call returns a call of fn whose arguments are built from fuzz inputs.
+func (b *fuzzBuilder) call(fn *types.Func) string {
+	sig := fn.Signature()
+	var args []string
+	for i := range sig.Params().Len() {
+		param := sig.Params().At(i)
+		name := param.Name()
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		arg, ok := b.build(param.Type(), name, i, nil)
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			if !ok {
+				break // omit variadic parameter
+			}
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	callee := fn.Name()
+	if name := b.qual(b.pkg); name != "" {
+		callee = name + "." + callee
+	}
+	return fmt.Sprintf("%s(%s)", callee, strings.Join(args, ", "))
+}
+
+// build returns an expression of type t built from fuzz inputs whose
+// names are derived from name, and reports whether any inputs were
+// used. If none were, the expression is the zero value of t, or for a
+// context.Context, a background context.
+func (b *fuzzBuilder) build(t types.Type, name string, param int, path []int) (string, bool) {
+	if leaf := fuzzableType(t); leaf != nil && b.accessible(t) {
+		name = b.fresh(name)
+		b.inputs = append(b.inputs, fuzzInput{
+			name:  name,
+			typ:   leaf,
+			param: param,
+			path:  path,
+		})
+		if types.Identical(t, leaf) {
+			return name, true
+		}
+		return fmt.Sprintf("%s(%s)", types.TypeString(t, b.qual), name), true
+	}
+
+	if isContext(t) {
+		return b.qual(types.NewPackage("context", "context")) + ".Background()", false
+	}
+
+	// Build a struct, or a pointer to one, from its fields.
+	lit, star := t, ""
+	if ptr, ok := t.Underlying().(*types.Pointer); ok && types.Unalias(t) == t.Underlying() {
+		lit, star = ptr.Elem(), "&"
+	}
+	if strct, ok := lit.Underlying().(*types.Struct); ok && len(path) < maxFuzzDepth && b.accessible(lit) {
+		var elts []string
+		for i := range strct.NumFields() {
+			field := strct.Field(i)
+			if field.Name() == "_" || !b.accessible(field) {
+				continue
+			}
+			elt, ok := b.build(field.Type(), name+upperFirst(field.Name()), param, append(slices.Clip(path), i))
+			if ok {
+				elts = append(elts, field.Name()+": "+elt)
+			}
+		}
+		if len(elts) > 0 {
+			return fmt.Sprintf("%s%s{%s}", star, types.TypeString(lit, b.qual), strings.Join(elts, ", ")), true
+		}
+	}
+
+	zero, _ := typesinternal.ZeroString(t, b.qual)
+	return zero, false
+}
+
+// accessible reports whether t, if it is a named type, or the object
+// x is accessible to the fuzz test.
+func (b *fuzzBuilder) accessible(x any) bool {
+	var obj types.Object
+	switch x := x.(type) {
+	case types.Object:
+		obj = x
+	case typesinternal.NamedOrAlias:
+		obj = x.Obj()
+	default:
+		return true
+	}
+	return obj.Pkg() == nil || obj.Exported() || !b.xtest && obj.Pkg() == b.pkg
+}
+
+// fresh returns name, or if it is already used, name with a numeric
+// suffix.
+func (b *fuzzBuilder) fresh(name string) string {
+	fresh := name
+	for i := 2; b.used[fresh]; i++ {
+		fresh = fmt.Sprintf("%s%d", name, i)
+	}
+	b.used[fresh] = true
+	return fresh
+}
+
+// fuzzableType returns the type of the fuzz input from which a value
+// of type t can be built, or nil if there is none. The fuzzable types
+// are []byte, string, bool, and the integer and floating-point types
+// other than uintptr.
+func fuzzableType(t types.Type) types.Type {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Kind() == types.Uintptr:
+			return nil
+		case u.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsString) != 0:
+			if basic, ok := types.Unalias(t).(*types.Basic); ok {
+				return basic // preserve byte and rune
+			}
+			return types.Typ[u.Kind()]
+		}
+	case *types.Slice:
+		if types.Identical(u.Elem(), types.Typ[types.Byte]) {
+			return types.NewSlice(types.Universe.Lookup("byte").Type())
+		}
+	}
+	return nil
+}
+
+// isContext reports whether t is context.Context.
+func isContext(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+// upperFirst returns s with its first letter in upper case.
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// fuzzSeeds returns the arguments of the f.Add calls that seed the
+// fuzz inputs of fn. They are taken from the constant arguments of
+// calls to fn in its package and its tests, including calls in loops
+// over tables of test cases.
+func fuzzSeeds(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, fn *types.Func, inputs []fuzzInput, qual types.Qualifier) ([][]string, error) {
+	path := pkg.Metadata().PkgPath
+	var ids []metadata.PackageID
+	for id, mp := range snapshot.MetadataGraph().Packages {
+		if mp.PkgPath == path || mp.ForTest == path {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	pkgs, err := snapshot.TypeCheck(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		seeds     [][]string
+		seen      = make(map[string]bool)
+		seenFiles = make(map[protocol.DocumentURI]bool)
+	)
+	for _, p := range pkgs {
+		info := p.TypesInfo()
+		for _, pgf := range p.CompiledGoFiles() {
+			// Test variants repeat the files of the package.
+			if seenFiles[pgf.URI] {
+				continue
+			}
+			seenFiles[pgf.URI] = true
+
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				callee := typeutil.StaticCallee(info, call)
+				if callee == nil || callee.Pkg() == nil || callee.Pkg().Path() != string(path) ||
+					callee.Name() != fn.Name() || callee.Signature().Recv() != nil {
+					return true
+				}
+				for _, seed := range callSeeds(info, pgf.File, call, fn.Signature(), inputs, qual) {
+					if key := strings.Join(seed, ", "); !seen[key] {
+						seen[key] = true
+						seeds = append(seeds, seed)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return seeds, nil
+}
+
+// callSeeds returns the seeds for the fuzz inputs found in the
+// arguments of a call to the function under test, whose signature is
+// sig. If the arguments refer to the element variable of an enclosing
+// loop over a table of test cases, it returns a seed for each of them.
+func callSeeds(info *types.Info, file *ast.File, call *ast.CallExpr, sig *types.Signature, inputs []fuzzInput, qual types.Qualifier) [][]string {
+	path, _ := astutil.PathEnclosingInterval(file, call.Pos(), call.End())
+	for _, n := range path {
+		rng, ok := n.(*ast.RangeStmt)
+		if !ok {
+			continue
+		}
+		id, ok := rng.Value.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		v := info.Defs[id]
+		if v == nil || !refersTo(info, call.Args, v) {
+			continue
+		}
+		table := tableOf(info, file, rng.X)
+		if table == nil {
+			return nil
+		}
+		var seeds [][]string
+		for _, elt := range table.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value // map of test cases
+			}
+			if lit := compositeLit(elt); lit != nil {
+				if seed := callSeed(info, call, sig, inputs, qual, map[types.Object]*ast.CompositeLit{v: lit}); seed != nil {
+					seeds = append(seeds, seed)
+				}
+			}
+		}
+		return seeds
+	}
+	if seed := callSeed(info, call, sig, inputs, qual, nil); seed != nil {
+		return [][]string{seed}
+	}
+	return nil
+}
+
+// callSeed returns the seed for the fuzz inputs found in the arguments
+// of a call, or nil if any of them is not a constant. The test map
+// holds the current test case of each table-driven loop.
+func callSeed(info *types.Info, call *ast.CallExpr, sig *types.Signature, inputs []fuzzInput, qual types.Qualifier, test map[types.Object]*ast.CompositeLit) []string {
+	var seed []string
+	for _, in := range inputs {
+		if in.param >= len(call.Args) ||
+			sig.Variadic() && in.param == sig.Params().Len()-1 && !call.Ellipsis.IsValid() {
+			return nil
+		}
+		v := seedValue(info, call.Args[in.param], in.path, in.typ, test)
+		if v == nil {
+			return nil
+		}
+		s, ok := seedString(v, in.typ, qual)
+		if !ok {
+			return nil
+		}
+		seed = append(seed, s)
+	}
+	return seed
+}
+
+// seedValue returns the constant value of the fuzz input of type leaf
+// at the given path of struct fields within the expression e, or nil
+// if it is not constant. The value of a []byte input is a string.
+func seedValue(info *types.Info, e ast.Expr, path []int, leaf types.Type, test map[types.Object]*ast.CompositeLit) constant.Value {
+	e = ast.Unparen(e)
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND && len(path) > 0 {
+		return seedValue(info, u.X, path, leaf, test)
+	}
+
+	// A field of the current test case?
+	if sel, ok := e.(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if lit, ok := test[info.Uses[id]]; ok {
+				fv, ok := fieldValue(info, lit, sel.Sel.Name)
+				if !ok {
+					return nil
+				}
+				if fv == nil {
+					return zeroConst(leaf)
+				}
+				return seedValue(info, fv, path, leaf, test)
+			}
+		}
+	}
+
+	if len(path) > 0 {
+		lit := compositeLit(e)
+		if lit == nil {
+			return nil
+		}
+		strct := structOf(info.TypeOf(lit))
+		if strct == nil || path[0] >= strct.NumFields() {
+			return nil
+		}
+		fv, ok := fieldValue(info, lit, strct.Field(path[0]).Name())
+		if !ok {
+			return nil
+		}
+		if fv == nil {
+			return zeroConst(leaf)
+		}
+		return seedValue(info, fv, path[1:], leaf, test)
+	}
+
+	if tv, ok := info.Types[e]; ok {
+		if tv.Value != nil {
+			return tv.Value
+		}
+		if tv.IsNil() {
+			return zeroConst(leaf)
+		}
+	}
+
+	// A conversion of a constant string to []byte?
+	if call, ok := e.(*ast.CallExpr); ok && len(call.Args) == 1 && info.Types[call.Fun].IsType() {
+		if v := info.Types[call.Args[0]].Value; v != nil && v.Kind() == constant.String {
+			return v
+		}
+	}
+	return nil
+}
+
+// seedString returns the f.Add argument for the value v of a fuzz
+// input of type leaf, and reports whether v is suitable.
+func seedString(v constant.Value, leaf types.Type, qual types.Qualifier) (string, bool) {
+	basic, ok := leaf.(*types.Basic)
+	if !ok { // []byte
+		if v.Kind() != constant.String {
+			return "", false
+		}
+		return fmt.Sprintf("%s(%s)", types.TypeString(leaf, qual), strconv.Quote(constant.StringVal(v))), true
+	}
+
+	switch info := basic.Info(); {
+	case info&types.IsBoolean != 0:
+		return v.String(), v.Kind() == constant.Bool
+
+	case info&types.IsString != 0:
+		if v.Kind() != constant.String {
+			return "", false
+		}
+		return strconv.Quote(constant.StringVal(v)), true
+
+	case info&types.IsInteger != 0:
+		v = constant.ToInt(v)
+		if v.Kind() != constant.Int {
+			return "", false
+		}
+		if basic.Kind() == types.Int {
+			return v.ExactString(), true
+		}
+		if basic == types.Universe.Lookup("rune").Type() {
+			if r, ok := constant.Int64Val(v); ok && utf8.ValidRune(rune(r)) {
+				return strconv.QuoteRune(rune(r)), true
+			}
+		}
+		return fmt.Sprintf("%s(%s)", basic.Name(), v.ExactString()), true
+
+	case info&types.IsFloat != 0:
+		v = constant.ToFloat(v)
+		if v.Kind() != constant.Float && v.Kind() != constant.Int {
+			return "", false
+		}
+		bits := 64
+		if basic.Kind() == types.Float32 {
+			bits = 32
+		}
+		f, _ := constant.Float64Val(v)
+		s := strconv.FormatFloat(f, 'g', -1, bits)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0" // an untyped float constant
+		}
+		if bits == 32 {
+			s = fmt.Sprintf("float32(%s)", s)
+		}
+		return s, true
+	}
+	return "", false
+}
+
+// zeroConst returns the zero value of a fuzz input of type leaf.
+func zeroConst(leaf types.Type) constant.Value {
+	if basic, ok := leaf.(*types.Basic); ok {
+		switch info := basic.Info(); {
+		case info&types.IsBoolean != 0:
+			return constant.MakeBool(false)
+		case info&types.IsNumeric != 0:
+			return constant.MakeInt64(0)
+		}
+	}
+	return constant.MakeString("")
+}
+
+// fieldValue returns the value of the named field in a struct
+// composite literal, or nil if the field is omitted. It reports
+// whether the literal is a struct literal that has such a field.
+func fieldValue(info *types.Info, lit *ast.CompositeLit, name string) (ast.Expr, bool) {
+	strct := structOf(info.TypeOf(lit))
+	if strct == nil {
+		return nil, false
+	}
+	if len(lit.Elts) > 0 {
+		if _, ok := lit.Elts[0].(*ast.KeyValueExpr); !ok {
+			// Unkeyed literal.
+			for i := range strct.NumFields() {
+				if strct.Field(i).Name() == name && i < len(lit.Elts) {
+					return lit.Elts[i], true
+				}
+			}
+			return nil, false
+		}
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+				return kv.Value, true
+			}
+		}
+	}
+	for i := range strct.NumFields() {
+		if strct.Field(i).Name() == name {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// structOf returns the struct type of t or of the type that t points
+// to, or nil if there is none.
+func structOf(t types.Type) *types.Struct {
+	if t == nil {
+		return nil
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	strct, _ := t.Underlying().(*types.Struct)
+	return strct
+}
+
+// compositeLit returns the composite literal that e is, or whose
+// address e takes, or nil.
+func compositeLit(e ast.Expr) *ast.CompositeLit {
+	e = ast.Unparen(e)
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		e = ast.Unparen(u.X)
+	}
+	lit, _ := e.(*ast.CompositeLit)
+	return lit
+}
+
+// tableOf returns the composite literal of the table of test cases
+// over which a range statement with operand x loops: either x itself,
+// or the initializer of the variable x in the same file.
+func tableOf(info *types.Info, file *ast.File, x ast.Expr) *ast.CompositeLit {
+	x = ast.Unparen(x)
+	if lit, ok := x.(*ast.CompositeLit); ok {
+		return lit
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	v, ok := info.Uses[id].(*types.Var)
+	if !ok {
+		return nil
+	}
+	var table *ast.CompositeLit
+	ast.Inspect(file, func(n ast.Node) bool {
+		var (
+			lhs []*ast.Ident
+			rhs []ast.Expr
+		)
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				return true
+			}
+			for _, e := range n.Lhs {
+				id, _ := e.(*ast.Ident)
+				lhs = append(lhs, id)
+			}
+			rhs = n.Rhs
+		case *ast.ValueSpec:
+			lhs, rhs = n.Names, n.Values
+		default:
+			return table == nil
+		}
+		if len(lhs) == len(rhs) {
+			for i, id := range lhs {
+				if id != nil && info.Defs[id] == v {
+					table, _ = ast.Unparen(rhs[i]).(*ast.CompositeLit)
+					return false
+				}
+			}
+		}
+		return table == nil
+	})
+	return table
+}
+
+// refersTo reports whether any of the expressions refers to obj.
+func refersTo(info *types.Info, exprs []ast.Expr, obj types.Object) bool {
+	found := false
+	for _, e := range exprs {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
@@ -213,6 +213,20 @@ var testTmpl = template.Must(template.New("test").Funcs(template.FuncMap{
 // AddTestForFunc adds a test for the function enclosing the given input range.
 // It creates a _test.go file if one does not already exist.
 func AddTestForFunc(ctx context.Context, snapshot *cache.Snapshot, loc protocol.Location) (changes []protocol.DocumentChange, _ error) {
+	return addToTestFile(ctx, snapshot, loc, addTestSource)
+}
+
+// A testGenerator returns the source of a new test of the function fn,
+// declared by decl in package pkg, to be added to a test file of
+// package pkg, or of its external test package if xtest is set.
+// References to packages must be qualified using qual, which records
+// the imports needed by the test file.
+type testGenerator func(pkg *cache.Package, decl *ast.FuncDecl, fn *types.Func, xtest bool, qual types.Qualifier) ([]byte, error)
+
+// addToTestFile adds the test produced by gen for the function
+// enclosing the given input range to the corresponding _test.go file.
+// It creates the file if one does not already exist.
+func addToTestFile(ctx context.Context, snapshot *cache.Snapshot, loc protocol.Location, gen testGenerator) (changes []protocol.DocumentChange, _ error) {
 	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, loc.URI)
 	if err != nil {
 		return nil, err
@@ -466,6 +480,82 @@ func AddTestForFunc(ctx context.Context, snapshot *cache.Snapshot, loc protocol.
 		// the option to drop the return value if the type is unexported.
 	}
 
+	test, err := gen(pkg, decl, fn, xtest, qual)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute edits to update imports.
+	//
+	// If we're adding to an existing test file, we need to adjust existing
+	// imports. Otherwise, we can simply write out the imports to the new file.
+	if testPGF != nil {
+		var importFixes []*imports.ImportFix
+		for path, name := range extraImports {
+			importFixes = append(importFixes, &imports.ImportFix{
+				StmtInfo: imports.ImportInfo{
+					ImportPath: path,
+					Name:       name,
+				},
+				FixType: imports.AddImport,
+			})
+		}
+		importEdits, err := ComputeImportFixEdits(snapshot.Options().Local, testPGF.Src, importFixes...)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute the import fix edits: %w", err)
+		}
+		edits = append(edits, importEdits...)
+	} else {
+		var importsBuffer bytes.Buffer
+		if len(extraImports) == 1 {
+			importsBuffer.WriteString("\nimport ")
+			for path, name := range extraImports {
+				if name != "" {
+					importsBuffer.WriteString(name + " ")
+				}
+				importsBuffer.WriteString(fmt.Sprintf("\"%s\"\n", path))
+			}
+		} else {
+			importsBuffer.WriteString("\nimport(")
+			// Loop over the map in sorted order ensures deterministic outcome.
+			paths := make([]string, 0, len(extraImports))
+			for key := range extraImports {
+				paths = append(paths, key)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				importsBuffer.WriteString("\n\t")
+				if name := extraImports[path]; name != "" {
+					importsBuffer.WriteString(name + " ")
+				}
+				importsBuffer.WriteString(fmt.Sprintf("\"%s\"", path))
+			}
+			importsBuffer.WriteString("\n)\n")
+		}
+		edits = append(edits, protocol.TextEdit{
+			Range:   protocol.Range{},
+			NewText: importsBuffer.String(),
+		})
+	}
+
+	formatted, err := format.Source(test)
+	if err != nil {
+		return nil, err
+	}
+
+	edits = append(edits,
+		protocol.TextEdit{
+			Range:   eofRange,
+			NewText: string(formatted),
+		})
+
+	return append(changes, protocol.DocumentChangeEdit(testFH, edits)), nil
+}
+
+// addTestSource is a testGenerator that returns a table-driven test of fn.
+func addTestSource(pkg *cache.Package, decl *ast.FuncDecl, fn *types.Func, xtest bool, qual types.Qualifier) ([]byte, error) {
+	sig := fn.Signature()
+
 	testName, err := testName(fn)
 	if err != nil {
 		return nil, err
@@ -695,76 +785,11 @@ func AddTestForFunc(ctx context.Context, snapshot *cache.Snapshot, loc protocol.
 		}
 	}
 
-	// Compute edits to update imports.
-	//
-	// If we're adding to an existing test file, we need to adjust existing
-	// imports. Otherwise, we can simply write out the imports to the new file.
-	if testPGF != nil {
-		var importFixes []*imports.ImportFix
-		for path, name := range extraImports {
-			importFixes = append(importFixes, &imports.ImportFix{
-				StmtInfo: imports.ImportInfo{
-					ImportPath: path,
-					Name:       name,
-				},
-				FixType: imports.AddImport,
-			})
-		}
-		importEdits, err := ComputeImportFixEdits(snapshot.Options().Local, testPGF.Src, importFixes...)
-		if err != nil {
-			return nil, fmt.Errorf("could not compute the import fix edits: %w", err)
-		}
-		edits = append(edits, importEdits...)
-	} else {
-		var importsBuffer bytes.Buffer
-		if len(extraImports) == 1 {
-			importsBuffer.WriteString("\nimport ")
-			for path, name := range extraImports {
-				if name != "" {
-					importsBuffer.WriteString(name + " ")
-				}
-				importsBuffer.WriteString(fmt.Sprintf("\"%s\"\n", path))
-			}
-		} else {
-			importsBuffer.WriteString("\nimport(")
-			// Loop over the map in sorted order ensures deterministic outcome.
-			paths := make([]string, 0, len(extraImports))
-			for key := range extraImports {
-				paths = append(paths, key)
-			}
-			sort.Strings(paths)
-			for _, path := range paths {
-				importsBuffer.WriteString("\n\t")
-				if name := extraImports[path]; name != "" {
-					importsBuffer.WriteString(name + " ")
-				}
-				importsBuffer.WriteString(fmt.Sprintf("\"%s\"", path))
-			}
-			importsBuffer.WriteString("\n)\n")
-		}
-		edits = append(edits, protocol.TextEdit{
-			Range:   protocol.Range{},
-			NewText: importsBuffer.String(),
-		})
-	}
-
 	var test bytes.Buffer
 	if err := testTmpl.Execute(&test, data); err != nil {
 		return nil, err
 	}
-
-	formatted, err := format.Source(test.Bytes())
-	if err != nil {
-		return nil, err
-	}
-
-	edits = append(edits,
-		protocol.TextEdit{
-			Range:   eofRange,
-			NewText: string(formatted),
-		})
-
-	return append(changes, protocol.DocumentChangeEdit(testFH, edits)), nil
+	return test.Bytes(), nil
 }
 
 // testName returns the name of the function to use for the new function that
@@ -4,18 +4,22 @@
 
 package golang
 
-// This file produces the "Browse GOARCH assembly of f" HTML report.
+// This file produces the "Browse GOARCH assembly of f" HTML report,
+// and its plain-text counterpart for clients that support virtual
+// documents.
 //
 // See also:
 // - ./codeaction.go - computes the symbol and offers the CodeAction command.
-// - ../server/command.go - handles the command by opening a web page.
+// - ../server/command.go - handles the command by opening a web page or virtual document.
 // - ../server/server.go - handles the HTTP request and calls this function.
+// - ../server/text_document_content.go - serves the virtual document.
 
 import (
 	"bytes"
 	"context"
 	"fmt"
 	"html"
+	"iter"
 	"regexp"
 	"strconv"
 	"strings"
@@ -29,17 +33,10 @@ import (
 // - display a "Compiling..." message as a cold build can be slow.
 // - cross-link jumps and block labels, like github.com/aclements/objbrowse.
 func AssemblyHTML(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, symbol string, web Web) ([]byte, error) {
-	// Compile the package with -S, and capture its stderr stream.
-	inv, cleanupInvocation, err := snapshot.GoCommandInvocation(cache.NoNetwork, pkg.Metadata().CompiledGoFiles[0].DirPath(), "build", []string{"-gcflags=-S", "."})
-	if err != nil {
-		return nil, err // e.g. failed to write overlays (rare)
-	}
-	defer cleanupInvocation()
-	_, stderr, err, _ := snapshot.View().GoCommandRunner().RunRaw(ctx, *inv)
+	content, err := compileAssembly(ctx, snapshot, pkg)
 	if err != nil {
-		return nil, err // e.g. won't compile
+		return nil, err
 	}
-	content := stderr.String()
 
 	escape := html.EscapeString
 
@@ -74,40 +71,16 @@ func AssemblyHTML(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pack
 <pre>
 `)
 
-	// insnRx matches an assembly instruction line.
-	// Submatch groups are: (offset-hex-dec, file-line-column, instruction).
-	insnRx := regexp.MustCompile(`^(\s+0x[0-9a-f ]+)\(([^)]*)\)\s+(.*)$`)
-
-	// Parse the functions of interest out of the listing.
-	// Each function is of the form:
-	//
-	//     symbol STEXT k=v...
-	//         0x0000 00000 (/file.go:123) NOP...
-	//         ...
-	//
-	// Allow matches of symbol, symbol.func1, symbol.deferwrap, etc.
-	on := false
-	for _, line := range strings.Split(content, "\n") {
-		// start of function symbol?
-		if strings.Contains(line, " STEXT ") {
-			on = strings.HasPrefix(line, symbol) &&
-				(line[len(symbol)] == ' ' || line[len(symbol)] == '.')
-		}
-		if !on {
-			continue // within uninteresting symbol
-		}
-
+	for line := range symbolAssembly(content, symbol) {
 		// In lines of the form
 		//   "\t0x0000 00000 (/file.go:123) NOP..."
 		// replace the "(/file.go:123)" portion with an "L0123" source link.
 		// Skip filenames of the form "<foo>".
 		if parts := insnRx.FindStringSubmatch(line); parts != nil {
 			link := "     " // if unknown
-			if file, linenum, ok := cutLast(parts[2], ":"); ok && !strings.HasPrefix(file, "<") {
-				if linenum, err := strconv.Atoi(linenum); err == nil {
-					text := fmt.Sprintf("L%04d", linenum)
-					link = sourceLink(text, web.SrcURL(file, linenum, 1))
-				}
+			if file, linenum, ok := sourceLine(parts[2]); ok {
+				text := fmt.Sprintf("L%04d", linenum)
+				link = sourceLink(text, web.SrcURL(file, linenum, 1))
 			}
 			fmt.Fprintf(&buf, "%s\t%s\t%s", escape(parts[1]), link, escape(parts[3]))
 		} else {
@@ -118,6 +91,100 @@ func AssemblyHTML(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pack
 	return buf.Bytes(), nil
 }
 
+// AssemblyText returns a plain-text assembly listing of the selected
+// function, in which each instruction is annotated with its source
+// line.
+func AssemblyText(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, symbol string) ([]byte, error) {
+	content, err := compileAssembly(ctx, snapshot, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s assembly for %s\n", snapshot.View().GOARCH(), symbol)
+	fmt.Fprintf(&buf, "// See https://go.dev/doc/asm for a guide to Go's assembler.\n\n")
+	for line := range symbolAssembly(content, symbol) {
+		// As in the HTML report, replace the "(/file.go:123)"
+		// portion of instruction lines with "L0123".
+		if parts := insnRx.FindStringSubmatch(line); parts != nil {
+			fmt.Fprintf(&buf, "%s\t", parts[1])
+			if _, linenum, ok := sourceLine(parts[2]); ok {
+				fmt.Fprintf(&buf, "L%04d", linenum)
+			} else {
+				buf.WriteString("     ") // if unknown
+			}
+			fmt.Fprintf(&buf, "\t%s", parts[3])
+		} else {
+			buf.WriteString(line)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// compileAssembly compiles the package with -S,
+// and returns its assembly listing.
+func compileAssembly(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package) (string, error) {
+	// Compile the package with -S, and capture its stderr stream.
+	inv, cleanupInvocation, err := snapshot.GoCommandInvocation(cache.NoNetwork, pkg.Metadata().CompiledGoFiles[0].DirPath(), "build", []string{"-gcflags=-S", "."})
+	if err != nil {
+		return "", err // e.g. failed to write overlays (rare)
+	}
+	defer cleanupInvocation()
+	_, stderr, err, _ := snapshot.View().GoCommandRunner().RunRaw(ctx, *inv)
+	if err != nil {
+		return "", err // e.g. won't compile
+	}
+	return stderr.String(), nil
+}
+
+// insnRx matches an assembly instruction line.
+// Submatch groups are: (offset-hex-dec, file-line-column, instruction).
+var insnRx = regexp.MustCompile(`^(\s+0x[0-9a-f ]+)\(([^)]*)\)\s+(.*)$`)
+
+// symbolAssembly returns an iterator over the lines of the assembly
+// listing of the given function symbol.
+func symbolAssembly(content, symbol string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		// Parse the functions of interest out of the listing.
+		// Each function is of the form:
+		//
+		//     symbol STEXT k=v...
+		//         0x0000 00000 (/file.go:123) NOP...
+		//         ...
+		//
+		// Allow matches of symbol, symbol.func1, symbol.deferwrap, etc.
+		on := false
+		for _, line := range strings.Split(content, "\n") {
+			// start of function symbol?
+			if strings.Contains(line, " STEXT ") {
+				on = strings.HasPrefix(line, symbol) &&
+					(line[len(symbol)] == ' ' || line[len(symbol)] == '.')
+			}
+			if !on {
+				continue // within uninteresting symbol
+			}
+			if !yield(line) {
+				return
+			}
+		}
+	}
+}
+
+// sourceLine parses the "/file.go:123" portion of an instruction line.
+// It reports false for filenames of the form "<foo>".
+func sourceLine(s string) (file string, line int, ok bool) {
+	file, linenum, ok := cutLast(s, ":")
+	if !ok || strings.HasPrefix(file, "<") {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(linenum)
+	if err != nil {
+		return "", 0, false
+	}
+	return file, line, true
+}
+
 // cutLast is the "last" analogue of [strings.Cut].
 func cutLast(s, sep string) (before, after string, ok bool) {
 	if i := strings.LastIndex(s, sep); i >= 0 {
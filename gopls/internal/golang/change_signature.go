@@ -13,13 +13,17 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"path"
 	"regexp"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
 	"golang.org/x/tools/gopls/internal/cache/parsego"
 	"golang.org/x/tools/gopls/internal/file"
 	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/protocol/command"
 	goplsastutil "golang.org/x/tools/gopls/internal/util/astutil"
 	"golang.org/x/tools/gopls/internal/util/bug"
 	"golang.org/x/tools/gopls/internal/util/safetoken"
@@ -84,8 +88,8 @@ import (
 // Furthermore, by running the change signature rewriting through the inliner,
 // we ensure that the inliner gets better to the point that it can handle a
 // change signature rewrite just as well as if we had implemented change
-// signature as its own operation. For example, suppose we support reordering
-// the results of a function. In that case, the wrapper would be:
+// signature as its own operation. For example, when reordering the results
+// of a function, the wrapper is:
 //
 // 	func Foo1() (int, int) {
 // 		y, x := Foo0()
@@ -120,193 +124,698 @@ func removeParam(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle,
 		return nil, fmt.Errorf("no param found")
 	}
 	// Write a transformation to remove the param.
-	var newParams []int
+	var newParams []command.ChangeSignatureParam
 	for i := 0; i < info.decl.Type.Params.NumFields(); i++ {
 		if i != info.paramIndex {
-			newParams = append(newParams, i)
+			newParams = append(newParams, command.OldField(i))
 		}
 	}
-	return ChangeSignature(ctx, snapshot, pkg, pgf, rng, newParams)
+	return ChangeSignature(ctx, snapshot, pkg, pgf, rng, newParams, nil)
 }
 
 // ChangeSignature computes a refactoring to update the signature according to
-// the provided parameter transformation, for the signature definition
-// surrounding rng.
+// the provided parameter and result transformations, for the signature
+// definition surrounding rng.
 //
 // newParams expresses the new parameters for the signature in terms of the old
-// parameters. Each entry in newParams is the index of the new parameter in the
-// original parameter list. For example, given func Foo(a, b, c int) and newParams
-// [2, 0, 1], the resulting changed signature is Foo(c, a, b int). If newParams
-// omits an index of the original signature, that parameter is removed.
+// parameters, as described at [command.ChangeSignatureArgs]. For example,
+// given func Foo(a, b, c int) and newParams [2, 0, 1], the resulting changed
+// signature is Foo(c, a, b int). If newParams omits an index of the original
+// signature, that parameter is removed. An element of newParams may also add
+// a parameter, whose default value is passed at each call site, or rename or
+// retype an existing one. newResults does the same for the results; if it is
+// nil, the results are unchanged. New results take their default value in
+// every return statement of the function body.
 //
 // This operation is a work in progress. Remaining TODO:
-//   - Handle adding parameters.
-//   - Handle adding/removing/reordering results.
 //   - Improve the extra newlines in output.
 //   - Stream type checking via ForEachPackage.
 //   - Avoid unnecessary additional type checking.
-func ChangeSignature(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, rng protocol.Range, newParams []int) ([]protocol.DocumentChange, error) {
+func ChangeSignature(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, rng protocol.Range, newParams, newResults []command.ChangeSignatureParam) ([]protocol.DocumentChange, error) {
 	// Changes to our heuristics for whether we can remove a parameter must also
 	// be reflected in the canRemoveParameter helper.
-	if perrors, terrors := pkg.ParseErrors(), pkg.TypeErrors(); len(perrors) > 0 || len(terrors) > 0 {
-		var sample string
-		if len(perrors) > 0 {
-			sample = perrors[0].Error()
-		} else {
-			sample = terrors[0].Error()
-		}
-		return nil, fmt.Errorf("can't change signatures for packages with parse or type errors: (e.g. %s)", sample)
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
 	}
 
 	info := findParam(pgf, rng)
 	if info == nil || info.decl == nil {
 		return nil, fmt.Errorf("failed to find declaration")
 	}
+	decl := info.decl
 
 	// Step 1: create the new declaration, which is a copy of the original decl
-	// with the rewritten signature.
+	// with the rewritten signature (and, if parameters are renamed or results
+	// change, the rewritten body).
+
+	oldParams, err := flattenFields(pkg, decl.Type.Params)
+	if err != nil {
+		return nil, err
+	}
+	oldResults, err := flattenFields(pkg, decl.Type.Results)
+	if err != nil {
+		return nil, err
+	}
+	params, err := transformFields(pkg, decl, oldParams, newParams, "parameter")
+	if err != nil {
+		return nil, err
+	}
+	results := oldResults
+	if newResults != nil {
+		results, err = transformFields(pkg, decl, oldResults, newResults, "result")
+		if err != nil {
+			return nil, err
+		}
+	}
+	resultsChanged := len(results) != len(oldResults)
+	for i, f := range results {
+		if f.old != i || f.name != oldResults[i].name || f.retyped {
+			resultsChanged = true
+		}
+	}
+
+	// Resolve the packages of any qualified identifiers in new types and
+	// values, adding imports as needed.
+	var exprs []ast.Expr
+	for _, f := range append(append([]flatField(nil), params...), results...) {
+		if f.old < 0 || f.retyped {
+			exprs = append(exprs, f.typeExpr)
+		}
+		if f.value != nil {
+			exprs = append(exprs, f.value)
+		}
+	}
+	newImports, err := resolveImports(ctx, snapshot, pkg, pgf, exprs)
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range [][]flatField{params, results} {
+		for i, f := range fields {
+			if f.typ == nil {
+				fields[i].typ = importedType(newImports, f.typeExpr)
+			}
+		}
+	}
+
+	// Compute the edits to the body.
+	body, err := rewriteBody(pkg, pgf, decl, oldParams, oldResults, params, results, resultsChanged)
+	if err != nil {
+		return nil, err
+	}
+
+	newDecl := internalastutil.CloneNode(decl)
+	newDecl.Type.Params = writeFields(params)
+	newDecl.Type.Results = nil
+	if len(results) > 0 {
+		newDecl.Type.Results = writeFields(results)
+	}
 
-	// Flatten, transform and regroup fields, using the flatField intermediate
-	// representation. A flatField is the result of flattening an *ast.FieldList
-	// along with type information.
-	type flatField struct {
-		name     string // empty if the field is unnamed
-		typeExpr ast.Expr
-		typ      types.Type
+	// Step 2: build a wrapper function calling the new declaration.
+
+	var kept []int // indices of old params, in new order
+	for _, f := range params {
+		if f.old >= 0 {
+			kept = append(kept, f.old)
+		}
+	}
+	wrapperParams, keptArgs, _ := delegatingParams(decl.Type.Params, kept)
+	qual := typesinternal.FileQualifier(pgf.File, pkg.Types())
+	args := make([]ast.Expr, len(params))
+	for i, f := range params {
+		if f.old < 0 {
+			arg, err := valueExpr(f, qual)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+			continue
+		}
+		args[i], keptArgs = keptArgs[0], keptArgs[1:]
+		if f.retyped {
+			if is[*ast.Ellipsis](f.typeExpr) || is[*ast.Ellipsis](oldParams[f.old].typeExpr) {
+				return nil, fmt.Errorf("cannot change the type of variadic parameter %s", oldParams[f.old].name)
+			}
+			if f.typ == nil || !types.AssignableTo(oldParams[f.old].typ, f.typ) {
+				args[i] = convertTo(f.typeExpr, args[i])
+			}
+		}
+	}
+	variadic := len(params) > 0 && params[len(params)-1].old >= 0 && is[*ast.Ellipsis](params[len(params)-1].typeExpr)
+
+	var wrapperResults []delegateResult
+	if resultsChanged {
+		newIndex := make(map[int]int)
+		for i, f := range results {
+			if f.old >= 0 {
+				newIndex[f.old] = i
+			}
+		}
+		for i, old := range oldResults {
+			res := delegateResult{index: -1}
+			if j, ok := newIndex[i]; ok {
+				res.index = j
+				if f := results[j]; f.retyped && (f.typ == nil || !types.AssignableTo(f.typ, old.typ)) {
+					res.conv = old.typeExpr
+				}
+			} else {
+				zero, ok := typesinternal.ZeroExpr(old.typ, qual)
+				if !ok {
+					return nil, fmt.Errorf("cannot remove result %d of type %s", i, old.typ)
+				}
+				res.zero = zero
+			}
+			wrapperResults = append(wrapperResults, res)
+		}
+	}
+
+	// Step 3: Rewrite all referring calls, by swapping in the wrapper and
+	// inlining all.
+
+	rw := signatureRewrite{
+		snapshot: snapshot,
+		pkg:      pkg,
+		pgf:      pgf,
+		origDecl: decl,
+		newDecl:  newDecl,
+		newBody:  body,
+		imports:  newImports,
+		params:   wrapperParams,
+		callArgs: args,
+		variadic: variadic,
+		results:  wrapperResults,
+	}
+	newContent, err := rewriteCalls(ctx, rw)
+	if err != nil {
+		return nil, err
 	}
 
-	var newParamFields []flatField
-	for id, field := range goplsastutil.FlatFields(info.decl.Type.Params) {
+	return signatureChanges(ctx, rw, newContent)
+}
+
+// A flatField is the result of flattening an *ast.FieldList along with type
+// information, and describes a field of a transformed signature.
+type flatField struct {
+	name     string     // empty if the field is unnamed
+	id       *ast.Ident // the field's name in the old signature, or nil
+	typeExpr ast.Expr
+	typ      types.Type // nil if the type of a new field could not be determined
+	old      int        // index of the field in the old signature, or -1 if new
+	retyped  bool       // the type of an old field is changed
+	value    ast.Expr   // for a new field, its value (or nil for the zero value)
+}
+
+// flattenFields returns the flattened fields of list, which may be nil.
+func flattenFields(pkg *cache.Package, list *ast.FieldList) ([]flatField, error) {
+	var fields []flatField
+	for id, field := range goplsastutil.FlatFields(list) {
 		typ := pkg.TypesInfo().TypeOf(field.Type)
 		if typ == nil {
-			return nil, fmt.Errorf("missing field type for field #%d", len(newParamFields))
+			return nil, fmt.Errorf("missing field type for field #%d", len(fields))
 		}
-		field := flatField{
+		f := flatField{
+			id:       id,
 			typeExpr: field.Type,
 			typ:      typ,
+			old:      len(fields),
 		}
 		if id != nil {
-			field.name = id.Name
+			f.name = id.Name
 		}
-		newParamFields = append(newParamFields, field)
-	}
-
-	// Select the new parameter fields.
-	newParamFields, ok := selectElements(newParamFields, newParams)
-	if !ok {
-		return nil, fmt.Errorf("failed to apply parameter transformation %v", newParams)
+		fields = append(fields, f)
 	}
+	return fields, nil
+}
 
-	// writeFields performs the regrouping of named fields.
-	writeFields := func(flatFields []flatField) *ast.FieldList {
-		list := new(ast.FieldList)
-		for i, f := range flatFields {
-			var field *ast.Field
-			if i > 0 && f.name != "" && flatFields[i-1].name != "" && types.Identical(f.typ, flatFields[i-1].typ) {
-				// Group named fields if they have the same type.
-				field = list.List[len(list.List)-1]
-			} else {
-				// Otherwise, create a new field.
-				field = &ast.Field{
-					Type: internalastutil.CloneNode(f.typeExpr),
+// transformFields applies the transformation to the old fields of a
+// parameter or result list (according to kind) of decl.
+func transformFields(pkg *cache.Package, decl *ast.FuncDecl, old []flatField, transform []command.ChangeSignatureParam, kind string) ([]flatField, error) {
+	var (
+		fields []flatField
+		named  bool // some field is named
+	)
+	for _, t := range transform {
+		var f flatField
+		if t.OldIndex != nil {
+			if i := *t.OldIndex; i < 0 || i >= len(old) {
+				return nil, fmt.Errorf("invalid %s index %d", kind, i)
+			}
+			if t.Default != "" {
+				return nil, fmt.Errorf("a default value applies only to a new %s", kind)
+			}
+			f = old[*t.OldIndex]
+		} else {
+			if t.NewField == "" {
+				return nil, fmt.Errorf("missing field for new %s", kind)
+			}
+			f = flatField{old: -1}
+			if t.Default != "" {
+				value, err := parser.ParseExpr(t.Default)
+				if err != nil {
+					return nil, fmt.Errorf("invalid default value %q: %v", t.Default, err)
+				}
+				f.value = value
+			}
+		}
+		if t.NewField != "" {
+			name, typeExpr, err := parseField(t.NewField)
+			if err != nil {
+				return nil, err
+			}
+			if f.old < 0 && is[*ast.Ellipsis](typeExpr) {
+				return nil, fmt.Errorf("cannot add a variadic %s", kind)
+			}
+			f.name = name
+			if f.old < 0 || types.ExprString(typeExpr) != types.ExprString(f.typeExpr) {
+				f.typeExpr = typeExpr
+				f.typ = nil
+				tinfo := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+				if err := types.CheckExpr(pkg.FileSet(), pkg.Types(), decl.Type.Params.Opening, typeExpr, tinfo); err == nil && tinfo.Types[typeExpr].IsType() {
+					f.typ = tinfo.Types[typeExpr].Type
 				}
-				list.List = append(list.List, field)
+				f.retyped = f.old >= 0
 			}
-			if f.name != "" {
-				field.Names = append(field.Names, ast.NewIdent(f.name))
+		}
+		named = named || f.name != ""
+		fields = append(fields, f)
+	}
+	if named {
+		// Parameters are either all named or all unnamed.
+		for i := range fields {
+			if fields[i].name == "" {
+				fields[i].name = "_"
 			}
 		}
-		return list
 	}
+	return fields, nil
+}
 
-	newDecl := internalastutil.CloneNode(info.decl)
-	newDecl.Type.Params = writeFields(newParamFields)
+// parseField parses a single field, such as "x int" or "error".
+func parseField(s string) (name string, typ ast.Expr, _ error) {
+	expr, err := parser.ParseExpr("func(" + s + ")")
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid field %q: %v", s, err)
+	}
+	ftyp, ok := expr.(*ast.FuncType)
+	if !ok || len(ftyp.Params.List) != 1 || len(ftyp.Params.List[0].Names) > 1 {
+		return "", nil, fmt.Errorf("invalid field %q: must declare a single field", s)
+	}
+	field := ftyp.Params.List[0]
+	if len(field.Names) > 0 {
+		name = field.Names[0].Name
+	}
+	return name, field.Type, nil
+}
 
-	// Step 2: build a wrapper function calling the new declaration.
+// writeFields performs the regrouping of named fields.
+func writeFields(flatFields []flatField) *ast.FieldList {
+	list := new(ast.FieldList)
+	for i, f := range flatFields {
+		var field *ast.Field
+		if i > 0 && f.name != "" && flatFields[i-1].name != "" && sameType(f, flatFields[i-1]) {
+			// Group named fields if they have the same type.
+			field = list.List[len(list.List)-1]
+		} else {
+			// Otherwise, create a new field.
+			field = &ast.Field{
+				Type: internalastutil.CloneNode(f.typeExpr),
+			}
+			list.List = append(list.List, field)
+		}
+		if f.name != "" {
+			field.Names = append(field.Names, ast.NewIdent(f.name))
+		}
+	}
+	return list
+}
+
+// sameType reports whether the types of two fields are the same, and
+// may be written as a group.
+func sameType(x, y flatField) bool {
+	if is[*ast.Ellipsis](x.typeExpr) || is[*ast.Ellipsis](y.typeExpr) {
+		return false
+	}
+	if x.typ != nil && y.typ != nil {
+		return types.Identical(x.typ, y.typ)
+	}
+	return types.ExprString(x.typeExpr) == types.ExprString(y.typeExpr)
+}
 
+// valueExpr returns the value of the new field f, which is its default, or
+// else the zero value of its type.
+func valueExpr(f flatField, qual types.Qualifier) (ast.Expr, error) {
+	if f.value != nil {
+		return f.value, nil
+	}
+	if f.typ != nil {
+		if zero, ok := typesinternal.ZeroExpr(f.typ, qual); ok {
+			return zero, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot determine the zero value of %s: please specify a default", types.ExprString(f.typeExpr))
+}
+
+// convertTo returns the conversion of x to type t.
+func convertTo(t, x ast.Expr) ast.Expr {
+	switch t.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr, *ast.ArrayType, *ast.MapType:
+	default:
+		t = &ast.ParenExpr{X: t}
+	}
+	return &ast.CallExpr{Fun: internalastutil.CloneNode(t), Args: []ast.Expr{x}}
+}
+
+// resolveImports returns the packages that must be imported by the file
+// of pgf for the package names used by exprs to resolve. Such
+// packages are found among the dependencies of pkg, or else in the
+// standard library.
+func resolveImports(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, exprs []ast.Expr) ([]*types.Package, error) {
+	fileScope := pkg.TypesInfo().Scopes[pgf.File]
+	if fileScope == nil {
+		return nil, bug.Errorf("no scope for file %s", pgf.URI)
+	}
 	var (
-		params   = internalastutil.CloneNode(info.decl.Type.Params) // parameters of wrapper func: "_" names must be modified
-		args     = make([]ast.Expr, len(newParams))                 // arguments to the delegated call
-		variadic = false                                            // whether the signature is variadic
+		imports []*types.Package
+		deps    []*types.Package // lazily computed
+		seen    = make(map[string]bool)
 	)
-	{
-		// Record names used by non-blank parameters, just in case the user had a
-		// parameter named 'blank0', which would conflict with the synthetic names
-		// we construct below.
-		// TODO(rfindley): add an integration test for this behavior.
-		nonBlankNames := make(map[string]bool) // for detecting conflicts with renamed blanks
-		for _, fld := range params.List {
-			for _, n := range fld.Names {
-				if n.Name != "_" {
-					nonBlankNames[n.Name] = true
+	for _, expr := range exprs {
+		var names []string
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && !seen[x.Name] {
+					seen[x.Name] = true
+					if _, obj := fileScope.LookupParent(x.Name, token.NoPos); obj == nil {
+						names = append(names, x.Name)
+					}
+				}
+			}
+			return true
+		})
+		for _, name := range names {
+			if deps == nil {
+				deps = transitiveImports(pkg.Types())
+			}
+			var found *types.Package
+			for _, dep := range deps {
+				if dep.Name() == name {
+					found = dep
+					break
 				}
 			}
-			if len(fld.Names) == 0 {
-				// All parameters must have a non-blank name. For convenience, give
-				// this field a blank name.
-				fld.Names = append(fld.Names, ast.NewIdent("_")) // will be named below
-			}
-		}
-		// oldParams maps parameters to their argument in the delegated call.
-		// In other words, it is the inverse of newParams, but it is represented as
-		// a map rather than a slice, as not every old param need exist in
-		// newParams.
-		oldParams := make(map[int]int)
-		for new, old := range newParams {
-			oldParams[old] = new
-		}
-		blanks := 0
-		paramIndex := 0 // global param index.
-		for id, field := range goplsastutil.FlatFields(params) {
-			argIndex, ok := oldParams[paramIndex]
-			paramIndex++
-			if !ok {
-				continue // parameter is removed
-			}
-			if id.Name == "_" { // from above: every field has names
-				// Create names for blank (_) parameters so the delegating wrapper
-				// can refer to them.
-				for {
-					// These names will not be seen by the user, so give them an
-					// arbitrary name.
-					newName := fmt.Sprintf("blank%d", blanks)
-					blanks++
-					if !nonBlankNames[newName] {
-						id.Name = newName
-						break
+			if found == nil {
+				// Look for a standard package of this name.
+				var best *metadata.Package
+				for _, mp := range snapshot.MetadataGraph().Packages {
+					path := string(mp.PkgPath)
+					if string(mp.Name) == name && mp.ForTest == "" && !strings.Contains(strings.Split(path, "/")[0], ".") && !strings.Contains(path, "internal") &&
+						(best == nil || len(path) < len(string(best.PkgPath))) {
+						best = mp
 					}
 				}
+				if best == nil {
+					continue // an undefined name will be reported by the type checker
+				}
+				pkgs, err := snapshot.TypeCheck(ctx, best.ID)
+				if err != nil {
+					return nil, err
+				}
+				found = pkgs[0].Types()
 			}
-			args[argIndex] = ast.NewIdent(id.Name)
-			// Record whether the call has an ellipsis.
-			// (Only the last loop iteration matters.)
-			_, variadic = field.Type.(*ast.Ellipsis)
+			imports = append(imports, found)
 		}
 	}
+	return imports, nil
+}
 
-	// Step 3: Rewrite all referring calls, by swapping in the wrapper and
-	// inlining all.
+// transitiveImports returns the transitive imports of pkg, in breadth-first
+// order.
+func transitiveImports(pkg *types.Package) []*types.Package {
+	var (
+		all  []*types.Package
+		seen = make(map[*types.Package]bool)
+	)
+	queue := pkg.Imports()
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if !seen[p] {
+			seen[p] = true
+			all = append(all, p)
+			queue = append(queue, p.Imports()...)
+		}
+	}
+	return all
+}
 
-	newContent, err := rewriteCalls(ctx, signatureRewrite{
-		snapshot: snapshot,
-		pkg:      pkg,
-		pgf:      pgf,
-		origDecl: info.decl,
-		newDecl:  newDecl,
-		params:   params,
-		callArgs: args,
-		variadic: variadic,
+// importedType returns the type denoted by a qualified identifier expr
+// whose package is among imports, or nil.
+func importedType(imports []*types.Package, expr ast.Expr) types.Type {
+	if sel, ok := expr.(*ast.SelectorExpr); ok {
+		if x, ok := sel.X.(*ast.Ident); ok {
+			for _, pkg := range imports {
+				if pkg.Name() == x.Name {
+					if tname, ok := pkg.Scope().Lookup(sel.Sel.Name).(*types.TypeName); ok {
+						return tname.Type()
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteBody returns the source of the body of decl rewritten for its new
+// params and results, or nil if the body is unchanged: renamed parameters and
+// results are updated, and return statements are rewritten to return the new
+// results.
+func rewriteBody(pkg *cache.Package, pgf *parsego.File, decl *ast.FuncDecl, oldParams, oldResults, params, results []flatField, resultsChanged bool) ([]byte, error) {
+	if decl.Body == nil {
+		return nil, nil
+	}
+	info := pkg.TypesInfo()
+
+	// Find renamed parameters and results.
+	renamed := make(map[types.Object]string)
+	for _, f := range append(append([]flatField(nil), params...), results...) {
+		if f.old >= 0 && f.id != nil && f.id.Name != "_" && f.name != f.id.Name {
+			if obj := info.Defs[f.id]; obj != nil {
+				renamed[obj] = f.name
+			}
+		}
+	}
+	// Renaming must not change the meaning of any other identifier of the
+	// declaration, nor be shadowed.
+	var renameErr error
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && renameErr == nil {
+			obj := info.ObjectOf(id)
+			if _, ok := renamed[obj]; ok || obj == nil {
+				return true
+			}
+			for _, newName := range renamed {
+				if id.Name == newName {
+					renameErr = fmt.Errorf("cannot rename to %s: the name is already used in %s", newName, decl.Name.Name)
+				}
+			}
+		}
+		return true
+	})
+	if renameErr != nil {
+		return nil, renameErr
+	}
+
+	var edits []diff.Edit
+	edit := func(start, end token.Pos, text string) error {
+		startOffset, endOffset, err := safetoken.Offsets(pgf.Tok, start, end)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, diff.Edit{Start: startOffset, End: endOffset, New: text})
+		return nil
+	}
+	// text returns the source of node, with renamings applied.
+	text := func(n ast.Node) (string, error) {
+		start, end, err := safetoken.Offsets(pgf.Tok, n.Pos(), n.End())
+		if err != nil {
+			return "", err
+		}
+		var renames []diff.Edit
+		ast.Inspect(n, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if newName, ok := renamed[info.Uses[id]]; ok {
+					offset, _ := safetoken.Offset(pgf.Tok, id.Pos())
+					renames = append(renames, diff.Edit{Start: offset - start, End: offset - start + len(id.Name), New: newName})
+				}
+			}
+			return true
+		})
+		return diff.Apply(string(pgf.Src[start:end]), renames)
+	}
+
+	// Rewrite return statements, and rename other references.
+	var (
+		qual       = typesinternal.FileQualifier(pgf.File, pkg.Types())
+		namedOld   = len(oldResults) > 0 && oldResults[0].name != ""
+		hasDefault = false
+		err        error
+	)
+	for _, f := range results {
+		hasDefault = hasDefault || (f.old < 0 && f.value != nil)
+	}
+	newResultText := func(f flatField, old []ast.Expr) (string, error) {
+		if f.old >= 0 {
+			if old == nil {
+				return f.name, nil // bare return of a named result
+			}
+			return text(old[f.old])
+		}
+		if old == nil && f.value == nil && f.name != "" && f.name != "_" {
+			return f.name, nil
+		}
+		value, err := valueExpr(f, qual)
+		if err != nil {
+			return "", err
+		}
+		return types.ExprString(value), nil
+	}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Returns within a function literal are unaffected, but renamed
+			// identifiers must still be updated.
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && err == nil {
+					if newName, ok := renamed[info.Uses[id]]; ok {
+						err = edit(id.Pos(), id.End(), newName)
+					}
+				}
+				return true
+			})
+			return false
+
+		case *ast.ReturnStmt:
+			if !resultsChanged {
+				break
+			}
+			var old []ast.Expr // old result expressions, or nil for a bare return
+			switch {
+			case len(n.Results) == len(oldResults) && len(oldResults) > 0:
+				old = n.Results
+			case len(n.Results) == 0 && namedOld:
+				if !hasDefault && len(results) > 0 && results[0].name != "" {
+					return false // a bare return remains valid
+				}
+			case len(n.Results) == 0:
+			default:
+				err = fmt.Errorf("cannot rewrite %q: the values of the results are unknown", FormatNode(pkg.FileSet(), n))
+				return false
+			}
+			var texts []string
+			for _, f := range results {
+				var t string
+				t, err = newResultText(f, old)
+				if err != nil {
+					return false
+				}
+				texts = append(texts, t)
+			}
+			start, end := n.Return+token.Pos(len("return")), n.End()
+			newText := ""
+			if len(texts) > 0 {
+				newText = " " + strings.Join(texts, ", ")
+			}
+			err = edit(start, end, newText)
+			return false
+
+		case *ast.Ident:
+			if newName, ok := renamed[info.Uses[n]]; ok {
+				err = edit(n.Pos(), n.End(), newName)
+			}
+		}
+		return true
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// A function that gains results must return them at the end of its body.
+	if len(oldResults) == 0 && len(results) > 0 {
+		if len(decl.Body.List) == 0 || !is[*ast.ReturnStmt](decl.Body.List[len(decl.Body.List)-1]) {
+			var texts []string
+			for _, f := range results {
+				t, err := newResultText(f, nil)
+				if err != nil {
+					return nil, err
+				}
+				texts = append(texts, t)
+			}
+			ret := "return " + strings.Join(texts, ", ")
+			if safetoken.Line(pgf.Tok, decl.Body.Lbrace) == safetoken.Line(pgf.Tok, decl.Body.Rbrace) {
+				ret = "; " + ret + " "
+			} else {
+				ret = "\t" + ret + "\n"
+			}
+			rbrace, err := safetoken.Offset(pgf.Tok, decl.Body.Rbrace)
+			if err != nil {
+				return nil, err
+			}
+			// Insert the return statement at the start of the line of the brace.
+			if ret[0] == '\t' {
+				rbrace = bytes.LastIndexByte(pgf.Src[:rbrace], '\n') + 1
+			}
+			edits = append(edits, diff.Edit{Start: rbrace, End: rbrace, New: ret})
+		}
+	}
+
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	// The body of a recursive function contains calls that are also
+	// rewritten by inlining, which we can't reconcile with these edits.
+	if fn := info.Defs[decl.Name]; fn != nil {
+		recursive := false
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if f, ok := info.Uses[id].(*types.Func); ok && f.Origin() == fn {
+					recursive = true
+				}
+			}
+			return !recursive
+		})
+		if recursive {
+			return nil, fmt.Errorf("cannot change the body of recursive function %s", decl.Name.Name)
+		}
+	}
+	start, end, err := safetoken.Offsets(pgf.Tok, decl.Body.Pos(), decl.Body.End())
+	if err != nil {
+		return nil, err
+	}
+	for i := range edits {
+		edits[i].Start -= start
+		edits[i].End -= start
+	}
+	newBody, err := diff.Apply(string(pgf.Src[start:end]), edits)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(newBody), nil
+}
+
+// signatureChanges returns the document changes that result from
+// the signature rewrite rw (rewriting origDecl to newDecl), in
+// addition to the rewritten calls in newContent.
+func signatureChanges(ctx context.Context, rw signatureRewrite, newContent map[protocol.DocumentURI][]byte) ([]protocol.DocumentChange, error) {
+	snapshot, pgf := rw.snapshot, rw.pgf
 	// Finally, rewrite the original declaration. We do this after inlining all
 	// calls, as there may be calls in the same file as the declaration. But none
 	// of the inlining should have changed the location of the original
 	// declaration.
 	{
-		idx := findDecl(pgf.File, info.decl)
+		idx := findDecl(pgf.File, rw.origDecl)
 		if idx < 0 {
 			return nil, bug.Errorf("didn't find original decl")
 		}
@@ -316,7 +825,7 @@ func ChangeSignature(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.P
 			src = pgf.Src
 		}
 		fset := tokeninternal.FileSetFor(pgf.Tok)
-		src, err := rewriteSignature(fset, idx, src, newDecl)
+		src, err := rewriteSignature(fset, idx, src, rw.newDecl, rw.newBody, rw.imports)
 		if err != nil {
 			return nil, err
 		}
@@ -346,8 +855,90 @@ func ChangeSignature(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.P
 	return changes, nil
 }
 
+// checkNoErrors returns an error if pkg has parse or type errors.
+func checkNoErrors(pkg *cache.Package) error {
+	if perrors, terrors := pkg.ParseErrors(), pkg.TypeErrors(); len(perrors) > 0 || len(terrors) > 0 {
+		var sample string
+		if len(perrors) > 0 {
+			sample = perrors[0].Error()
+		} else {
+			sample = terrors[0].Error()
+		}
+		return fmt.Errorf("can't change signatures for packages with parse or type errors: (e.g. %s)", sample)
+	}
+	return nil
+}
+
+// delegatingParams returns the parameters of a wrapper function with
+// the given parameters, which delegates to a function whose parameters
+// are selected by newParams (see [ChangeSignature]), along with the
+// arguments of the delegated call and whether it is variadic.
+//
+// Blank and unnamed parameters are given names, so that the
+// wrapper can refer to them.
+func delegatingParams(fields *ast.FieldList, newParams []int) (params *ast.FieldList, args []ast.Expr, variadic bool) {
+	params = internalastutil.CloneNode(fields)
+	args = make([]ast.Expr, len(newParams))
+	// Record names used by non-blank parameters, just in case the user had a
+	// parameter named 'blank0', which would conflict with the synthetic names
+	// we construct below.
+	// TODO(rfindley): add an integration test for this behavior.
+	nonBlankNames := make(map[string]bool) // for detecting conflicts with renamed blanks
+	for _, fld := range params.List {
+		for _, n := range fld.Names {
+			if n.Name != "_" {
+				nonBlankNames[n.Name] = true
+			}
+		}
+		if len(fld.Names) == 0 {
+			// All parameters must have a non-blank name. For convenience, give
+			// this field a blank name.
+			fld.Names = append(fld.Names, ast.NewIdent("_")) // will be named below
+		}
+	}
+	// oldParams maps parameters to their argument in the delegated call.
+	// In other words, it is the inverse of newParams, but it is represented as
+	// a map rather than a slice, as not every old param need exist in
+	// newParams.
+	oldParams := make(map[int]int)
+	for new, old := range newParams {
+		oldParams[old] = new
+	}
+	blanks := 0
+	paramIndex := 0 // global param index.
+	for id, field := range goplsastutil.FlatFields(params) {
+		argIndex, ok := oldParams[paramIndex]
+		paramIndex++
+		if !ok {
+			continue // parameter is removed
+		}
+		if id.Name == "_" { // from above: every field has names
+			// Create names for blank (_) parameters so the delegating wrapper
+			// can refer to them.
+			for {
+				// These names will not be seen by the user, so give them an
+				// arbitrary name.
+				newName := fmt.Sprintf("blank%d", blanks)
+				blanks++
+				if !nonBlankNames[newName] {
+					id.Name = newName
+					break
+				}
+			}
+		}
+		args[argIndex] = ast.NewIdent(id.Name)
+		// Record whether the call has an ellipsis.
+		// (Only the last loop iteration matters.)
+		_, variadic = field.Type.(*ast.Ellipsis)
+	}
+	return params, args, variadic
+}
+
 // rewriteSignature rewrites the signature of the declIdx'th declaration in src
-// to use the signature of newDecl (described by fset).
+// to use the signature of newDecl (described by fset), including its
+// receiver, if that was added or removed. If newBody is non-nil, it
+// replaces the body of the declaration, and any imports are added to
+// the file.
 //
 // TODO(rfindley): I think this operation could be generalized, for example by
 // using a concept of a 'nodepath' to correlate nodes between two related
@@ -356,7 +947,7 @@ func ChangeSignature(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.P
 // Note that with its current application, rewriteSignature is expected to
 // succeed. Separate bug.Errorf calls are used below (rather than one call at
 // the callsite) in order to have greater precision.
-func rewriteSignature(fset *token.FileSet, declIdx int, src0 []byte, newDecl *ast.FuncDecl) ([]byte, error) {
+func rewriteSignature(fset *token.FileSet, declIdx int, src0 []byte, newDecl *ast.FuncDecl, newBody []byte, newImports []*types.Package) ([]byte, error) {
 	// Parse the new file0 content, to locate the original params.
 	file0, err := parser.ParseFile(fset, "", src0, parser.ParseComments|parser.SkipObjectResolution)
 	if err != nil {
@@ -368,32 +959,68 @@ func rewriteSignature(fset *token.FileSet, declIdx int, src0 []byte, newDecl *as
 	if decl0 == nil || decl0.Name.Name != newDecl.Name.Name {
 		return nil, bug.Errorf("inlining affected declaration order: found %v, not func %s", decl0, newDecl.Name.Name)
 	}
-	opening0, closing0, err := safetoken.Offsets(fset.File(decl0.Pos()), decl0.Type.Params.Opening, decl0.Type.Params.Closing)
+	// If the receiver is unchanged, replace only the parameters and results.
+	// Otherwise replace the receiver, name, parameters, and results.
+	start0 := decl0.Type.Params.Opening
+	if (decl0.Recv != nil) != (newDecl.Recv != nil) {
+		start0 = decl0.Name.Pos()
+		if decl0.Recv != nil {
+			start0 = decl0.Recv.Opening
+		}
+	}
+	tok0 := fset.File(decl0.Pos())
+	opening0, closing0, err := safetoken.Offsets(tok0, start0, decl0.Type.End())
 	if err != nil {
 		return nil, bug.Errorf("can't find params: %v", err)
 	}
+	bodyStart0, bodyEnd0 := closing0, closing0
+	if newBody != nil {
+		bodyStart0, bodyEnd0, err = safetoken.Offsets(tok0, decl0.Body.Pos(), decl0.Body.End())
+		if err != nil {
+			return nil, bug.Errorf("can't find body: %v", err)
+		}
+	}
 
 	// Format the modified signature and apply a textual replacement. This
 	// minimizes comment disruption.
-	formattedType := FormatNode(fset, newDecl.Type)
-	expr, err := parser.ParseExprFrom(fset, "", []byte(formattedType), 0)
-	if err != nil {
-		return nil, bug.Errorf("parsing modified signature: %v", err)
-	}
-	newType := expr.(*ast.FuncType)
-	opening1, closing1, err := safetoken.Offsets(fset.File(newType.Pos()), newType.Params.Opening, newType.Params.Closing)
-	if err != nil {
-		return nil, bug.Errorf("param offsets: %v", err)
+	var newParams string
+	if start0 == decl0.Type.Params.Opening {
+		formattedType := FormatNode(fset, newDecl.Type)
+		expr, err := parser.ParseExprFrom(fset, "", []byte(formattedType), 0)
+		if err != nil {
+			return nil, bug.Errorf("parsing modified signature: %v", err)
+		}
+		newType := expr.(*ast.FuncType)
+		opening1, closing1, err := safetoken.Offsets(fset.File(newType.Pos()), newType.Params.Opening, newType.End())
+		if err != nil {
+			return nil, bug.Errorf("param offsets: %v", err)
+		}
+		newParams = formattedType[opening1:closing1]
+	} else {
+		// Format "func (recv) name(params) results", without type parameters.
+		header := FormatNode(fset, &ast.FuncDecl{
+			Recv: newDecl.Recv,
+			Name: newDecl.Name,
+			Type: &ast.FuncType{Params: newDecl.Type.Params, Results: newDecl.Type.Results},
+		})
+		newParams = strings.TrimPrefix(header, "func ")
 	}
-	newParams := formattedType[opening1 : closing1+1]
 
 	// Splice.
 	var buf bytes.Buffer
 	buf.Write(src0[:opening0])
 	buf.WriteString(newParams)
-	buf.Write(src0[closing0+1:])
+	buf.Write(src0[closing0:bodyStart0])
+	buf.Write(newBody)
+	buf.Write(src0[bodyEnd0:])
 	newSrc := buf.Bytes()
-	if len(file0.Imports) > 0 {
+	if len(newImports) > 0 {
+		newSrc, err = addImports(fset, newSrc, newImports)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(file0.Imports) > 0 || len(newImports) > 0 {
 		formatted, err := imports.Process("output", newSrc, nil)
 		if err != nil {
 			return nil, bug.Errorf("imports.Process failed: %v", err)
@@ -482,9 +1109,21 @@ type signatureRewrite struct {
 	pkg               *cache.Package
 	pgf               *parsego.File
 	origDecl, newDecl *ast.FuncDecl
+	newBody           []byte           // optional source of the body of newDecl, if changed
+	imports           []*types.Package // packages newly imported by newDecl
 	params            *ast.FieldList
 	callArgs          []ast.Expr
 	variadic          bool
+	results           []delegateResult // if non-nil, the wrapper's results in terms of the delegate's
+	rewriteRef        refRewriter      // optional rewriting of non-call references
+}
+
+// A delegateResult describes a result of a wrapper function in terms of the
+// results of the function to which it delegates.
+type delegateResult struct {
+	index int      // index of the delegated result, or -1 if it was removed
+	conv  ast.Expr // if non-nil, the type to which the delegated result is converted
+	zero  ast.Expr // if index < 0, the zero value of the result
 }
 
 // rewriteCalls returns the document changes required to rewrite the
@@ -497,7 +1136,9 @@ type signatureRewrite struct {
 // used to perform this delegation: params must have the same type as origDecl,
 // but may have renamed parameters (such as is required for delegating blank
 // parameters). callArgs are the arguments of the delegated call (i.e. using
-// params).
+// params). If the receivers of origDecl and newDecl differ, the
+// receiver of the wrapper is the first argument of the delegated call,
+// or vice versa.
 //
 // For example, consider removing the unused 'b' parameter below, rewriting
 //
@@ -548,42 +1189,62 @@ func rewriteCalls(ctx context.Context, rw signatureRewrite) (map[protocol.Docume
 		wrapper := internalastutil.CloneNode(rw.origDecl)
 		wrapper.Type.Params = rw.params
 
+		// freshName returns a unique name for a temporary variable of the
+		// wrapper, which will be inlined away.
+		//
+		// We use the lexical scope of the original function to avoid conflicts
+		// with (e.g.) named result variables. However, since the parameter syntax
+		// may have been modified/renamed from the original function, we must
+		// reject those names too.
+		scope := rw.pkg.TypesInfo().Scopes[rw.origDecl.Type]
+		if scope == nil {
+			return nil, bug.Errorf("missing function scope for %v", rw.origDecl.Name.Name)
+		}
+		usedNames := make(map[string]bool)
+		for _, fld := range wrapper.Type.Params.List {
+			for _, name := range fld.Names {
+				usedNames[name.Name] = true
+			}
+		}
+		freshName := func() string {
+			for i := 0; ; i++ {
+				name := fmt.Sprintf("r%d", i)
+				_, obj := scope.LookupParent(name, token.NoPos)
+				if obj == nil && !usedNames[name] {
+					usedNames[name] = true
+					return name
+				}
+			}
+		}
+
 		// Get the receiver name, creating it if necessary.
 		var recv string // nonempty => call is a method call with receiver recv
 		if wrapper.Recv.NumFields() > 0 {
 			if len(wrapper.Recv.List[0].Names) > 0 {
 				recv = wrapper.Recv.List[0].Names[0].Name
 			} else {
-				// Create unique name for the temporary receiver, which will be inlined away.
-				//
-				// We use the lexical scope of the original function to avoid conflicts
-				// with (e.g.) named result variables. However, since the parameter syntax
-				// may have been modified/renamed from the original function, we must
-				// reject those names too.
-				usedParams := make(map[string]bool)
-				for _, fld := range wrapper.Type.Params.List {
-					for _, name := range fld.Names {
-						usedParams[name.Name] = true
-					}
-				}
-				scope := rw.pkg.TypesInfo().Scopes[rw.origDecl.Type]
-				if scope == nil {
-					return nil, bug.Errorf("missing function scope for %v", rw.origDecl.Name.Name)
-				}
-				for i := 0; ; i++ {
-					recv = fmt.Sprintf("r%d", i)
-					_, obj := scope.LookupParent(recv, token.NoPos)
-					if obj == nil && !usedParams[recv] {
-						break
-					}
-				}
+				recv = freshName()
 				wrapper.Recv.List[0].Names = []*ast.Ident{{Name: recv}}
 			}
 		}
 
 		name := &ast.Ident{Name: delegate.Name.Name}
-		var fun ast.Expr = name
-		if recv != "" {
+		var (
+			fun  ast.Expr = name
+			args          = rw.callArgs
+		)
+		switch {
+		case recv != "" && delegate.Recv.NumFields() == 0:
+			// Method to function: the receiver becomes the first argument.
+			args = append([]ast.Expr{&ast.Ident{Name: recv}}, args...)
+		case recv == "" && delegate.Recv.NumFields() > 0:
+			// Function to method: the first argument becomes the receiver.
+			fun = &ast.SelectorExpr{
+				X:   args[0],
+				Sel: name,
+			}
+			args = args[1:]
+		case recv != "":
 			fun = &ast.SelectorExpr{
 				X:   &ast.Ident{Name: recv},
 				Sel: name,
@@ -591,29 +1252,43 @@ func rewriteCalls(ctx context.Context, rw signatureRewrite) (map[protocol.Docume
 		}
 		call := &ast.CallExpr{
 			Fun:  fun,
-			Args: rw.callArgs,
+			Args: args,
 		}
 		if rw.variadic {
 			call.Ellipsis = 1 // must not be token.NoPos
 		}
 
-		var stmt ast.Stmt
-		if delegate.Type.Results.NumFields() > 0 {
-			stmt = &ast.ReturnStmt{
+		var stmts []ast.Stmt
+		switch {
+		case rw.results != nil:
+			stmts = delegateResults(call, rw.results, delegate.Type.Results.NumFields(), freshName)
+		case delegate.Type.Results.NumFields() > 0:
+			stmts = []ast.Stmt{&ast.ReturnStmt{
 				Results: []ast.Expr{call},
-			}
-		} else {
-			stmt = &ast.ExprStmt{
+			}}
+		default:
+			stmts = []ast.Stmt{&ast.ExprStmt{
 				X: call,
-			}
+			}}
 		}
 		wrapper.Body = &ast.BlockStmt{
-			List: []ast.Stmt{stmt},
+			List: stmts,
 		}
 
 		fset := tokeninternal.FileSetFor(rw.pgf.Tok)
+		var delegateSrc []byte
+		if rw.newBody != nil {
+			delegate.Body = nil
+			delegateSrc = []byte(FormatNode(fset, delegate) + " " + string(rw.newBody))
+		} else {
+			var buf bytes.Buffer
+			if err := format.Node(&buf, fset, delegate); err != nil {
+				return nil, bug.Errorf("formatting new node: %v", err)
+			}
+			delegateSrc = buf.Bytes()
+		}
 		var err error
-		modifiedSrc, err = replaceFileDecl(rw.pgf, rw.origDecl, delegate)
+		modifiedSrc, err = replaceFileDecl(rw.pgf, rw.origDecl, delegateSrc)
 		if err != nil {
 			return nil, err
 		}
@@ -621,6 +1296,12 @@ func rewriteCalls(ctx context.Context, rw signatureRewrite) (map[protocol.Docume
 		// by returning the modified AST from replaceDecl. Investigate if that is
 		// accurate.
 		modifiedSrc = append(modifiedSrc, []byte("\n\n"+FormatNode(fset, wrapper))...)
+		if len(rw.imports) > 0 {
+			modifiedSrc, err = addImports(rw.pkg.FileSet(), modifiedSrc, rw.imports)
+			if err != nil {
+				return nil, err
+			}
+		}
 		modifiedFile, err = parser.ParseFile(rw.pkg.FileSet(), rw.pgf.URI.Path(), modifiedSrc, parser.ParseComments|parser.SkipObjectResolution)
 		if err != nil {
 			return nil, err
@@ -631,7 +1312,7 @@ func rewriteCalls(ctx context.Context, rw signatureRewrite) (map[protocol.Docume
 	// Type check pkg again with the modified file, to compute the synthetic
 	// callee.
 	logf := logger(ctx, "change signature", rw.snapshot.Options().VerboseOutput)
-	pkg2, info, err := reTypeCheck(logf, rw.pkg, map[protocol.DocumentURI]*ast.File{rw.pgf.URI: modifiedFile}, false)
+	pkg2, info, err := reTypeCheck(logf, rw.pkg, map[protocol.DocumentURI]*ast.File{rw.pgf.URI: modifiedFile}, rw.imports, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -645,18 +1326,18 @@ func rewriteCalls(ctx context.Context, rw signatureRewrite) (map[protocol.Docume
 		Logf:          logf,
 		IgnoreEffects: true,
 	}
-	return inlineAllCalls(ctx, rw.snapshot, rw.pkg, rw.pgf, rw.origDecl, calleeInfo, post, opts)
+	return inlineAllCalls(ctx, rw.snapshot, rw.pkg, rw.pgf, rw.origDecl, calleeInfo, post, opts, rw.rewriteRef)
 }
 
 // reTypeCheck re-type checks orig with new file contents defined by fileMask.
 //
 // It expects that any newly added imports are already present in the
-// transitive imports of orig.
+// transitive imports of orig, or among extra.
 //
-// If expectErrors is true, reTypeCheck allows errors in the new package.
-// TODO(rfindley): perhaps this should be a filter to specify which errors are
-// acceptable.
-func reTypeCheck(logf func(string, ...any), orig *cache.Package, fileMask map[protocol.DocumentURI]*ast.File, expectErrors bool) (*types.Package, *types.Info, error) {
+// Errors in the new package for which allowError returns true are
+// logged and otherwise ignored; if allowError is nil, any error causes
+// reTypeCheck to fail.
+func reTypeCheck(logf func(string, ...any), orig *cache.Package, fileMask map[protocol.DocumentURI]*ast.File, extra []*types.Package, allowError func(types.Error) bool) (*types.Package, *types.Info, error) {
 	pkg := types.NewPackage(string(orig.Metadata().PkgPath), string(orig.Metadata().Name))
 	info := &types.Info{
 		Types:        make(map[ast.Expr]types.TypeAndValue),
@@ -691,6 +1372,9 @@ func reTypeCheck(logf func(string, ...any), orig *cache.Package, fileMask map[pr
 				toSearch      = []*types.Package{orig.Types()}  // packages to search
 				searched      = make(map[string]bool)           // path -> (false, if present in toSearch; true, if already searched)
 			)
+			for _, p := range extra {
+				importsByPath[p.Path()] = p
+			}
 			importer = func(path string) (*types.Package, error) {
 				if p, ok := importsByPath[path]; ok {
 					return p, nil
@@ -738,20 +1422,27 @@ func reTypeCheck(logf func(string, ...any), orig *cache.Package, fileMask map[pr
 				cfg.GoVersion = goVersion
 			}
 		}
-		if expectErrors {
-			cfg.Error = func(err error) {
+		var firstErr error
+		cfg.Error = func(err error) {
+			if terr, ok := err.(types.Error); ok && allowError != nil && allowError(terr) {
 				logf("re-type checking: expected error: %v", err)
+			} else if firstErr == nil {
+				firstErr = err
 			}
 		}
 		typesinternal.SetUsesCgo(cfg)
 		checker := types.NewChecker(cfg, orig.FileSet(), pkg, info)
-		if err := checker.Files(files); err != nil && !expectErrors {
-			return nil, nil, fmt.Errorf("type checking rewritten package: %v", err)
+		checker.Files(files) // ignore error: reported through cfg.Error
+		if firstErr != nil {
+			return nil, nil, fmt.Errorf("type checking rewritten package: %v", firstErr)
 		}
 	}
 	return pkg, info, nil
 }
 
+// allErrors is an error filter for [reTypeCheck] that allows all errors.
+func allErrors(types.Error) bool { return true }
+
 // TODO(golang/go#63472): this looks wrong with the new Go version syntax.
 var goVersionRx = regexp.MustCompile(`^go([1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
 
@@ -775,10 +1466,11 @@ func selectElements[T any](s []T, indices []int) ([]T, bool) {
 	return res, true
 }
 
-// replaceFileDecl replaces old with new in the file described by pgf.
+// replaceFileDecl replaces old with the source of new in the file
+// described by pgf.
 //
 // TODO(rfindley): generalize, and combine with rewriteSignature.
-func replaceFileDecl(pgf *parsego.File, old, new ast.Decl) ([]byte, error) {
+func replaceFileDecl(pgf *parsego.File, old ast.Decl, new []byte) ([]byte, error) {
 	i := findDecl(pgf.File, old)
 	if i == -1 {
 		return nil, bug.Errorf("didn't find old declaration")
@@ -789,10 +1481,7 @@ func replaceFileDecl(pgf *parsego.File, old, new ast.Decl) ([]byte, error) {
 	}
 	var out bytes.Buffer
 	out.Write(pgf.Src[:start])
-	fset := tokeninternal.FileSetFor(pgf.Tok)
-	if err := format.Node(&out, fset, new); err != nil {
-		return nil, bug.Errorf("formatting new node: %v", err)
-	}
+	out.Write(new)
 	out.Write(pgf.Src[end:])
 	return out.Bytes(), nil
 }
@@ -808,3 +1497,88 @@ func findDecl(file *ast.File, decl ast.Decl) int {
 	}
 	return -1
 }
+
+// delegateResults returns the statements of a wrapper function that
+// returns the given results in terms of those of call, which has n
+// results. freshName returns names for temporary variables.
+func delegateResults(call *ast.CallExpr, results []delegateResult, n int, freshName func() string) []ast.Stmt {
+	uses := make([]int, n) // number of uses of each result of call
+	for _, res := range results {
+		if res.index >= 0 {
+			uses[res.index]++
+		}
+	}
+	result := func(res delegateResult, x ast.Expr) ast.Expr {
+		if res.index < 0 {
+			return res.zero
+		}
+		if res.conv != nil {
+			return convertTo(res.conv, x)
+		}
+		return x
+	}
+
+	// If the call has a single result used once, return it directly.
+	if n == 1 && uses[0] == 1 {
+		ret := &ast.ReturnStmt{}
+		for _, res := range results {
+			ret.Results = append(ret.Results, result(res, call))
+		}
+		return []ast.Stmt{ret}
+	}
+
+	var (
+		stmts []ast.Stmt
+		vars  []ast.Expr // variables holding the results of call
+	)
+	used := false
+	for _, u := range uses {
+		if u > 0 {
+			vars = append(vars, ast.NewIdent(freshName()))
+			used = true
+		} else {
+			vars = append(vars, ast.NewIdent("_"))
+		}
+	}
+	if used {
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: vars,
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call},
+		})
+	} else {
+		stmts = append(stmts, &ast.ExprStmt{X: call})
+	}
+	if len(results) > 0 {
+		ret := &ast.ReturnStmt{}
+		for _, res := range results {
+			var x ast.Expr
+			if res.index >= 0 {
+				x = ast.NewIdent(vars[res.index].(*ast.Ident).Name)
+			}
+			ret.Results = append(ret.Results, result(res, x))
+		}
+		stmts = append(stmts, ret)
+	}
+	return stmts
+}
+
+// addImports adds imports of the given packages to the file source src.
+func addImports(fset *token.FileSet, src []byte, pkgs []*types.Package) ([]byte, error) {
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, bug.Errorf("parsing file before adding imports: %v", err)
+	}
+	for _, pkg := range pkgs {
+		name := pkg.Name()
+		if name == path.Base(pkg.Path()) {
+			name = ""
+		}
+		astutil.AddNamedImport(fset, file, name, pkg.Path())
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, bug.Errorf("formatting file after adding imports: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -11,6 +11,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"sort"
@@ -30,6 +31,7 @@ import (
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/imports"
 	"golang.org/x/tools/internal/typesinternal"
+	"golang.org/x/tools/internal/versions"
 )
 
 // CodeActions returns all enabled code actions (edits and other
@@ -227,12 +229,17 @@ var codeActionProducers = [...]codeActionProducer{
 	{kind: protocol.QuickFix, fn: quickFix, needPkg: true},
 	{kind: protocol.SourceOrganizeImports, fn: sourceOrganizeImports},
 	{kind: settings.AddTest, fn: addTest, needPkg: true},
+	{kind: settings.AddFuzzTest, fn: addFuzzTest, needPkg: true},
 	{kind: settings.GoAssembly, fn: goAssembly, needPkg: true},
+	{kind: settings.GoCgo, fn: goCgo},
 	{kind: settings.GoDoc, fn: goDoc, needPkg: true},
 	{kind: settings.GoFreeSymbols, fn: goFreeSymbols},
+	{kind: settings.GoInstantiation, fn: goInstantiation, needPkg: true},
 	{kind: settings.GoTest, fn: goTest},
 	{kind: settings.GoplsDocFeatures, fn: goplsDocFeatures},
 	{kind: settings.RefactorExtractFunction, fn: refactorExtractFunction},
+	{kind: settings.RefactorExtractInterface, fn: refactorExtractInterface, needPkg: true},
+	{kind: settings.RefactorExtractInterfaceUse, fn: refactorExtractInterface, needPkg: true},
 	{kind: settings.RefactorExtractMethod, fn: refactorExtractMethod},
 	{kind: settings.RefactorExtractToNewFile, fn: refactorExtractToNewFile},
 	{kind: settings.RefactorExtractConstant, fn: refactorExtractVariable, needPkg: true},
@@ -240,15 +247,26 @@ var codeActionProducers = [...]codeActionProducer{
 	{kind: settings.RefactorExtractConstantAll, fn: refactorExtractVariableAll, needPkg: true},
 	{kind: settings.RefactorExtractVariableAll, fn: refactorExtractVariableAll, needPkg: true},
 	{kind: settings.RefactorInlineCall, fn: refactorInlineCall, needPkg: true},
+	{kind: settings.RefactorInlineVariable, fn: refactorInlineVariable, needPkg: true},
+	{kind: settings.RefactorInlineConstant, fn: refactorInlineConstant, needPkg: true},
+	{kind: settings.RefactorMoveToPackage, fn: refactorMoveToPackage},
+	{kind: settings.RefactorRewriteAddTypeParam, fn: refactorRewriteAddTypeParam, needPkg: true},
 	{kind: settings.RefactorRewriteChangeQuote, fn: refactorRewriteChangeQuote},
+	{kind: settings.RefactorRewriteDelegateMethods, fn: refactorRewriteDelegateMethods, needPkg: true},
+	{kind: settings.RefactorRewriteEncapsulateField, fn: refactorRewriteEncapsulateField, needPkg: true},
 	{kind: settings.RefactorRewriteFillStruct, fn: refactorRewriteFillStruct, needPkg: true},
 	{kind: settings.RefactorRewriteFillSwitch, fn: refactorRewriteFillSwitch, needPkg: true},
+	{kind: settings.RefactorRewriteFuncToMethod, fn: refactorRewriteFuncToMethod, needPkg: true},
+	{kind: settings.RefactorRewriteIfToSwitch, fn: refactorRewriteIfToSwitch, needPkg: true},
 	{kind: settings.RefactorRewriteInvertIf, fn: refactorRewriteInvertIf},
 	{kind: settings.RefactorRewriteJoinLines, fn: refactorRewriteJoinLines, needPkg: true},
+	{kind: settings.RefactorRewriteMethodToFunc, fn: refactorRewriteMethodToFunc, needPkg: true},
 	{kind: settings.RefactorRewriteRemoveUnusedParam, fn: refactorRewriteRemoveUnusedParam, needPkg: true},
 	{kind: settings.RefactorRewriteMoveParamLeft, fn: refactorRewriteMoveParamLeft, needPkg: true},
 	{kind: settings.RefactorRewriteMoveParamRight, fn: refactorRewriteMoveParamRight, needPkg: true},
+	{kind: settings.RefactorRewritePropagateContext, fn: refactorRewritePropagateContext, needPkg: true},
 	{kind: settings.RefactorRewriteSplitLines, fn: refactorRewriteSplitLines, needPkg: true},
+	{kind: settings.RefactorRewriteSwitchToIf, fn: refactorRewriteSwitchToIf, needPkg: true},
 
 	// Note: don't forget to update the allow-list in Server.CodeAction
 	// when adding new query operations like GoTest and GoDoc that
@@ -464,6 +482,21 @@ func refactorExtractMethod(ctx context.Context, req *codeActionsRequest) error {
 	return nil
 }
 
+// refactorExtractInterface produces "Extract interface from T [and use it]"
+// code actions.
+// See [extractInterfaceFixer] and [extractInterfaceAndUse] for command implementation.
+func refactorExtractInterface(ctx context.Context, req *codeActionsRequest) error {
+	if ei := stubmethods.GetExtractIfaceInfo(req.pkg.FileSet(), req.pkg.TypesInfo(), req.pgf.File, req.start, req.end); ei != nil {
+		title := "Extract interface from " + ei.Concrete.Obj().Name()
+		if req.kind == settings.RefactorExtractInterfaceUse {
+			req.addApplyFixAction(title+" and use it", fixExtractInterfaceAndUse, req.loc)
+		} else {
+			req.addApplyFixAction(title, fixExtractInterface, req.loc)
+		}
+	}
+	return nil
+}
+
 // refactorExtractVariable produces "Extract variable|constant" code actions.
 // See [extractVariable] for command implementation.
 func refactorExtractVariable(ctx context.Context, req *codeActionsRequest) error {
@@ -527,6 +560,16 @@ func refactorExtractToNewFile(ctx context.Context, req *codeActionsRequest) erro
 	return nil
 }
 
+// refactorMoveToPackage produces "Move declarations to another package" code actions.
+// See [server.commandHandler.MoveToPackage] for command implementation.
+func refactorMoveToPackage(ctx context.Context, req *codeActionsRequest) error {
+	if canMoveToPackage(req.pgf, req.start, req.end) {
+		cmd := command.NewMoveToPackageCommand("Move declarations to another package", command.MoveToPackageArgs{Location: req.loc})
+		req.addCommandAction(cmd, false)
+	}
+	return nil
+}
+
 // addTest produces "Add test for FUNC" code actions.
 // See [server.commandHandler.AddTest] for command implementation.
 func addTest(ctx context.Context, req *codeActionsRequest) error {
@@ -562,12 +605,54 @@ func addTest(ctx context.Context, req *codeActionsRequest) error {
 	return nil
 }
 
+// addFuzzTest produces "Add fuzz test for FUNC" code actions.
+// See [server.commandHandler.AddFuzzTest] for command implementation.
+func addFuzzTest(ctx context.Context, req *codeActionsRequest) error {
+	// Reject test package.
+	if req.pkg.Metadata().ForTest != "" {
+		return nil
+	}
+
+	path, _ := astutil.PathEnclosingInterval(req.pgf.File, req.start, req.end)
+	if len(path) < 2 {
+		return nil
+	}
+
+	// Only package-level functions without type parameters are supported.
+	decl, ok := path[len(path)-2].(*ast.FuncDecl)
+	if !ok || decl.Recv != nil || decl.Type.TypeParams != nil {
+		return nil
+	}
+	if decl.Name.Name == "_" || decl.Name.Name == "init" {
+		return nil
+	}
+
+	// Fuzzing requires go1.18.
+	info := req.pkg.TypesInfo()
+	if versions.Before(versions.FileVersion(info, req.pgf.File), versions.Go1_18) {
+		return nil
+	}
+
+	// At least one parameter must be built from fuzzable types.
+	fn, ok := info.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	if inputs, _ := fuzzInputs(req.pkg.Types(), fn, false, (*types.Package).Name); len(inputs) == 0 {
+		return nil
+	}
+
+	cmd := command.NewAddFuzzTestCommand("Add fuzz test for "+decl.Name.String(), req.loc)
+	req.addCommandAction(cmd, true)
+	return nil
+}
+
 // identityTransform returns a change signature transformation that leaves the
 // given fieldlist unmodified.
 func identityTransform(fields *ast.FieldList) []command.ChangeSignatureParam {
 	var id []command.ChangeSignatureParam
 	for i := 0; i < fields.NumFields(); i++ {
-		id = append(id, command.ChangeSignatureParam{OldIndex: i})
+		id = append(id, command.OldField(i))
 	}
 	return id
 }
@@ -579,7 +664,7 @@ func refactorRewriteRemoveUnusedParam(ctx context.Context, req *codeActionsReque
 		var transform []command.ChangeSignatureParam
 		for i := 0; i < info.decl.Type.Params.NumFields(); i++ {
 			if i != info.paramIndex {
-				transform = append(transform, command.ChangeSignatureParam{OldIndex: i})
+				transform = append(transform, command.OldField(i))
 			}
 		}
 		cmd := command.NewChangeSignatureCommand("Remove unused parameter", command.ChangeSignatureArgs{
@@ -602,8 +687,8 @@ func refactorRewriteMoveParamLeft(ctx context.Context, req *codeActionsRequest)
 		// TODO(rfindley): implement.
 
 		transform := identityTransform(info.decl.Type.Params)
-		transform[info.paramIndex] = command.ChangeSignatureParam{OldIndex: info.paramIndex - 1}
-		transform[info.paramIndex-1] = command.ChangeSignatureParam{OldIndex: info.paramIndex}
+		transform[info.paramIndex] = command.OldField(info.paramIndex - 1)
+		transform[info.paramIndex-1] = command.OldField(info.paramIndex)
 		cmd := command.NewChangeSignatureCommand("Move parameter left", command.ChangeSignatureArgs{
 			Location:     req.loc,
 			NewParams:    transform,
@@ -628,8 +713,8 @@ func refactorRewriteMoveParamRight(ctx context.Context, req *codeActionsRequest)
 			}
 
 			transform := identityTransform(info.decl.Type.Params)
-			transform[info.paramIndex] = command.ChangeSignatureParam{OldIndex: info.paramIndex + 1}
-			transform[info.paramIndex+1] = command.ChangeSignatureParam{OldIndex: info.paramIndex}
+			transform[info.paramIndex] = command.OldField(info.paramIndex + 1)
+			transform[info.paramIndex+1] = command.OldField(info.paramIndex)
 			cmd := command.NewChangeSignatureCommand("Move parameter right", command.ChangeSignatureArgs{
 				Location:     req.loc,
 				NewParams:    transform,
@@ -642,6 +727,57 @@ func refactorRewriteMoveParamRight(ctx context.Context, req *codeActionsRequest)
 	return nil
 }
 
+// refactorRewritePropagateContext produces "Add context parameter" code actions.
+// See [server.commandHandler.PropagateContext] for command implementation.
+func refactorRewritePropagateContext(ctx context.Context, req *codeActionsRequest) error {
+	if decl := contextFuncDecl(req.pkg, req.pgf, req.start, req.end); decl != nil {
+		cmd := command.NewPropagateContextCommand("Add context parameter to "+decl.Name.Name+" and its callers", command.PropagateContextArgs{
+			Location:     req.loc,
+			ResolveEdits: req.resolveEdits(),
+		})
+		req.addCommandAction(cmd, true)
+	}
+	return nil
+}
+
+// refactorRewriteAddTypeParam produces "Generalize F over T" code actions.
+// See [addTypeParam] for command implementation.
+func refactorRewriteAddTypeParam(ctx context.Context, req *codeActionsRequest) error {
+	if decl, texpr := addTypeParamTarget(req.pkg, req.pgf, req.start, req.end); decl != nil {
+		qual := typesQualifier(req.pkg.Types(), req.pgf.File, req.pkg.TypesInfo(), nil)
+		title := fmt.Sprintf("Generalize %s over %s", decl.Name.Name, types.TypeString(req.pkg.TypesInfo().TypeOf(texpr), qual))
+		req.addApplyFixAction(title, fixAddTypeParam, req.loc)
+	}
+	return nil
+}
+
+// refactorRewriteEncapsulateField produces "Encapsulate field" code actions.
+// See [encapsulateField] for command implementation.
+func refactorRewriteEncapsulateField(ctx context.Context, req *codeActionsRequest) error {
+	if id, _, _, _ := encapsulatedField(req.pkg, req.pgf, req.start, req.end); id != nil {
+		req.addApplyFixAction("Encapsulate field "+id.Name, fixEncapsulateField, req.loc)
+	}
+	return nil
+}
+
+// refactorRewriteFuncToMethod produces "Convert function to method" code actions.
+// See [convertFuncToMethod] for command implementation.
+func refactorRewriteFuncToMethod(ctx context.Context, req *codeActionsRequest) error {
+	if _, named, ok := funcToMethodReceiver(req.pkg, req.pgf, req.start, req.end); ok {
+		req.addApplyFixAction("Convert function to method of "+named.Obj().Name(), fixFuncToMethod, req.loc)
+	}
+	return nil
+}
+
+// refactorRewriteMethodToFunc produces "Convert method to function" code actions.
+// See [convertMethodToFunc] for command implementation.
+func refactorRewriteMethodToFunc(ctx context.Context, req *codeActionsRequest) error {
+	if _, _, ok := methodToFuncDecl(req.pkg, req.pgf, req.start, req.end); ok {
+		req.addApplyFixAction("Convert method to function", fixMethodToFunc, req.loc)
+	}
+	return nil
+}
+
 // refactorRewriteChangeQuote produces "Convert to {raw,interpreted} string literal" code actions.
 func refactorRewriteChangeQuote(ctx context.Context, req *codeActionsRequest) error {
 	convertStringLiteral(req)
@@ -657,6 +793,75 @@ func refactorRewriteInvertIf(ctx context.Context, req *codeActionsRequest) error
 	return nil
 }
 
+// refactorRewriteDelegateMethods produces "Add methods forwarding to
+// field F" code actions when the selection is a struct field, and
+// "Declare missing methods of INTERFACE forwarding to field F" code
+// actions when it is a "missing method" error that a field can fix.
+// See [delegateMethodsFixer] and [delegateMissingInterfaceMethodsFixer]
+// for command implementation.
+func refactorRewriteDelegateMethods(ctx context.Context, req *codeActionsRequest) error {
+	info := req.pkg.TypesInfo()
+	path, _ := astutil.PathEnclosingInterval(req.pgf.File, req.start, req.end)
+	if di, err := stubmethods.GetDelegateInfo(req.pkg.FileSet(), info, path, req.start); err == nil {
+		req.addApplyFixAction("Add methods forwarding to field "+di.Field.Name(), fixDelegateMethods, req.loc)
+		return nil
+	}
+
+	for _, typeError := range req.pkg.TypeErrors() {
+		start, end := typeError.Pos, typeError.Pos
+		if _, _, endPos, ok := typesinternal.ReadGo116ErrorData(typeError); ok {
+			end = endPos
+		}
+		typeErrorRange, err := req.pgf.PosRange(start, end)
+		if err != nil || !protocol.Intersect(typeErrorRange, req.loc.Range) {
+			continue
+		}
+		if msg := typeError.Msg; strings.Contains(msg, "missing method") ||
+			strings.HasPrefix(msg, "cannot convert") ||
+			strings.Contains(msg, "not implement") {
+			path, _ := astutil.PathEnclosingInterval(req.pgf.File, start, end)
+			si := stubmethods.GetIfaceStubInfo(req.pkg.FileSet(), info, path, start)
+			if si == nil {
+				continue
+			}
+			if di := si.DelegateInfo(); di != nil {
+				qual := typesinternal.FileQualifier(req.pgf.File, si.Concrete.Obj().Pkg())
+				iface := types.TypeString(si.Interface.Type(), qual)
+				msg := fmt.Sprintf("Declare missing methods of %s forwarding to field %s", iface, di.Field.Name())
+				req.addApplyFixAction(msg, fixDelegateMissingMethods, req.loc)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// refactorRewriteIfToSwitch produces "Convert if/else chain to switch" code actions.
+// See [ifChainToSwitch] for command implementation.
+func refactorRewriteIfToSwitch(ctx context.Context, req *codeActionsRequest) error {
+	if chain, err := canConvertIfChainToSwitch(req.pgf.File, req.pkg.TypesInfo(), req.start, req.end); err == nil {
+		title := "Convert if/else chain to switch"
+		if chain.isType {
+			title = "Convert if/else chain to type switch"
+		}
+		req.addApplyFixAction(title, fixIfChainToSwitch, req.loc)
+	}
+	return nil
+}
+
+// refactorRewriteSwitchToIf produces "Convert switch to if/else chain" code actions.
+// See [switchToIfChain] for command implementation.
+func refactorRewriteSwitchToIf(ctx context.Context, req *codeActionsRequest) error {
+	if sw, err := canConvertSwitchToIfChain(req.pgf.File, req.pkg.TypesInfo(), req.start, req.end); err == nil {
+		title := "Convert switch to if/else chain"
+		if _, ok := sw.stmt.(*ast.TypeSwitchStmt); ok {
+			title = "Convert type switch to if/else chain"
+		}
+		req.addApplyFixAction(title, fixSwitchToIfChain, req.loc)
+	}
+	return nil
+}
+
 // refactorRewriteSplitLines produces "Split ITEMS into separate lines" code actions.
 // See [splitLines] for command implementation.
 func refactorRewriteSplitLines(ctx context.Context, req *codeActionsRequest) error {
@@ -776,6 +981,31 @@ func refactorInlineCall(ctx context.Context, req *codeActionsRequest) error {
 	return nil
 }
 
+// refactorInlineVariable produces "Inline variable x" code actions.
+// See [inline.InlineVariable] for command implementation.
+func refactorInlineVariable(ctx context.Context, req *codeActionsRequest) error {
+	// As with refactorInlineCall, offer only after an explicit request.
+	if req.trigger == protocol.CodeActionAutomatic && req.loc.Empty() {
+		return nil
+	}
+	if _, obj, err := inlinableName(req.pkg, req.pgf, req.start, req.end); err == nil && is[*types.Var](obj) {
+		req.addApplyFixAction("Inline variable "+obj.Name(), fixInlineVariable, req.loc)
+	}
+	return nil
+}
+
+// refactorInlineConstant produces "Inline constant C" code actions.
+// See [inline.InlineConstant] for command implementation.
+func refactorInlineConstant(ctx context.Context, req *codeActionsRequest) error {
+	if req.trigger == protocol.CodeActionAutomatic && req.loc.Empty() {
+		return nil
+	}
+	if _, obj, err := inlinableName(req.pkg, req.pgf, req.start, req.end); err == nil && is[*types.Const](obj) {
+		req.addApplyFixAction("Inline constant "+obj.Name(), fixInlineConstant, req.loc)
+	}
+	return nil
+}
+
 // goTest produces "Run tests and benchmarks" code actions.
 // See [server.commandHandler.runTests] for command implementation.
 func goTest(ctx context.Context, req *codeActionsRequest) error {
@@ -871,3 +1101,43 @@ func goAssembly(ctx context.Context, req *codeActionsRequest) error {
 	}
 	return nil
 }
+
+// goCgo produces "Browse Go file generated by cgo" code actions.
+// They require client support for virtual documents.
+// See [server.commandHandler.CgoFile] for command implementation.
+func goCgo(ctx context.Context, req *codeActionsRequest) error {
+	if !req.snapshot.Options().TextDocumentContentSupported {
+		return nil
+	}
+	for _, imp := range req.pgf.File.Imports {
+		if imp.Path.Value == `"C"` {
+			cmd := command.NewCgoFileCommand(
+				fmt.Sprintf("Browse Go file generated by cgo from %s", filepath.Base(req.fh.URI().Path())),
+				req.snapshot.View().ID(),
+				req.fh.URI())
+			req.addCommandAction(cmd, false)
+			break
+		}
+	}
+	return nil
+}
+
+// goInstantiation produces "Browse instantiation F[T]" code actions
+// for references to instantiated generic functions and methods.
+// They require client support for virtual documents.
+// See [server.commandHandler.Instantiation] for command implementation.
+func goInstantiation(ctx context.Context, req *codeActionsRequest) error {
+	if !req.snapshot.Options().TextDocumentContentSupported {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(req.pgf.File, req.start, req.end)
+	if fn, targs := instantiationAt(req.pkg.TypesInfo(), path); fn != nil {
+		qual := typesinternal.FileQualifier(req.pgf.File, req.pkg.Types())
+		cmd := command.NewInstantiationCommand(
+			fmt.Sprintf("Browse instantiation %s", instantiationName(fn, targs, qual)),
+			req.snapshot.View().ID(),
+			req.loc)
+		req.addCommandAction(cmd, false)
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/event"
+)
+
+// DocumentColors returns the colors denoted by literals in the file:
+// composite literals of the RGBA, NRGBA, RGBA64 and NRGBA64 types of
+// the image/color package whose components are constants, and strings
+// of hexadecimal color notation such as "#ff8000" whose type is a
+// color type (see [isColorType]), as in a conversion Color("#ff8000").
+func DocumentColors(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]protocol.ColorInformation, error) {
+	ctx, done := event.Start(ctx, "golang.DocumentColors")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	var colors []protocol.ColorInformation
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		lit := parseColorLiteral(pkg.TypesInfo(), n)
+		if lit == nil {
+			return true
+		}
+		rng, err := pgf.NodeRange(n)
+		if err != nil {
+			return true // e.g. a literal in fixed syntax
+		}
+		colors = append(colors, protocol.ColorInformation{
+			Range: rng,
+			Color: lit.color(),
+		})
+		return true
+	})
+	return colors, nil
+}
+
+// ColorPresentations returns the presentation of the given color as
+// the color literal at the given range, which is written in the same
+// form as the original literal: for a composite literal, the same
+// keyed or positional elements, each in the same hexadecimal or
+// decimal notation; for a string, the same number of digits, if
+// possible.
+func ColorPresentations(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, color protocol.Color, rng protocol.Range) ([]protocol.ColorPresentation, error) {
+	ctx, done := event.Start(ctx, "golang.ColorPresentations")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		node ast.Node
+		lit  *colorLiteral
+	)
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if lit != nil || n == nil || n.End() < start || n.Pos() > end {
+			return false
+		}
+		if n.Pos() == start && n.End() == end {
+			if l := parseColorLiteral(pkg.TypesInfo(), n); l != nil {
+				node, lit = n, l
+				return false
+			}
+		}
+		return true
+	})
+	if lit == nil {
+		return nil, fmt.Errorf("no color literal at %v", rng)
+	}
+	text, err := lit.format(pgf, node, color)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.ColorPresentation{{
+		Label:    text,
+		TextEdit: &protocol.TextEdit{Range: rng, NewText: text},
+	}}, nil
+}
+
+// A colorLiteral is a literal denoting a color.
+type colorLiteral struct {
+	// For a composite literal:
+	max           float64     // maximum value of a component
+	premultiplied bool        // whether components are premultiplied by alpha
+	keyed         bool        // whether elements are keyed
+	elts          [4]ast.Expr // R, G, B and A element values, or nil if absent
+	last          ast.Expr    // last element, or nil if none
+	lbrace        token.Pos   // position of the opening brace
+	values        [4]uint64   // R, G, B and A components
+
+	// For a string:
+	hex   string // hexadecimal digits, or "" for a composite literal
+	quote byte   // the quotation mark of the string
+}
+
+// colorTypes maps the names of the color types of the image/color
+// package that may be denoted by composite literals to whether their
+// components are 16-bit, and whether they are premultiplied by alpha.
+var colorTypes = map[string]struct{ wide, premultiplied bool }{
+	"RGBA":    {false, true},
+	"NRGBA":   {false, false},
+	"RGBA64":  {true, true},
+	"NRGBA64": {true, false},
+}
+
+// parseColorLiteral returns the color literal denoted by n, or nil if
+// n is not a color literal.
+func parseColorLiteral(info *types.Info, n ast.Node) *colorLiteral {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.STRING {
+			return nil
+		}
+		if !isColorType(info.TypeOf(n)) {
+			return nil // not (converted to) a color type
+		}
+		s, err := strconv.Unquote(n.Value)
+		if err != nil || len(s) < 4 || s[0] != '#' {
+			return nil
+		}
+		hex := s[1:]
+		switch len(hex) {
+		case 3, 4, 6, 8:
+		default:
+			return nil
+		}
+		if _, err := strconv.ParseUint(hex, 16, 64); err != nil {
+			return nil
+		}
+		return &colorLiteral{hex: hex, quote: n.Value[0]}
+
+	case *ast.CompositeLit:
+		named, ok := types.Unalias(info.TypeOf(n)).(*types.Named)
+		if !ok {
+			return nil
+		}
+		obj := named.Obj()
+		t, ok := colorTypes[obj.Name()]
+		if !ok || obj.Pkg() == nil || obj.Pkg().Path() != "image/color" {
+			return nil
+		}
+		lit := &colorLiteral{max: math.MaxUint8, premultiplied: t.premultiplied, lbrace: n.Lbrace}
+		if t.wide {
+			lit.max = math.MaxUint16
+		}
+		for i, elt := range n.Elts {
+			index := i
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				key, ok := kv.Key.(*ast.Ident)
+				if !ok || len(key.Name) != 1 {
+					return nil
+				}
+				index = strings.Index("RGBA", key.Name)
+				lit.keyed = true
+				elt = kv.Value
+			}
+			if index < 0 || index >= len(lit.elts) {
+				return nil
+			}
+			tv, ok := info.Types[elt]
+			if !ok || tv.Value == nil {
+				return nil // not a constant
+			}
+			v, exact := constant.Uint64Val(constant.ToInt(tv.Value))
+			if !exact {
+				return nil
+			}
+			lit.elts[index] = elt
+			lit.values[index] = v
+			lit.last = elt
+		}
+		return lit
+	}
+	return nil
+}
+
+// isColorType reports whether t is a named type (or an alias) whose
+// name suggests that its values denote colors, such as the string
+// type Color of a terminal styling package. Since any string might
+// look like a hexadecimal color, only strings of such types, for
+// example by conversion or assignment, are treated as colors.
+func isColorType(t types.Type) bool {
+	isColorName := func(name string) bool {
+		name = strings.ToLower(name)
+		return strings.Contains(name, "color") || strings.Contains(name, "colour")
+	}
+	if alias, ok := t.(*types.Alias); ok && isColorName(alias.Obj().Name()) {
+		return true
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && isColorName(named.Obj().Name())
+}
+
+// color returns the color denoted by the literal.
+func (lit *colorLiteral) color() protocol.Color {
+	if lit.hex != "" {
+		return lit.hexColor()
+	}
+	alpha := float64(lit.values[3]) / lit.max
+	component := func(i int) float64 {
+		v := float64(lit.values[i]) / lit.max
+		if lit.premultiplied {
+			if alpha == 0 {
+				return 0
+			}
+			v /= alpha
+		}
+		return min(v, 1)
+	}
+	return protocol.Color{
+		Red:   component(0),
+		Green: component(1),
+		Blue:  component(2),
+		Alpha: min(alpha, 1),
+	}
+}
+
+// hexColor returns the color denoted by a string literal.
+func (lit *colorLiteral) hexColor() protocol.Color {
+	digits := 1 // per component
+	if len(lit.hex) > 4 {
+		digits = 2
+	}
+	maxValue := float64(int(1)<<(4*digits) - 1)
+	var components [4]float64
+	components[3] = 1
+	for i := 0; i*digits < len(lit.hex); i++ {
+		v, _ := strconv.ParseUint(lit.hex[i*digits:(i+1)*digits], 16, 8)
+		components[i] = float64(v) / maxValue
+	}
+	return protocol.Color{
+		Red:   components[0],
+		Green: components[1],
+		Blue:  components[2],
+		Alpha: components[3],
+	}
+}
+
+// format returns the text of the literal node, changed to denote the
+// given color.
+func (lit *colorLiteral) format(pgf *parsego.File, node ast.Node, color protocol.Color) (string, error) {
+	clamp := func(v float64) float64 { return max(0, min(v, 1)) }
+	components := [4]float64{clamp(color.Red), clamp(color.Green), clamp(color.Blue), clamp(color.Alpha)}
+
+	if lit.hex != "" {
+		return lit.formatHex(components), nil
+	}
+
+	start, end, err := pgf.NodeOffsets(node)
+	if err != nil {
+		return "", err
+	}
+	var values [4]uint64
+	for i, v := range components {
+		if lit.premultiplied && i < 3 {
+			v *= components[3]
+		}
+		values[i] = uint64(math.Round(v * lit.max))
+	}
+
+	// Elements of a composite literal are changed in place, in the
+	// notation of the original element or, for an element that is
+	// not a literal or is a plain zero, of the first literal element.
+	notation := func(elt ast.Expr) *ast.BasicLit {
+		if lit, ok := elt.(*ast.BasicLit); ok && lit.Kind == token.INT && lit.Value != "0" {
+			return lit
+		}
+		return nil
+	}
+	var first *ast.BasicLit
+	for _, elt := range lit.elts {
+		if first = notation(elt); first != nil {
+			break
+		}
+	}
+	formatValue := func(v uint64, elt ast.Expr) string {
+		basic := notation(elt)
+		if basic == nil {
+			basic = first
+		}
+		if basic == nil || len(basic.Value) < 3 || !strings.EqualFold(basic.Value[:2], "0x") {
+			return strconv.FormatUint(v, 10)
+		}
+		verb := "%0*x"
+		if strings.ContainsAny(basic.Value[2:], "ABCDEF") {
+			verb = "%0*X"
+		}
+		return basic.Value[:2] + fmt.Sprintf(verb, len(basic.Value)-2, v)
+	}
+
+	var edits []diff.Edit
+	var missing []string // keyed elements to add
+	for i, elt := range lit.elts {
+		if elt == nil {
+			if values[i] != 0 {
+				missing = append(missing, fmt.Sprintf("%c: %s", "RGBA"[i], formatValue(values[i], nil)))
+			}
+			continue
+		}
+		eltStart, eltEnd, err := safetoken.Offsets(pgf.Tok, elt.Pos(), elt.End())
+		if err != nil {
+			return "", err
+		}
+		edits = append(edits, diff.Edit{
+			Start: eltStart - start,
+			End:   eltEnd - start,
+			New:   formatValue(values[i], elt),
+		})
+	}
+	if len(missing) > 0 {
+		if !lit.keyed && lit.last != nil {
+			return "", fmt.Errorf("positional color literal has too few elements")
+		}
+		// Add the missing keyed elements after the last element,
+		// or, for an empty literal, after the opening brace.
+		pos, sep := lit.lbrace+1, ""
+		if lit.last != nil {
+			pos, sep = lit.last.End(), ", "
+		}
+		offset, _, err := safetoken.Offsets(pgf.Tok, pos, pos)
+		if err != nil {
+			return "", err
+		}
+		edits = append(edits, diff.Edit{
+			Start: offset - start,
+			End:   offset - start,
+			New:   sep + strings.Join(missing, ", "),
+		})
+	}
+	return diff.Apply(string(pgf.Src[start:end]), edits)
+}
+
+// formatHex returns the text of the string literal, changed to denote
+// the given color components, using the same number of digits if
+// possible, and the same case.
+func (lit *colorLiteral) formatHex(components [4]float64) string {
+	var values [4]uint64
+	for i, v := range components {
+		values[i] = uint64(math.Round(v * 0xff))
+	}
+	ncomponents := 3
+	if len(lit.hex) == 4 || len(lit.hex) == 8 || values[3] != 0xff {
+		ncomponents = 4
+	}
+	short := len(lit.hex) <= 4
+	for _, v := range values[:ncomponents] {
+		if v%0x11 != 0 {
+			short = false // not representable by a single digit
+		}
+	}
+	verb := "%02x"
+	if strings.ContainsAny(lit.hex, "ABCDEF") {
+		verb = "%02X"
+	}
+	var buf strings.Builder
+	buf.WriteByte(lit.quote)
+	buf.WriteByte('#')
+	for _, v := range values[:ncomponents] {
+		digits := fmt.Sprintf(verb, v)
+		if short {
+			digits = digits[:1]
+		}
+		buf.WriteString(digits)
+	}
+	buf.WriteByte(lit.quote)
+	return buf.String()
+}
@@ -51,7 +51,8 @@ import (
 // A CompletionItem represents a possible completion suggested by the algorithm.
 type CompletionItem struct {
 
-	// Invariant: CompletionItem does not refer to syntax or types.
+	// Invariant: CompletionItem does not refer to syntax or types,
+	// except through its resolve function.
 
 	// Label is the primary text the user sees for this completion item.
 	Label string
@@ -109,6 +110,10 @@ type CompletionItem struct {
 	// from which this candidate was derived is a slice.
 	// (Used to complete append() calls.)
 	isSlice bool
+
+	// resolve, if non-nil, computes the complete item, including
+	// the properties whose computation was deferred.
+	resolve func(context.Context) (CompletionItem, error)
 }
 
 // completionOptions holds completion specific configuration.
@@ -122,6 +127,12 @@ type completionOptions struct {
 	matcher               settings.Matcher
 	budget                time.Duration
 	completeFunctionCalls bool
+
+	// Expensive properties of items whose computation is deferred
+	// until the item is resolved, as permitted by the client.
+	deferDocumentation bool // documentation and deprecation tags
+	deferImportEdits   bool // additional text edits adding imports
+	deferSnippets      bool // parameter snippets of deep candidates
 }
 
 // Snippet is a convenience returns the snippet if available, otherwise
@@ -134,6 +145,22 @@ func (i *CompletionItem) Snippet() string {
 	return i.InsertText
 }
 
+// Lazy reports whether the computation of some properties of the
+// item was deferred until it is resolved.
+func (i *CompletionItem) Lazy() bool {
+	return i.resolve != nil
+}
+
+// Resolve returns the complete item, computing the properties whose
+// computation was deferred. The snapshot from which the item was
+// computed must not have been released.
+func (i *CompletionItem) Resolve(ctx context.Context) (CompletionItem, error) {
+	if i.resolve == nil {
+		return *i, nil
+	}
+	return i.resolve(ctx)
+}
+
 // addConversion wraps the existing completionItem in a conversion expression.
 // Only affects the receiver's InsertText and snippet fields, not the Label.
 // An empty conv argument has no effect.
@@ -278,6 +305,11 @@ type completer struct {
 	// (The value is the minimum version in the form "go1.%d".)
 	tooNewSymbolsCache map[*types.Package]map[types.Object]string
 
+	// deprecatedNames caches, for each file, the offsets of the
+	// identifiers it declares that are deprecated (see [completer.deprecated]).
+	deprecatedMu    sync.Mutex
+	deprecatedNames map[protocol.DocumentURI]map[int]bool
+
 	// mapper converts the positions in the file from which the completion originated.
 	mapper *protocol.Mapper
 
@@ -630,6 +662,9 @@ func Completion(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, p
 			snippets:              opts.InsertTextFormat == protocol.SnippetTextFormat,
 			postfix:               opts.ExperimentalPostfixCompletions,
 			completeFunctionCalls: opts.CompleteFunctionCalls,
+			deferDocumentation:    slices.Contains(opts.CompletionResolveOptions, "documentation"),
+			deferImportEdits:      slices.Contains(opts.CompletionResolveOptions, "additionalTextEdits"),
+			deferSnippets:         slices.Contains(opts.CompletionResolveOptions, "textEdit"),
 		},
 		// default to a matcher that always matches
 		matcher:            prefixMatcher(""),
@@ -1385,7 +1420,16 @@ func (c *completer) selector(ctx context.Context, sel *ast.SelectorExpr) error {
 				if imports.ImportPathToAssumedName(path) != string(mp.Name) {
 					imp.name = string(mp.Name)
 				}
-				item.AdditionalTextEdits, _ = c.importEdits(imp)
+				if c.opts.deferImportEdits {
+					item.resolve = func(context.Context) (CompletionItem, error) {
+						resolved := item
+						resolved.resolve = nil
+						resolved.AdditionalTextEdits, _ = c.importEdits(imp)
+						return resolved, nil
+					}
+				} else {
+					item.AdditionalTextEdits, _ = c.importEdits(imp)
+				}
 			}
 
 			// For functions, add a parameter snippet.
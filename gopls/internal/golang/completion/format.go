@@ -10,9 +10,11 @@ import (
 	"fmt"
 	"go/ast"
 	"go/doc"
+	"go/token"
 	"go/types"
 	"strings"
 
+	"golang.org/x/tools/gopls/internal/cache/parsego"
 	"golang.org/x/tools/gopls/internal/golang"
 	"golang.org/x/tools/gopls/internal/golang/completion/snippet"
 	"golang.org/x/tools/gopls/internal/protocol"
@@ -49,7 +51,19 @@ func (c *completer) item(ctx context.Context, cand candidate) (CompletionItem, e
 		return c.formatBuiltin(ctx, cand)
 	}
 
+	return c.formatItem(ctx, cand, true)
+}
+
+// formatItem formats a non-builtin candidate to a CompletionItem.
+//
+// If lazy is set, the computation of expensive properties may be
+// deferred according to the completion options, in which case the
+// resulting item may be resolved to compute them.
+func (c *completer) formatItem(ctx context.Context, cand candidate, lazy bool) (CompletionItem, error) {
+	obj := cand.obj
+
 	var (
+		deferred      bool // some properties are deferred to resolution
 		label         = cand.name
 		detail        = types.TypeString(obj.Type(), c.qual)
 		insert        = label
@@ -127,7 +141,16 @@ Suffixes:
 	for _, mod := range cand.mods {
 		switch mod {
 		case invoke:
-			if sig, ok := funcType.Underlying().(*types.Signature); ok {
+			if sig, ok := funcType.Underlying().(*types.Signature); ok && lazy && c.opts.deferSnippets && len(cand.path) > 0 {
+				// Deep candidates are numerous: defer the formatting
+				// of their parameters and type parameters.
+				deferred = true
+				c.functionCallSnippet("", nil, nil, &snip)
+				if sig.Results().Len() == 1 {
+					funcType = sig.Results().At(0).Type()
+				}
+				detail = types.TypeString(sig, c.qual)
+			} else if ok {
 				s, err := golang.NewSignature(ctx, c.snapshot, c.pkg, sig, nil, c.qual, c.mq)
 				if err != nil {
 					return CompletionItem{}, err
@@ -180,13 +203,15 @@ Suffixes:
 	// If this candidate needs an additional import statement,
 	// add the additional text edits needed.
 	if cand.imp != nil {
-		addlEdits, err := c.importEdits(cand.imp)
-
-		if err != nil {
-			return CompletionItem{}, err
+		if lazy && c.opts.deferImportEdits {
+			deferred = true
+		} else {
+			addlEdits, err := c.importEdits(cand.imp)
+			if err != nil {
+				return CompletionItem{}, err
+			}
+			protocolEdits = append(protocolEdits, addlEdits...)
 		}
-
-		protocolEdits = append(protocolEdits, addlEdits...)
 		if kind != protocol.ModuleCompletion {
 			if detail != "" {
 				detail += " "
@@ -237,6 +262,16 @@ Suffixes:
 		snippet:             &snip,
 		isSlice:             isSlice(obj),
 	}
+	opts := c.snapshot.Options()
+	deferDoc := lazy && c.opts.deferDocumentation && c.opts.documentation
+	if deferDoc {
+		deferred = true
+	}
+	if deferred {
+		item.resolve = func(ctx context.Context) (CompletionItem, error) {
+			return c.formatItem(ctx, cand, false)
+		}
+	}
 	// If the user doesn't want documentation for completion items.
 	if !c.opts.documentation {
 		return item, nil
@@ -249,6 +284,19 @@ Suffixes:
 		return item, nil
 	}
 
+	// Deprecation is reported even if the documentation is deferred,
+	// so it is determined from the syntax of the declaring file alone.
+	if (opts.CompletionTags || opts.CompletionDeprecated) && c.deprecated(ctx, pos) {
+		if opts.CompletionTags {
+			item.Tags = []protocol.CompletionItemTag{protocol.ComplDeprecated}
+		} else {
+			item.Deprecated = true
+		}
+	}
+	if deferDoc { // left to item.resolve
+		return item, nil
+	}
+
 	comment, err := golang.HoverDocForObject(ctx, c.snapshot, c.pkg.FileSet(), obj)
 	if err != nil {
 		event.Error(ctx, fmt.Sprintf("failed to find Hover for %q", obj.Name()), err)
@@ -259,20 +307,32 @@ Suffixes:
 	} else {
 		item.Documentation = doc.Synopsis(comment.Text())
 	}
-	// The desired pattern is `^// Deprecated`, but the prefix has been removed
-	// TODO(rfindley): It doesn't look like this does the right thing for
-	// multi-line comments.
-	if strings.HasPrefix(comment.Text(), "Deprecated") {
-		if c.snapshot.Options().CompletionTags {
-			item.Tags = []protocol.CompletionItemTag{protocol.ComplDeprecated}
-		} else if c.snapshot.Options().CompletionDeprecated {
-			item.Deprecated = true
-		}
-	}
 
 	return item, nil
 }
 
+// deprecated reports whether the doc comment of the object declared at
+// the specified position marks it as deprecated. The deprecated names
+// of each file are computed once per completion request.
+func (c *completer) deprecated(ctx context.Context, posn token.Position) bool {
+	uri := protocol.URIFromPath(posn.Filename)
+	c.deprecatedMu.Lock()
+	defer c.deprecatedMu.Unlock()
+	names, ok := c.deprecatedNames[uri]
+	if !ok {
+		if fh, err := c.snapshot.ReadFile(ctx, uri); err == nil {
+			if pgf, err := c.snapshot.ParseGo(ctx, fh, parsego.Full); err == nil {
+				names = golang.DeprecatedNames(pgf)
+			}
+		}
+		if c.deprecatedNames == nil {
+			c.deprecatedNames = make(map[protocol.DocumentURI]map[int]bool)
+		}
+		c.deprecatedNames[uri] = names
+	}
+	return names[posn.Offset]
+}
+
 // conversionEdits represents the string edits needed to make a type conversion
 // of an expression.
 type conversionEdits struct {
@@ -0,0 +1,420 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the "Convert function to method" and "Convert
+// method to function" code actions, which use the change signature
+// machinery (see change_signature.go) to update all calls.
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/moremaps"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	internalastutil "golang.org/x/tools/internal/astutil"
+)
+
+// enclosingFuncHeader returns the declaration of the function whose
+// header (that is, its signature, not its body or doc comment)
+// encloses the selected range.
+func enclosingFuncHeader(pgf *parsego.File, start, end token.Pos) *ast.FuncDecl {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			if decl.Body != nil && decl.Type.Pos() <= start && end <= decl.Type.End() {
+				return decl
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// funcToMethodReceiver returns the declaration of the function whose
+// header encloses the selected range, and the named type of its first
+// parameter, if the function can be converted to a method of that
+// type. The type must be a non-generic type declared by the package,
+// and the function must not be generic.
+func funcToMethodReceiver(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*ast.FuncDecl, *types.Named, bool) {
+	decl := enclosingFuncHeader(pgf, start, end)
+	if decl == nil || decl.Recv != nil || decl.Type.TypeParams != nil || decl.Type.Params.NumFields() == 0 {
+		return nil, nil, false
+	}
+	named, _ := receiverNamed(pkg.TypesInfo().TypeOf(decl.Type.Params.List[0].Type))
+	if named == nil || named.Obj().Pkg() != pkg.Types() || named.Obj().Parent() != pkg.Types().Scope() {
+		return nil, nil, false
+	}
+	return decl, named, true
+}
+
+// methodToFuncDecl returns the declaration of the method whose header
+// encloses the selected range, if it can be converted to a function.
+// The receiver type must not be generic.
+func methodToFuncDecl(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*ast.FuncDecl, *types.Named, bool) {
+	decl := enclosingFuncHeader(pgf, start, end)
+	if decl == nil || decl.Recv.NumFields() != 1 {
+		return nil, nil, false
+	}
+	named, _ := receiverNamed(pkg.TypesInfo().TypeOf(decl.Recv.List[0].Type))
+	if named == nil {
+		return nil, nil, false
+	}
+	return decl, named, true
+}
+
+// receiverNamed returns the named type T of a type T or *T that is
+// valid as a method receiver, and whether it is a pointer.
+// It returns nil if the type is generic.
+func receiverNamed(t types.Type) (*types.Named, bool) {
+	if t == nil {
+		return nil, false
+	}
+	ptr, isPtr := types.Unalias(t).(*types.Pointer)
+	if isPtr {
+		t = ptr.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 || named.TypeArgs().Len() > 0 {
+		return nil, false
+	}
+	switch named.Underlying().(type) {
+	case *types.Interface, *types.Pointer:
+		return nil, false
+	}
+	return named, isPtr
+}
+
+// convertFuncToMethod converts the function whose header encloses rng
+// into a method of the type of its first parameter, and updates all
+// references to it. Function values become method expressions.
+func convertFuncToMethod(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	decl, named, ok := funcToMethodReceiver(pkg, pgf, start, end)
+	if !ok {
+		return nil, fmt.Errorf("no function to convert to a method")
+	}
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
+	}
+	fn := pkg.TypesInfo().Defs[decl.Name].(*types.Func)
+	_, isPtr := receiverNamed(fn.Signature().Params().At(0).Type())
+	tname := named.Obj().Name()
+
+	if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, pkg.Types(), fn.Name()); obj != nil {
+		return nil, fmt.Errorf("type %s already has a field or method %s", tname, fn.Name())
+	}
+
+	// The new method must not cause the type to implement
+	// other interfaces.
+	sig := fn.Signature()
+	var params []*types.Var
+	for i := 1; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i))
+	}
+	methodSig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
+	if err := checkImplementations(ctx, snapshot, pkg, named, fn.Name(), methodSig, isPtr); err != nil {
+		return nil, err
+	}
+
+	// Move the first parameter to the receiver.
+	newDecl := internalastutil.CloneNode(decl)
+	first := newDecl.Type.Params.List[0]
+	recv := &ast.Field{Type: first.Type}
+	if len(first.Names) > 0 {
+		recv.Names = first.Names[:1]
+		first.Names = first.Names[1:]
+	}
+	if len(first.Names) == 0 {
+		newDecl.Type.Params.List = newDecl.Type.Params.List[1:]
+	}
+	newDecl.Recv = &ast.FieldList{List: []*ast.Field{recv}}
+
+	// Function values F become method expressions T.F or (*T).F.
+	rewriteRef := func(refpkg *cache.Package, refpgf *parsego.File, path []ast.Node) (token.Pos, token.Pos, string, error) {
+		id := path[0].(*ast.Ident)
+		var (
+			ref  ast.Expr = id
+			qual string
+		)
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == id {
+			ref = sel
+			if x, ok := sel.X.(*ast.Ident); ok {
+				qual = x.Name + "."
+			}
+			if !named.Obj().Exported() {
+				return 0, 0, "", fmt.Errorf("cannot convert %s: the reference at %s would refer to unexported type %s",
+					fn.Name(), safetoken.StartPosition(refpkg.FileSet(), id.Pos()), tname)
+			}
+		} else if _, obj := refpkg.Types().Scope().Innermost(id.Pos()).LookupParent(tname, id.Pos()); obj != named.Obj() {
+			return 0, 0, "", fmt.Errorf("cannot convert %s: type %s is shadowed at %s",
+				fn.Name(), tname, safetoken.StartPosition(refpkg.FileSet(), id.Pos()))
+		}
+		recv := qual + tname
+		if isPtr {
+			recv = "(*" + recv + ")"
+		}
+		return ref.Pos(), ref.End(), recv + "." + fn.Name(), nil
+	}
+
+	wrapperParams, args, variadic := delegatingParams(decl.Type.Params, identityPermutation(sig.Params().Len()))
+	rw := signatureRewrite{
+		snapshot:   snapshot,
+		pkg:        pkg,
+		pgf:        pgf,
+		origDecl:   decl,
+		newDecl:    newDecl,
+		params:     wrapperParams,
+		callArgs:   args,
+		variadic:   variadic,
+		rewriteRef: rewriteRef,
+	}
+	newContent, err := rewriteCalls(ctx, rw)
+	if err != nil {
+		return nil, err
+	}
+	return signatureChanges(ctx, rw, newContent)
+}
+
+// convertMethodToFunc converts the method whose header encloses rng
+// into a function whose first parameter is the receiver, and updates
+// all references to it. Method expressions become function values;
+// method values cannot be converted.
+func convertMethodToFunc(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	decl, named, ok := methodToFuncDecl(pkg, pgf, start, end)
+	if !ok {
+		return nil, fmt.Errorf("no method to convert to a function")
+	}
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
+	}
+	fn := pkg.TypesInfo().Defs[decl.Name].(*types.Func)
+	recvType := fn.Signature().Recv().Type()
+	_, isPtr := receiverNamed(recvType)
+	name := fn.Name()
+
+	// The new function must not conflict with, or be shadowed by,
+	// another declaration.
+	if obj := pkg.Types().Scope().Lookup(name); obj != nil {
+		return nil, fmt.Errorf("package %s already declares %s", pkg.Types().Name(), name)
+	}
+	for _, pgf := range pkg.CompiledGoFiles() {
+		if obj := pkg.TypesInfo().Scopes[pgf.File].Lookup(name); obj != nil {
+			return nil, fmt.Errorf("%s conflicts with the import at %s", name, safetoken.StartPosition(pkg.FileSet(), obj.Pos()))
+		}
+	}
+	for id, obj := range pkg.TypesInfo().Uses {
+		if obj == fn {
+			if _, shadow := pkg.Types().Scope().Innermost(id.Pos()).LookupParent(name, id.Pos()); shadow != nil {
+				return nil, fmt.Errorf("%s would be shadowed by the declaration at %s", name, safetoken.StartPosition(pkg.FileSet(), shadow.Pos()))
+			}
+		}
+	}
+
+	// Removing the method must not stop the type from
+	// implementing an interface.
+	if err := checkImplementations(ctx, snapshot, pkg, named, name, nil, isPtr); err != nil {
+		return nil, err
+	}
+
+	// Prepend the receiver to the parameters. Parameters must be
+	// all named or all unnamed.
+	newDecl := internalastutil.CloneNode(decl)
+	recv := newDecl.Recv.List[0]
+	newDecl.Recv = nil
+	params := newDecl.Type.Params
+	switch {
+	case len(recv.Names) == 0 && len(params.List) > 0 && len(params.List[0].Names) > 0:
+		recv.Names = []*ast.Ident{ast.NewIdent("_")}
+	case len(recv.Names) > 0:
+		for _, field := range params.List {
+			if len(field.Names) == 0 {
+				field.Names = []*ast.Ident{ast.NewIdent("_")}
+			}
+		}
+	}
+	params.List = append([]*ast.Field{recv}, params.List...)
+
+	// Method expressions T.M become function values M.
+	rewriteRef := func(refpkg *cache.Package, refpgf *parsego.File, path []ast.Node) (token.Pos, token.Pos, string, error) {
+		id := path[0].(*ast.Ident)
+		posn := safetoken.StartPosition(refpkg.FileSet(), id.Pos())
+		sel, ok := path[1].(*ast.SelectorExpr)
+		if !ok || sel.Sel != id {
+			return 0, 0, "", fmt.Errorf("cannot convert %s: unexpected reference at %s", name, posn)
+		}
+		seln := refpkg.TypesInfo().Selections[sel]
+		if seln == nil || seln.Kind() != types.MethodExpr {
+			return 0, 0, "", fmt.Errorf("cannot convert %s: method value %s at %s", name, types.ExprString(sel), posn)
+		}
+		if !types.Identical(seln.Recv(), recvType) {
+			return 0, 0, "", fmt.Errorf("cannot convert %s: method expression %s at %s has a different receiver type", name, types.ExprString(sel), posn)
+		}
+		if refpkg.Types() == pkg.Types() {
+			return sel.Pos(), sel.End(), name, nil
+		}
+		// Find the qualifier of the receiver type, as in a.T.M.
+		qual := ""
+		ast.Inspect(sel.X, func(n ast.Node) bool {
+			if x, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := x.X.(*ast.Ident); ok {
+					if pkgname, ok := refpkg.TypesInfo().Uses[id].(*types.PkgName); ok && pkgname.Imported() == pkg.Types() {
+						qual = id.Name
+					}
+				}
+			}
+			return qual == ""
+		})
+		if qual == "" {
+			return 0, 0, "", fmt.Errorf("cannot convert %s: the method expression at %s does not name package %s", name, posn, pkg.Types().Name())
+		}
+		return sel.Pos(), sel.End(), qual + "." + name, nil
+	}
+
+	wrapperParams, args, variadic := delegatingParams(decl.Type.Params, identityPermutation(fn.Signature().Params().Len()))
+	rw := signatureRewrite{
+		snapshot:   snapshot,
+		pkg:        pkg,
+		pgf:        pgf,
+		origDecl:   decl,
+		newDecl:    newDecl,
+		params:     wrapperParams,
+		callArgs:   args,
+		variadic:   variadic,
+		rewriteRef: rewriteRef,
+	}
+	newContent, err := rewriteCalls(ctx, rw)
+	if err != nil {
+		return nil, err
+	}
+	return signatureChanges(ctx, rw, newContent)
+}
+
+// identityPermutation returns the parameter transformation [0, ..., n-1].
+func identityPermutation(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	return perm
+}
+
+// checkImplementations reports an error if adding (sig != nil) or
+// removing (sig == nil) the named method of type T would change
+// whether T or *T implements an interface, among those used by pkg and
+// its reverse dependencies or declared by their direct imports.
+// isPtr indicates whether the method has a pointer receiver.
+func checkImplementations(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, T *types.Named, name string, sig *types.Signature, isPtr bool) error {
+	rdeps, err := snapshot.ReverseDependencies(ctx, pkg.Metadata().ID, true)
+	if err != nil {
+		return err
+	}
+	ids := append(moremaps.KeySlice(rdeps), pkg.Metadata().ID)
+	pkgs, err := snapshot.TypeCheck(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[types.Type]bool)
+	check := func(t types.Type) error {
+		if seen[t] {
+			return nil
+		}
+		seen[t] = true
+		iface, ok := t.Underlying().(*types.Interface)
+		if !ok {
+			return nil
+		}
+		var method *types.Func
+		for i := 0; i < iface.NumMethods(); i++ {
+			if m := iface.Method(i); m.Name() == name && (token.IsExported(name) || m.Pkg() == pkg.Types()) {
+				method = m
+			}
+		}
+		if method == nil {
+			return nil
+		}
+		qual := types.RelativeTo(pkg.Types())
+		for _, recv := range []types.Type{T, types.NewPointer(T)} {
+			implements := types.Implements(recv, iface)
+			if sig == nil {
+				if implements {
+					return fmt.Errorf("%s would no longer implement %s", types.TypeString(recv, qual), types.TypeString(t, qual))
+				}
+				continue
+			}
+			if implements || (isPtr && recv == types.Type(T)) || !types.Identical(method.Type(), sig) {
+				continue
+			}
+			// Does recv have all the other methods?
+			mset := types.NewMethodSet(recv)
+			others := true
+			for i := 0; i < iface.NumMethods(); i++ {
+				m := iface.Method(i)
+				if m != method {
+					sel := mset.Lookup(m.Pkg(), m.Name())
+					if sel == nil || !types.Identical(sel.Type(), m.Type()) {
+						others = false
+					}
+				}
+			}
+			if others {
+				return fmt.Errorf("%s would implement %s", types.TypeString(recv, qual), types.TypeString(t, qual))
+			}
+		}
+		return nil
+	}
+
+	for _, p := range pkgs {
+		info := p.TypesInfo()
+		for _, tv := range info.Types {
+			if tv.Type != nil {
+				if err := check(tv.Type); err != nil {
+					return err
+				}
+			}
+		}
+		for _, obj := range info.Defs {
+			if tname, ok := obj.(*types.TypeName); ok {
+				if err := check(tname.Type()); err != nil {
+					return err
+				}
+			}
+		}
+		for _, imp := range p.Types().Imports() {
+			for _, n := range imp.Scope().Names() {
+				if tname, ok := imp.Scope().Lookup(n).(*types.TypeName); ok && tname.Exported() {
+					if err := check(tname.Type()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,746 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the code actions that convert an if/else chain
+// into a switch statement, and vice versa:
+//
+//	if x == A || x == B {         switch x {
+//		...                        case A, B:
+//	} else if x == C {      <=>        ...
+//		...                        case C:
+//	} else {                           ...
+//		...                        default:
+//	}                                  ...
+//	                               }
+//
+// and likewise for a chain of type assertions and a type switch:
+//
+//	if v, ok := x.(T); ok {       switch v := x.(type) {
+//		...                        case T:
+//	} else if x == nil {    <=>        ...
+//		...                        case nil:
+//	}                                  ...
+//	                               }
+//
+// An if/else chain re-evaluates the tested expression x for each
+// comparison whereas a switch evaluates it exactly once, so both
+// directions require that x (and, for expression switches, each case
+// value) be free of side effects.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	goplsastutil "golang.org/x/tools/gopls/internal/util/astutil"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+)
+
+// An ifChain is an if/else chain that is convertible to a switch.
+type ifChain struct {
+	head   *ast.IfStmt
+	init   ast.Stmt         // init statement of the head of an expression chain, or nil
+	tag    ast.Expr         // the tested expression
+	isType bool             // the chain tests the dynamic type of tag
+	bind   *ast.Ident       // for type chains, the variable bound by the assertions, or nil
+	cases  [][]ast.Expr     // values (or types) tested by each link of the chain
+	bodies []*ast.BlockStmt // body of each link, then the final else block, if any
+}
+
+// ifChainToSwitch is a singleFileFixFunc that converts an if/else
+// chain into an equivalent switch statement.
+func ifChainToSwitch(fset *token.FileSet, start, end token.Pos, src []byte, file *ast.File, _ *types.Package, info *types.Info) (*token.FileSet, *analysis.SuggestedFix, error) {
+	chain, err := canConvertIfChainToSwitch(file, info, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := func(n ast.Node) string {
+		return string(src[safetoken.StartPosition(fset, n.Pos()).Offset:safetoken.EndPosition(fset, n.End()).Offset])
+	}
+	indent := lineIndent(fset, src, chain.head.Pos())
+
+	var buf strings.Builder
+	buf.WriteString("switch ")
+	if chain.init != nil {
+		buf.WriteString(text(chain.init) + "; ")
+	}
+	switch {
+	case !chain.isType:
+		buf.WriteString(text(chain.tag))
+	case chain.bind != nil:
+		fmt.Fprintf(&buf, "%s := %s.(type)", chain.bind.Name, text(chain.tag))
+	default:
+		buf.WriteString(text(chain.tag) + ".(type)")
+	}
+	buf.WriteString(" {")
+	for i, body := range chain.bodies {
+		buf.WriteString("\n" + indent)
+		if i < len(chain.cases) {
+			var exprs []string
+			for _, e := range chain.cases[i] {
+				exprs = append(exprs, text(e))
+			}
+			buf.WriteString("case " + strings.Join(exprs, ", ") + ":")
+		} else {
+			buf.WriteString("default:")
+		}
+		buf.WriteString(clauseText(fset, src, body.Lbrace+1, body.Rbrace))
+	}
+	buf.WriteString("\n" + indent + "}")
+
+	return fset, &analysis.SuggestedFix{
+		TextEdits: []analysis.TextEdit{{
+			Pos:     chain.head.Pos(),
+			End:     chain.head.End(),
+			NewText: []byte(buf.String()),
+		}},
+	}, nil
+}
+
+// canConvertIfChainToSwitch reports whether the selection is within
+// the header of an if statement that belongs to an if/else chain
+// convertible to a switch statement, and if so returns the chain.
+func canConvertIfChainToSwitch(file *ast.File, info *types.Info, start, end token.Pos) (*ifChain, error) {
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for i, node := range path {
+		stmt, ok := node.(*ast.IfStmt)
+		if !ok {
+			continue
+		}
+		if !(stmt.Pos() <= start && end <= stmt.Body.Lbrace) {
+			return nil, fmt.Errorf("selection is not within an if condition")
+		}
+		// Find the head of the chain.
+		for ; i+1 < len(path); i++ {
+			parent, ok := path[i+1].(*ast.IfStmt)
+			if !ok || parent.Else != path[i] {
+				break
+			}
+		}
+		return ifChainOf(info, path[i].(*ast.IfStmt))
+	}
+	return nil, fmt.Errorf("not an if statement")
+}
+
+// ifChainOf analyzes the if/else chain beginning at head.
+func ifChainOf(info *types.Info, head *ast.IfStmt) (*ifChain, error) {
+	var (
+		links []*ast.IfStmt
+		els   *ast.BlockStmt
+	)
+	for cur := head; ; {
+		links = append(links, cur)
+		next, ok := cur.Else.(*ast.IfStmt)
+		if !ok {
+			els, _ = cur.Else.(*ast.BlockStmt)
+			break
+		}
+		cur = next
+	}
+	if len(links) < 2 {
+		return nil, fmt.Errorf("if statement has no else-if clause")
+	}
+
+	var (
+		chain *ifChain
+		err   error
+	)
+	if slices.ContainsFunc(links, isTypeAssertLink) {
+		chain, err = typeAssertChainOf(info, links, els)
+	} else {
+		chain, err = comparisonChainOf(info, links)
+	}
+	if err != nil {
+		return nil, err
+	}
+	chain.head = head
+	for _, link := range links {
+		chain.bodies = append(chain.bodies, link.Body)
+	}
+	if els != nil {
+		chain.bodies = append(chain.bodies, els)
+	}
+
+	// A break statement in the body of an if refers to an
+	// enclosing for, switch, or select statement, but would
+	// refer to the new switch after the transformation.
+	for _, body := range chain.bodies {
+		if breaksOut(body, "") {
+			return nil, fmt.Errorf("if/else chain contains a break statement")
+		}
+	}
+	return chain, nil
+}
+
+// isTypeAssertLink reports whether the link of an if/else chain has
+// the form "if v, ok := x.(T); ok".
+func isTypeAssertLink(link *ast.IfStmt) bool {
+	assign, ok := link.Init.(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return false
+	}
+	assert, ok := assign.Rhs[0].(*ast.TypeAssertExpr)
+	if !ok || assert.Type == nil {
+		return false
+	}
+	lhsOK, ok1 := assign.Lhs[1].(*ast.Ident)
+	cond, ok2 := link.Cond.(*ast.Ident)
+	return ok1 && ok2 && lhsOK.Name != "_" && lhsOK.Name == cond.Name
+}
+
+// comparisonChainOf analyzes an if/else chain in which each condition
+// is a disjunction of comparisons "tag == value".
+func comparisonChainOf(info *types.Info, links []*ast.IfStmt) (*ifChain, error) {
+	chain := &ifChain{init: links[0].Init}
+	for _, link := range links[1:] {
+		if link.Init != nil {
+			return nil, fmt.Errorf("else-if clause has an init statement")
+		}
+	}
+
+	// Choose the tag from the first comparison. A constant tag is
+	// rejected: the case values would be converted to its default
+	// type, which may differ from theirs, as in "switch 1 { case x: }"
+	// for x of type int64.
+	first, ok := ast.Unparen(disjuncts(links[0].Cond)[0]).(*ast.BinaryExpr)
+	if !ok || first.Op != token.EQL {
+		return nil, fmt.Errorf("if condition is not an equality comparison")
+	}
+	var candidates []ast.Expr
+	for _, operand := range []ast.Expr{first.X, first.Y} {
+		if info.Types[operand].Value == nil {
+			candidates = append(candidates, operand)
+		}
+	}
+	identical := func(x, y *ast.Ident) bool {
+		return x.Name == y.Name && info.ObjectOf(x) == info.ObjectOf(y)
+	}
+outer:
+	for _, tag := range candidates {
+		var cases [][]ast.Expr
+		for _, link := range links {
+			var values []ast.Expr
+			for _, d := range disjuncts(link.Cond) {
+				cmp, ok := ast.Unparen(d).(*ast.BinaryExpr)
+				if !ok || cmp.Op != token.EQL {
+					continue outer
+				}
+				switch {
+				case goplsastutil.Equal(cmp.X, tag, identical):
+					values = append(values, cmp.Y)
+				case goplsastutil.Equal(cmp.Y, tag, identical):
+					values = append(values, cmp.X)
+				default:
+					continue outer
+				}
+			}
+			cases = append(cases, values)
+		}
+		chain.tag, chain.cases = tag, cases
+		break
+	}
+	if chain.tag == nil {
+		return nil, fmt.Errorf("if conditions do not all compare the same expression")
+	}
+
+	if !isSideEffectFree(info, chain.tag) {
+		return nil, fmt.Errorf("tested expression may have side effects")
+	}
+	var consts []constant.Value
+	for _, values := range chain.cases {
+		for _, v := range values {
+			if !isSideEffectFree(info, v) {
+				return nil, fmt.Errorf("compared value %s may have side effects", types.ExprString(v))
+			}
+			if c := info.Types[v].Value; c != nil {
+				for _, prev := range consts {
+					if sameConstant(prev, c) {
+						return nil, fmt.Errorf("value %s is tested more than once", types.ExprString(v))
+					}
+				}
+				consts = append(consts, c)
+			}
+		}
+	}
+	return chain, nil
+}
+
+// typeAssertChainOf analyzes an if/else chain in which each link
+// has the form "if v, ok := x.(T); ok" or "if x == nil".
+func typeAssertChainOf(info *types.Info, links []*ast.IfStmt, els *ast.BlockStmt) (*ifChain, error) {
+	chain := &ifChain{isType: true}
+	for _, link := range links {
+		if isTypeAssertLink(link) {
+			chain.tag = link.Init.(*ast.AssignStmt).Rhs[0].(*ast.TypeAssertExpr).X
+			break
+		}
+	}
+	identical := func(x, y *ast.Ident) bool {
+		return x.Name == y.Name && info.ObjectOf(x) == info.ObjectOf(y)
+	}
+
+	var (
+		okVars   = make(map[types.Object]bool)
+		boundVar = make(map[*ast.BlockStmt]types.Object) // variable bound for each body, or nil
+		seenNil  = false
+		seen     []types.Type
+	)
+	for _, link := range links {
+		if !isTypeAssertLink(link) {
+			// if x == nil
+			cmp, ok := ast.Unparen(link.Cond).(*ast.BinaryExpr)
+			if link.Init != nil || !ok || cmp.Op != token.EQL ||
+				!(goplsastutil.Equal(cmp.X, chain.tag, identical) && info.Types[cmp.Y].IsNil() ||
+					goplsastutil.Equal(cmp.Y, chain.tag, identical) && info.Types[cmp.X].IsNil()) {
+				return nil, fmt.Errorf("if/else chain mixes type assertions and other conditions")
+			}
+			if seenNil {
+				return nil, fmt.Errorf("nil is tested more than once")
+			}
+			seenNil = true
+			nilExpr := cmp.Y
+			if info.Types[cmp.X].IsNil() {
+				nilExpr = cmp.X
+			}
+			chain.cases = append(chain.cases, []ast.Expr{nilExpr})
+			continue
+		}
+
+		assign := link.Init.(*ast.AssignStmt)
+		assert := assign.Rhs[0].(*ast.TypeAssertExpr)
+		if !goplsastutil.Equal(assert.X, chain.tag, identical) {
+			return nil, fmt.Errorf("if conditions do not all assert the type of the same expression")
+		}
+		t := info.TypeOf(assert.Type)
+		for _, prev := range seen {
+			if types.Identical(prev, t) {
+				return nil, fmt.Errorf("type %s is tested more than once", types.ExprString(assert.Type))
+			}
+		}
+		seen = append(seen, t)
+		chain.cases = append(chain.cases, []ast.Expr{assert.Type})
+
+		okVars[info.Defs[assign.Lhs[1].(*ast.Ident)]] = true
+		if v := assign.Lhs[0].(*ast.Ident); v.Name != "_" {
+			if chain.bind != nil && chain.bind.Name != v.Name {
+				return nil, fmt.Errorf("type assertions bind different variables (%s and %s)", chain.bind.Name, v.Name)
+			}
+			if chain.bind == nil {
+				chain.bind = v
+			}
+			boundVar[link.Body] = info.Defs[v]
+		}
+	}
+	if !isSideEffectFree(info, chain.tag) {
+		return nil, fmt.Errorf("tested expression may have side effects")
+	}
+
+	// Within the chain, each body may refer only to its own
+	// variable v (or to a v declared within it), since a type switch
+	// binds v afresh in every clause; and the ok variables disappear.
+	bodies := make([]*ast.BlockStmt, 0, len(links)+1)
+	for _, link := range links {
+		bodies = append(bodies, link.Body)
+	}
+	if els != nil {
+		bodies = append(bodies, els)
+	}
+	for _, body := range bodies {
+		var err error
+		ast.Inspect(body, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || err != nil {
+				return err == nil
+			}
+			obj, ok := info.Uses[id].(*types.Var)
+			if !ok || obj.IsField() {
+				return true
+			}
+			if okVars[obj] {
+				err = fmt.Errorf("variable %s is used within the if/else chain", id.Name)
+			} else if chain.bind != nil && id.Name == chain.bind.Name && obj != boundVar[body] &&
+				!(body.Pos() <= obj.Pos() && obj.Pos() < body.End()) {
+				err = fmt.Errorf("reference to %s would be shadowed by the type switch variable", id.Name)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// A switchToIf holds a switch statement that is convertible to an
+// if/else chain.
+type switchToIf struct {
+	stmt    ast.Stmt // *ast.SwitchStmt or *ast.TypeSwitchStmt
+	clauses []*ast.CaseClause
+	used    map[*ast.CaseClause]bool // for type switches, whether the clause uses its variable
+}
+
+// switchToIfChain is a singleFileFixFunc that converts a switch
+// statement into an equivalent if/else chain.
+func switchToIfChain(fset *token.FileSet, start, end token.Pos, src []byte, file *ast.File, _ *types.Package, info *types.Info) (*token.FileSet, *analysis.SuggestedFix, error) {
+	sw, err := canConvertSwitchToIfChain(file, info, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := func(n ast.Node) string {
+		return string(src[safetoken.StartPosition(fset, n.Pos()).Offset:safetoken.EndPosition(fset, n.End()).Offset])
+	}
+	// operand returns the text of e as an operand of ==.
+	operand := func(e ast.Expr) string {
+		if bin, ok := e.(*ast.BinaryExpr); ok && bin.Op.Precedence() <= token.EQL.Precedence() {
+			return "(" + text(e) + ")"
+		}
+		return text(e)
+	}
+	indent := lineIndent(fset, src, sw.stmt.Pos())
+
+	var (
+		buf        strings.Builder
+		body       *ast.BlockStmt
+		dflt       *ast.CaseClause
+		first      = true
+		clauseBody = func(i int) string {
+			end := body.Rbrace
+			if i+1 < len(body.List) {
+				end = body.List[i+1].Pos()
+			}
+			return clauseText(fset, src, sw.clauses[i].Colon+1, end)
+		}
+	)
+	switch stmt := sw.stmt.(type) {
+	case *ast.SwitchStmt:
+		body = stmt.Body
+		for i, clause := range sw.clauses {
+			if clause.List == nil {
+				dflt = clause
+				continue
+			}
+			var conds []string
+			for _, e := range clause.List {
+				if stmt.Tag == nil {
+					conds = append(conds, text(e))
+				} else {
+					conds = append(conds, operand(stmt.Tag)+" == "+operand(e))
+				}
+			}
+			if first {
+				buf.WriteString("if ")
+				if stmt.Init != nil {
+					buf.WriteString(text(stmt.Init) + "; ")
+				}
+			} else {
+				buf.WriteString(" else if ")
+			}
+			first = false
+			buf.WriteString(strings.Join(conds, " || ") + " {" + clauseBody(i) + "\n" + indent + "}")
+		}
+
+	case *ast.TypeSwitchStmt:
+		body = stmt.Body
+		x, v := typeSwitchOperands(stmt)
+		for i, clause := range sw.clauses {
+			if clause.List == nil {
+				dflt = clause
+				continue
+			}
+			if first {
+				buf.WriteString("if ")
+			} else {
+				buf.WriteString(" else if ")
+			}
+			first = false
+			if info.Types[clause.List[0]].IsNil() {
+				buf.WriteString(text(x) + " == nil")
+			} else {
+				name := "_"
+				if v != nil && sw.used[clause] {
+					name = v.Name
+				}
+				fmt.Fprintf(&buf, "%s, ok := %s.(%s); ok", name, text(x), text(clause.List[0]))
+			}
+			buf.WriteString(" {" + clauseBody(i) + "\n" + indent + "}")
+		}
+	}
+	if dflt != nil {
+		i := 0
+		for sw.clauses[i] != dflt {
+			i++
+		}
+		buf.WriteString(" else {" + clauseBody(i) + "\n" + indent + "}")
+	}
+
+	return fset, &analysis.SuggestedFix{
+		TextEdits: []analysis.TextEdit{{
+			Pos:     sw.stmt.Pos(),
+			End:     sw.stmt.End(),
+			NewText: []byte(buf.String()),
+		}},
+	}, nil
+}
+
+// canConvertSwitchToIfChain reports whether the selection is within
+// the header of a switch statement that is convertible to an if/else
+// chain, and if so returns it.
+func canConvertSwitchToIfChain(file *ast.File, info *types.Info, start, end token.Pos) (*switchToIf, error) {
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for i, node := range path {
+		var body *ast.BlockStmt
+		switch stmt := node.(type) {
+		case *ast.SwitchStmt:
+			body = stmt.Body
+		case *ast.TypeSwitchStmt:
+			body = stmt.Body
+		default:
+			continue
+		}
+		if !(node.Pos() <= start && end <= body.Lbrace) {
+			return nil, fmt.Errorf("selection is not within a switch header")
+		}
+		label := ""
+		if i+1 < len(path) {
+			if labeled, ok := path[i+1].(*ast.LabeledStmt); ok {
+				label = labeled.Label.Name
+			}
+		}
+		return switchToIfOf(info, node.(ast.Stmt), body, label)
+	}
+	return nil, fmt.Errorf("not a switch statement")
+}
+
+// switchToIfOf analyzes the switch statement stmt, whose label (if
+// any) is given.
+func switchToIfOf(info *types.Info, stmt ast.Stmt, body *ast.BlockStmt, label string) (*switchToIf, error) {
+	sw := &switchToIf{stmt: stmt}
+	ncases := 0
+	for _, s := range body.List {
+		clause := s.(*ast.CaseClause)
+		sw.clauses = append(sw.clauses, clause)
+		if clause.List != nil {
+			ncases++
+		}
+		if n := len(clause.Body); n > 0 {
+			if branch, ok := clause.Body[n-1].(*ast.BranchStmt); ok && branch.Tok == token.FALLTHROUGH {
+				return nil, fmt.Errorf("switch contains a fallthrough statement")
+			}
+		}
+		for _, s := range clause.Body {
+			if breaksOut(s, label) {
+				return nil, fmt.Errorf("switch contains a break statement")
+			}
+		}
+	}
+	if ncases == 0 {
+		return nil, fmt.Errorf("switch has no cases")
+	}
+
+	switch stmt := stmt.(type) {
+	case *ast.SwitchStmt:
+		if stmt.Tag != nil {
+			if !isSideEffectFree(info, stmt.Tag) {
+				return nil, fmt.Errorf("switch tag may have side effects")
+			}
+			for _, clause := range sw.clauses {
+				for _, e := range clause.List {
+					if !isSideEffectFree(info, e) {
+						return nil, fmt.Errorf("case value %s may have side effects", types.ExprString(e))
+					}
+				}
+			}
+		}
+
+	case *ast.TypeSwitchStmt:
+		if stmt.Init != nil {
+			return nil, fmt.Errorf("type switch has an init statement")
+		}
+		x, v := typeSwitchOperands(stmt)
+		if !isSideEffectFree(info, x) {
+			return nil, fmt.Errorf("switch operand may have side effects")
+		}
+		// Each link of the chain declares ok (and perhaps v),
+		// which are in scope in all subsequent links.
+		var err error
+		ast.Inspect(x, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && (id.Name == "ok" || v != nil && id.Name == v.Name) {
+				err = fmt.Errorf("switch operand refers to %s, which would be shadowed", id.Name)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sw.used = make(map[*ast.CaseClause]bool)
+		for _, clause := range sw.clauses {
+			if len(clause.List) > 1 {
+				return nil, fmt.Errorf("case clause tests more than one type")
+			}
+			obj := info.Implicits[clause]
+			for _, s := range clause.Body {
+				ast.Inspect(s, func(n ast.Node) bool {
+					id, ok := n.(*ast.Ident)
+					if !ok {
+						return true
+					}
+					use := info.Uses[id]
+					if obj != nil && use == obj {
+						sw.used[clause] = true
+					}
+					if id.Name == "ok" && use != nil && !(s.Pos() <= use.Pos() && use.Pos() < s.End()) {
+						if v, ok := use.(*types.Var); ok && !v.IsField() {
+							err = fmt.Errorf("reference to ok would be shadowed")
+						}
+					}
+					return true
+				})
+			}
+			if err != nil {
+				return nil, err
+			}
+			if sw.used[clause] && (clause.List == nil || info.Types[clause.List[0]].IsNil()) {
+				return nil, fmt.Errorf("%s is used in a case without a type", v.Name)
+			}
+		}
+	}
+	return sw, nil
+}
+
+// typeSwitchOperands returns the operand x of the type switch
+// "switch v := x.(type)", and the variable v, if any.
+func typeSwitchOperands(stmt *ast.TypeSwitchStmt) (x ast.Expr, v *ast.Ident) {
+	switch assign := stmt.Assign.(type) {
+	case *ast.AssignStmt:
+		v = assign.Lhs[0].(*ast.Ident)
+		x = assign.Rhs[0].(*ast.TypeAssertExpr).X
+	case *ast.ExprStmt:
+		x = assign.X.(*ast.TypeAssertExpr).X
+	}
+	return x, v
+}
+
+// disjuncts returns the operands of the || expressions in cond.
+func disjuncts(cond ast.Expr) []ast.Expr {
+	if bin, ok := ast.Unparen(cond).(*ast.BinaryExpr); ok && bin.Op == token.LOR {
+		return append(disjuncts(bin.X), disjuncts(bin.Y)...)
+	}
+	return []ast.Expr{cond}
+}
+
+// sameConstant reports whether x and y are equal constant values.
+func sameConstant(x, y constant.Value) bool {
+	numeric := func(v constant.Value) bool {
+		k := v.Kind()
+		return k == constant.Int || k == constant.Float || k == constant.Complex
+	}
+	if x.Kind() != y.Kind() && !(numeric(x) && numeric(y)) ||
+		x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+		return false
+	}
+	return constant.Compare(x, token.EQL, y)
+}
+
+// isSideEffectFree reports whether the expression e has no side
+// effects and yields the same value each time it is evaluated, so
+// that evaluating it once is equivalent to evaluating it repeatedly.
+//
+// It is conservative: it rejects all calls other than conversions
+// and calls to pure built-in functions, channel receives, and
+// expressions (such as &T{}) that yield a fresh variable each time.
+func isSideEffectFree(info *types.Info, e ast.Expr) bool {
+	free := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+				break // conversion
+			}
+			if id, ok := ast.Unparen(n.Fun).(*ast.Ident); ok {
+				if b, ok := info.Uses[id].(*types.Builtin); ok {
+					switch b.Name() {
+					case "len", "cap":
+						// The length of a channel may change.
+						if len(n.Args) == 1 {
+							if _, ok := info.TypeOf(n.Args[0]).Underlying().(*types.Chan); !ok {
+								return true
+							}
+						}
+					case "complex", "real", "imag", "min", "max":
+						return true
+					}
+				}
+			}
+			free = false
+
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				free = false
+			} else if _, ok := ast.Unparen(n.X).(*ast.CompositeLit); ok && n.Op == token.AND {
+				free = false
+			}
+
+		case *ast.FuncLit:
+			free = false
+		}
+		return free
+	})
+	return free
+}
+
+// breaksOut reports whether n contains a break statement that would
+// terminate a statement enclosing n: either an unlabeled break not
+// nested within a for, switch, or select statement, or a break
+// whose target is the given label (if nonempty).
+func breaksOut(n ast.Node, label string) bool {
+	found := false
+	var visit func(n ast.Node, nested bool)
+	visit = func(n ast.Node, nested bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BranchStmt:
+				if n.Tok == token.BREAK && (n.Label == nil && !nested || n.Label != nil && n.Label.Name == label) {
+					found = true
+				}
+			case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				if !nested {
+					visit(n, true)
+					return false
+				}
+			case *ast.FuncLit:
+				return false // breaks cannot cross function boundaries
+			}
+			return !found
+		})
+	}
+	visit(n, false)
+	return found
+}
+
+// clauseText returns the text of the statements of a block or case
+// clause, from start to end, without trailing space.
+func clauseText(fset *token.FileSet, src []byte, start, end token.Pos) string {
+	text := string(src[safetoken.StartPosition(fset, start).Offset:safetoken.StartPosition(fset, end).Offset])
+	return strings.TrimRight(text, " \t\n")
+}
+
+// lineIndent returns the leading white space of the line containing pos.
+func lineIndent(fset *token.FileSet, src []byte, pos token.Pos) string {
+	offset := safetoken.StartPosition(fset, pos).Offset
+	lineStart := offset
+	for lineStart > 0 && src[lineStart-1] != '\n' {
+		lineStart--
+	}
+	line := src[lineStart:offset]
+	return string(line[:len(line)-len(strings.TrimLeft(string(line), " \t"))])
+}
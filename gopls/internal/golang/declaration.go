@@ -0,0 +1,122 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	goplsastutil "golang.org/x/tools/gopls/internal/util/astutil"
+	"golang.org/x/tools/internal/event"
+)
+
+// Declaration handles the textDocument/declaration request for Go files.
+//
+// In Go, a symbol is usually declared where it is defined, but some
+// declarations are connected to another place in the program:
+//   - a go:linkname directive refers to a function of another package;
+//   - a function declared without a body is implemented in assembly,
+//     or by a function of another package, through a go:linkname
+//     directive in either package;
+//   - a method of a concrete type satisfies methods of interfaces.
+//
+// At such places, Declaration reports the other side of the
+// connection. Elsewhere, it is equivalent to [Definition].
+func Declaration(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, position protocol.Position) ([]protocol.Location, error) {
+	ctx, done := event.Start(ctx, "golang.Declaration")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.PositionPos(position)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle the case where the cursor is in a linkname directive.
+	for _, group := range pgf.File.Comments {
+		for _, c := range group.List {
+			if c.Pos() <= pos && pos <= c.End() {
+				if _, pkgPath, name := linknameArgs(c.Text); pkgPath != "" {
+					return linknameLocation(ctx, snapshot, pkgPath, name)
+				}
+			}
+		}
+	}
+
+	// Handle the case where the cursor is on the name
+	// of a package-level function declaration.
+	for _, decl := range pgf.File.Decls {
+		if decl, ok := decl.(*ast.FuncDecl); ok &&
+			decl.Recv == nil &&
+			goplsastutil.NodeContains(decl.Name, pos) {
+			locs, err := funcDeclaration(ctx, snapshot, pkg, decl)
+			if err != nil || len(locs) > 0 {
+				return locs, err
+			}
+			break
+		}
+	}
+
+	// Handle the case where the cursor is on a concrete method
+	// by reporting the interface methods it satisfies.
+	if _, obj, _ := referencedObject(pkg, pgf, pos); obj != nil {
+		if fn, ok := obj.(*types.Func); ok {
+			if recv := fn.Signature().Recv(); recv != nil && !types.IsInterface(recv.Type()) {
+				locs, err := Implementation(ctx, snapshot, fh, position)
+				if err != nil || len(locs) > 0 {
+					return locs, err
+				}
+			}
+		}
+	}
+
+	return Definition(ctx, snapshot, fh, position)
+}
+
+// funcDeclaration returns the locations connected to the declaration
+// of a package-level function: the target of its go:linkname
+// directive, if any; otherwise its assembly implementation, if it has
+// no body; otherwise the go:linkname directives of other packages that
+// refer to it. It returns no locations if there are none.
+func funcDeclaration(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, decl *ast.FuncDecl) ([]protocol.Location, error) {
+	name := decl.Name.Name
+
+	// A directive of the package gives the function another name.
+	if pkgPath, target := localLinkname(pkg.CompiledGoFiles(), name); pkgPath != "" {
+		return linknameLocation(ctx, snapshot, pkgPath, target)
+	}
+
+	if decl.Body == nil {
+		if locs, err := nonGoDefinition(ctx, snapshot, pkg, name); err == nil {
+			return locs, nil
+		}
+	}
+
+	// A directive of another package gives it this name.
+	return linknameReferences(ctx, snapshot, pkg.Metadata().PkgPath, name)
+}
+
+// localLinkname returns the target of the go:linkname directive,
+// if any, among the given files whose local name is name.
+func localLinkname(pgfs []*parsego.File, name string) (PackagePath, string) {
+	for _, pgf := range pgfs {
+		for _, group := range pgf.File.Comments {
+			for _, c := range group.List {
+				if local, pkgPath, target := linknameArgs(c.Text); local == name && pkgPath != "" {
+					return pkgPath, target
+				}
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,417 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the "Encapsulate field" code action, which
+// unexports a struct field, declares accessor methods for it, and
+// rewrites all references to the field to use them.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+)
+
+// encapsulatedField returns the name and declaration of the struct
+// field whose declaration encloses the selection, if it is a field
+// that may be encapsulated: an exported, named, untagged field of a
+// package-level struct type. It also returns the declaration of the
+// type. It returns a nil name if there is none.
+func encapsulatedField(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*ast.Ident, *ast.Field, *ast.TypeSpec, *ast.GenDecl) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	for i, n := range path {
+		field, ok := n.(*ast.Field)
+		if !ok {
+			continue
+		}
+		// The field must be directly within a package-level type declaration.
+		if len(path) < i+6 {
+			return nil, nil, nil, nil
+		}
+		if _, ok := path[i+2].(*ast.StructType); !ok {
+			return nil, nil, nil, nil
+		}
+		spec, ok := path[i+3].(*ast.TypeSpec)
+		if !ok || spec.Assign.IsValid() {
+			return nil, nil, nil, nil
+		}
+		decl, ok := path[i+4].(*ast.GenDecl)
+		if !ok || path[i+5] != pgf.File {
+			return nil, nil, nil, nil
+		}
+		var id *ast.Ident
+		if name, ok := path[0].(*ast.Ident); ok && slices.Contains(field.Names, name) {
+			id = name
+		} else if len(field.Names) == 1 {
+			id = field.Names[0]
+		}
+		if id == nil || !id.IsExported() || field.Tag != nil {
+			return nil, nil, nil, nil
+		}
+		if _, ok := pkg.TypesInfo().Defs[id].(*types.Var); !ok {
+			return nil, nil, nil, nil
+		}
+		return id, field, spec, decl
+	}
+	return nil, nil, nil, nil
+}
+
+// unexportedName returns the unexported form of the exported name,
+// lowering the leading initialism as a whole: URLPath becomes urlPath.
+func unexportedName(name string) string {
+	runes := []rune(name)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) {
+		n-- // the last capital begins the next word
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// encapsulateField unexports the struct field whose declaration
+// encloses rng, declares a getter and a setter method for it after
+// the declaration of its type, and updates all references to the
+// field throughout the workspace to call them.
+//
+// Assignments x.F = v become calls x.SetF(v); other references x.F
+// become x.F(). Uses that require the field to be addressable, such
+// as x.F++, x.F += v, &x.F, or a call of a pointer method x.F.M(),
+// cannot be expressed using the accessors, so they are reported as
+// errors, even within the declaring package. Within that package,
+// composite literals T{F: v} and references through non-addressable
+// values refer to the unexported field directly.
+func encapsulateField(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	id, fieldDecl, spec, decl := encapsulatedField(pkg, pgf, start, end)
+	if id == nil {
+		return nil, fmt.Errorf("no exported struct field to encapsulate")
+	}
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
+	}
+	field := pkg.TypesInfo().Defs[id].(*types.Var)
+	named := pkg.TypesInfo().Defs[spec.Name].Type().(*types.Named)
+	var (
+		tname  = named.Obj().Name()
+		name   = field.Name()
+		fname  = unexportedName(name)
+		setter = "Set" + name
+	)
+	if token.IsKeyword(fname) {
+		return nil, fmt.Errorf("cannot encapsulate %s: the unexported name %s is a keyword", name, fname)
+	}
+	for _, n := range []string{fname, setter} {
+		if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, pkg.Types(), n); obj != nil {
+			return nil, fmt.Errorf("cannot encapsulate %s: type %s already has a field or method %s", name, tname, n)
+		}
+	}
+
+	// The accessors must not cause the type to implement other
+	// interfaces.
+	getterSig := types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewParam(token.NoPos, nil, "", field.Type())), false)
+	setterSig := types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewParam(token.NoPos, nil, "", field.Type())), nil, false)
+	if err := checkImplementations(ctx, snapshot, pkg, named, name, getterSig, true); err != nil {
+		return nil, err
+	}
+	if err := checkImplementations(ctx, snapshot, pkg, named, setter, setterSig, true); err != nil {
+		return nil, err
+	}
+
+	// Declare the accessors after the type, using the receiver name
+	// of its existing methods, if any.
+	recvName := strings.ToLower(tname[:1])
+	for i := 0; i < named.NumMethods(); i++ {
+		if mdecl := methodDecl(pkg, named.Method(i)); mdecl != nil && len(mdecl.Recv.List[0].Names) > 0 {
+			if n := mdecl.Recv.List[0].Names[0].Name; n != "_" {
+				recvName = n
+				break
+			}
+		}
+	}
+	recvType := tname
+	if tparams := spec.TypeParams; tparams != nil {
+		var names []string
+		for _, f := range tparams.List {
+			for _, n := range f.Names {
+				names = append(names, n.Name)
+			}
+		}
+		recvType += "[" + strings.Join(names, ", ") + "]"
+	}
+	paramName := fname
+	if paramName == recvName {
+		paramName = "v"
+	}
+	typeText, err := exprText(pgf, fieldDecl.Type)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\n\n// %s returns the value of the %s field.\n", name, fname)
+	fmt.Fprintf(&buf, "func (%s *%s) %s() %s {\n\treturn %s.%s\n}\n", recvName, recvType, name, typeText, recvName, fname)
+	fmt.Fprintf(&buf, "\n// %s sets the value of the %s field.\n", setter, fname)
+	fmt.Fprintf(&buf, "func (%s *%s) %s(%s %s) {\n\t%s.%s = %s\n}", recvName, recvType, setter, paramName, typeText, recvName, fname, paramName)
+	declEnd, err := safetoken.Offset(pgf.Tok, decl.End())
+	if err != nil {
+		return nil, err
+	}
+	edits := map[protocol.DocumentURI][]diff.Edit{
+		pgf.URI: {{Start: declEnd, End: declEnd, New: buf.String()}},
+	}
+
+	// Update the references in all packages that may refer to the field.
+	fieldPath, err := objectpath.For(field)
+	if err != nil {
+		return nil, bug.Errorf("no object path for field %s: %v", name, err)
+	}
+	pkgs, err := typeCheckReverseDependencies(ctx, snapshot, pgf.URI, true)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		seen    = make(map[token.Position]bool) // files of several package variants
+		closers = make(map[protocol.DocumentURI][]diff.Edit)
+	)
+	for _, p := range pkgs {
+		declPkg := p.DependencyTypes(PackagePath(field.Pkg().Path()))
+		if declPkg == nil {
+			continue
+		}
+		obj, err := objectpath.Object(declPkg, fieldPath)
+		if err != nil {
+			continue
+		}
+		info := p.TypesInfo()
+		samePkg := p.Types().Path() == field.Pkg().Path()
+		for _, refpgf := range p.CompiledGoFiles() {
+			var refs []*ast.Ident
+			ast.Inspect(refpgf.File, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.Ident:
+					if info.Uses[n] == obj || info.Defs[n] == obj {
+						refs = append(refs, n)
+					}
+				case *ast.CompositeLit:
+					// Unkeyed literals of the type cannot set an
+					// unexported field outside its package.
+					if !samePkg && len(n.Elts) > 0 && !is[*ast.KeyValueExpr](n.Elts[0]) {
+						if t, ok := types.Unalias(info.TypeOf(n)).(*types.Named); ok && t.Origin().Obj().Pkg() == declPkg && t.Origin().Obj().Name() == tname {
+							err = fmt.Errorf("cannot encapsulate %s: the unkeyed composite literal at %s would set an unexported field",
+								name, safetoken.StartPosition(p.FileSet(), n.Pos()))
+						}
+					}
+				}
+				return err == nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, ref := range refs {
+				posn := safetoken.StartPosition(p.FileSet(), ref.Pos())
+				if seen[posn] {
+					continue
+				}
+				seen[posn] = true
+
+				// edit adds an edit replacing the range [start, end).
+				edit := func(edits map[protocol.DocumentURI][]diff.Edit, start, end token.Pos, newText string) error {
+					startOffset, endOffset, err := safetoken.Offsets(refpgf.Tok, start, end)
+					if err != nil {
+						return err
+					}
+					edits[refpgf.URI] = append(edits[refpgf.URI], diff.Edit{Start: startOffset, End: endOffset, New: newText})
+					return nil
+				}
+				// inexpressible reports a use of the field that the
+				// accessor methods cannot express.
+				inexpressible := func(what ast.Node) error {
+					return fmt.Errorf("cannot encapsulate %s: %s at %s cannot be expressed using the accessor methods",
+						name, FormatNode(p.FileSet(), what), posn)
+				}
+				// direct refers to the unexported field itself,
+				// which is possible only within its package.
+				direct := func(what ast.Node) error {
+					if !samePkg {
+						return inexpressible(what)
+					}
+					return edit(edits, ref.Pos(), ref.End(), fname)
+				}
+
+				path, _ := astutil.PathEnclosingInterval(refpgf.File, ref.Pos(), ref.End())
+				switch parent := path[1].(type) {
+				case *ast.Field:
+					err = edit(edits, ref.Pos(), ref.End(), fname) // the declaration
+
+				case *ast.KeyValueExpr:
+					err = direct(parent) // composite literal T{F: v}
+
+				case *ast.SelectorExpr:
+					sel := parent
+					if assign, ok := path[2].(*ast.AssignStmt); ok && assign.Tok == token.ASSIGN &&
+						len(assign.Lhs) == 1 && len(assign.Rhs) == 1 && assign.Lhs[0] == sel {
+						// x.F = v => x.SetF(v)
+						if err = edit(edits, sel.Sel.Pos(), assign.Rhs[0].Pos(), setter+"("); err == nil {
+							err = edit(closers, assign.Rhs[0].End(), assign.Rhs[0].End(), ")")
+						}
+						break
+					}
+					if mustBeAddressable(info, path, 1) {
+						err = inexpressible(enclosingStmtOrExpr(path))
+						break
+					}
+					// The getter has a pointer receiver.
+					if seln := info.Selections[sel]; seln != nil && !seln.Indirect() && !info.Types[sel.X].Addressable() {
+						err = direct(sel)
+						break
+					}
+					// x.F => x.F()
+					err = edit(edits, sel.End(), sel.End(), "()")
+
+				default:
+					err = bug.Errorf("unexpected reference to field %s at %s", name, posn)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// An insertion of a closing parenthesis must follow the other
+	// insertions at the same offset, which belong to inner expressions.
+	for uri, fileClosers := range closers {
+		slices.Reverse(fileClosers)
+		edits[uri] = append(edits[uri], fileClosers...)
+	}
+	return diffEditsToDocumentChanges(ctx, snapshot, edits)
+}
+
+// mustBeAddressable reports whether the expression at path[i] must be
+// addressable in its context: because it is assigned or incremented,
+// its address is taken, explicitly or by a call of a pointer method,
+// or because it is a component of an array or struct value that must
+// itself be addressable.
+func mustBeAddressable(info *types.Info, path []ast.Node, i int) bool {
+	e := path[i]
+	for {
+		paren, ok := path[i+1].(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		e = paren
+		i++
+	}
+	isArray := func(e ast.Expr) bool {
+		_, ok := info.TypeOf(e).Underlying().(*types.Array)
+		return ok
+	}
+	switch parent := path[i+1].(type) {
+	case *ast.AssignStmt:
+		return slices.Contains(parent.Lhs, e.(ast.Expr))
+	case *ast.IncDecStmt:
+		return true
+	case *ast.UnaryExpr:
+		return parent.Op == token.AND
+	case *ast.RangeStmt:
+		return parent.Tok == token.ASSIGN && (parent.Key == e || parent.Value == e)
+	case *ast.SelectorExpr:
+		if parent.X != e {
+			return false
+		}
+		seln := info.Selections[parent]
+		if seln == nil {
+			return false
+		}
+		if is[*types.Pointer](info.TypeOf(parent.X).Underlying()) {
+			return false // x.F.G where F is a pointer
+		}
+		switch seln.Kind() {
+		case types.FieldVal:
+			return mustBeAddressable(info, path, i+1)
+		case types.MethodVal:
+			// A pointer method requires the address of its operand,
+			// unless it is promoted through an embedded pointer.
+			if !is[*types.Pointer](seln.Obj().(*types.Func).Signature().Recv().Type()) {
+				return false
+			}
+			idx := seln.Index()
+			t := info.TypeOf(parent.X)
+			for _, j := range idx[:len(idx)-1] {
+				f := t.Underlying().(*types.Struct).Field(j)
+				if is[*types.Pointer](f.Type().Underlying()) {
+					return false
+				}
+				t = f.Type()
+			}
+			return true
+		}
+	case *ast.IndexExpr:
+		return parent.X == e && isArray(parent.X) && mustBeAddressable(info, path, i+1)
+	case *ast.SliceExpr:
+		return parent.X == e && isArray(parent.X)
+	case *ast.CallExpr:
+		// unsafe.Offsetof requires a field selector.
+		if id, ok := ast.Unparen(parent.Fun).(*ast.SelectorExpr); ok {
+			if b, ok := info.Uses[id.Sel].(*types.Builtin); ok && b.Name() == "Offsetof" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enclosingStmtOrExpr returns the innermost statement enclosing
+// path[0], or the outermost expression if there is none.
+func enclosingStmtOrExpr(path []ast.Node) ast.Node {
+	var last ast.Node = path[0]
+	for _, n := range path {
+		switch n := n.(type) {
+		case ast.Stmt:
+			return n
+		case ast.Expr:
+			last = n
+		default:
+			return last
+		}
+	}
+	return last
+}
+
+// exprText returns the source text of the expression e of the file.
+func exprText(pgf *parsego.File, e ast.Expr) (string, error) {
+	start, end, err := safetoken.Offsets(pgf.Tok, e.Pos(), e.End())
+	if err != nil {
+		return "", err
+	}
+	return string(pgf.Src[start:end]), nil
+}
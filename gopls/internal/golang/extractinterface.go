@@ -0,0 +1,352 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the code actions "Extract interface from T" and
+// "Extract interface from T and use it". The interface is declared by
+// the same logic as the stubmethods fixes (see [insertDeclsAfter]).
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/golang/stubmethods"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+)
+
+// extractInterfaceInfo returns the interface that may be extracted at
+// the selection (see [stubmethods.GetExtractIfaceInfo]), with the doc
+// comments of its methods.
+func extractInterfaceInfo(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*stubmethods.ExtractIfaceInfo, error) {
+	ei := stubmethods.GetExtractIfaceInfo(pkg.FileSet(), pkg.TypesInfo(), pgf.File, start, end)
+	if ei == nil {
+		return nil, fmt.Errorf("no type or methods selected")
+	}
+	ei.Docs = make(map[*types.Func]*ast.CommentGroup)
+	for _, m := range ei.Methods {
+		if decl := methodDecl(pkg, m); decl != nil {
+			ei.Docs[m] = decl.Doc
+		}
+	}
+	return ei, nil
+}
+
+// extractInterfaceFixer returns a suggested fix to declare, after the
+// selected type, an interface whose methods are the selected methods
+// of the type.
+func extractInterfaceFixer(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*token.FileSet, *analysis.SuggestedFix, error) {
+	ei, err := extractInterfaceInfo(pkg, pgf, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	return insertDeclsAfter(ctx, snapshot, pkg.Metadata(), ei.Fset, ei.Concrete.Obj(), ei.Emit)
+}
+
+// extractInterfaceAndUse implements the "Extract interface and use
+// it" code action: as [extractInterfaceFixer], it declares the
+// interface, and it also replaces the type T, or *T, of each
+// parameter of a function and each struct field, throughout the
+// workspace, by the interface, wherever the parameter or field is
+// used only to call those methods or, for fields, assigned. The
+// parameters of methods, and of functions used other than in calls,
+// are left unchanged, as changing them could break assignments.
+func extractInterfaceAndUse(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	ei, err := extractInterfaceInfo(pkg, pgf, start, end)
+	if err != nil {
+		return nil, err
+	}
+	tname := ei.Concrete.Obj()
+	if errs := pkg.TypeErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("can't replace uses of %s in a package with type errors (e.g. %s)", tname.Name(), errs[0].Error())
+	}
+
+	fixFset, fix, err := insertDeclsAfter(ctx, snapshot, pkg.Metadata(), ei.Fset, tname, ei.Emit)
+	if err != nil {
+		return nil, err
+	}
+	edits := make(map[protocol.DocumentURI][]diff.Edit)
+	for _, edit := range fix.TextEdits {
+		tok := fixFset.File(edit.Pos)
+		start, end, err := safetoken.Offsets(tok, edit.Pos, edit.End)
+		if err != nil {
+			return nil, bug.Errorf("invalid edit: %v", err)
+		}
+		uri := protocol.URIFromPath(tok.Name())
+		edits[uri] = append(edits[uri], diff.Edit{Start: start, End: end, New: string(edit.NewText)})
+	}
+	uses, err := interfaceUses(ctx, snapshot, pkg, ei.Concrete, ei.Methods, ei.Name)
+	if err != nil {
+		return nil, err
+	}
+	for uri, fileEdits := range uses {
+		edits[uri] = append(edits[uri], fileEdits...)
+	}
+	return diffEditsToDocumentChanges(ctx, snapshot, edits)
+}
+
+// methodDecl returns the declaration of a method of the package,
+// or nil if it is not found.
+func methodDecl(pkg *cache.Package, m *types.Func) *ast.FuncDecl {
+	pgf, err := pkg.File(protocol.URIFromPath(pkg.FileSet().File(m.Pos()).Name()))
+	if err != nil {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(pgf.File, m.Pos(), m.Pos())
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// interfaceUses returns the edits that replace the type T, or *T, of
+// parameters and fields by the interface iname whose methods are the
+// specified methods of T, where possible (see [extractInterfaceAndUse]).
+//
+// Only the package of T and the packages that import it may refer to T.
+func interfaceUses(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, named *types.Named, methods []*types.Func, iname string) (map[protocol.DocumentURI][]diff.Edit, error) {
+	tname := named.Obj()
+	methodNames := make(map[string]bool)
+	pointerOnly := false // some method has a pointer receiver
+	for _, m := range methods {
+		methodNames[m.Name()] = true
+		if _, ok := m.Signature().Recv().Type().(*types.Pointer); ok {
+			pointerOnly = true
+		}
+	}
+
+	// isT reports whether t is T or *T.
+	isT := func(t types.Type) (ptr, ok bool) {
+		if p, isPtr := t.(*types.Pointer); isPtr {
+			t, ptr = p.Elem(), true
+		}
+		n, _ := types.Unalias(t).(*types.Named)
+		return ptr, n != nil && n.Obj().Name() == tname.Name() &&
+			n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == tname.Pkg().Path()
+	}
+
+	// Type-check the package of T and its importers.
+	rdeps, err := snapshot.ReverseDependencies(ctx, pkg.Metadata().ID, false)
+	if err != nil {
+		return nil, err
+	}
+	ids := []PackageID{pkg.Metadata().ID}
+	for id := range rdeps {
+		ids = append(ids, id)
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	// onlyUses reports whether every reference to the object declared
+	// at pos, in the workspace, satisfies the predicate, which is
+	// given the path to the referring identifier.
+	onlyUses := func(pgf *parsego.File, pos token.Pos, pred func(path []ast.Node) bool) (bool, error) {
+		fh, err := snapshot.ReadFile(ctx, pgf.URI)
+		if err != nil {
+			return false, err
+		}
+		pp, err := pgf.Mapper.PosPosition(pgf.Tok, pos)
+		if err != nil {
+			return false, err
+		}
+		refs, err := References(ctx, snapshot, fh, pp, false)
+		if err != nil {
+			return false, err
+		}
+		for _, ref := range refs {
+			fh, err := snapshot.ReadFile(ctx, ref.URI)
+			if err != nil {
+				return false, err
+			}
+			refPGF, err := snapshot.ParseGo(ctx, fh, parsego.Full)
+			if err != nil {
+				return false, err
+			}
+			start, end, err := refPGF.RangePos(ref.Range)
+			if err != nil {
+				return false, err
+			}
+			path, _ := astutil.PathEnclosingInterval(refPGF.File, start, end)
+			if _, ok := path[0].(*ast.Ident); !ok || !pred(path) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	// isCallee reports whether the identifier at path[0] is the
+	// callee of a call, possibly qualified.
+	isCallee := func(path []ast.Node) bool {
+		var fun ast.Node = path[0]
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+			fun, path = sel, path[1:]
+		}
+		call, ok := path[1].(*ast.CallExpr)
+		return ok && call.Fun == fun
+	}
+
+	// methodCall reports whether the expression at path[i] is the
+	// operand of a selection of one of the methods.
+	methodCall := func(path []ast.Node, i int) bool {
+		sel, ok := path[i+1].(*ast.SelectorExpr)
+		return ok && sel.X == path[i] && methodNames[sel.Sel.Name]
+	}
+
+	edits := make(map[protocol.DocumentURI][]diff.Edit)
+	seen := make(map[token.Position]bool) // type expressions of files of several packages
+	for _, p := range pkgs {
+		info := p.TypesInfo()
+		for _, pgf := range p.CompiledGoFiles() {
+			// replaceType adds the edit replacing the type T or *T
+			// of a field, if the predicate holds.
+			replaceType := func(field *ast.Field, pred func() (bool, error)) error {
+				tv, ok := info.Types[field.Type]
+				if !ok {
+					return nil
+				}
+				ptr, ok := isT(tv.Type)
+				if !ok || !ptr && pointerOnly {
+					return nil // not T, or T lacks some methods
+				}
+				posn := safetoken.StartPosition(p.FileSet(), field.Type.Pos())
+				if seen[posn] {
+					return nil
+				}
+				seen[posn] = true
+
+				// Qualify the interface as the type.
+				typ := field.Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+				newText := iname
+				switch typ := typ.(type) {
+				case *ast.Ident:
+				case *ast.SelectorExpr:
+					newText = typ.X.(*ast.Ident).Name + "." + iname
+				default:
+					return nil // e.g. (T)
+				}
+				if p.Types().Path() != tname.Pkg().Path() && !token.IsExported(iname) {
+					return nil // inaccessible
+				}
+
+				if ok, err := pred(); !ok || err != nil {
+					return err
+				}
+				start, end, err := safetoken.Offsets(pgf.Tok, field.Type.Pos(), field.Type.End())
+				if err != nil {
+					return err
+				}
+				edits[pgf.URI] = append(edits[pgf.URI], diff.Edit{Start: start, End: end, New: newText})
+				return nil
+			}
+
+			var err error
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				if err != nil {
+					return false
+				}
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					// Parameters of functions used only in calls.
+					if n.Recv != nil || n.Body == nil {
+						break
+					}
+					called := -1 // unknown
+					for _, field := range n.Type.Params.List {
+						err = replaceType(field, func() (bool, error) {
+							if called < 0 {
+								ok, err := onlyUses(pgf, n.Name.Pos(), isCallee)
+								if err != nil {
+									return false, err
+								}
+								called = 0
+								if ok {
+									called = 1
+								}
+							}
+							if called == 0 {
+								return false, nil
+							}
+							// Each parameter is used only to call methods.
+							for _, name := range field.Names {
+								obj := info.Defs[name]
+								for id, use := range info.Uses {
+									if use == obj {
+										path, _ := astutil.PathEnclosingInterval(pgf.File, id.Pos(), id.End())
+										if !methodCall(path, 0) {
+											return false, nil
+										}
+									}
+								}
+							}
+							return true, nil
+						})
+						if err != nil {
+							return false
+						}
+					}
+
+				case *ast.StructType:
+					// Fields used only to call methods, or assigned.
+					for _, field := range n.Fields.List {
+						err = replaceType(field, func() (bool, error) {
+							for _, name := range field.Names {
+								ok, err := onlyUses(pgf, name.Pos(), func(path []ast.Node) bool {
+									switch parent := path[1].(type) {
+									case *ast.KeyValueExpr: // T{f: x}
+										return parent.Key == path[0]
+									case *ast.SelectorExpr: // x.f
+										if assign, ok := path[2].(*ast.AssignStmt); ok && assign.Tok == token.ASSIGN {
+											return slices.Contains(assign.Lhs, ast.Expr(parent))
+										}
+										return parent.Sel == path[0] && methodCall(path, 1)
+									}
+									return false
+								})
+								if !ok || err != nil {
+									return false, err
+								}
+							}
+							return len(field.Names) > 0, nil // not embedded
+						})
+						if err != nil {
+							return false
+						}
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return edits, nil
+}
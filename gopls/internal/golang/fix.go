@@ -5,11 +5,15 @@
 package golang
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/token"
 	"go/types"
+	"slices"
+	"strings"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/gopls/internal/analysis/embeddirective"
@@ -20,6 +24,7 @@ import (
 	"golang.org/x/tools/gopls/internal/file"
 	"golang.org/x/tools/gopls/internal/protocol"
 	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/internal/diff"
 	"golang.org/x/tools/internal/imports"
 )
 
@@ -62,12 +67,24 @@ const (
 	fixExtractVariableAll      = "extract_variable_all"
 	fixExtractFunction         = "extract_function"
 	fixExtractMethod           = "extract_method"
+	fixExtractInterface        = "extract_interface"
+	fixExtractInterfaceAndUse  = "extract_interface_and_use"
 	fixInlineCall              = "inline_call"
+	fixInlineVariable          = "inline_variable"
+	fixInlineConstant          = "inline_constant"
 	fixInvertIfCondition       = "invert_if_condition"
+	fixIfChainToSwitch         = "if_chain_to_switch"
+	fixSwitchToIfChain         = "switch_to_if_chain"
+	fixAddTypeParam            = "add_type_param"
+	fixEncapsulateField        = "encapsulate_field"
+	fixFuncToMethod            = "func_to_method"
+	fixMethodToFunc            = "method_to_func"
 	fixSplitLines              = "split_lines"
 	fixJoinLines               = "join_lines"
 	fixCreateUndeclared        = "create_undeclared"
 	fixMissingInterfaceMethods = "stub_missing_interface_method"
+	fixDelegateMissingMethods  = "delegate_missing_interface_methods"
+	fixDelegateMethods         = "delegate_methods"
 	fixMissingCalledFunction   = "stub_missing_called_function"
 )
 
@@ -87,13 +104,24 @@ const (
 // SuggestedFix.Category field, or some other way to squirrel metadata
 // in the fix.
 func ApplyFix(ctx context.Context, fix string, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.DocumentChange, error) {
-	// This can't be expressed as an entry in the fixer table below
-	// because it operates in the protocol (not go/{token,ast}) domain.
+	// These can't be expressed as entries in the fixer table below
+	// because they operate in the protocol (not go/{token,ast}) domain.
 	// (Sigh; perhaps it was a mistake to factor out the
 	// NarrowestPackageForFile/RangePos/suggestedFixToEdits
 	// steps.)
-	if fix == unusedparams.FixCategory {
+	switch fix {
+	case unusedparams.FixCategory:
 		return removeParam(ctx, snapshot, fh, rng)
+	case fixExtractInterfaceAndUse:
+		return extractInterfaceAndUse(ctx, snapshot, fh, rng)
+	case fixAddTypeParam:
+		return addTypeParam(ctx, snapshot, fh, rng)
+	case fixEncapsulateField:
+		return encapsulateField(ctx, snapshot, fh, rng)
+	case fixFuncToMethod:
+		return convertFuncToMethod(ctx, snapshot, fh, rng)
+	case fixMethodToFunc:
+		return convertMethodToFunc(ctx, snapshot, fh, rng)
 	}
 
 	fixers := map[string]fixer{
@@ -108,12 +136,19 @@ func ApplyFix(ctx context.Context, fix string, snapshot *cache.Snapshot, fh file
 		fixExtractMethod:           singleFile(extractMethod),
 		fixExtractVariable:         singleFile(extractVariable),
 		fixExtractVariableAll:      singleFile(extractVariableAll),
+		fixExtractInterface:        extractInterfaceFixer,
 		fixInlineCall:              inlineCall,
+		fixInlineVariable:          inlineVariable,
+		fixInlineConstant:          inlineConstant,
 		fixInvertIfCondition:       singleFile(invertIfCondition),
+		fixIfChainToSwitch:         singleFile(ifChainToSwitch),
+		fixSwitchToIfChain:         singleFile(switchToIfChain),
 		fixSplitLines:              singleFile(splitLines),
 		fixJoinLines:               singleFile(joinLines),
 		fixCreateUndeclared:        singleFile(CreateUndeclared),
 		fixMissingInterfaceMethods: stubMissingInterfaceMethodsFixer,
+		fixDelegateMissingMethods:  delegateMissingInterfaceMethodsFixer,
+		fixDelegateMethods:         delegateMethodsFixer,
 		fixMissingCalledFunction:   stubMissingCalledFunctionFixer,
 	}
 	fixer, ok := fixers[fix]
@@ -138,6 +173,43 @@ func ApplyFix(ctx context.Context, fix string, snapshot *cache.Snapshot, fh file
 	return suggestedFixToDocumentChange(ctx, snapshot, fixFset, suggestion)
 }
 
+// diffEditsToDocumentChanges converts the edits of each file from diff
+// form into protocol form, in order of file URI. Insertions at the
+// same offset are applied in the order they appear. A file that was
+// formatted remains so, realigning, for example, struct fields whose
+// types were edited.
+func diffEditsToDocumentChanges(ctx context.Context, snapshot *cache.Snapshot, edits map[protocol.DocumentURI][]diff.Edit) ([]protocol.DocumentChange, error) {
+	var changes []protocol.DocumentChange
+	for uri, fileEdits := range edits {
+		fh, err := snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fh.Content()
+		if err != nil {
+			return nil, err
+		}
+		after, err := diff.ApplyBytes(content, fileEdits)
+		if err != nil {
+			return nil, bug.Errorf("conflicting edits in %s: %v", uri.Path(), err)
+		}
+		if formatted, err := format.Source(content); err == nil && bytes.Equal(formatted, content) {
+			if formatted, err := format.Source(after); err == nil {
+				after = formatted
+			}
+		}
+		textedits, err := protocol.EditsFromDiffEdits(protocol.NewMapper(uri, content), diff.Bytes(content, after))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, protocol.DocumentChangeEdit(fh, textedits))
+	}
+	slices.SortFunc(changes, func(x, y protocol.DocumentChange) int {
+		return strings.Compare(string(x.TextDocumentEdit.TextDocument.URI), string(y.TextDocumentEdit.TextDocument.URI))
+	})
+	return changes, nil
+}
+
 // suggestedFixToDocumentChange converts the suggestion's edits from analysis form into protocol form.
 func suggestedFixToDocumentChange(ctx context.Context, snapshot *cache.Snapshot, fset *token.FileSet, suggestion *analysis.SuggestedFix) ([]protocol.DocumentChange, error) {
 	type fileInfo struct {
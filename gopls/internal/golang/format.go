@@ -17,6 +17,7 @@ import (
 	"strings"
 	"text/scanner"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/gopls/internal/cache"
 	"golang.org/x/tools/gopls/internal/cache/parsego"
 	"golang.org/x/tools/gopls/internal/file"
@@ -43,15 +44,24 @@ func Format(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]pr
 	if err != nil {
 		return nil, err
 	}
+	formatted, err := formatFile(ctx, snapshot, fh, pgf)
+	if err != nil {
+		return nil, err
+	}
+	return computeTextEdits(ctx, pgf, formatted)
+}
+
+// formatFile returns the formatted content of the parsed file.
+func formatFile(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, pgf *parsego.File) (string, error) {
 	// Even if this file has parse errors, it might still be possible to format it.
 	// Using format.Node on an AST with errors may result in code being modified.
 	// Attempt to format the source of this file instead.
 	if pgf.ParseErr != nil {
 		formatted, err := formatSource(ctx, fh)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		return computeTextEdits(ctx, pgf, string(formatted))
+		return string(formatted), nil
 	}
 
 	// format.Node changes slightly from one release to another, so the version
@@ -61,38 +71,340 @@ func Format(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]pr
 	buf := &bytes.Buffer{}
 	fset := tokeninternal.FileSetFor(pgf.Tok)
 	if err := format.Node(buf, fset, pgf.File); err != nil {
+		return "", err
+	}
+	return gofumpt(ctx, snapshot, fh, buf.Bytes())
+}
+
+// gofumpt applies additional formatting, if any is supported, to the
+// gofmt-formatted content of the file. Currently, the only supported
+// additional formatter is gofumpt.
+func gofumpt(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, src []byte) (string, error) {
+	if !snapshot.Options().Gofumpt {
+		return string(src), nil
+	}
+
+	// gofumpt can customize formatting based on language version and module
+	// path, if available.
+	//
+	// Try to derive this information, but fall-back on the default behavior.
+	//
+	// TODO: under which circumstances can we fail to find module information?
+	// Can this, for example, result in inconsistent formatting across saves,
+	// due to pending calls to packages.Load?
+	var opts gofumptFormat.Options
+	meta, err := NarrowestMetadataForFile(ctx, snapshot, fh.URI())
+	if err == nil {
+		if mi := meta.Module; mi != nil {
+			if v := mi.GoVersion; v != "" {
+				opts.LangVersion = "go" + v
+			}
+			opts.ModulePath = mi.Path
+		}
+	}
+	b, err := gofumptFormat.Source(src, opts)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// RangeFormat formats the portions of a file denoted by the given
+// ranges, returning edits that affect only those portions.
+//
+// Each range is first widened to the complete lines of the innermost
+// statements or declarations that enclose its endpoints, so that
+// formatting a range that begins or ends in the middle of a statement
+// treats the statement as a unit. RangeFormat then formats the entire
+// file and keeps only the edits that touch a widened range.
+// If a range touches the package clause or import declarations, the
+// imports are also organized, as if by goimports, and the range is
+// widened to include them.
+func RangeFormat(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, ranges []protocol.Range) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "golang.RangeFormat")
+	defer done()
+
+	if IsGenerated(ctx, snapshot, fh.URI()) {
+		return nil, fmt.Errorf("can't format %q: file is generated", fh.URI().Path())
+	}
+
+	pgf, err := snapshot.ParseGo(ctx, fh, parsego.Full)
+	if err != nil {
 		return nil, err
 	}
-	formatted := buf.String()
-
-	// Apply additional formatting, if any is supported. Currently, the only
-	// supported additional formatter is gofumpt.
-	if snapshot.Options().Gofumpt {
-		// gofumpt can customize formatting based on language version and module
-		// path, if available.
-		//
-		// Try to derive this information, but fall-back on the default behavior.
-		//
-		// TODO: under which circumstances can we fail to find module information?
-		// Can this, for example, result in inconsistent formatting across saves,
-		// due to pending calls to packages.Load?
-		var opts gofumptFormat.Options
-		meta, err := NarrowestMetadataForFile(ctx, snapshot, fh.URI())
-		if err == nil {
-			if mi := meta.Module; mi != nil {
-				if v := mi.GoVersion; v != "" {
-					opts.LangVersion = "go" + v
-				}
-				opts.ModulePath = mi.Path
+
+	// Widen each range to complete lines of syntax.
+	type span struct{ start, end int }
+	var spans []span
+	fixImports := false
+	for _, rng := range ranges {
+		start, end, err := pgf.RangePos(rng)
+		if err != nil {
+			return nil, err
+		}
+		if pgf.ParseErr == nil {
+			// Organizing imports may affect the entire import section.
+			if importsEnd := importsEnd(pgf.File); start <= importsEnd {
+				fixImports = true
+				start = min(start, pgf.File.Package)
+				end = max(end, importsEnd)
 			}
 		}
-		b, err := gofumptFormat.Source(buf.Bytes(), opts)
+		start, end = formatRangePos(pgf, start, end)
+		startOffset, endOffset, err := safetoken.Offsets(pgf.Tok, start, end)
 		if err != nil {
 			return nil, err
 		}
-		formatted = string(b)
+		spans = append(spans, span{startOffset, endOffset})
 	}
-	return computeTextEdits(ctx, pgf, formatted)
+
+	var formatted string
+	if fixImports {
+		var out []byte
+		if err := snapshot.RunProcessEnvFunc(ctx, func(ctx context.Context, opts *imports.Options) error {
+			out, err = imports.Process(pgf.URI.Path(), pgf.Src, opts)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		formatted, err = gofumpt(ctx, snapshot, fh, out)
+	} else {
+		formatted, err = formatFile(ctx, snapshot, fh, pgf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the edits that touch some span.
+	var edits []diff.Edit
+	for _, edit := range diff.Strings(string(pgf.Src), formatted) {
+		for _, sp := range spans {
+			if edit.Start <= sp.end && edit.End >= sp.start {
+				edits = append(edits, edit)
+				break
+			}
+		}
+	}
+	return protocol.EditsFromDiffEdits(pgf.Mapper, edits)
+}
+
+// OnTypeFormat formats the code affected by typing the character ch
+// at (just before) the given position: the block or composite closed
+// by a '}', or the line terminated by a ';' or a newline. It formats
+// only the innermost statement (or top-level declaration) enclosing
+// that code, reindented relative to the line that opens its block,
+// and returns edits that lie entirely within that statement.
+// Unlike [Format], it does not apply gofumpt, which requires a
+// complete file.
+//
+// Since it is invoked on every keystroke of a trigger character,
+// OnTypeFormat reports no edits, rather than an error, for generated
+// files and files with syntax errors.
+func OnTypeFormat(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, pp protocol.Position, ch string) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "golang.OnTypeFormat")
+	defer done()
+
+	if IsGenerated(ctx, snapshot, fh.URI()) {
+		return nil, nil
+	}
+	pgf, err := snapshot.ParseGo(ctx, fh, parsego.Full)
+	if err != nil {
+		return nil, err
+	}
+	if pgf.ParseErr != nil {
+		return nil, nil // incomplete code, most likely
+	}
+	offset, err := pgf.Mapper.PositionOffset(pp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the typed character, which is the last occurrence of ch
+	// before the position, ignoring any intervening indentation
+	// that the client may have inserted after a newline.
+	i := offset
+	if ch == "\n" {
+		i = bytes.LastIndexByte(pgf.Src[:offset], '\n')
+		if i < 0 || strings.TrimLeft(string(pgf.Src[i+1:offset]), " \t") != "" {
+			return nil, nil
+		}
+	} else if i = i - len(ch); i < 0 || string(pgf.Src[i:offset]) != ch {
+		return nil, nil
+	}
+	pos, err := safetoken.Pos(pgf.Tok, i)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ch {
+	case "}":
+		// Check that the brace closes some node.
+		path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos+1)
+		closes := false
+		for _, n := range path {
+			if n.End() == pos+1 {
+				closes = true
+				break
+			}
+		}
+		if !closes {
+			return nil, nil // not a closing brace
+		}
+
+	case ";", "\n":
+		// Use the first code on the line that was just terminated.
+		if ch == "\n" {
+			if i == 0 {
+				return nil, nil
+			}
+			i-- // the end of the previous line
+		}
+		lineStart := bytes.LastIndexByte(pgf.Src[:i], '\n') + 1
+		indent := len(pgf.Src[lineStart:i]) - len(bytes.TrimLeft(pgf.Src[lineStart:i], " \t"))
+		pos, err = safetoken.Pos(pgf.Tok, lineStart+indent)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, nil
+	}
+
+	node, indent := onTypeFormatNode(pgf, pos)
+	if node == nil {
+		return nil, nil
+	}
+
+	// Format the complete lines of the node, as a list of
+	// statements or declarations whose first line has the
+	// correct indentation; format.Source indents the rest
+	// of the list to match.
+	start, end, err := safetoken.Offsets(pgf.Tok, node.Pos(), node.End())
+	if err != nil {
+		return nil, err
+	}
+	start = bytes.LastIndexByte(pgf.Src[:start], '\n') + 1
+	if nl := bytes.IndexByte(pgf.Src[end:], '\n'); nl >= 0 {
+		end += nl + 1
+	} else {
+		end = len(pgf.Src)
+	}
+	src := pgf.Src[start:end]
+	formatted, err := format.Source([]byte(indent + string(bytes.TrimLeft(src, " \t"))))
+	if err != nil {
+		return nil, nil // e.g. the lines include part of another statement
+	}
+	edits := diff.Strings(string(src), string(formatted))
+	for i := range edits {
+		edits[i].Start += start
+		edits[i].End += start
+	}
+	return protocol.EditsFromDiffEdits(pgf.Mapper, edits)
+}
+
+// onTypeFormatNode returns the innermost statement enclosing pos that
+// is an element of a statement list, or else the top-level declaration
+// enclosing pos, along with the indentation of its first line: one tab
+// more than that of the line containing the opening of its list.
+// It returns a nil node if pos is not within a declaration.
+func onTypeFormatNode(pgf *parsego.File, pos token.Pos) (ast.Node, string) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos)
+	for i, n := range path {
+		if i+1 == len(path) {
+			break
+		}
+		var open token.Pos
+		switch n.(type) {
+		case *ast.CaseClause, *ast.CommClause:
+			// Clauses are not elements of an ordinary statement list.
+			continue
+		case ast.Decl:
+			if _, ok := path[i+1].(*ast.File); ok {
+				return n, ""
+			}
+			continue
+		case ast.Stmt:
+			switch parent := path[i+1].(type) {
+			case *ast.BlockStmt:
+				open = parent.Lbrace
+			case *ast.CaseClause:
+				open = parent.Case
+			case *ast.CommClause:
+				open = parent.Case
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		offset, err := safetoken.Offset(pgf.Tok, open)
+		if err != nil {
+			return nil, ""
+		}
+		lineStart := bytes.LastIndexByte(pgf.Src[:offset], '\n') + 1
+		line := pgf.Src[lineStart:offset]
+		indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		return n, string(indent) + "\t"
+	}
+	return nil, ""
+}
+
+// formatRangePos widens the interval [start, end) so that each of its
+// endpoints lies at a line boundary outside the innermost statement,
+// declaration, spec, or field that encloses it.
+func formatRangePos(pgf *parsego.File, start, end token.Pos) (token.Pos, token.Pos) {
+	// A non-empty range ending at the start of a line
+	// (such as a selection of complete lines) excludes that line.
+	if end > start && safetoken.Position(pgf.Tok, end).Column == 1 {
+		end--
+	}
+	if pgf.ParseErr == nil {
+		if n := enclosingFormatNode(pgf.File, start); n != nil {
+			start = min(start, n.Pos())
+		}
+		if n := enclosingFormatNode(pgf.File, end); n != nil {
+			end = max(end, n.End())
+		}
+	}
+
+	// Widen to complete lines.
+	start = pgf.Tok.LineStart(safetoken.Line(pgf.Tok, start))
+	if line := safetoken.Line(pgf.Tok, end); line < pgf.Tok.LineCount() {
+		end = pgf.Tok.LineStart(line+1) - 1 // the newline
+	} else {
+		end = token.Pos(pgf.Tok.Base() + pgf.Tok.Size())
+	}
+	return start, end
+}
+
+// enclosingFormatNode returns the innermost statement, declaration,
+// spec, or field that encloses pos, or nil if there is none or pos
+// lies directly within a block or field list.
+func enclosingFormatNode(f *ast.File, pos token.Pos) ast.Node {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.BlockStmt, *ast.FieldList:
+			// The position lies between statements or fields;
+			// formatting the entire block would be excessive.
+			return nil
+		case ast.Stmt, ast.Decl, ast.Spec, *ast.Field:
+			return n
+		}
+	}
+	return nil
+}
+
+// importsEnd returns the end of the last import declaration of the
+// file, or of its package clause if it has no imports.
+func importsEnd(f *ast.File) token.Pos {
+	end := f.Name.End()
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			end = decl.End()
+		}
+	}
+	return end
 }
 
 func formatSource(ctx context.Context, fh file.Handle) ([]byte, error) {
@@ -1185,6 +1185,45 @@ func chooseDocComment(decl ast.Decl, spec ast.Spec, field *ast.Field) *ast.Comme
 	return nil
 }
 
+// DeprecatedNames returns the offsets within the file of the
+// identifiers it declares whose doc comments, as chosen by
+// [HoverDocForObject], mark them as deprecated.
+func DeprecatedNames(pgf *parsego.File) map[int]bool {
+	names := make(map[int]bool)
+	mark := func(doc *ast.CommentGroup, ids ...*ast.Ident) {
+		// The desired pattern is `^// Deprecated`, but the prefix has been removed.
+		if doc != nil && strings.HasPrefix(doc.Text(), "Deprecated") {
+			for _, id := range ids {
+				if offset, err := safetoken.Offset(pgf.Tok, id.Pos()); err == nil {
+					names[offset] = true
+				}
+			}
+		}
+	}
+	for _, decl := range pgf.File.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			mark(chooseDocComment(decl, nil, nil), decl.Name)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					mark(chooseDocComment(decl, spec, nil), spec.Names...)
+				case *ast.TypeSpec:
+					mark(chooseDocComment(decl, spec, nil), spec.Name)
+				}
+			}
+		}
+	}
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if field, ok := n.(*ast.Field); ok {
+			mark(chooseDocComment(nil, nil, field), field.Names...)
+		}
+		return true
+	})
+	return names
+}
+
 // parseFull fully parses the file corresponding to position pos (for
 // which fset provides file/line information).
 //
@@ -177,6 +177,8 @@ func implementations(ctx context.Context, snapshot *cache.Snapshot, fh file.Hand
 		return nil, fmt.Errorf("querying method sets: %v", err)
 	}
 
+	queryIsInterface := types.IsInterface(queryType)
+
 	// Search local and global packages in parallel.
 	var (
 		group  errgroup.Group
@@ -232,7 +234,14 @@ func implementations(ctx context.Context, snapshot *cache.Snapshot, fh file.Hand
 	for _, index := range indexes {
 		index := index
 		group.Go(func() error {
-			for _, res := range index.Search(key, queryMethod) {
+			for _, res := range index.Search(key, methodsets.Subtype|methodsets.Supertype, queryMethod) {
+				// Traditionally this feature doesn't report
+				// interface/interface elements of the relation.
+				// I think that's a mistake.
+				// TODO(adonovan): UX: change it, here and in the local implementation.
+				if res.IsInterface && queryIsInterface {
+					continue
+				}
 				loc := res.Location
 				// Map offsets to protocol.Locations in parallel (may involve I/O).
 				group.Go(func() error {
@@ -134,3 +134,145 @@ func logger(ctx context.Context, name string, verbose bool) func(format string,
 		return func(string, ...any) {}
 	}
 }
+
+// inlinableName returns the local variable or package constant
+// referenced or declared by the identifier at the selected range,
+// provided that it is declared (in the same package) with an
+// initializer.
+func inlinableName(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*ast.Ident, types.Object, error) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, fmt.Errorf("no identifier selected")
+	}
+	obj := pkg.TypesInfo().ObjectOf(id)
+	switch obj := obj.(type) {
+	case *types.Var:
+		// A local variable declared in this file.
+		if obj.IsField() || obj.Pkg() != pkg.Types() || obj.Parent() == obj.Pkg().Scope() ||
+			!(pgf.File.FileStart <= obj.Pos() && obj.Pos() < pgf.File.FileEnd) {
+			return nil, nil, fmt.Errorf("%s is not a local variable", id.Name)
+		}
+		declPath, _ := astutil.PathEnclosingInterval(pgf.File, obj.Pos(), obj.Pos())
+		switch parent := declPath[1].(type) {
+		case *ast.AssignStmt:
+			if parent.Tok == token.DEFINE && len(parent.Lhs) == 1 && len(parent.Rhs) == 1 {
+				return id, obj, nil
+			}
+		case *ast.ValueSpec:
+			if len(parent.Names) == 1 && len(parent.Values) == 1 {
+				return id, obj, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("%s is not declared with an initializer", id.Name)
+
+	case *types.Const:
+		if obj.Pkg() != pkg.Types() {
+			return nil, nil, fmt.Errorf("%s is declared in another package", id.Name)
+		}
+		return id, obj, nil
+	}
+	return nil, nil, fmt.Errorf("%s is not a variable or constant", id.Name)
+}
+
+// inlineVariable replaces each reference to the selected local
+// variable by its initializer, and deletes its declaration.
+func inlineVariable(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (_ *token.FileSet, _ *analysis.SuggestedFix, err error) {
+	_, obj, err := inlinableName(pkg, pgf, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, ok := obj.(*types.Var)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a local variable", obj.Name())
+	}
+
+	// As with inlineCall, report panics on ill-typed inputs as errors.
+	if len(pkg.ParseErrors())+len(pkg.TypeErrors()) > 0 {
+		defer func() {
+			if x := recover(); x != nil {
+				err = fmt.Errorf("inlining failed (%q), likely because inputs were ill-typed", x)
+			}
+		}()
+	}
+
+	logf := logger(ctx, "inliner", snapshot.Options().VerboseOutput)
+	res, err := inline.InlineVariable(pkg.FileSet(), pkg.TypesInfo(), pgf.File, pgf.Src, v, &inline.Options{Logf: logf})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg.FileSet(), &analysis.SuggestedFix{
+		Message:   fmt.Sprintf("inline variable %s", v.Name()),
+		TextEdits: diffToTextEdits(pgf.Tok, diff.Bytes(pgf.Src, res.Content)),
+	}, nil
+}
+
+// inlineConstant replaces each reference to the selected constant,
+// in all files of its package (including in-package tests), by its
+// initializer. A local constant's declaration is deleted.
+func inlineConstant(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (_ *token.FileSet, _ *analysis.SuggestedFix, err error) {
+	// Re-resolve the selection in the widest package,
+	// so that references in in-package tests are updated too.
+	startOffset, endOffset, err := safetoken.Offsets(pgf.Tok, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg, pgf, err = WidestPackageForFile(ctx, snapshot, pgf.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	start, end = pgf.Tok.Pos(startOffset), pgf.Tok.Pos(endOffset)
+
+	_, obj, err := inlinableName(pkg, pgf, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, ok := obj.(*types.Const)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a constant", obj.Name())
+	}
+	declPGF, err := pkg.File(protocol.URIFromPath(pkg.FileSet().File(c.Pos()).Name()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(pkg.ParseErrors())+len(pkg.TypeErrors()) > 0 {
+		defer func() {
+			if x := recover(); x != nil {
+				err = fmt.Errorf("inlining failed (%q), likely because inputs were ill-typed", x)
+			}
+		}()
+	}
+
+	logf := logger(ctx, "inliner", snapshot.Options().VerboseOutput)
+	var edits []analysis.TextEdit
+	for _, file := range pkg.CompiledGoFiles() {
+		if !usesObject(pkg.TypesInfo(), file.File, c) {
+			continue
+		}
+		res, err := inline.InlineConstant(pkg.FileSet(), pkg.TypesInfo(), declPGF.File, file.File, file.Src, c, &inline.Options{Logf: logf})
+		if err != nil {
+			return nil, nil, err
+		}
+		edits = append(edits, diffToTextEdits(file.Tok, diff.Bytes(file.Src, res.Content))...)
+	}
+	if len(edits) == 0 {
+		return nil, nil, fmt.Errorf("%s is not referenced", c.Name())
+	}
+	return pkg.FileSet(), &analysis.SuggestedFix{
+		Message:   fmt.Sprintf("inline constant %s", c.Name()),
+		TextEdits: edits,
+	}, nil
+}
+
+// usesObject reports whether file contains a reference to obj.
+func usesObject(info *types.Info, file *ast.File, obj types.Object) bool {
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
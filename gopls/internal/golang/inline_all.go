@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/token"
 	"go/types"
+	"slices"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/types/typeutil"
@@ -17,6 +19,8 @@ import (
 	"golang.org/x/tools/gopls/internal/cache/parsego"
 	"golang.org/x/tools/gopls/internal/protocol"
 	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
 	"golang.org/x/tools/internal/refactor/inline"
 )
 
@@ -44,7 +48,11 @@ import (
 //
 // The code below notes where are assumptions are made that only hold true in
 // the case of parameter removal (annotated with 'Assumption:')
-func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, origDecl *ast.FuncDecl, callee *inline.Callee, post func([]byte) []byte, opts *inline.Options) (map[protocol.DocumentURI][]byte, error) {
+//
+// References to the function other than calls, such as function values,
+// are rewritten by rewriteRef before any calls are inlined. If
+// rewriteRef is nil, such references cause inlineAllCalls to fail.
+func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, origDecl *ast.FuncDecl, callee *inline.Callee, post func([]byte) []byte, opts *inline.Options, rewriteRef refRewriter) (map[protocol.DocumentURI][]byte, error) {
 	// Collect references.
 	var refs []protocol.Location
 	{
@@ -103,6 +111,7 @@ func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pa
 		pkg   *cache.Package
 		pgf   *parsego.File
 		calls []*ast.CallExpr
+		edits []diff.Edit // rewritten non-call references
 	}
 
 	refsByFile := make(map[protocol.DocumentURI]*fileCalls)
@@ -127,18 +136,42 @@ func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pa
 		name, _ = path[0].(*ast.Ident)
 
 		// TODO(rfindley): handle method expressions correctly.
-		if _, ok := path[1].(*ast.SelectorExpr); ok {
-			call, _ = path[2].(*ast.CallExpr)
-		} else {
-			call, _ = path[1].(*ast.CallExpr)
+		fun := ast.Node(name)
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == name {
+			fun = sel
+		}
+		if i := slices.Index(path, fun); i >= 0 && i+1 < len(path) {
+			if c, ok := path[i+1].(*ast.CallExpr); ok && ast.Unparen(c.Fun) == fun {
+				call = c
+			}
 		}
 		if name == nil || call == nil {
-			// TODO(rfindley): handle this case with eta-abstraction:
-			// a reference to the target function f in a non-call position
-			//    use(f)
-			// is replaced by
-			//    use(func(...) { f(...) })
-			return nil, fmt.Errorf("cannot inline: found non-call function reference %v", ref)
+			if rewriteRef == nil || name == nil {
+				// TODO(rfindley): handle this case with eta-abstraction:
+				// a reference to the target function f in a non-call position
+				//    use(f)
+				// is replaced by
+				//    use(func(...) { f(...) })
+				return nil, fmt.Errorf("cannot inline: found non-call function reference %v", ref)
+			}
+			start, end, text, err := rewriteRef(refpkg, pgf, path)
+			if err != nil {
+				return nil, err
+			}
+			startOffset, endOffset, err := safetoken.Offsets(pgf.Tok, start, end)
+			if err != nil {
+				return nil, err
+			}
+			callInfo, ok := refsByFile[ref.URI]
+			if !ok {
+				callInfo = &fileCalls{
+					pkg: refpkg,
+					pgf: pgf,
+				}
+				refsByFile[ref.URI] = callInfo
+			}
+			callInfo.edits = append(callInfo.edits, diff.Edit{Start: startOffset, End: endOffset, New: text})
+			continue
 		}
 
 		// Heuristic: ignore references that overlap with type checker errors, as they may
@@ -195,6 +228,41 @@ func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pa
 			content = callInfo.pgf.Src
 		)
 
+		// collectCalls returns the calls to the target function in file.
+		collectCalls := func(file *ast.File, tinfo *types.Info) []*ast.CallExpr {
+			var calls []*ast.CallExpr
+			ast.Inspect(file, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					fn := typeutil.StaticCallee(tinfo, call)
+					if fn != nil && fn.Pkg().Path() == string(pkg.Metadata().PkgPath) && fn.Name() == origDecl.Name.Name {
+						calls = append(calls, call)
+					}
+				}
+				return true
+			})
+			return calls
+		}
+
+		// Rewrite the non-call references first, and find the calls anew.
+		if len(callInfo.edits) > 0 {
+			var err error
+			content, err = diff.ApplyBytes(content, callInfo.edits)
+			if err != nil {
+				return nil, bug.Errorf("rewriting references: %v", err)
+			}
+			if len(calls) > 0 {
+				file, err = parser.ParseFile(fset, uri.Path(), content, parser.ParseComments|parser.SkipObjectResolution)
+				if err != nil {
+					return nil, bug.Errorf("rewritten file failed to parse: %v", err)
+				}
+				tpkg, tinfo, err = reTypeCheck(func(string, ...any) {}, callInfo.pkg, map[protocol.DocumentURI]*ast.File{uri: file}, nil, allErrors)
+				if err != nil {
+					return nil, bug.Errorf("type checking after rewriting references failed: %v", err)
+				}
+				calls = collectCalls(file, tinfo)
+			}
+		}
+
 		// Check for overlapping calls (such as Foo(Foo())). We can't handle these
 		// because inlining may change the source order of the inner call with
 		// respect to the inlined outer call, and so the heuristic we use to find
@@ -256,22 +324,13 @@ func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pa
 			if opts != nil {
 				logf = opts.Logf
 			}
-			tpkg, tinfo, err = reTypeCheck(logf, callInfo.pkg, map[protocol.DocumentURI]*ast.File{uri: file}, true)
+			tpkg, tinfo, err = reTypeCheck(logf, callInfo.pkg, map[protocol.DocumentURI]*ast.File{uri: file}, nil, allErrors)
 			if err != nil {
 				return nil, bug.Errorf("type checking after inlining failed: %v", err)
 			}
 
 			// Collect calls to the target function in the modified declaration.
-			var calls2 []*ast.CallExpr
-			ast.Inspect(file, func(n ast.Node) bool {
-				if call, ok := n.(*ast.CallExpr); ok {
-					fn := typeutil.StaticCallee(tinfo, call)
-					if fn != nil && fn.Pkg().Path() == string(pkg.Metadata().PkgPath) && fn.Name() == origDecl.Name.Name {
-						calls2 = append(calls2, call)
-					}
-				}
-				return true
-			})
+			calls2 := collectCalls(file, tinfo)
 
 			// If the number of calls has increased, this process will never cease.
 			// If the number of calls has decreased, assume that inlining removed a
@@ -300,3 +359,9 @@ func inlineAllCalls(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Pa
 	}
 	return result, nil
 }
+
+// A refRewriter returns a textual replacement for the range from start
+// to end, for a reference to the target function of [inlineAllCalls]
+// that is not the operand of a call, given the path from the
+// reference to the root of the file pgf of package pkg.
+type refRewriter func(pkg *cache.Package, pgf *parsego.File, path []ast.Node) (start, end token.Pos, text string, _ error)
@@ -0,0 +1,135 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// InlineValues returns the inline values within the specified range
+// of the file, for a debugger stopped at the given location.
+//
+// Inline values are reported only within the innermost function
+// enclosing the stopping point. They are the references to local
+// variables, parameters, and named results that are in scope at the
+// stopping point, each reported as a variable lookup, and chains of
+// struct field selections based on them, such as x.f.g, each reported
+// as an expression to evaluate. Since a debugger evaluates these
+// expressions in the stopped frame, expressions that may have side
+// effects, such as function and method calls, are never reported,
+// nor are variables that are shadowed at the stopping point.
+func InlineValues(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng, stopped protocol.Range) ([]protocol.InlineValue, error) {
+	ctx, done := event.Start(ctx, "golang.InlineValues")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := pgf.PositionPos(stopped.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the innermost function enclosing the stopping point.
+	path, _ := astutil.PathEnclosingInterval(pgf.File, stop, stop)
+	var fn ast.Node
+	for _, n := range path {
+		if _, ok := n.(*ast.FuncDecl); ok {
+			fn = n
+			break
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			fn = n
+			break
+		}
+	}
+	if fn == nil {
+		return nil, nil // not stopped in a function
+	}
+
+	info := pkg.TypesInfo()
+	scope := pkg.Types().Scope().Innermost(stop)
+	if scope == nil {
+		return nil, nil
+	}
+
+	// isLocal reports whether id refers to a local variable,
+	// parameter, or result that is visible at the stopping point.
+	isLocal := func(id *ast.Ident) bool {
+		v, ok := info.ObjectOf(id).(*types.Var)
+		if !ok || v.Name() == "_" || v.IsField() || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+			return false
+		}
+		_, obj := scope.LookupParent(id.Name, stop)
+		return obj == v
+	}
+
+	// isFieldSelection reports whether e is a chain of struct field
+	// selections based on a visible local variable.
+	var isFieldSelection func(e ast.Expr) bool
+	isFieldSelection = func(e ast.Expr) bool {
+		switch e := e.(type) {
+		case *ast.Ident:
+			return isLocal(e)
+		case *ast.SelectorExpr:
+			sel, ok := info.Selections[e]
+			return ok && sel.Kind() == types.FieldVal && isFieldSelection(e.X)
+		}
+		return false
+	}
+
+	var values []protocol.InlineValue
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil || n.End() < start || n.Pos() > end {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if isFieldSelection(n) {
+				rng, err := pgf.NodeRange(n)
+				if err != nil {
+					return false
+				}
+				values = append(values, protocol.InlineValue{
+					Value: protocol.InlineValueEvaluatableExpression{
+						Range:      rng,
+						Expression: types.ExprString(n),
+					},
+				})
+				return false
+			}
+
+		case *ast.Ident:
+			if isLocal(n) {
+				rng, err := pgf.NodeRange(n)
+				if err != nil {
+					return false
+				}
+				values = append(values, protocol.InlineValue{
+					Value: protocol.InlineValueVariableLookup{
+						Range:               rng,
+						VariableName:        n.Name,
+						CaseSensitiveLookup: true,
+					},
+				})
+			}
+		}
+		return true
+	})
+	return values, nil
+}
@@ -5,6 +5,7 @@
 package golang
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -31,7 +32,13 @@ func linknameDefinition(ctx context.Context, snapshot *cache.Snapshot, m *protoc
 		return nil, ErrNoLinkname
 	}
 
-	_, pgf, pos, err := findLinkname(ctx, snapshot, PackagePath(pkgPath), name)
+	return linknameLocation(ctx, snapshot, PackagePath(pkgPath), name)
+}
+
+// linknameLocation returns the location of the declaration of the
+// object with the given linker name.
+func linknameLocation(ctx context.Context, snapshot *cache.Snapshot, pkgPath PackagePath, name string) ([]protocol.Location, error) {
+	_, pgf, pos, err := findLinkname(ctx, snapshot, pkgPath, name)
 	if err != nil {
 		return nil, fmt.Errorf("find linkname: %w", err)
 	}
@@ -42,6 +49,79 @@ func linknameDefinition(ctx context.Context, snapshot *cache.Snapshot, m *protoc
 	return []protocol.Location{loc}, nil
 }
 
+// linknameArgs returns the arguments of a two-argument go:linkname
+// directive, whose target is split into its package path and name.
+// If text is not such a directive, it returns "", "", "".
+func linknameArgs(text string) (local string, pkgPath PackagePath, name string) {
+	if !strings.HasPrefix(text, "//go:linkname ") {
+		return "", "", ""
+	}
+	// Trim a comment after the directive, as in parseLinkname.
+	if i := strings.LastIndex(text, "//"); i != 0 {
+		text = text[:i]
+	}
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return "", "", ""
+	}
+	dot := strings.LastIndexByte(fields[2], '.')
+	if dot < 0 {
+		return "", "", ""
+	}
+	return fields[1], PackagePath(fields[2][:dot]), fields[2][dot+1:]
+}
+
+// linknameReferences returns the locations of the local names of the
+// go:linkname directives, in workspace packages, whose target is the
+// object with the given linker name: the functions that provide its
+// body, or that obtain it.
+//
+// Directives in dependencies are not reported: scanning every file of
+// every dependency on each request would be too costly.
+func linknameReferences(ctx context.Context, snapshot *cache.Snapshot, pkgPath PackagePath, name string) ([]protocol.Location, error) {
+	metas, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata.RemoveIntermediateTestVariants(&metas)
+
+	// Scan the lines of each file, without parsing it,
+	// as the directive is rare and the files are many.
+	target := []byte(string(pkgPath) + "." + name)
+	seen := make(map[protocol.DocumentURI]bool)
+	var locs []protocol.Location
+	for _, mp := range metas {
+		for _, uri := range mp.CompiledGoFiles {
+			if seen[uri] {
+				continue // e.g. file of both a package and its test variant
+			}
+			seen[uri] = true
+			fh, err := snapshot.ReadFile(ctx, uri)
+			if err != nil {
+				return nil, err // context cancelled
+			}
+			content, err := fh.Content()
+			if err != nil || !bytes.Contains(content, target) {
+				continue
+			}
+			for offset, rest := 0, content; len(rest) > 0; {
+				line, next, _ := bytes.Cut(rest, []byte("\n"))
+				if local, p, n := linknameArgs(string(line)); p == pkgPath && n == name {
+					start := offset + bytes.Index(line[len("//go:linkname"):], []byte(local)) + len("//go:linkname")
+					loc, err := protocol.NewMapper(uri, content).OffsetLocation(start, start+len(local))
+					if err != nil {
+						return nil, err
+					}
+					locs = append(locs, loc)
+				}
+				offset += len(rest) - len(next)
+				rest = next
+			}
+		}
+	}
+	return locs, nil
+}
+
 // parseLinkname attempts to parse a go:linkname declaration at the given pos.
 // If successful, it returns
 // - package path referenced
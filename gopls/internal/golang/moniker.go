@@ -0,0 +1,163 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// MonikerScheme is the scheme of the monikers reported by gopls.
+const MonikerScheme = "gomod"
+
+// Monikers returns the moniker of the symbol referred to at the given
+// position, if it has one.
+//
+// A moniker identifies a symbol across workspaces and repositories.
+// Its identifier has the form
+//
+//	module[@version] package#objectpath
+//
+// where module and version identify the module that declares the
+// symbol, package is the symbol's package path, and objectpath is the
+// symbol's [objectpath.Path] within its package. For a package name,
+// the "#objectpath" suffix is omitted. The module of a standard
+// package is "std", and its version that of the Go toolchain.
+//
+// Only a moniker whose module has a version is globally unique; the
+// moniker of a symbol of a workspace module, or of a package without
+// module information (in which case "module[@version]" is "_"), is
+// unique only within the project.
+//
+// Only symbols that are reachable from the exported declarations of
+// their package have an object path, and thus a moniker; local
+// variables, labels, unexported package-level declarations, and so on
+// do not.
+func Monikers(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, pp protocol.Position) ([]protocol.Moniker, error) {
+	ctx, done := event.Start(ctx, "golang.Monikers")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.PositionPos(pp)
+	if err != nil {
+		return nil, err
+	}
+	_, obj, _ := referencedObject(pkg, pgf, pos)
+	if obj == nil {
+		return nil, nil
+	}
+
+	// Compute the package path and object path of the symbol.
+	var (
+		declPkg *types.Package
+		objPath objectpath.Path
+	)
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		declPkg = pkgName.Imported()
+	} else {
+		declPkg = obj.Pkg()
+		if declPkg == nil {
+			return nil, nil // built-in
+		}
+		objPath, err = objectpath.For(obj)
+		if err != nil {
+			return nil, nil // not reachable from an exported declaration
+		}
+	}
+
+	// Find the module of the declaring package.
+	mp := pkg.Metadata()
+	if declPkg != pkg.Types() {
+		mp, err = packageMetadata(snapshot, pkg.Metadata(), PackagePath(declPkg.Path()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	kind := protocol.Import
+	if declPkg == pkg.Types() {
+		kind = protocol.Local
+		if obj.Exported() {
+			kind = protocol.Export
+		}
+	}
+	identifier, unique := monikerIdentifier(snapshot, mp, objPath)
+	return []protocol.Moniker{{
+		Scheme:     MonikerScheme,
+		Identifier: identifier,
+		Unique:     unique,
+		Kind:       &kind,
+	}}, nil
+}
+
+// monikerIdentifier returns the identifier of the moniker for the
+// object with the specified path in package mp, and its uniqueness.
+// See [Monikers].
+func monikerIdentifier(snapshot *cache.Snapshot, mp *metadata.Package, objPath objectpath.Path) (string, protocol.UniquenessLevel) {
+	module, version := "_", ""
+	if mod := mp.Module; mod != nil {
+		module, version = mod.Path, mod.Version
+	} else if isStandardPackage(snapshot, mp) {
+		module, version = "std", snapshot.View().GoVersionString()
+	}
+	var buf strings.Builder
+	buf.WriteString(module)
+	unique := protocol.Project
+	if version != "" {
+		fmt.Fprintf(&buf, "@%s", version)
+		unique = protocol.Global
+	}
+	fmt.Fprintf(&buf, " %s", mp.PkgPath)
+	if objPath != "" {
+		fmt.Fprintf(&buf, "#%s", objPath)
+	}
+	return buf.String(), unique
+}
+
+// isStandardPackage reports whether mp is a package of the standard
+// library, that is, one whose files lie in $GOROOT/src.
+func isStandardPackage(snapshot *cache.Snapshot, mp *metadata.Package) bool {
+	goroot := snapshot.View().Folder().Env.GOROOT
+	dir := packageDir(mp)
+	return goroot != "" && dir != "" && pathEncloses(filepath.Join(goroot, "src"), dir)
+}
+
+// packageMetadata returns the metadata for the package with the
+// specified path among the transitive dependencies of from.
+func packageMetadata(snapshot *cache.Snapshot, from *metadata.Package, path PackagePath) (*metadata.Package, error) {
+	seen := make(map[PackageID]bool)
+	queue := []*metadata.Package{from}
+	for len(queue) > 0 {
+		mp := queue[0]
+		queue = queue[1:]
+		if id, ok := mp.DepsByPkgPath[path]; ok {
+			if dep := snapshot.Metadata(id); dep != nil {
+				return dep, nil
+			}
+		}
+		for _, id := range mp.DepsByPkgPath {
+			if !seen[id] {
+				seen[id] = true
+				if dep := snapshot.Metadata(id); dep != nil {
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no metadata for package %q", path)
+}
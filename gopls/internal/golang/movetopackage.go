@@ -0,0 +1,744 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the code action "Move declarations to another package".
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/bug"
+	"golang.org/x/tools/gopls/internal/util/moremaps"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/imports"
+	"golang.org/x/tools/internal/typesinternal"
+)
+
+// canMoveToPackage reports whether the code in the given range can be
+// moved to another package.
+func canMoveToPackage(pgf *parsego.File, start, end token.Pos) bool {
+	return !strings.HasSuffix(pgf.URI.Path(), "_test.go") && canExtractToNewFile(pgf, start, end)
+}
+
+// MoveDestinations returns the candidate destinations of the
+// declarations selected in the file, for the user to choose from: a new
+// package, beneath the current one, named after the first declaration,
+// followed by the packages of the same module, nearest first.
+func MoveDestinations(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]PackagePath, error) {
+	mp, err := NarrowestMetadataForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	pgf, err := snapshot.ParseGo(ctx, fh, parsego.Full)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	_, _, firstSymbol, ok := selectedToplevelDecls(pgf, start, end)
+	if !ok {
+		return nil, fmt.Errorf("no declarations selected")
+	}
+
+	const maxDestinations = 5
+	var dsts []PackagePath
+	if name := dirPackageName(firstSymbol); name != "" {
+		dsts = append(dsts, mp.PkgPath+"/"+PackagePath(name))
+	}
+	wsmeta, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var others []PackagePath
+	for _, other := range wsmeta {
+		if other.ForTest == "" &&
+			other.Name != "main" &&
+			other.PkgPath != mp.PkgPath &&
+			!slices.Contains(dsts, other.PkgPath) &&
+			!metadata.IsCommandLineArguments(other.ID) &&
+			sameModule(mp, other) {
+			others = append(others, other.PkgPath)
+		}
+	}
+	// Order the packages by the length of the path prefix
+	// they share with the current package.
+	common := func(p PackagePath) int {
+		x, y := strings.Split(string(mp.PkgPath), "/"), strings.Split(string(p), "/")
+		n := 0
+		for n < len(x) && n < len(y) && x[n] == y[n] {
+			n++
+		}
+		return n
+	}
+	slices.SortFunc(others, func(x, y PackagePath) int {
+		if cx, cy := common(x), common(y); cx != cy {
+			return cy - cx
+		}
+		return strings.Compare(string(x), string(y))
+	})
+	others = slices.Compact(others) // packages may belong to several views
+	dsts = append(dsts, others[:min(len(others), maxDestinations-len(dsts))]...)
+	return dsts, nil
+}
+
+// sameModule reports whether two packages belong to the same module.
+func sameModule(x, y *metadata.Package) bool {
+	return x.Module != nil && y.Module != nil && x.Module.Path == y.Module.Path
+}
+
+// MoveToPackage moves the selected top-level declarations to the
+// package with the specified path, which is created, in a directory of
+// the module of the current package, if it does not exist. A path
+// beginning with "./" or "../" is relative to the current package. It updates
+// the references to the declarations throughout the workspace, and the
+// references of the declarations to the rest of their package.
+//
+// It refuses to move declarations that refer, or are referred to
+// from outside the selection, by unexported names of the current
+// package; methods without their type; and declarations whose move
+// would create an import cycle.
+func MoveToPackage(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range, dstPath PackagePath) ([]protocol.DocumentChange, error) {
+	ctx, done := event.Start(ctx, "golang.MoveToPackage")
+	defer done()
+
+	return moveToPackage(ctx, snapshot, fh, rng, dstPath, "")
+}
+
+// moveToPackage implements [MoveToPackage]. If inPlaceName is set, the
+// range is ignored and all the declarations of the file are moved,
+// but rather than creating a new file, the file is edited in place,
+// with inPlaceName as its package name, as it is about to be moved
+// into the directory of the destination package (see [mover.moveFile]).
+// In that case, it returns no changes if the file declares nothing.
+func moveToPackage(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range, dstPath PackagePath, inPlaceName string) ([]protocol.DocumentChange, error) {
+	inPlace := inPlaceName != ""
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(pgf.URI.Path(), "_test.go") {
+		return nil, fmt.Errorf("can't move declarations of a test file")
+	}
+	if errs := pkg.TypeErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("can't move declarations of a package with type errors (e.g. %s)", errs[0].Error())
+	}
+	srcMeta := pkg.Metadata()
+	srcPath, srcName := srcMeta.PkgPath, string(srcMeta.Name)
+	if s := string(dstPath); s == "." || s == ".." || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
+		dstPath = PackagePath(path.Join(string(srcPath), s))
+	}
+	if dstPath == srcPath {
+		return nil, fmt.Errorf("declarations are already in package %s", dstPath)
+	}
+
+	var start, end token.Pos
+	if inPlace {
+		// Select all the declarations that follow the imports.
+		for _, decl := range pgf.File.Decls {
+			if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+				continue
+			}
+			if !start.IsValid() {
+				start = decl.Pos()
+			}
+			end = decl.End()
+		}
+		if !start.IsValid() {
+			return nil, nil // nothing to move
+		}
+	} else {
+		start, end, err = pgf.RangePos(rng)
+		if err != nil {
+			return nil, err
+		}
+	}
+	start, end, firstSymbol, ok := selectedToplevelDecls(pgf, start, end)
+	if !ok {
+		return nil, fmt.Errorf("no declarations selected")
+	}
+	// Select trailing empty lines, which are deleted too.
+	startOff, endOff, err := safetoken.Offsets(pgf.Tok, start, end)
+	if err != nil {
+		return nil, err
+	}
+	rest := pgf.Src[endOff:]
+	deleteEndOff := endOff + len(rest) - len(bytes.TrimLeft(rest, " \t\n"))
+
+	// moved reports whether the position, in the given file set,
+	// is within the selected declarations. It applies to the
+	// syntax of all the variants of the current package.
+	moved := func(fset *token.FileSet, pos token.Pos) bool {
+		posn := safetoken.StartPosition(fset, pos)
+		return posn.Filename == pgf.URI.Path() && startOff <= posn.Offset && posn.Offset < endOff
+	}
+
+	// Identify the moved package-level declarations,
+	// checking that methods move with their type.
+	info := pkg.TypesInfo()
+	scope := pkg.Types().Scope()
+	movedNames := make(map[string]bool)
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !moved(pkg.FileSet(), obj.Pos()) {
+			continue
+		}
+		movedNames[name] = true
+		if tname, ok := obj.(*types.TypeName); ok && !tname.IsAlias() {
+			if named, ok := tname.Type().(*types.Named); ok {
+				for i := range named.NumMethods() {
+					m := named.Method(i)
+					if !moved(pkg.FileSet(), m.Pos()) {
+						return nil, fmt.Errorf("method %s.%s must be moved along with its type", name, m.Name())
+					}
+				}
+			}
+		}
+	}
+	for _, decl := range pgf.File.Decls {
+		if decl, ok := decl.(*ast.FuncDecl); ok && decl.Recv != nil && moved(pkg.FileSet(), decl.Pos()) {
+			if fn, ok := info.Defs[decl.Name].(*types.Func); ok {
+				if _, named := typesinternal.ReceiverNamed(fn.Signature().Recv()); named != nil && !moved(pkg.FileSet(), named.Obj().Pos()) {
+					return nil, fmt.Errorf("method %s.%s can't be moved without its type", named.Obj().Name(), fn.Name())
+				}
+			}
+		}
+	}
+
+	// Resolve the destination package.
+	var (
+		dstMeta *metadata.Package
+		dstName string
+		dstDir  string
+	)
+	for _, mp := range snapshot.MetadataGraph().Packages {
+		if mp.PkgPath == dstPath && mp.ForTest == "" && len(mp.CompiledGoFiles) > 0 {
+			dstMeta = mp
+			break
+		}
+	}
+	if dstMeta != nil {
+		if !sameModule(srcMeta, dstMeta) && (dstMeta.Module == nil || !dstMeta.Module.Main) {
+			return nil, fmt.Errorf("package %s is not in the workspace", dstPath)
+		}
+		dstName = string(dstMeta.Name)
+		dstDir = dstMeta.CompiledGoFiles[0].DirPath()
+	} else {
+		mod := srcMeta.Module
+		if mod == nil || !strings.HasPrefix(string(dstPath), mod.Path+"/") {
+			return nil, fmt.Errorf("package %s does not exist, and is not in the module of package %s", dstPath, srcPath)
+		}
+		if err := module.CheckImportPath(string(dstPath)); err != nil {
+			return nil, fmt.Errorf("invalid destination package: %v", err)
+		}
+		dstName = inPlaceName
+		if dstName == "" {
+			dstName = dirPackageName(path.Base(string(dstPath)))
+		}
+		if dstName == "" {
+			return nil, fmt.Errorf("cannot choose a package name for %s", dstPath)
+		}
+		dstDir = filepath.Join(mod.Dir, filepath.FromSlash(strings.TrimPrefix(string(dstPath), mod.Path+"/")))
+	}
+	if dstMeta != nil {
+		dstPkgs, err := snapshot.TypeCheck(ctx, dstMeta.ID)
+		if err != nil {
+			return nil, err
+		}
+		for name := range movedNames {
+			if dstPkgs[0].Types().Scope().Lookup(name) != nil {
+				return nil, fmt.Errorf("package %s already declares %s", dstPath, name)
+			}
+		}
+	}
+
+	// Compute the edits of the moved declarations: qualify their
+	// references to the rest of the current package, and unqualify
+	// their references to the destination package.
+	var (
+		movedEdits []diff.Edit // offsets relative to startOff
+		needSrc    bool        // moved declarations refer to the current package
+		droppedDst bool        // some qualified references to the destination were removed
+		refErr     error
+	)
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		if refErr != nil || n == nil || !posRangeIntersects(start, end, n.Pos(), n.End()) {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if pkgName, ok := info.Uses[x].(*types.PkgName); ok {
+					if PackagePath(pkgName.Imported().Path()) == dstPath {
+						movedEdits = append(movedEdits, diff.Edit{
+							Start: safetoken.StartPosition(pkg.FileSet(), n.Pos()).Offset - startOff,
+							End:   safetoken.StartPosition(pkg.FileSet(), n.Sel.Pos()).Offset - startOff,
+						})
+						droppedDst = true
+					}
+					return false
+				}
+			}
+		case *ast.Ident:
+			obj := info.Uses[n]
+			if obj == nil || obj.Pkg() != pkg.Types() || moved(pkg.FileSet(), obj.Pos()) {
+				break
+			}
+			if _, ok := obj.(*types.PkgName); ok {
+				break
+			}
+			if !obj.Exported() {
+				refErr = fmt.Errorf("the declarations refer to unexported %s of package %s", obj.Name(), srcPath)
+				break
+			}
+			if obj.Parent() == scope {
+				needSrc = true
+				off := safetoken.StartPosition(pkg.FileSet(), n.Pos()).Offset - startOff
+				movedEdits = append(movedEdits, diff.Edit{Start: off, End: off, New: srcName + "."})
+			}
+		}
+		return true
+	})
+	if refErr != nil {
+		return nil, refErr
+	}
+	if needSrc && srcName == "main" {
+		return nil, fmt.Errorf("the declarations refer to package main, which cannot be imported")
+	}
+
+	// The imports of the moved declarations.
+	adds, deletes, err := findImportEdits(pgf.File, info, start, end)
+	if err != nil {
+		return nil, err
+	}
+	dstDeps := make(map[PackagePath]bool) // new dependencies of the destination
+	if needSrc {
+		dstDeps[srcPath] = true
+	}
+	var imports []*ast.ImportSpec
+	for _, spec := range adds {
+		if pkgName := info.PkgNameOf(spec); pkgName != nil {
+			if path := PackagePath(pkgName.Imported().Path()); path != dstPath {
+				dstDeps[path] = true
+				imports = append(imports, spec)
+			}
+		}
+	}
+
+	// Type-check the variants of the current package and their importers,
+	// which are the only packages that may refer to the declarations.
+	variants, err := snapshot.MetadataForFile(ctx, pgf.URI)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[PackageID]bool)
+	for _, mp := range variants {
+		ids[mp.ID] = true
+		rdeps, err := snapshot.ReverseDependencies(ctx, mp.ID, false)
+		if err != nil {
+			return nil, err
+		}
+		for id := range rdeps {
+			ids[id] = true
+		}
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, moremaps.KeySlice(ids)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute the edits of the references to the moved declarations.
+	type fileEdits struct {
+		edits      []diff.Edit
+		deletes    []*ast.ImportSpec // imports to delete
+		needDst    bool              // the file must import the destination
+		droppedSrc bool              // some qualified references to the current package were removed
+		keepsSrc   bool              // some qualified references to the current package remain
+	}
+	files := make(map[protocol.DocumentURI]*fileEdits)
+	if inPlace {
+		// Edit the moved declarations and the package clause.
+		var edits []diff.Edit
+		for _, edit := range movedEdits {
+			edit.Start += startOff
+			edit.End += startOff
+			edits = append(edits, edit)
+		}
+		if pgf.File.Name.Name != dstName {
+			nameStart, nameEnd, err := safetoken.Offsets(pgf.Tok, pgf.File.Name.Pos(), pgf.File.Name.End())
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, diff.Edit{Start: nameStart, End: nameEnd, New: dstName})
+		}
+		files[pgf.URI] = &fileEdits{edits: edits}
+	} else {
+		files[pgf.URI] = &fileEdits{
+			edits:   []diff.Edit{{Start: startOff, End: deleteEndOff}},
+			deletes: deletes,
+		}
+	}
+	referrers := make(map[PackagePath]bool) // packages that will import the destination
+	visited := make(map[protocol.DocumentURI]bool)
+	var (
+		dstID       PackageID // the destination package, if it exists
+		dstKeepsSrc bool      // the destination still refers to the current package
+		srcKeepsDst bool      // the current package still refers to the destination
+	)
+	if dstMeta != nil {
+		dstID = dstMeta.ID
+	}
+	for _, p := range pkgs {
+		pkgPath := p.Metadata().PkgPath
+		isSrc := pkgPath == srcPath
+		for _, refPGF := range p.CompiledGoFiles() {
+			fe := files[refPGF.URI]
+			if refPGF.URI == pgf.URI {
+				if p.Metadata().ID != srcMeta.ID {
+					continue // another variant of the current package
+				}
+			} else if fe != nil || visited[refPGF.URI] {
+				continue
+			} else {
+				fe = new(fileEdits)
+			}
+			visited[refPGF.URI] = true
+			pinfo := p.TypesInfo()
+			if p.Metadata().ID == dstID {
+				for _, spec := range refPGF.File.Imports {
+					if PackagePath(metadata.UnquoteImportPath(spec)) == srcPath && spec.Name != nil && spec.Name.Name == "_" {
+						dstKeepsSrc = true
+					}
+				}
+			}
+			var err error
+			ast.Inspect(refPGF.File, func(n ast.Node) bool {
+				if err != nil || n == nil {
+					return false
+				}
+				if refPGF == pgf && posRangeContains(start, end, n.Pos(), n.End()) {
+					return false // within the moved declarations
+				}
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					x, ok := n.X.(*ast.Ident)
+					if !ok {
+						break
+					}
+					pkgName, ok := pinfo.Uses[x].(*types.PkgName)
+					if !ok {
+						break
+					}
+					if PackagePath(pkgName.Imported().Path()) == dstPath && p.Metadata().ID == srcMeta.ID {
+						srcKeepsDst = true
+					}
+					if PackagePath(pkgName.Imported().Path()) != srcPath {
+						break
+					}
+					if !movedNames[n.Sel.Name] {
+						fe.keepsSrc = true
+						if p.Metadata().ID == dstID {
+							dstKeepsSrc = true
+						}
+						break
+					}
+					if pkgPath == dstPath {
+						// src.F => F
+						fe.edits = append(fe.edits, diff.Edit{
+							Start: safetoken.StartPosition(p.FileSet(), n.Pos()).Offset,
+							End:   safetoken.StartPosition(p.FileSet(), n.Sel.Pos()).Offset,
+						})
+					} else {
+						// src.F => dst.F
+						fe.edits = append(fe.edits, diff.Edit{
+							Start: safetoken.StartPosition(p.FileSet(), x.Pos()).Offset,
+							End:   safetoken.StartPosition(p.FileSet(), x.End()).Offset,
+							New:   importName(refPGF.File, dstPath, dstName),
+						})
+						fe.needDst = true
+						referrers[pkgPath] = true
+					}
+					fe.droppedSrc = true
+					return false
+
+				case *ast.Ident:
+					obj := pinfo.Uses[n]
+					if obj == nil || obj.Pkg() == nil || PackagePath(obj.Pkg().Path()) != srcPath {
+						break
+					}
+					if obj.Parent() == obj.Pkg().Scope() && movedNames[obj.Name()] {
+						if !isSrc {
+							err = fmt.Errorf("%s refers to %s through a dot import", refPGF.URI.Path(), obj.Name())
+							break
+						}
+						if !obj.Exported() {
+							err = fmt.Errorf("unexported %s is referred to by %s", obj.Name(), refPGF.URI.Path())
+							break
+						}
+						// F => dst.F
+						off := safetoken.StartPosition(p.FileSet(), n.Pos()).Offset
+						fe.edits = append(fe.edits, diff.Edit{Start: off, End: off, New: importName(refPGF.File, dstPath, dstName) + "."})
+						fe.needDst = true
+						referrers[pkgPath] = true
+					} else if isSrc && !obj.Exported() && moved(p.FileSet(), obj.Pos()) {
+						err = fmt.Errorf("unexported %s is referred to by %s", obj.Name(), refPGF.URI.Path())
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(fe.edits) > 0 {
+				files[refPGF.URI] = fe
+			}
+		}
+	}
+
+	// Check for import cycles: the destination must not depend, through
+	// its existing and new dependencies, on a package that will import it.
+	if len(referrers) > 0 && dstName == "main" {
+		return nil, fmt.Errorf("package %s is a main package, which cannot be imported", dstPath)
+	}
+	byPath := make(map[PackagePath]*metadata.Package)
+	for _, mp := range snapshot.MetadataGraph().Packages {
+		if mp.ForTest == "" {
+			byPath[mp.PkgPath] = mp
+		}
+	}
+	seen := make(map[PackagePath]bool)
+	queue := moremaps.KeySlice(dstDeps)
+	if dstMeta != nil {
+		for path := range dstMeta.DepsByPkgPath {
+			// The destination no longer imports the current package
+			// if it referred only to the moved declarations.
+			if path != srcPath || dstKeepsSrc {
+				queue = append(queue, path)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if path == dstPath || referrers[path] {
+			return nil, fmt.Errorf("moving the declarations to %s would create an import cycle through %s", dstPath, path)
+		}
+		if mp := byPath[path]; mp != nil {
+			for dep := range mp.DepsByPkgPath {
+				// The current package no longer imports the destination
+				// if only the moved declarations referred to it.
+				if path != srcPath || dep != dstPath || srcKeepsDst {
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+
+	// Edit the files referring to the declarations.
+	edits := make(map[protocol.DocumentURI][]diff.Edit)
+	for uri, fe := range files {
+		fh, err := snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fh.Content()
+		if err != nil {
+			return nil, err
+		}
+		after, err := diff.ApplyBytes(content, fe.edits)
+		if err != nil {
+			return nil, bug.Errorf("conflicting edits in %s: %v", uri.Path(), err)
+		}
+		if inPlace && uri == pgf.URI {
+			// The file now belongs to the destination: it
+			// must import the current package instead.
+			after, err = fixMovedImports(snapshot.Options().Local, uri, after, nil, needSrc, droppedDst, dstPath, srcPath, srcName)
+		} else {
+			after, err = fixMovedImports(snapshot.Options().Local, uri, after, fe.deletes, fe.needDst, fe.droppedSrc && !fe.keepsSrc, srcPath, dstPath, dstName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("updating %s: %v", uri.Path(), err)
+		}
+		edits[uri] = diff.Bytes(content, after)
+	}
+	// Declarations whose types were qualified are realigned only in
+	// files that were formatted.
+	changes, err := diffEditsToDocumentChanges(ctx, snapshot, edits)
+	if err != nil {
+		return nil, err
+	}
+	if inPlace {
+		return changes, nil
+	}
+
+	// Create the new file of the destination package.
+	newFile, err := chooseNewFile(ctx, snapshot, dstDir, firstSymbol)
+	if err != nil {
+		return nil, err
+	}
+	header, err := NewFileContent(ctx, snapshot, newFile.URI())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if dstMeta == nil {
+		// A new package inherits the copyright header of the declarations.
+		if c := copyrightComment(pgf.File); c != nil {
+			start, end, err := pgf.NodeOffsets(c)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(pgf.Src[start:end])
+			buf.WriteString("\n\n")
+		}
+	}
+	if c := buildConstraintComment(pgf.File); c != nil {
+		start, end, err := pgf.NodeOffsets(c)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(pgf.Src[start:end])
+		buf.WriteString("\n\n")
+	}
+	if dstMeta == nil {
+		fmt.Fprintf(&buf, "package %s\n", dstName)
+	} else {
+		buf.WriteString(header)
+	}
+	// Group the imports of the standard library before the others.
+	var std, others []string
+	for _, spec := range imports {
+		line := spec.Path.Value
+		if spec.Name != nil {
+			line = spec.Name.Name + " " + line
+		}
+		if path := metadata.UnquoteImportPath(spec); strings.Contains(strings.Split(string(path), "/")[0], ".") {
+			others = append(others, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+	if needSrc {
+		line := fmt.Sprintf("%q", srcPath)
+		if srcName != path.Base(string(srcPath)) {
+			line = srcName + " " + line
+		}
+		others = append(others, line)
+	}
+	if len(std)+len(others) > 0 {
+		buf.WriteString("import (\n")
+		for _, line := range std {
+			buf.WriteString(line + "\n")
+		}
+		if len(std) > 0 && len(others) > 0 {
+			buf.WriteString("\n")
+		}
+		for _, line := range others {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString(")\n")
+	}
+	buf.WriteString("\n")
+	text, err := diff.ApplyBytes(pgf.Src[startOff:endOff], movedEdits)
+	if err != nil {
+		return nil, bug.Errorf("conflicting edits of moved declarations: %v", err)
+	}
+	buf.Write(text)
+	buf.WriteString("\n")
+	newContent, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, bug.Errorf("formatting new file: %v", err)
+	}
+
+	return append(changes,
+		protocol.DocumentChangeCreate(newFile.URI()),
+		protocol.DocumentChangeEdit(newFile, []protocol.TextEdit{
+			{Range: protocol.Range{}, NewText: string(newContent)},
+		})), nil
+}
+
+// importName returns the name by which the file refers to the package
+// with the specified path, if it imports it, or else its default name.
+func importName(file *ast.File, path PackagePath, name string) string {
+	for _, spec := range file.Imports {
+		if PackagePath(metadata.UnquoteImportPath(spec)) == path && spec.Name != nil && spec.Name.Name != "_" && spec.Name.Name != "." {
+			return spec.Name.Name
+		}
+	}
+	return name
+}
+
+// fixMovedImports updates the imports of a Go file whose references
+// to moved declarations have been updated: it deletes the specified
+// imports, adds an import of the destination package if needed, and
+// deletes the import of the source package if dropSrc is set.
+func fixMovedImports(localPrefix string, uri protocol.DocumentURI, src []byte, deletes []*ast.ImportSpec, needDst, dropSrc bool, srcPath, dstPath PackagePath, dstName string) ([]byte, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var fixes []*imports.ImportFix
+	deleteImport := func(spec *ast.ImportSpec) {
+		info := imports.ImportInfo{ImportPath: string(metadata.UnquoteImportPath(spec))}
+		if spec.Name != nil {
+			info.Name = spec.Name.Name
+		}
+		fixes = append(fixes, &imports.ImportFix{StmtInfo: info, FixType: imports.DeleteImport})
+	}
+	for _, spec := range deletes {
+		deleteImport(spec)
+	}
+	// Delete imports before adding any, so that an import
+	// replacing the only one remains unparenthesized.
+	if dropSrc {
+		for _, spec := range f.Imports {
+			if PackagePath(metadata.UnquoteImportPath(spec)) == srcPath {
+				deleteImport(spec)
+			}
+		}
+	}
+	if needDst && !slices.ContainsFunc(f.Imports, func(spec *ast.ImportSpec) bool {
+		return PackagePath(metadata.UnquoteImportPath(spec)) == dstPath
+	}) {
+		info := imports.ImportInfo{ImportPath: string(dstPath)}
+		if dstName != path.Base(string(dstPath)) {
+			info.Name = dstName
+		}
+		fixes = append(fixes, &imports.ImportFix{StmtInfo: info, FixType: imports.AddImport})
+	}
+	if len(fixes) == 0 {
+		return src, nil
+	}
+	edits, err := ComputeImportFixEdits(localPrefix, src, fixes...)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := protocol.ApplyEdits(protocol.NewMapper(uri, src), edits)
+	return out, err
+}
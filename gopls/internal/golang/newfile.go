@@ -0,0 +1,156 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// NewFileContent returns the initial content of a new Go file: a
+// package clause, preceded by the copyright header shared by the other
+// Go files of its directory, if any.
+//
+// The package is that of the other files of the directory or, if there
+// are none, is named after the directory. A new test file belongs to
+// the internal or the external test package, following the convention
+// of the existing test files of the directory.
+func NewFileContent(ctx context.Context, snapshot *cache.Snapshot, uri protocol.DocumentURI) (string, error) {
+	ctx, done := event.Start(ctx, "golang.NewFileContent")
+	defer done()
+
+	var (
+		names     = make(map[string]int) // package names of non-test files
+		testNames = make(map[string]int) // package names of test files
+		headers   []string               // copyright headers of all files
+		shared    = true                 // whether all files have a copyright header
+	)
+	dir := uri.DirPath()
+	entries, _ := os.ReadDir(dir) // a missing directory has no files
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || name == filepath.Base(uri.Path()) {
+			continue
+		}
+		fh, err := snapshot.ReadFile(ctx, protocol.URIFromPath(filepath.Join(dir, name)))
+		if err != nil {
+			return "", err
+		}
+		pgf, err := snapshot.ParseGo(ctx, fh, parsego.Header)
+		if err != nil || pgf.File.Name == nil || pgf.File.Name.Name == "_" {
+			continue // e.g. a file without package clause
+		}
+		if c := buildConstraintComment(pgf.File); c != nil && strings.Contains(c.Text, "ignore") {
+			continue // e.g. a generator program
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			testNames[pgf.File.Name.Name]++
+		} else {
+			names[pgf.File.Name.Name]++
+		}
+		if c := copyrightComment(pgf.File); c != nil {
+			start, end, err := pgf.NodeOffsets(c)
+			if err != nil {
+				return "", err
+			}
+			headers = append(headers, string(pgf.Src[start:end]))
+		} else {
+			shared = false
+		}
+	}
+
+	// Determine the package name.
+	pkgName := mostFrequent(names)
+	if pkgName == "" {
+		pkgName = strings.TrimSuffix(mostFrequent(testNames), "_test")
+	}
+	if pkgName == "" {
+		pkgName = dirPackageName(filepath.Base(dir))
+	}
+	if pkgName == "" {
+		return "", fmt.Errorf("cannot choose a package name for %s", uri)
+	}
+	if strings.HasSuffix(uri.Path(), "_test.go") && pkgName != "main" {
+		// Follow the convention of the majority of the existing tests,
+		// and prefer internal tests, which may use unexported names.
+		external := 0
+		for name, n := range testNames {
+			if strings.HasSuffix(name, "_test") {
+				external += n
+			} else {
+				external -= n
+			}
+		}
+		if external > 0 {
+			pkgName += "_test"
+		}
+	}
+
+	var buf bytes.Buffer
+	if header := sharedHeader(headers); shared && header != "" {
+		buf.WriteString(header)
+		buf.WriteString("\n\n")
+	}
+	fmt.Fprintf(&buf, "package %s\n", pkgName)
+	return buf.String(), nil
+}
+
+// yearRx matches a year in a copyright header.
+var yearRx = regexp.MustCompile(`\b(19|20)[0-9][0-9]\b`)
+
+// sharedHeader returns the copyright header common to all the given
+// headers, which may differ only in their years, or "" if there is
+// none. Of equivalent headers, it returns the one with the latest
+// years.
+func sharedHeader(headers []string) string {
+	var latest string
+	for _, header := range headers {
+		if latest != "" && yearRx.ReplaceAllString(header, "") != yearRx.ReplaceAllString(latest, "") {
+			return ""
+		}
+		// Headers that differ only in their years are ordered by them.
+		latest = max(latest, header)
+	}
+	return latest
+}
+
+// mostFrequent returns the key with the highest count, or "" if there
+// are none. Ties are broken in favor of the least key.
+func mostFrequent(counts map[string]int) string {
+	var best string
+	for key, n := range counts {
+		if best == "" || n > counts[best] || n == counts[best] && key < best {
+			best = key
+		}
+	}
+	return best
+}
+
+// dirPackageName returns the conventional name of a package in a
+// directory of the given name: the name, reduced to lower-case letters
+// and digits, starting with a letter. It returns "" if there is none.
+func dirPackageName(dirName string) string {
+	var buf strings.Builder
+	for _, r := range dirName {
+		switch {
+		case 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z':
+			buf.WriteRune(unicode.ToLower(r))
+		case buf.Len() > 0 && '0' <= r && r <= '9':
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
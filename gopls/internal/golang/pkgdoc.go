@@ -278,60 +278,7 @@ type Web interface {
 // bend the tests to the production interfaces, not the other way
 // around.)
 func PackageDocHTML(viewID string, pkg *cache.Package, web Web) ([]byte, error) {
-	// We can't use doc.NewFromFiles (even with doc.PreserveAST
-	// mode) as it calls ast.NewPackage which assumes that each
-	// ast.File has an ast.Scope and resolves identifiers to
-	// (deprecated) ast.Objects. (This is golang/go#66290.)
-	// But doc.New only requires pkg.{Name,Files},
-	// so we just boil it down.
-	//
-	// The only loss is doc.classifyExamples.
-	// TODO(adonovan): simulate that too.
-	fileMap := make(map[string]*ast.File)
-	for _, f := range pkg.Syntax() {
-		fileMap[pkg.FileSet().File(f.FileStart).Name()] = f
-	}
-	astpkg := &ast.Package{
-		Name:  pkg.Types().Name(),
-		Files: fileMap,
-	}
-	// PreserveAST mode only half works (golang/go#66449): it still
-	// mutates ASTs when filtering out non-exported symbols.
-	// As a workaround, enable AllDecls to suppress filtering,
-	// and do it ourselves.
-	mode := doc.PreserveAST | doc.AllDecls
-	docpkg := doc.New(astpkg, pkg.Types().Path(), mode)
-
-	// Discard non-exported symbols.
-	// TODO(adonovan): do this conditionally, and expose option in UI.
-	const showUnexported = false
-	if !showUnexported {
-		var (
-			unexported   = func(name string) bool { return !token.IsExported(name) }
-			filterValues = func(slice *[]*doc.Value) {
-				delValue := func(v *doc.Value) bool {
-					v.Names = slices.DeleteFunc(v.Names, unexported)
-					return len(v.Names) == 0
-				}
-				*slice = slices.DeleteFunc(*slice, delValue)
-			}
-			filterFuncs = func(funcs *[]*doc.Func) {
-				*funcs = slices.DeleteFunc(*funcs, func(v *doc.Func) bool {
-					return unexported(v.Name)
-				})
-			}
-		)
-		filterValues(&docpkg.Consts)
-		filterValues(&docpkg.Vars)
-		filterFuncs(&docpkg.Funcs)
-		docpkg.Types = slices.DeleteFunc(docpkg.Types, func(t *doc.Type) bool {
-			filterValues(&t.Consts)
-			filterValues(&t.Vars)
-			filterFuncs(&t.Funcs)
-			filterFuncs(&t.Methods)
-			return unexported(t.Name)
-		})
-	}
+	docpkg := newDocPackage(pkg)
 
 	// docHTML renders the doc comment as Markdown.
 	// The fileNode is used to deduce the enclosing file
@@ -852,6 +799,259 @@ window.addEventListener('load', function() {
 	return buf.Bytes(), nil
 }
 
+// PackageDocText returns the documentation of the exported API of the
+// package as Go declarations preceded by their doc comments, in the
+// manner of "go doc -all". Unexported fields, methods and values are
+// omitted, as are function bodies.
+func PackageDocText(pkg *cache.Package) ([]byte, error) {
+	docpkg := newDocPackage(pkg)
+	parse := newDocCommentParser(pkg)
+
+	var (
+		buf bytes.Buffer
+		cp  comment.Printer
+	)
+
+	// docComment emits the doc comment (if any) of a declaration.
+	docComment := func(fileNode ast.Node, text string) {
+		if text == "" {
+			return
+		}
+		// The printer omits the comment markers.
+		for _, line := range strings.SplitAfter(string(cp.Comment(parse(fileNode, text))), "\n") {
+			if line == "\n" {
+				buf.WriteString("//\n")
+			} else if line != "" {
+				buf.WriteString("// " + line)
+			}
+		}
+	}
+
+	// decl emits the exported part of a declaration, followed by a
+	// blank line.
+	decl := func(n ast.Decl) error {
+		if err := format.Node(&buf, pkg.FileSet(), exportedDecl(n)); err != nil {
+			return err
+		}
+		buf.WriteString("\n\n")
+		return nil
+	}
+
+	values := func(vals []*doc.Value) error {
+		for _, v := range vals {
+			docComment(v.Decl, v.Doc)
+			if err := decl(v.Decl); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	funcs := func(funcs []*doc.Func) error {
+		for _, fn := range funcs {
+			docComment(fn.Decl, fn.Doc)
+			if err := decl(fn.Decl); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// package clause
+	for _, f := range pkg.Syntax() {
+		if f.Doc != nil {
+			docComment(f.Doc, docpkg.Doc)
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "package %s // import %q\n\n", pkg.Types().Name(), pkg.Types().Path())
+
+	if err := values(docpkg.Consts); err != nil {
+		return nil, err
+	}
+	if err := values(docpkg.Vars); err != nil {
+		return nil, err
+	}
+	if err := funcs(docpkg.Funcs); err != nil {
+		return nil, err
+	}
+	for _, doctype := range docpkg.Types {
+		docComment(doctype.Decl, doctype.Doc)
+		if err := decl(doctype.Decl); err != nil {
+			return nil, err
+		}
+		if err := values(doctype.Consts); err != nil {
+			return nil, err
+		}
+		if err := values(doctype.Vars); err != nil {
+			return nil, err
+		}
+		if err := funcs(doctype.Funcs); err != nil {
+			return nil, err
+		}
+		if err := funcs(doctype.Methods); err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// exportedDecl returns a copy of the declaration without its doc
+// comment, its function body, or its unexported specs, fields, and
+// methods. (The syntax trees of the package are shared, so must not
+// be mutated.) As in go/doc, a struct or interface type from which
+// something was omitted is marked Incomplete, so that the printer
+// mentions it.
+func exportedDecl(decl ast.Decl) ast.Decl {
+	// filterType returns a copy of a type expression
+	// without the unexported fields and methods of
+	// struct and interface types.
+	var filterType func(ast.Expr) ast.Expr
+	filterFields := func(list *ast.FieldList, isInterface bool) (*ast.FieldList, bool) {
+		res := &ast.FieldList{Opening: list.Opening, Closing: list.Closing}
+		incomplete := false
+		for _, field := range list.List {
+			var names []*ast.Ident
+			for _, name := range field.Names {
+				if name.IsExported() {
+					names = append(names, name)
+				}
+			}
+			exported := len(names) > 0
+			if len(field.Names) == 0 { // embedded field or type
+				switch id := embeddedIdent(field.Type); {
+				case id != nil:
+					exported = id.IsExported()
+				case isInterface:
+					exported = true // type union or constraint literal
+				}
+			}
+			if len(names) < len(field.Names) || !exported {
+				incomplete = true
+			}
+			if exported {
+				field2 := *field
+				field2.Names = names
+				field2.Type = filterType(field.Type)
+				res.List = append(res.List, &field2)
+			}
+		}
+		return res, incomplete
+	}
+	filterType = func(t ast.Expr) ast.Expr {
+		switch t := t.(type) {
+		case *ast.StructType:
+			clone := *t
+			clone.Fields, clone.Incomplete = filterFields(t.Fields, false)
+			return &clone
+		case *ast.InterfaceType:
+			clone := *t
+			clone.Methods, clone.Incomplete = filterFields(t.Methods, true)
+			return &clone
+		case *ast.StarExpr:
+			clone := *t
+			clone.X = filterType(t.X)
+			return &clone
+		}
+		return t
+	}
+
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		res := *decl
+		res.Doc = nil
+		res.Body = nil
+		return &res
+
+	case *ast.GenDecl:
+		res := *decl
+		res.Doc = nil
+		res.Specs = nil
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				if slices.ContainsFunc(spec.Names, (*ast.Ident).IsExported) {
+					res.Specs = append(res.Specs, spec)
+				}
+			case *ast.TypeSpec:
+				if spec.Name.IsExported() {
+					spec2 := *spec
+					spec2.Type = filterType(spec.Type)
+					if !decl.Lparen.IsValid() {
+						// go/doc splits grouped type declarations;
+						// the doc comment is emitted by the caller.
+						spec2.Doc = nil
+					}
+					res.Specs = append(res.Specs, &spec2)
+				}
+			}
+		}
+		return &res
+	}
+	return decl
+}
+
+// newDocPackage returns the go/doc model of the exported API of the
+// package.
+func newDocPackage(pkg *cache.Package) *doc.Package {
+	// We can't use doc.NewFromFiles (even with doc.PreserveAST
+	// mode) as it calls ast.NewPackage which assumes that each
+	// ast.File has an ast.Scope and resolves identifiers to
+	// (deprecated) ast.Objects. (This is golang/go#66290.)
+	// But doc.New only requires pkg.{Name,Files},
+	// so we just boil it down.
+	//
+	// The only loss is doc.classifyExamples.
+	// TODO(adonovan): simulate that too.
+	fileMap := make(map[string]*ast.File)
+	for _, f := range pkg.Syntax() {
+		fileMap[pkg.FileSet().File(f.FileStart).Name()] = f
+	}
+	astpkg := &ast.Package{
+		Name:  pkg.Types().Name(),
+		Files: fileMap,
+	}
+	// PreserveAST mode only half works (golang/go#66449): it still
+	// mutates ASTs when filtering out non-exported symbols.
+	// As a workaround, enable AllDecls to suppress filtering,
+	// and do it ourselves.
+	mode := doc.PreserveAST | doc.AllDecls
+	docpkg := doc.New(astpkg, pkg.Types().Path(), mode)
+
+	// Discard non-exported symbols.
+	// TODO(adonovan): do this conditionally, and expose option in UI.
+	const showUnexported = false
+	if !showUnexported {
+		var (
+			unexported   = func(name string) bool { return !token.IsExported(name) }
+			filterValues = func(slice *[]*doc.Value) {
+				delValue := func(v *doc.Value) bool {
+					v.Names = slices.DeleteFunc(v.Names, unexported)
+					return len(v.Names) == 0
+				}
+				*slice = slices.DeleteFunc(*slice, delValue)
+			}
+			filterFuncs = func(funcs *[]*doc.Func) {
+				*funcs = slices.DeleteFunc(*funcs, func(v *doc.Func) bool {
+					return unexported(v.Name)
+				})
+			}
+		)
+		filterValues(&docpkg.Consts)
+		filterValues(&docpkg.Vars)
+		filterFuncs(&docpkg.Funcs)
+		docpkg.Types = slices.DeleteFunc(docpkg.Types, func(t *doc.Type) bool {
+			filterValues(&t.Consts)
+			filterValues(&t.Vars)
+			filterFuncs(&t.Funcs)
+			filterFuncs(&t.Methods)
+			return unexported(t.Name)
+		})
+	}
+
+	return docpkg
+}
+
 // tupleVariables returns a go1.23 iterator over the variables of a tuple type.
 //
 // Example: for v := range tuple.Variables() { ... }
@@ -0,0 +1,494 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the "propagate context" refactoring, which adds
+// a context.Context parameter to a function and its callers.
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/imports"
+)
+
+// contextFuncDecl returns the declaration of the function whose header
+// encloses the selection, if a context parameter may be added to it.
+func contextFuncDecl(pkg *cache.Package, pgf *parsego.File, start, end token.Pos) *ast.FuncDecl {
+	decl := enclosingFuncHeader(pgf, start, end)
+	if decl == nil || decl.Recv == nil && (decl.Name.Name == "main" || decl.Name.Name == "init") {
+		return nil
+	}
+	fn, ok := pkg.TypesInfo().Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	params := fn.Signature().Params()
+	for i := 0; i < params.Len(); i++ {
+		if isContextType(params.At(i).Type()) {
+			return nil // already has a context
+		}
+	}
+	return decl
+}
+
+// isContextType reports whether t is context.Context.
+func isContextType(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+// PropagateContext adds a ctx parameter of type context.Context to
+// the function whose header encloses rng, and updates its callers,
+// walking the static call graph upward.
+//
+// At each call, the caller passes a context variable that is in scope
+// at the call, if any. Otherwise, if the caller is an unexported
+// function or method whose signature may change, it too gains a ctx
+// parameter, which it passes, and its callers are updated in turn.
+// (All callers of an unexported function or method are within its
+// package, so all of them are updated, but a method's signature may
+// not change if it is needed to satisfy an interface.) The remaining
+// callers (exported functions and methods, methods that satisfy an
+// interface, main, package initializers, and functions used as values)
+// pass context.TODO().
+//
+// If annotate, the edits of the resulting WorkspaceEdit are annotated
+// by function, so that clients may preview them. The caller must set
+// it only if the client supports change annotations.
+func PropagateContext(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range, annotate bool) (*protocol.WorkspaceEdit, error) {
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNoErrors(pkg); err != nil {
+		return nil, err
+	}
+	start, end, err := pgf.RangePos(rng)
+	if err != nil {
+		return nil, err
+	}
+	decl := contextFuncDecl(pkg, pgf, start, end)
+	if decl == nil {
+		return nil, fmt.Errorf("no function to which a context parameter may be added")
+	}
+	fn := pkg.TypesInfo().Defs[decl.Name].(*types.Func)
+	if usesName(decl, "ctx") {
+		return nil, fmt.Errorf("cannot add a context parameter to %s: it already uses the name ctx", fn.Name())
+	}
+	// checkRecv reports an error if the new signature of a method
+	// would break an interface.
+	checkRecv := func(pkg *cache.Package, fn *types.Func) error {
+		if recv := fn.Signature().Recv(); recv != nil {
+			T, isPtr := recv.Type(), false
+			if ptr, ok := T.(*types.Pointer); ok {
+				T, isPtr = ptr.Elem(), true
+			}
+			if named, ok := types.Unalias(T).(*types.Named); ok {
+				return checkImplementations(ctx, snapshot, pkg, named, fn.Name(), nil, isPtr)
+			}
+		}
+		return nil
+	}
+	if err := checkRecv(pkg, fn); err != nil {
+		return nil, fmt.Errorf("cannot add a context parameter to %s: %v", fn.Name(), err)
+	}
+
+	// Type check all packages that may call the function, including
+	// test variants.
+	ids := make(map[PackageID]bool)
+	mps, err := snapshot.MetadataForFile(ctx, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	for _, mp := range mps {
+		ids[mp.ID] = true
+		rdeps, err := snapshot.ReverseDependencies(ctx, mp.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		for id := range rdeps {
+			ids[id] = true
+		}
+	}
+	var idList []PackageID
+	for id := range ids {
+		idList = append(idList, id)
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, idList...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the function declarations, calls, and other references
+	// to functions, by the full name of the function.
+	type funcDecl struct {
+		pkg   *cache.Package
+		pgf   *parsego.File
+		decl  *ast.FuncDecl
+		obj   *types.Func
+		label string // name of the function, for annotations
+	}
+	type callSite struct {
+		pkg    *cache.Package
+		pgf    *parsego.File
+		call   *ast.CallExpr
+		caller string // full name of the enclosing function, or "" at package level
+	}
+	var (
+		decls     = make(map[string]funcDecl)
+		calls     = make(map[string][]callSite)
+		valueRefs = make(map[string]bool) // functions referenced other than by calls
+	)
+	for _, p := range pkgs {
+		info := p.TypesInfo()
+		for _, pgf := range p.CompiledGoFiles() {
+			callees := make(map[*ast.Ident]bool) // identifiers of called functions
+			for _, d := range pgf.File.Decls {
+				caller := ""
+				if fd, ok := d.(*ast.FuncDecl); ok {
+					if obj, ok := info.Defs[fd.Name].(*types.Func); ok {
+						caller = obj.FullName()
+						if _, ok := decls[caller]; !ok {
+							decls[caller] = funcDecl{p, pgf, fd, obj, funcLabel(obj)}
+						}
+					}
+				}
+				ast.Inspect(d, func(n ast.Node) bool {
+					if call, ok := n.(*ast.CallExpr); ok {
+						if callee := typeutil.StaticCallee(info, call); callee != nil {
+							if id := calleeIdent(call.Fun); id != nil {
+								callees[id] = true
+							}
+							key := callee.Origin().FullName()
+							calls[key] = append(calls[key], callSite{p, pgf, call, caller})
+						}
+					}
+					return true
+				})
+			}
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && !callees[id] {
+					if obj, ok := info.Uses[id].(*types.Func); ok {
+						valueRefs[obj.Origin().FullName()] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	if valueRefs[fn.FullName()] {
+		return nil, fmt.Errorf("cannot add a context parameter to %s: it is used as a value", fn.Name())
+	}
+
+	// canAdd reports whether the function of the given name may gain a
+	// context parameter, to pass to its callees.
+	canAdd := func(name string) bool {
+		d, ok := decls[name]
+		return ok &&
+			d.decl.Body != nil &&
+			!ast.IsExported(d.decl.Name.Name) &&
+			(d.decl.Recv != nil || d.decl.Name.Name != "main" && d.decl.Name.Name != "init") &&
+			d.decl.Type.TypeParams == nil &&
+			!valueRefs[name] &&
+			!usesName(d.decl, "ctx") &&
+			checkRecv(d.pkg, d.obj) == nil
+	}
+
+	// Compute the edits: insertions, labeled by the enclosing function.
+	//
+	// Several insertions may have the same offset, such as those of
+	// "ctx context.Context, " and "_ " before an unnamed first
+	// parameter; they are applied in the order they were made.
+	type insertion struct {
+		offset int
+		seq    int // order of insertions at the same offset
+		rng    protocol.Range
+		text   string
+		fn     string // full name of the enclosing function, or "" at package level
+	}
+	type insertionKey struct {
+		offset int
+		text   string
+	}
+	type fileEdits struct {
+		pkg        *cache.Package
+		pgf        *parsego.File
+		insertions map[insertionKey]insertion
+		imports    bool // the file must import "context"
+	}
+	files := make(map[protocol.DocumentURI]*fileEdits)
+	insert := func(p *cache.Package, pgf *parsego.File, pos token.Pos, text, fn string) error {
+		fe, ok := files[pgf.URI]
+		if !ok {
+			fe = &fileEdits{pkg: p, pgf: pgf, insertions: make(map[insertionKey]insertion)}
+			files[pgf.URI] = fe
+		}
+		offset, err := safetoken.Offset(pgf.Tok, pos)
+		if err != nil {
+			return err
+		}
+		key := insertionKey{offset, text}
+		if _, ok := fe.insertions[key]; ok {
+			return nil // e.g. from a test variant of the same package
+		}
+		rng, err := pgf.PosRange(pos, pos)
+		if err != nil {
+			return err
+		}
+		fe.insertions[key] = insertion{offset, len(fe.insertions), rng, text, fn}
+		return nil
+	}
+	// contextPkg returns the name by which the file refers to the
+	// context package, noting an import if required.
+	contextPkg := func(p *cache.Package, pgf *parsego.File) string {
+		for _, imp := range pgf.File.Imports {
+			if imp.Path.Value == `"context"` {
+				if imp.Name == nil {
+					return "context"
+				} else if imp.Name.Name != "_" && imp.Name.Name != "." {
+					return imp.Name.Name
+				}
+			}
+		}
+		if _, ok := files[pgf.URI]; !ok {
+			files[pgf.URI] = &fileEdits{pkg: p, pgf: pgf, insertions: make(map[insertionKey]insertion)}
+		}
+		files[pgf.URI].imports = true
+		return "context"
+	}
+	addParam := func(name string) error {
+		d := decls[name]
+		text := "ctx " + contextPkg(d.pkg, d.pgf) + ".Context"
+		params := d.decl.Type.Params
+		if len(params.List) > 0 {
+			text += ", "
+		}
+		if err := insert(d.pkg, d.pgf, params.Opening+1, text, name); err != nil {
+			return err
+		}
+		for _, field := range params.List {
+			if len(field.Names) == 0 {
+				// All parameters must now be named.
+				if err := insert(d.pkg, d.pgf, field.Type.Pos(), "_ ", name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	added := map[string]bool{fn.FullName(): true}
+	queue := []string{fn.FullName()}
+	if err := addParam(fn.FullName()); err != nil {
+		return nil, err
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, site := range calls[name] {
+			arg := contextInScope(site.pkg, site.call.Pos())
+			if arg == "" {
+				switch {
+				case added[site.caller]:
+					arg = "ctx"
+				case site.caller != "" && canAdd(site.caller):
+					added[site.caller] = true
+					queue = append(queue, site.caller)
+					if err := addParam(site.caller); err != nil {
+						return nil, err
+					}
+					arg = "ctx"
+				default:
+					arg = contextPkg(site.pkg, site.pgf) + ".TODO()"
+				}
+			}
+			call := site.call
+			pos, text := call.Lparen+1, arg
+			if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && isMethodExpr(site.pkg.TypesInfo(), sel) {
+				// The first argument of a method expression call is the receiver.
+				pos, text = call.Args[0].End(), ", "+arg
+			} else if len(call.Args) > 0 {
+				text += ", "
+			}
+			if err := insert(site.pkg, site.pgf, pos, text, site.caller); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Build the workspace edit, annotating the edits of each function
+	// if requested.
+	wsedit := new(protocol.WorkspaceEdit)
+	if annotate {
+		wsedit.ChangeAnnotations = make(map[protocol.ChangeAnnotationIdentifier]protocol.ChangeAnnotation)
+	}
+	annotation := func(label, description string) *protocol.ChangeAnnotationIdentifier {
+		if !annotate {
+			return nil
+		}
+		wsedit.ChangeAnnotations[label] = protocol.ChangeAnnotation{
+			Label:             label,
+			NeedsConfirmation: true,
+			Description:       description,
+		}
+		return &label
+	}
+	var uris []protocol.DocumentURI
+	for uri := range files {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	for _, uri := range uris {
+		fe := files[uri]
+		fh, err := snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		var edits []protocol.Or_TextDocumentEdit_edits_Elem
+		if fe.imports {
+			importEdits, err := ComputeImportFixEdits(snapshot.Options().Local, fe.pgf.Src, &imports.ImportFix{
+				StmtInfo: imports.ImportInfo{ImportPath: "context"},
+				FixType:  imports.AddImport,
+			})
+			if err != nil {
+				return nil, err
+			}
+			id := annotation(fmt.Sprintf("Import context in %s", filepath.Base(uri.Path())), "")
+			for _, e := range importEdits {
+				edits = append(edits, protocol.Or_TextDocumentEdit_edits_Elem{
+					Value: protocol.AnnotatedTextEdit{AnnotationID: id, TextEdit: e},
+				})
+			}
+		}
+		var insertions []insertion
+		for _, ins := range fe.insertions {
+			insertions = append(insertions, ins)
+		}
+		sort.Slice(insertions, func(i, j int) bool {
+			if x, y := insertions[i], insertions[j]; x.offset != y.offset {
+				return x.offset < y.offset
+			} else {
+				return x.seq < y.seq
+			}
+		})
+		for _, ins := range insertions {
+			var id *protocol.ChangeAnnotationIdentifier
+			switch {
+			case ins.fn == "":
+				id = annotation(fmt.Sprintf("Pass context in package initializers of %s", fe.pkg.Types().Name()),
+					"Passes context.TODO() in package-level declarations.")
+			case added[ins.fn]:
+				id = annotation(fmt.Sprintf("Add ctx parameter to %s", decls[ins.fn].label),
+					"Adds a ctx parameter, and passes it to callees that need a context.")
+			default:
+				id = annotation(fmt.Sprintf("Pass context in %s", decls[ins.fn].label),
+					"Passes an existing context, or context.TODO(), to callees that need a context.")
+			}
+			edits = append(edits, protocol.Or_TextDocumentEdit_edits_Elem{
+				Value: protocol.AnnotatedTextEdit{
+					AnnotationID: id,
+					TextEdit:     protocol.TextEdit{Range: ins.rng, NewText: ins.text},
+				},
+			})
+		}
+		wsedit.DocumentChanges = append(wsedit.DocumentChanges, protocol.DocumentChange{
+			TextDocumentEdit: &protocol.TextDocumentEdit{
+				TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+					Version:                fh.Version(),
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+				},
+				Edits: edits,
+			},
+		})
+	}
+	return wsedit, nil
+}
+
+// contextInScope returns the name of a variable of type context.Context
+// that is in scope at pos within a function of pkg, preferring the
+// innermost, and then one named ctx. It returns "" if there is none.
+func contextInScope(pkg *cache.Package, pos token.Pos) string {
+	pkgScope := pkg.Types().Scope()
+	inner := pkgScope.Innermost(pos)
+	for s := inner; s != nil && s != pkgScope && s.Parent() != pkgScope; s = s.Parent() {
+		found := ""
+		for _, name := range s.Names() {
+			if v, ok := s.Lookup(name).(*types.Var); ok && isContextType(v.Type()) {
+				if _, obj := inner.LookupParent(name, pos); obj == v && (found == "" || name == "ctx") {
+					found = name
+				}
+			}
+		}
+		if found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// calleeIdent returns the identifier that names the function called
+// by a call whose function expression is fun, or nil.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	switch fun := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	case *ast.IndexExpr:
+		return calleeIdent(fun.X)
+	case *ast.IndexListExpr:
+		return calleeIdent(fun.X)
+	}
+	return nil
+}
+
+// isMethodExpr reports whether sel is a method expression, such as T.M.
+func isMethodExpr(info *types.Info, sel *ast.SelectorExpr) bool {
+	s, ok := info.Selections[sel]
+	return ok && s.Kind() == types.MethodExpr
+}
+
+// usesName reports whether the declaration uses the given identifier.
+func usesName(decl *ast.FuncDecl, name string) bool {
+	found := false
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// funcLabel returns the name of the function, qualified by its package
+// name, and its receiver type, if any: for example, "pkg.T.M".
+func funcLabel(fn *types.Func) string {
+	name := fn.Name()
+	if recv := fn.Signature().Recv(); recv != nil {
+		T := recv.Type()
+		if ptr, ok := T.(*types.Pointer); ok {
+			T = ptr.Elem()
+		}
+		if named, ok := types.Unalias(T).(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	if fn.Pkg() != nil {
+		name = fn.Pkg().Name() + "." + name
+	}
+	return name
+}
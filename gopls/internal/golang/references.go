@@ -518,6 +518,7 @@ func expandMethodSearch(ctx context.Context, snapshot *cache.Snapshot, workspace
 	if err != nil {
 		return err
 	}
+	recvIsInterface := types.IsInterface(recv)
 	var mu sync.Mutex // guards addRdeps, targets, expansions
 	var group errgroup.Group
 	for i, index := range indexes {
@@ -525,7 +526,13 @@ func expandMethodSearch(ctx context.Context, snapshot *cache.Snapshot, workspace
 		index := index
 		group.Go(func() error {
 			// Consult index for matching methods.
-			results := index.Search(key, method)
+			// (Interface/interface pairs are not considered.)
+			var results []methodsets.Result
+			for _, res := range index.Search(key, methodsets.Subtype|methodsets.Supertype, method) {
+				if !(res.IsInterface && recvIsInterface) {
+					results = append(results, res)
+				}
+			}
 			if len(results) == 0 {
 				return nil
 			}
@@ -0,0 +1,352 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the computation of edits in response to the
+// renaming (moving) of Go files and package directories, as reported
+// by the workspace/willRenameFiles request. It plays the role of the
+// gomvpkg tool (refactor/rename.Move), but for modules.
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/event"
+)
+
+// RenameFiles returns the edits required to keep the workspace
+// consistent when the specified files or directories are renamed.
+// The edits apply to the files in their original locations.
+//
+// When a package directory is moved, the import path of each package
+// in or beneath it changes. RenameFiles updates every import of
+// those packages, and renames the package clause of the package
+// whose directory was renamed, if the package name matched the old
+// directory name.
+//
+// When a .go file is moved to a different directory, its package
+// clause is updated to match the package already in that directory,
+// if any. Unless it is a test file, the references to and from its
+// declarations are updated as if by [MoveToPackage].
+//
+// RenameFiles reports an error if the move would break the "internal"
+// visibility rules, create an import cycle, or collide with an
+// existing package.
+func RenameFiles(ctx context.Context, snapshot *cache.Snapshot, renames []protocol.FileRename) (map[protocol.DocumentURI][]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "golang.RenameFiles")
+	defer done()
+
+	allMetadata, err := snapshot.AllMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := &mover{
+		snapshot:    snapshot,
+		allMetadata: allMetadata,
+		goList:      snapshot.View().Type() != cache.GoPackagesDriverView,
+		edits:       make(map[protocol.DocumentURI][]diff.Edit),
+	}
+	for _, r := range renames {
+		oldURI, err := protocol.ParseDocumentURI(r.OldURI)
+		if err != nil {
+			return nil, err
+		}
+		newURI, err := protocol.ParseDocumentURI(r.NewURI)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Ext(oldURI.Path()) == ".go" {
+			err = m.moveFile(ctx, oldURI, newURI)
+		} else {
+			err = m.moveDir(ctx, oldURI.Path(), newURI.Path())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return toProtocolEdits(ctx, snapshot, m.edits)
+}
+
+// A mover holds the state of a single call to RenameFiles.
+type mover struct {
+	snapshot    *cache.Snapshot
+	allMetadata []*metadata.Package
+	goList      bool // metadata came from go list; see metadata.IsValidImport
+	edits       map[protocol.DocumentURI][]diff.Edit
+}
+
+// moveDir computes the edits resulting from the renaming of directory
+// oldDir to newDir, which moves every package in or beneath oldDir.
+func (m *mover) moveDir(ctx context.Context, oldDir, newDir string) error {
+	// Compute the new path of each moved package.
+	newPaths := make(map[PackagePath]PackagePath)
+	var moved []*metadata.Package
+	for _, mp := range m.allMetadata {
+		dir := packageDir(mp)
+		if dir == "" || !pathEncloses(oldDir, dir) {
+			continue
+		}
+		if mp.IsIntermediateTestVariant() {
+			continue // for renaming, these variants are redundant
+		}
+		moved = append(moved, mp)
+		if isXTest(mp) || mp.ForTest != "" {
+			continue // x_test packages and test variants are not importable
+		}
+		if mp.Module == nil {
+			// This check will always fail under Bazel.
+			return fmt.Errorf("cannot move package: missing module information for package %q", mp.PkgPath)
+		}
+		if pathEncloses(oldDir, mp.Module.Dir) {
+			continue // the enclosing module moves too; import paths are unchanged
+		}
+		rel, err := filepath.Rel(mp.Module.Dir, filepath.Join(newDir, strings.TrimPrefix(dir, oldDir)))
+		if err != nil {
+			return err
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("cannot move package %s outside its module %s", mp.PkgPath, mp.Module.Path)
+		}
+		newPaths[mp.PkgPath] = PackagePath(path.Join(mp.Module.Path, filepath.ToSlash(rel)))
+	}
+	if len(newPaths) == 0 {
+		return nil // no packages were moved
+	}
+
+	// Check that the move is valid.
+	newPathOf := func(path PackagePath) PackagePath {
+		if newPath, ok := newPaths[path]; ok {
+			return newPath
+		}
+		return path
+	}
+	for _, mp := range m.allMetadata {
+		if _, ok := newPaths[mp.PkgPath]; !ok {
+			for _, newPath := range newPaths {
+				if mp.PkgPath == newPath {
+					return fmt.Errorf("cannot move package: package %s already exists", newPath)
+				}
+			}
+		}
+		// Check that each package that imports a moved
+		// package, or is imported by one, may still do so.
+		_, isMovedPkg := newPaths[basePackagePath(mp)]
+		from := newPathOf(mp.PkgPath)
+		if isXTest(mp) {
+			from = newPathOf(basePackagePath(mp)) + "_test"
+		}
+		for depPath := range mp.DepsByPkgPath {
+			if _, isMovedDep := newPaths[depPath]; !isMovedDep && !isMovedPkg {
+				continue
+			}
+			if to := newPathOf(depPath); !metadata.IsValidImport(from, to, m.goList) {
+				return fmt.Errorf("cannot move package: %s would not be allowed to import internal package %s", from, to)
+			}
+		}
+	}
+
+	// Update package clauses and imports.
+	oldName := filepath.Base(oldDir)
+	newName := PackageName(filepath.Base(newDir))
+	for _, mp := range moved {
+		pkgName := mp.Name
+		if packageDir(mp) == oldDir &&
+			strings.TrimSuffix(string(mp.Name), "_test") == oldName &&
+			newName != PackageName(oldName) &&
+			isValidIdentifier(string(newName)) {
+			// The package name matched its directory; keep it that way.
+			pkgName = newName
+			if isXTest(mp) {
+				pkgName += "_test"
+			}
+			if err := renamePackageClause(ctx, mp, m.snapshot, pkgName, m.edits); err != nil {
+				return err
+			}
+		}
+		if isXTest(mp) {
+			continue // no importers
+		}
+		newPath, ok := newPaths[mp.PkgPath]
+		if !ok {
+			continue // import path unchanged
+		}
+		if err := renameImports(ctx, m.snapshot, mp, ImportPath(newPath), pkgName, m.edits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveFile computes the edits resulting from the renaming of a single
+// Go file, which may move it into a different package.
+func (m *mover) moveFile(ctx context.Context, oldURI, newURI protocol.DocumentURI) error {
+	oldDir, newDir := oldURI.DirPath(), newURI.DirPath()
+	if oldDir == newDir {
+		return nil // package is unchanged
+	}
+
+	fh, err := m.snapshot.ReadFile(ctx, oldURI)
+	if err != nil {
+		return err
+	}
+	pgf, err := m.snapshot.ParseGo(ctx, fh, parsego.Header)
+	if err != nil {
+		return err
+	}
+	if pgf.File.Name == nil || !pgf.File.Name.Pos().IsValid() {
+		return nil // no package clause
+	}
+	curName := pgf.File.Name.Name
+	isTestFile := strings.HasSuffix(newURI.Path(), "_test.go")
+	isXTestFile := isTestFile && strings.HasSuffix(curName, "_test")
+
+	// Find the package (if any) in the destination directory,
+	// and compute its name and path.
+	var (
+		dest     *metadata.Package
+		destName string
+		destPath PackagePath
+	)
+	for _, mp := range m.allMetadata {
+		if packageDir(mp) == newDir && mp.ForTest == "" && !isXTest(mp) {
+			dest = mp
+			destName, destPath = string(mp.Name), mp.PkgPath
+			break
+		}
+	}
+	if dest == nil {
+		// No package in the destination directory:
+		// choose the name from the directory if the
+		// file's package was named after its directory.
+		destName = curName
+		if base := filepath.Base(newDir); strings.TrimSuffix(curName, "_test") == filepath.Base(oldDir) && isValidIdentifier(base) {
+			destName = base
+		}
+		if mp, err := NarrowestMetadataForFile(ctx, m.snapshot, oldURI); err == nil && mp.Module != nil {
+			if rel, err := filepath.Rel(mp.Module.Dir, newDir); err == nil && !strings.HasPrefix(rel, "..") {
+				destPath = PackagePath(path.Join(mp.Module.Path, filepath.ToSlash(rel)))
+			}
+		}
+	}
+	newName := strings.TrimSuffix(destName, "_test")
+	if isXTestFile {
+		newName += "_test"
+	}
+
+	// Check that the imports of the file are valid in its new package.
+	if destPath != "" {
+		var rdeps map[PackageID]*metadata.Package
+		if dest != nil && !isXTestFile {
+			rdeps, err = m.snapshot.ReverseDependencies(ctx, dest.ID, true)
+			if err != nil {
+				return err
+			}
+		}
+		for _, imp := range pgf.File.Imports {
+			impPath := PackagePath(metadata.UnquoteImportPath(imp))
+			if impPath == "" || impPath == "C" {
+				continue
+			}
+			if !metadata.IsValidImport(destPath, impPath, m.goList) {
+				return fmt.Errorf("cannot move %s: package %s would not be allowed to import internal package %s", filepath.Base(oldURI.Path()), destPath, impPath)
+			}
+			if isXTestFile {
+				continue // an x_test package may import its package under test
+			}
+			if impPath == destPath && isTestFile {
+				// (Qualified references in other files are unqualified below.)
+				return fmt.Errorf("cannot move %s: it imports its destination package %s", filepath.Base(oldURI.Path()), destPath)
+			}
+			for _, rdep := range rdeps {
+				if rdep.PkgPath == impPath {
+					return fmt.Errorf("cannot move %s to package %s: it would create an import cycle through %s", filepath.Base(oldURI.Path()), destPath, impPath)
+				}
+			}
+		}
+	}
+
+	// The declarations of a (non-test) file that moves to another
+	// package must be referred to by qualified names, and vice versa.
+	if destPath != "" && !isTestFile && isValidIdentifier(newName) {
+		if mp, err := NarrowestMetadataForFile(ctx, m.snapshot, oldURI); err == nil && mp.PkgPath != destPath {
+			changes, err := moveToPackage(ctx, m.snapshot, fh, protocol.Range{}, destPath, newName)
+			if err != nil {
+				return fmt.Errorf("cannot move %s: %v", filepath.Base(oldURI.Path()), err)
+			}
+			if len(changes) > 0 {
+				// The changes include the package clause.
+				return m.addChanges(ctx, changes)
+			}
+		}
+	}
+
+	if newName != curName && isValidIdentifier(newName) {
+		edit, err := posEdit(pgf.Tok, pgf.File.Name.Pos(), pgf.File.Name.End(), newName)
+		if err != nil {
+			return err
+		}
+		m.edits[oldURI] = append(m.edits[oldURI], edit)
+	}
+	return nil
+}
+
+// addChanges adds the edits of the specified document changes.
+func (m *mover) addChanges(ctx context.Context, changes []protocol.DocumentChange) error {
+	for _, change := range changes {
+		if change.TextDocumentEdit == nil {
+			continue
+		}
+		uri := change.TextDocumentEdit.TextDocument.URI
+		fh, err := m.snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		content, err := fh.Content()
+		if err != nil {
+			return err
+		}
+		edits, err := protocol.EditsToDiffEdits(protocol.NewMapper(uri, content), protocol.AsTextEdits(change.TextDocumentEdit.Edits))
+		if err != nil {
+			return err
+		}
+		m.edits[uri] = append(m.edits[uri], edits...)
+	}
+	return nil
+}
+
+// packageDir returns the directory of the package, or "" if it has no files.
+func packageDir(mp *metadata.Package) string {
+	if len(mp.GoFiles) == 0 {
+		return ""
+	}
+	return mp.GoFiles[0].DirPath()
+}
+
+// isXTest reports whether mp is an external test package (p_test).
+func isXTest(mp *metadata.Package) bool {
+	return mp.ForTest != "" && strings.HasSuffix(string(mp.PkgPath), "_test")
+}
+
+// basePackagePath returns the path of the package under test for an
+// external test package, and the package path otherwise.
+func basePackagePath(mp *metadata.Package) PackagePath {
+	if isXTest(mp) {
+		return PackagePath(strings.TrimSuffix(string(mp.PkgPath), "_test"))
+	}
+	return mp.PkgPath
+}
+
+// pathEncloses reports whether file path x equals or encloses y.
+func pathEncloses(x, y string) bool {
+	return x == y || strings.HasPrefix(y, x+string(filepath.Separator))
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/imports"
+)
+
+// WillSave returns the edits to apply to a Go file that is about to be
+// saved, as configured by the OrganizeImportsOnSave and FixesOnSave
+// options: the suggested fixes of the configured analyzers, followed by
+// the formatting and organization of imports, as if by goimports.
+//
+// All edits are relative to the current content of the file: they are
+// computed by applying the fixes and organizing the imports of the
+// content in sequence, then comparing the result with the original.
+// A fix that overlaps one applied earlier is skipped, and no fixes
+// are applied to a package that does not compile.
+func WillSave(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "golang.WillSave")
+	defer done()
+
+	opts := snapshot.Options()
+	if !opts.OrganizeImportsOnSave && len(opts.FixesOnSave) == 0 {
+		return nil, nil
+	}
+	// Generated files shouldn't be edited.
+	if IsGenerated(ctx, snapshot, fh.URI()) {
+		return nil, nil
+	}
+	content, err := fh.Content()
+	if err != nil {
+		return nil, err
+	}
+	mapper := protocol.NewMapper(fh.URI(), content)
+
+	src := string(content)
+	if len(opts.FixesOnSave) > 0 {
+		edits, err := fixesOnSave(ctx, snapshot, fh, mapper)
+		if err != nil {
+			return nil, err
+		}
+		src, err = diff.Apply(src, edits)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.OrganizeImportsOnSave {
+		var formatted []byte
+		if err := snapshot.RunProcessEnvFunc(ctx, func(ctx context.Context, opts *imports.Options) error {
+			var err error
+			formatted, err = imports.Process(fh.URI().Path(), []byte(src), opts)
+			return err
+		}); err != nil {
+			// The file may contain syntax errors: save it as is.
+			event.Error(ctx, "organizing imports on save", err)
+		} else {
+			src, err = gofumpt(ctx, snapshot, fh, formatted)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return protocol.EditsFromDiffEdits(mapper, diff.Strings(string(content), src))
+}
+
+// fixesOnSave returns the edits of the suggested fixes of the analyzers
+// configured by the FixesOnSave option for the file, without overlap.
+// It returns no edits if the package of the file has parse or type
+// errors.
+func fixesOnSave(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, mapper *protocol.Mapper) ([]diff.Edit, error) {
+	pkg, _, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	if checkNoErrors(pkg) != nil {
+		return nil, nil
+	}
+	mp := pkg.Metadata()
+	diagnostics, err := Analyze(ctx, snapshot, map[PackageID]*metadata.Package{mp.ID: mp}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accumulate the edits of the first fix of each diagnostic in order,
+	// skipping those that overlap an edit already accepted.
+	diags := diagnostics[fh.URI()]
+	sort.Slice(diags, func(i, j int) bool {
+		return protocol.CompareRange(diags[i].Range, diags[j].Range) < 0
+	})
+	var edits []diff.Edit
+	overlaps := func(fix []diff.Edit) bool {
+		for _, x := range fix {
+			for _, y := range edits {
+				if x.Start < y.End && y.Start < x.End || x.Start == y.Start {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, diag := range diags {
+		if !slices.Contains(snapshot.Options().FixesOnSave, string(diag.Source)) || len(diag.SuggestedFixes) == 0 {
+			continue
+		}
+		fix := diag.SuggestedFixes[0]
+		if len(fix.Edits) != 1 || fix.Edits[fh.URI()] == nil {
+			continue // fix is lazy, or affects other files
+		}
+		fixEdits, err := protocol.EditsToDiffEdits(mapper, fix.Edits[fh.URI()])
+		if err != nil {
+			return nil, fmt.Errorf("fix of %s: %v", diag.Source, err)
+		}
+		if !overlaps(fixEdits) {
+			edits = append(edits, fixEdits...)
+		}
+	}
+	return edits, nil
+}
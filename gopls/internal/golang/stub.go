@@ -39,6 +39,35 @@ func stubMissingInterfaceMethodsFixer(ctx context.Context, snapshot *cache.Snaps
 	return insertDeclsAfter(ctx, snapshot, pkg.Metadata(), si.Fset, si.Concrete.Obj(), si.Emit)
 }
 
+// delegateMissingInterfaceMethodsFixer returns a suggested fix to
+// declare the missing methods of the concrete type that is assigned
+// to an interface type at the cursor position, each of which forwards
+// to the method of the same name of one of the type's fields.
+func delegateMissingInterfaceMethodsFixer(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*token.FileSet, *analysis.SuggestedFix, error) {
+	nodes, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	si := stubmethods.GetIfaceStubInfo(pkg.FileSet(), pkg.TypesInfo(), nodes, start)
+	if si == nil {
+		return nil, nil, fmt.Errorf("nil interface request")
+	}
+	di := si.DelegateInfo()
+	if di == nil {
+		return nil, nil, fmt.Errorf("no field of %s provides the missing methods", si.Concrete.Obj().Name())
+	}
+	return insertDeclsAfter(ctx, snapshot, pkg.Metadata(), di.Fset, di.Outer.Obj(), di.Emit)
+}
+
+// delegateMethodsFixer returns a suggested fix to declare methods of
+// the struct type whose field is at the cursor position, each of
+// which forwards to the method of the same name of that field.
+func delegateMethodsFixer(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, start, end token.Pos) (*token.FileSet, *analysis.SuggestedFix, error) {
+	nodes, _ := astutil.PathEnclosingInterval(pgf.File, start, end)
+	di, err := stubmethods.GetDelegateInfo(pkg.FileSet(), pkg.TypesInfo(), nodes, start)
+	if err != nil {
+		return nil, nil, err
+	}
+	return insertDeclsAfter(ctx, snapshot, pkg.Metadata(), di.Fset, di.Outer.Obj(), di.Emit)
+}
+
 // stubMissingCalledFunctionFixer returns a suggested fix to declare the missing
 // method that the user may want to generate based on CallExpr
 // at the cursor position.
@@ -0,0 +1,300 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stubmethods
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/util/typesutil"
+	"golang.org/x/tools/internal/typesinternal"
+)
+
+// DelegateInfo represents a set of methods to be declared on a
+// struct type, each of which forwards to the method of the same name
+// of one of its fields, as in the decorator or wrapper pattern.
+type DelegateInfo struct {
+	Fset    *token.FileSet // the FileSet used to type-check the types below
+	Outer   *types.Named   // the struct type on which to declare the methods
+	Field   *types.Var     // the field to which the methods delegate
+	Methods []*types.Func  // the methods of the field to delegate to, in order
+	pointer bool           // whether the new methods have pointer receivers
+	iface   string         // the interface that the methods implement, if any (used only in a comment)
+}
+
+// GetDelegateInfo returns the methods to be declared on a
+// package-level struct type in order to delegate to the field
+// enclosing pos, which must be a named (non-embedded) field.
+//
+// The methods are those of the field's method set, excluding any
+// that are inaccessible, and any whose names are already those of a
+// field or method (whether declared or promoted) of the struct type.
+// It returns an error if no methods remain.
+func GetDelegateInfo(fset *token.FileSet, info *types.Info, path []ast.Node, pos token.Pos) (*DelegateInfo, error) {
+	outer, field := enclosingStructField(info, path, pos)
+	if field == nil {
+		return nil, fmt.Errorf("not a named field of a package-level struct type")
+	}
+
+	pointer := delegatePointerRecv(outer)
+	var methods []*types.Func
+	mset := delegateMethodSet(field.Type(), pointer)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj().(*types.Func)
+		if !m.Exported() && m.Pkg() != outer.Obj().Pkg() {
+			continue // inaccessible
+		}
+		if obj, _, _ := types.LookupFieldOrMethod(outer, true, m.Pkg(), m.Name()); obj != nil {
+			continue // already defined, or would conflict with a field
+		}
+		methods = append(methods, m)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("type %s has no methods to delegate to field %s", outer.Obj().Name(), field.Name())
+	}
+	return &DelegateInfo{
+		Fset:    fset,
+		Outer:   outer,
+		Field:   field,
+		Methods: methods,
+		pointer: pointer,
+	}, nil
+}
+
+// DelegateInfo returns the information needed to declare the
+// methods that the concrete type is missing by delegating to a field
+// of it. It returns nil unless the concrete type is a struct with
+// exactly one named field that provides all the missing methods.
+func (si *IfaceStubInfo) DelegateInfo() *DelegateInfo {
+	outer, ok := types.Unalias(si.Concrete).(*types.Named)
+	if !ok {
+		return nil
+	}
+	strct, ok := outer.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+
+	// Find the methods that the concrete type lacks.
+	ifaceType := si.Interface.Type().Underlying().(*types.Interface)
+	var missing []*types.Func
+	for i := 0; i < ifaceType.NumMethods(); i++ {
+		imethod := ifaceType.Method(i)
+		if obj, _, _ := types.LookupFieldOrMethod(outer, si.pointer, imethod.Pkg(), imethod.Name()); obj == nil {
+			missing = append(missing, imethod)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Find the unique field that has all of them.
+	var result *DelegateInfo
+	for i := 0; i < strct.NumFields(); i++ {
+		field := strct.Field(i)
+		if field.Embedded() {
+			continue
+		}
+		mset := delegateMethodSet(field.Type(), si.pointer)
+		var methods []*types.Func
+		for _, imethod := range missing {
+			sel := mset.Lookup(imethod.Pkg(), imethod.Name())
+			if sel == nil || !types.Identical(sel.Obj().Type(), imethod.Type()) {
+				break
+			}
+			methods = append(methods, sel.Obj().(*types.Func))
+		}
+		if len(methods) < len(missing) {
+			continue
+		}
+		if result != nil {
+			return nil // ambiguous
+		}
+		iface := si.Interface.Name()
+		if ipkg := si.Interface.Pkg(); ipkg != nil && ipkg != outer.Obj().Pkg() {
+			iface = ipkg.Name() + "." + iface
+		}
+		result = &DelegateInfo{
+			Fset:    si.Fset,
+			Outer:   outer,
+			Field:   field,
+			Methods: methods,
+			pointer: si.pointer,
+			iface:   iface,
+		}
+	}
+	return result
+}
+
+// Emit writes to out the declarations of the delegating methods.
+func (di *DelegateInfo) Emit(out *bytes.Buffer, qual types.Qualifier) error {
+	// Pointer receiver?
+	var star string
+	if di.pointer {
+		star = "*"
+	}
+
+	// If there are any methods that have a named receiver, choose
+	// the first one. Otherwise, use the lowercase first letter of the type.
+	recvName := strings.ToLower(di.Outer.Obj().Name()[0:1])
+	for i := 0; i < di.Outer.NumMethods(); i++ {
+		if recv := di.Outer.Method(i).Type().(*types.Signature).Recv(); recv.Name() != "" && recv.Name() != "_" {
+			recvName = recv.Name()
+			break
+		}
+	}
+
+	for _, m := range di.Methods {
+		sig := m.Signature()
+
+		// The parameters must all be named, and no parameter or
+		// result may be named like the receiver.
+		used := map[string]bool{recvName: true}
+		resultsNamed := true
+		for i := 0; i < sig.Results().Len(); i++ {
+			if name := sig.Results().At(i).Name(); name == recvName {
+				resultsNamed = false
+			} else if name != "" && name != "_" {
+				used[name] = true
+			}
+		}
+		var (
+			params []*types.Var
+			args   []string
+		)
+		for i := 0; i < sig.Params().Len(); i++ {
+			p := sig.Params().At(i)
+			name := p.Name()
+			if name == "" || name == "_" || used[name] {
+				for j := i; ; j++ {
+					name = fmt.Sprintf("p%d", j)
+					if !used[name] {
+						break
+					}
+				}
+			}
+			used[name] = true
+			params = append(params, types.NewParam(p.Pos(), p.Pkg(), name, p.Type()))
+			args = append(args, name)
+		}
+		if sig.Variadic() {
+			args[len(args)-1] += "..."
+		}
+		results := sig.Results()
+		if !resultsNamed {
+			var vars []*types.Var
+			for i := 0; i < results.Len(); i++ {
+				vars = append(vars, types.NewParam(token.NoPos, nil, "", results.At(i).Type()))
+			}
+			results = types.NewTuple(vars...)
+		}
+		newSig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), results, sig.Variadic())
+
+		var ret string
+		if results.Len() > 0 {
+			ret = "return "
+		}
+		doc := fmt.Sprintf("%s forwards to the %s field.", m.Name(), di.Field.Name())
+		if di.iface != "" {
+			doc = fmt.Sprintf("%s implements %s by forwarding to the %s field.", m.Name(), di.iface, di.Field.Name())
+		}
+		fmt.Fprintf(out, `// %s
+func (%s %s%s%s) %s%s {
+	%s%s.%s.%s(%s)
+}
+`,
+			doc,
+			recvName,
+			star,
+			di.Outer.Obj().Name(),
+			typesutil.FormatTypeParams(typesinternal.TypeParams(di.Outer)),
+			m.Name(),
+			strings.TrimPrefix(types.TypeString(newSig, qual), "func"),
+			ret,
+			recvName,
+			di.Field.Name(),
+			m.Name(),
+			strings.Join(args, ", "))
+	}
+	return nil
+}
+
+// enclosingStructField returns the named field enclosing pos, and
+// the package-level struct type that declares it, if any.
+func enclosingStructField(info *types.Info, path []ast.Node, pos token.Pos) (*types.Named, *types.Var) {
+	for i, n := range path {
+		field, ok := n.(*ast.Field)
+		if !ok {
+			continue
+		}
+		if i+3 >= len(path) {
+			return nil, nil
+		}
+		_, ok1 := path[i+2].(*ast.StructType)
+		spec, ok2 := path[i+3].(*ast.TypeSpec)
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		tname, ok := info.Defs[spec.Name].(*types.TypeName)
+		if !ok || tname.IsAlias() || tname.Parent() != tname.Pkg().Scope() {
+			return nil, nil
+		}
+		outer, ok := tname.Type().(*types.Named)
+		if !ok {
+			return nil, nil
+		}
+
+		// Choose the name enclosing pos, or else the sole name.
+		var name *ast.Ident
+		for _, id := range field.Names {
+			if id.Pos() <= pos && pos <= id.End() {
+				name = id
+			}
+		}
+		if name == nil && len(field.Names) == 1 {
+			name = field.Names[0]
+		}
+		if name == nil {
+			return nil, nil
+		}
+		v, ok := info.Defs[name].(*types.Var)
+		if !ok || !v.IsField() {
+			return nil, nil
+		}
+		return outer, v
+	}
+	return nil, nil
+}
+
+// delegatePointerRecv reports whether methods added to the named
+// type should have pointer receivers: they should unless all of its
+// existing methods have value receivers.
+func delegatePointerRecv(t *types.Named) bool {
+	if t.NumMethods() == 0 {
+		return true
+	}
+	for i := 0; i < t.NumMethods(); i++ {
+		if _, ok := t.Method(i).Signature().Recv().Type().(*types.Pointer); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// delegateMethodSet returns the method set of a field of type t,
+// which is addressable if the delegating methods have pointer
+// receivers.
+func delegateMethodSet(t types.Type, pointer bool) *types.MethodSet {
+	if pointer && !types.IsInterface(t) {
+		if _, ok := t.Underlying().(*types.Pointer); !ok {
+			t = types.NewPointer(t)
+		}
+	}
+	return types.NewMethodSet(t)
+}
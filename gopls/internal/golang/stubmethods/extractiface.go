@@ -0,0 +1,142 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stubmethods
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ExtractIfaceInfo represents an interface to be declared after a
+// package-level named type, whose methods are some of those of the
+// type.
+type ExtractIfaceInfo struct {
+	Fset     *token.FileSet // the FileSet used to type-check the types below
+	Concrete *types.Named   // the type from which the interface is extracted
+	Methods  []*types.Func  // the methods of the interface, in order
+	Name     string         // the name of the interface, which is not yet declared
+
+	// Docs holds the doc comments of the methods, if any, which
+	// are copied to the interface. The caller may populate it.
+	Docs map[*types.Func]*ast.CommentGroup
+}
+
+// GetExtractIfaceInfo returns the interface that may be extracted at
+// the selection of the file: one whose methods are all the methods of
+// a type whose declaration is selected, or the selected methods of a
+// type. An empty selection must be within the name of a method. It
+// returns nil if there is none.
+func GetExtractIfaceInfo(fset *token.FileSet, info *types.Info, file *ast.File, start, end token.Pos) *ExtractIfaceInfo {
+	// eligible reports whether an interface may be extracted from
+	// the methods of the named type.
+	eligible := func(named *types.Named) bool {
+		if named == nil {
+			return false
+		}
+		obj := named.Obj()
+		return obj.Pkg() != nil &&
+			obj.Parent() == obj.Pkg().Scope() &&
+			named.TypeParams().Len() == 0 &&
+			!types.IsInterface(named)
+	}
+
+	var (
+		named   *types.Named
+		methods []*types.Func
+	)
+
+	// Is the selection within a type declaration?
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for _, n := range path {
+		if spec, ok := n.(*ast.TypeSpec); ok {
+			tname, _ := info.Defs[spec.Name].(*types.TypeName)
+			if tname == nil || tname.IsAlias() {
+				return nil
+			}
+			named, _ = tname.Type().(*types.Named)
+			if !eligible(named) || named.NumMethods() == 0 {
+				return nil
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				methods = append(methods, named.Method(i))
+			}
+			return newExtractIfaceInfo(fset, named, methods)
+		}
+	}
+
+	// Does the selection intersect method declarations of a single type?
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.FuncDecl)
+		if !ok || decl.Recv == nil {
+			continue
+		}
+		if start == end {
+			if !(decl.Name.Pos() <= start && start <= decl.Name.End()) {
+				continue
+			}
+		} else if !(decl.Pos() < end && start < decl.End()) {
+			continue
+		}
+		fn, _ := info.Defs[decl.Name].(*types.Func)
+		if fn == nil {
+			return nil
+		}
+		recv := fn.Signature().Recv().Type()
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+		recvNamed, _ := types.Unalias(recv).(*types.Named)
+		if named == nil {
+			named = recvNamed
+		}
+		if recvNamed != named || !eligible(named) {
+			return nil
+		}
+		methods = append(methods, fn)
+	}
+	if named == nil {
+		return nil
+	}
+	return newExtractIfaceInfo(fset, named, methods)
+}
+
+// newExtractIfaceInfo returns the interface to be extracted from the
+// methods of the named type, choosing a name for it based on that of
+// the type that is not yet declared in the package.
+func newExtractIfaceInfo(fset *token.FileSet, named *types.Named, methods []*types.Func) *ExtractIfaceInfo {
+	tname := named.Obj()
+	name := tname.Name() + "Interface"
+	for i := 1; tname.Pkg().Scope().Lookup(name) != nil; i++ {
+		name = fmt.Sprintf("%sInterface%d", tname.Name(), i)
+	}
+	return &ExtractIfaceInfo{
+		Fset:     fset,
+		Concrete: named,
+		Methods:  methods,
+		Name:     name,
+	}
+}
+
+// Emit writes to out the declaration of the interface.
+func (ei *ExtractIfaceInfo) Emit(out *bytes.Buffer, qual types.Qualifier) error {
+	fmt.Fprintf(out, "\ntype %s interface {\n", ei.Name)
+	for _, m := range ei.Methods {
+		if doc := ei.Docs[m]; doc != nil {
+			for _, c := range doc.List {
+				fmt.Fprintf(out, "\t%s\n", c.Text)
+			}
+		}
+		out.WriteString(m.Name())
+		types.WriteSignature(out, m.Signature(), qual)
+		out.WriteString("\n")
+	}
+	out.WriteString("}\n")
+	return nil
+}
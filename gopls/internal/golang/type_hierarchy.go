@@ -0,0 +1,269 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/cache/methodsets"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// This file defines the LSP type hierarchy operations
+// (prepareTypeHierarchy, supertypes, subtypes), which are computed
+// using the same global method-set index as the implementation query.
+//
+// Unlike Implementation, the type hierarchy reports the
+// interface/interface elements of the "implements" relation, so that
+// for example io.ReadWriter is a subtype of io.Reader.
+//
+// Only package-level types are reported; see package methodsets.
+
+// PrepareTypeHierarchy returns the TypeHierarchyItem for the named
+// type referred to at the given position, if any.
+func PrepareTypeHierarchy(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, pp protocol.Position) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "golang.PrepareTypeHierarchy")
+	defer done()
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.PositionPos(pp)
+	if err != nil {
+		return nil, err
+	}
+
+	_, obj, _ := referencedObject(pkg, pgf, pos)
+	tname := typeHierarchyObj(obj)
+	if tname == nil {
+		return nil, nil
+	}
+
+	var declLoc protocol.Location
+	if isBuiltin(tname) {
+		pgf, id, err := builtinDecl(ctx, snapshot, tname)
+		if err != nil {
+			return nil, err
+		}
+		declLoc, err = pgf.NodeLocation(id)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		declLoc, err = mapPosition(ctx, pkg.FileSet(), snapshot, tname.Pos(), adjustedObjEnd(tname))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pkgPath := "builtin"
+	if tname.Pkg() != nil {
+		pkgPath = tname.Pkg().Path()
+	}
+	return []protocol.TypeHierarchyItem{
+		typeHierarchyItem(tname.Name(), pkgPath, typeHierarchyKind(tname.Type()), declLoc),
+	}, nil
+}
+
+// Subtypes returns the package-level types that implement the type
+// denoted by the given item.
+func Subtypes(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, item protocol.TypeHierarchyItem) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "golang.Subtypes")
+	defer done()
+
+	return relatedTypes(ctx, snapshot, fh, item, methodsets.Subtype)
+}
+
+// Supertypes returns the package-level interface types that are
+// implemented by the type denoted by the given item.
+func Supertypes(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, item protocol.TypeHierarchyItem) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "golang.Supertypes")
+	defer done()
+
+	return relatedTypes(ctx, snapshot, fh, item, methodsets.Supertype)
+}
+
+// relatedTypes returns a new sorted array of items for the types
+// related by rel to the type denoted by item, which was previously
+// returned by PrepareTypeHierarchy.
+func relatedTypes(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, item protocol.TypeHierarchyItem, rel methodsets.TypeRelation) ([]protocol.TypeHierarchyItem, error) {
+	tname, err := typeHierarchyItemObj(ctx, snapshot, fh, item)
+	if err != nil {
+		return nil, err
+	}
+	queryType := tname.Type()
+
+	// Compute the method-set fingerprint used as a key to the global search.
+	key, hasMethods := methodsets.KeyOf(queryType)
+	if !hasMethods {
+		// A type with no methods yields an empty result.
+		// (No point reporting that every type satisfies 'any'.)
+		return nil, nil
+	}
+
+	// Search the method-set index of every package in the forward
+	// transitive closure of the workspace, including the package
+	// that declares the query type.
+	mps, err := snapshot.AllMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata.RemoveIntermediateTestVariants(&mps)
+	ids := make([]PackageID, len(mps))
+	for i, mp := range mps {
+		ids[i] = mp.ID
+	}
+	indexes, err := snapshot.MethodSets(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("querying method sets: %v", err)
+	}
+
+	var (
+		group   errgroup.Group
+		itemsMu sync.Mutex
+		items   []protocol.TypeHierarchyItem
+	)
+	for _, index := range indexes {
+		group.Go(func() error {
+			for _, res := range index.Search(key, rel, nil) {
+				group.Go(func() error {
+					loc, err := offsetToLocation(ctx, snapshot, res.Location.Filename, res.Location.Start, res.Location.End)
+					if err != nil {
+						return err
+					}
+					kind := protocol.Class
+					if res.IsInterface {
+						kind = protocol.Interface
+					}
+					itemsMu.Lock()
+					items = append(items, typeHierarchyItem(res.TypeName, res.PkgPath, kind, loc))
+					itemsMu.Unlock()
+					return nil
+				})
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Special case: the built-in error type is not indexed,
+	// so report it explicitly as a supertype (see #59527).
+	if rel&methodsets.Supertype != 0 &&
+		tname.Pkg() != nil &&
+		types.Implements(methodsets.EnsurePointer(queryType), errorInterfaceType) {
+		loc, err := errorLocation(ctx, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, typeHierarchyItem("error", "builtin", protocol.Interface, loc))
+	}
+
+	// Sort and de-duplicate items, which may be reported by more
+	// than one variant of a package, and discard the query type,
+	// which is related to itself if it is an interface.
+	sort.Slice(items, func(i, j int) bool {
+		return protocol.CompareLocation(typeHierarchyLocation(items[i]), typeHierarchyLocation(items[j])) < 0
+	})
+	self := typeHierarchyLocation(item)
+	out := items[:0]
+	for _, it := range items {
+		loc := typeHierarchyLocation(it)
+		if loc == self {
+			continue
+		}
+		if len(out) > 0 && typeHierarchyLocation(out[len(out)-1]) == loc {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out, nil
+}
+
+// typeHierarchyItemObj returns the type name denoted by an item
+// previously returned by PrepareTypeHierarchy.
+func typeHierarchyItemObj(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, item protocol.TypeHierarchyItem) (*types.TypeName, error) {
+	// The only built-in type with methods is error,
+	// which is declared in the fake builtin.go file.
+	builtin, err := snapshot.BuiltinFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if fh.URI() == builtin.URI {
+		if tname, ok := types.Universe.Lookup(item.Name).(*types.TypeName); ok {
+			return tname, nil
+		}
+		return nil, fmt.Errorf("%s is not a built-in type", item.Name)
+	}
+
+	pkg, pgf, err := NarrowestPackageForFile(ctx, snapshot, fh.URI())
+	if err != nil {
+		return nil, err
+	}
+	pos, err := pgf.PositionPos(item.SelectionRange.Start)
+	if err != nil {
+		return nil, err
+	}
+	_, obj, _ := referencedObject(pkg, pgf, pos)
+	tname := typeHierarchyObj(obj)
+	if tname == nil {
+		return nil, fmt.Errorf("no type declaration at %s:%v", fh.URI(), item.SelectionRange.Start)
+	}
+	return tname, nil
+}
+
+// typeHierarchyObj returns the named type denoted by obj, following
+// aliases, or nil if obj does not denote a named type.
+func typeHierarchyObj(obj types.Object) *types.TypeName {
+	tname, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	if tname.IsAlias() {
+		named, ok := types.Unalias(tname.Type()).(*types.Named)
+		if !ok {
+			return nil // alias for an unnamed type
+		}
+		tname = named.Origin().Obj()
+	}
+	if _, ok := tname.Type().(*types.TypeParam); ok {
+		return nil
+	}
+	return tname
+}
+
+// typeHierarchyKind returns the symbol kind of a named type.
+func typeHierarchyKind(t types.Type) protocol.SymbolKind {
+	if types.IsInterface(t) {
+		return protocol.Interface
+	}
+	return protocol.Class
+}
+
+func typeHierarchyItem(name, pkgPath string, kind protocol.SymbolKind, loc protocol.Location) protocol.TypeHierarchyItem {
+	return protocol.TypeHierarchyItem{
+		Name:           name,
+		Kind:           kind,
+		Detail:         pkgPath,
+		URI:            loc.URI,
+		Range:          loc.Range,
+		SelectionRange: loc.Range,
+	}
+}
+
+func typeHierarchyLocation(item protocol.TypeHierarchyItem) protocol.Location {
+	return protocol.Location{URI: item.URI, Range: item.SelectionRange}
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golang
+
+// This file defines the content of the read-only virtual documents
+// for cgo-generated files and instantiated generic functions.
+// (Package documentation and assembly listings are produced by
+// ./pkgdoc.go and ./assembly.go.)
+//
+// See ../server/text_document_content.go, which serves them.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/parsego"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/util/safetoken"
+	"golang.org/x/tools/internal/diff"
+	"golang.org/x/tools/internal/typesinternal"
+)
+
+// CgoFileText returns the content of the Go file that cgo generates
+// from the specified file.
+//
+// gopls type-checks cgo files directly (see [packagesinternal.TypecheckCgo]),
+// so the generated files are not part of the package metadata:
+// this function asks the go command to generate them.
+func CgoFileText(ctx context.Context, snapshot *cache.Snapshot, uri protocol.DocumentURI) ([]byte, error) {
+	inv, cleanupInvocation, err := snapshot.GoCommandInvocation(cache.NoNetwork, uri.DirPath(), "list", []string{"-compiled", "-f", "{{range .CompiledGoFiles}}{{println .}}{{end}}", "."})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanupInvocation()
+	stdout, err := snapshot.View().GoCommandRunner().Run(ctx, *inv)
+	if err != nil {
+		return nil, err
+	}
+
+	// The generated files have names in the build cache, so
+	// identify the one with a //line directive referring to
+	// the start of the original file.
+	directive := []byte(fmt.Sprintf("\n//line %s:1:1\n", uri.Path()))
+	for _, compiled := range strings.Fields(stdout.String()) {
+		if !filepath.IsAbs(compiled) {
+			continue // a file of the package directory, not generated
+		}
+		content, err := os.ReadFile(compiled)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(content, directive) {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no file generated by cgo from %s", filepath.Base(uri.Path()))
+}
+
+// instantiationAt returns the instantiation of a generic function
+// or method denoted by the identifier at the start of the path, or a
+// nil func if there is none. For a method, the type arguments are
+// those of its receiver type.
+//
+// Instantiations whose type arguments are type parameters, such as
+// calls within a generic function, are not reported.
+func instantiationAt(info *types.Info, path []ast.Node) (fn *types.Func, targs *types.TypeList) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	fn, ok = info.Uses[id].(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	if inst, ok := info.Instances[id]; ok {
+		targs = inst.TypeArgs
+	} else if recv := fn.Signature().Recv(); recv != nil {
+		if _, named := typesinternal.ReceiverNamed(recv); named != nil {
+			targs = named.TypeArgs()
+		}
+	}
+	if targs.Len() == 0 {
+		return nil, nil
+	}
+	for i := range targs.Len() {
+		if _, ok := targs.At(i).(*types.TypeParam); ok {
+			return nil, nil
+		}
+	}
+	return fn, targs
+}
+
+// instantiationName returns the name of an instantiated function
+// or method for display, e.g. "F[int]" or "Stack[string].Push".
+func instantiationName(fn *types.Func, targs *types.TypeList, qual types.Qualifier) string {
+	var args []string
+	for i := range targs.Len() {
+		args = append(args, types.TypeString(targs.At(i), qual))
+	}
+	name := fmt.Sprintf("[%s]", strings.Join(args, ", "))
+	if recv := fn.Signature().Recv(); recv != nil {
+		if _, named := typesinternal.ReceiverNamed(recv); named != nil {
+			return named.Obj().Name() + name + "." + fn.Name()
+		}
+	}
+	return fn.Name() + name
+}
+
+// InstantiationText returns the declaration of the generic function
+// or method instantiated at the specified position, in which the
+// type parameters are replaced by the type arguments.
+func InstantiationText(ctx context.Context, snapshot *cache.Snapshot, pkg *cache.Package, pgf *parsego.File, pos token.Pos) ([]byte, error) {
+	path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos)
+	fn, targs := instantiationAt(pkg.TypesInfo(), path)
+	if fn == nil {
+		return nil, fmt.Errorf("no instantiation of a generic function or method at this position")
+	}
+
+	// Find the declaration of the generic function, which may
+	// belong to another package.
+	origin := fn.Origin()
+	posn := safetoken.StartPosition(pkg.FileSet(), origin.Pos())
+	declPkg, declPGF, err := NarrowestPackageForFile(ctx, snapshot, protocol.URIFromPath(posn.Filename))
+	if err != nil {
+		return nil, err
+	}
+	namePos, err := safetoken.Pos(declPGF.Tok, posn.Offset)
+	if err != nil {
+		return nil, err
+	}
+	declPath, _ := astutil.PathEnclosingInterval(declPGF.File, namePos, namePos)
+	var decl *ast.FuncDecl
+	for _, n := range declPath {
+		if d, ok := n.(*ast.FuncDecl); ok && d.Name.Pos() == namePos {
+			decl = d
+			break
+		}
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("no declaration found for %s", origin.Name())
+	}
+	declFn, ok := declPkg.TypesInfo().Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("no declaration found for %s", origin.Name())
+	}
+
+	// Map each type parameter to its type argument,
+	// as it would be written in the declaring package.
+	qual := func(p *types.Package) string {
+		if p.Path() == declPkg.Types().Path() {
+			return ""
+		}
+		return p.Name()
+	}
+	tparams := declFn.Signature().TypeParams()
+	if declFn.Signature().Recv() != nil {
+		tparams = declFn.Signature().RecvTypeParams()
+	}
+	if tparams.Len() != targs.Len() {
+		return nil, fmt.Errorf("%s has %d type parameters, but %d type arguments", origin.Name(), tparams.Len(), targs.Len())
+	}
+	subst := make(map[types.Object]string)
+	for i := range tparams.Len() {
+		subst[tparams.At(i).Obj()] = types.TypeString(targs.At(i), qual)
+	}
+
+	// Delete the type parameter list of a function,
+	// and replace each reference to a type parameter.
+	start, end, err := safetoken.Offsets(declPGF.Tok, decl.Pos(), decl.End())
+	if err != nil {
+		return nil, err
+	}
+	var edits []diff.Edit
+	var tparamList *ast.FieldList
+	if list := decl.Type.TypeParams; list != nil {
+		tparamList = list
+		listStart, listEnd, err := safetoken.Offsets(declPGF.Tok, list.Opening, list.Closing+1)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, diff.Edit{Start: listStart - start, End: listEnd - start})
+	}
+	var inspectErr error
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == tparamList || inspectErr != nil {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			if arg, ok := subst[declPkg.TypesInfo().ObjectOf(id)]; ok {
+				idStart, idEnd, err := safetoken.Offsets(declPGF.Tok, id.Pos(), id.End())
+				if err != nil {
+					inspectErr = err
+					return false
+				}
+				edits = append(edits, diff.Edit{Start: idStart - start, End: idEnd - start, New: arg})
+			}
+		}
+		return true
+	})
+	if inspectErr != nil {
+		return nil, inspectErr
+	}
+	text, err := diff.Apply(string(declPGF.Src[start:end]), edits)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "// %s is an instantiation of %s,\n", instantiationName(fn, targs, qual), origin.FullName())
+	fmt.Fprintf(&buf, "// declared at %s:%d.\n\n", filepath.Base(posn.Filename), posn.Line)
+	fmt.Fprintf(&buf, "package %s\n\n", declPkg.Types().Name())
+	buf.WriteString(text)
+	buf.WriteString("\n")
+	return []byte(buf.String()), nil
+}
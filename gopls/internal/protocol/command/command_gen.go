@@ -25,11 +25,13 @@ import (
 // and executed by an ExecuteCommand request.
 const (
 	AddDependency           Command = "gopls.add_dependency"
+	AddFuzzTest             Command = "gopls.add_fuzz_test"
 	AddImport               Command = "gopls.add_import"
 	AddTelemetryCounters    Command = "gopls.add_telemetry_counters"
 	AddTest                 Command = "gopls.add_test"
 	ApplyFix                Command = "gopls.apply_fix"
 	Assembly                Command = "gopls.assembly"
+	CgoFile                 Command = "gopls.cgo_file"
 	ChangeSignature         Command = "gopls.change_signature"
 	CheckUpgrades           Command = "gopls.check_upgrades"
 	ClientOpenURL           Command = "gopls.client_open_url"
@@ -42,12 +44,15 @@ const (
 	GCDetails               Command = "gopls.gc_details"
 	Generate                Command = "gopls.generate"
 	GoGetPackage            Command = "gopls.go_get_package"
+	Instantiation           Command = "gopls.instantiation"
 	ListImports             Command = "gopls.list_imports"
 	ListKnownPackages       Command = "gopls.list_known_packages"
 	MaybePromptForTelemetry Command = "gopls.maybe_prompt_for_telemetry"
 	MemStats                Command = "gopls.mem_stats"
 	Modules                 Command = "gopls.modules"
+	MoveToPackage           Command = "gopls.move_to_package"
 	Packages                Command = "gopls.packages"
+	PropagateContext        Command = "gopls.propagate_context"
 	RegenerateCgo           Command = "gopls.regenerate_cgo"
 	RemoveDependency        Command = "gopls.remove_dependency"
 	ResetGoModDiagnostics   Command = "gopls.reset_go_mod_diagnostics"
@@ -71,11 +76,13 @@ const (
 
 var Commands = []Command{
 	AddDependency,
+	AddFuzzTest,
 	AddImport,
 	AddTelemetryCounters,
 	AddTest,
 	ApplyFix,
 	Assembly,
+	CgoFile,
 	ChangeSignature,
 	CheckUpgrades,
 	ClientOpenURL,
@@ -88,12 +95,15 @@ var Commands = []Command{
 	GCDetails,
 	Generate,
 	GoGetPackage,
+	Instantiation,
 	ListImports,
 	ListKnownPackages,
 	MaybePromptForTelemetry,
 	MemStats,
 	Modules,
+	MoveToPackage,
 	Packages,
+	PropagateContext,
 	RegenerateCgo,
 	RemoveDependency,
 	ResetGoModDiagnostics,
@@ -123,6 +133,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.AddDependency(ctx, a0)
+	case AddFuzzTest:
+		var a0 protocol.Location
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.AddFuzzTest(ctx, a0)
 	case AddImport:
 		var a0 AddImportArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -155,6 +171,13 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.Assembly(ctx, a0, a1, a2)
+	case CgoFile:
+		var a0 string
+		var a1 protocol.DocumentURI
+		if err := UnmarshalArgs(params.Arguments, &a0, &a1); err != nil {
+			return nil, err
+		}
+		return nil, s.CgoFile(ctx, a0, a1)
 	case ChangeSignature:
 		var a0 ChangeSignatureArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -228,6 +251,13 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.GoGetPackage(ctx, a0)
+	case Instantiation:
+		var a0 string
+		var a1 protocol.Location
+		if err := UnmarshalArgs(params.Arguments, &a0, &a1); err != nil {
+			return nil, err
+		}
+		return nil, s.Instantiation(ctx, a0, a1)
 	case ListImports:
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -250,12 +280,24 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return s.Modules(ctx, a0)
+	case MoveToPackage:
+		var a0 MoveToPackageArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.MoveToPackage(ctx, a0)
 	case Packages:
 		var a0 PackagesArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
 			return nil, err
 		}
 		return s.Packages(ctx, a0)
+	case PropagateContext:
+		var a0 PropagateContextArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.PropagateContext(ctx, a0)
 	case RegenerateCgo:
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -372,6 +414,14 @@ func NewAddDependencyCommand(title string, a0 DependencyArgs) *protocol.Command
 	}
 }
 
+func NewAddFuzzTestCommand(title string, a0 protocol.Location) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   AddFuzzTest.String(),
+		Arguments: MustMarshalArgs(a0),
+	}
+}
+
 func NewAddImportCommand(title string, a0 AddImportArgs) *protocol.Command {
 	return &protocol.Command{
 		Title:     title,
@@ -412,6 +462,14 @@ func NewAssemblyCommand(title string, a0 string, a1 string, a2 string) *protocol
 	}
 }
 
+func NewCgoFileCommand(title string, a0 string, a1 protocol.DocumentURI) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   CgoFile.String(),
+		Arguments: MustMarshalArgs(a0, a1),
+	}
+}
+
 func NewChangeSignatureCommand(title string, a0 ChangeSignatureArgs) *protocol.Command {
 	return &protocol.Command{
 		Title:     title,
@@ -508,6 +566,14 @@ func NewGoGetPackageCommand(title string, a0 GoGetPackageArgs) *protocol.Command
 	}
 }
 
+func NewInstantiationCommand(title string, a0 string, a1 protocol.Location) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   Instantiation.String(),
+		Arguments: MustMarshalArgs(a0, a1),
+	}
+}
+
 func NewListImportsCommand(title string, a0 URIArg) *protocol.Command {
 	return &protocol.Command{
 		Title:     title,
@@ -548,6 +614,14 @@ func NewModulesCommand(title string, a0 ModulesArgs) *protocol.Command {
 	}
 }
 
+func NewMoveToPackageCommand(title string, a0 MoveToPackageArgs) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   MoveToPackage.String(),
+		Arguments: MustMarshalArgs(a0),
+	}
+}
+
 func NewPackagesCommand(title string, a0 PackagesArgs) *protocol.Command {
 	return &protocol.Command{
 		Title:     title,
@@ -556,6 +630,14 @@ func NewPackagesCommand(title string, a0 PackagesArgs) *protocol.Command {
 	}
 }
 
+func NewPropagateContextCommand(title string, a0 PropagateContextArgs) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   PropagateContext.String(),
+		Arguments: MustMarshalArgs(a0),
+	}
+}
+
 func NewRegenerateCgoCommand(title string, a0 URIArg) *protocol.Command {
 	return &protocol.Command{
 		Title:     title,
@@ -167,6 +167,16 @@ type Interface interface {
 	// Used by the code action of the same name.
 	ExtractToNewFile(context.Context, protocol.Location) error
 
+	// MoveToPackage: Move selected declarations to another package
+	//
+	// Moves the selected top-level declarations to the specified
+	// package, which is created if it does not exist, and updates the
+	// references to them throughout the workspace. If no package is
+	// specified, the user is asked to choose one.
+	//
+	// Used by the code action of the same name.
+	MoveToPackage(context.Context, MoveToPackageArgs) error
+
 	// StartDebugging: Start the gopls debug server
 	//
 	// Start the gopls debug server if it isn't running, and return the debug
@@ -243,6 +253,9 @@ type Interface interface {
 	// AddTest: add test for the selected function
 	AddTest(context.Context, protocol.Location) (*protocol.WorkspaceEdit, error)
 
+	// AddFuzzTest: add fuzz test for the selected function
+	AddFuzzTest(context.Context, protocol.Location) (*protocol.WorkspaceEdit, error)
+
 	// MaybePromptForTelemetry: Prompt user to enable telemetry
 	//
 	// Checks for the right conditions, and then prompts the user
@@ -252,10 +265,23 @@ type Interface interface {
 
 	// ChangeSignature: Perform a "change signature" refactoring
 	//
-	// This command is experimental, currently only supporting parameter removal.
-	// Its signature will certainly change in the future (pun intended).
+	// This command is experimental. It adds, removes, reorders, renames, and
+	// retypes parameters, and adds, removes, and reorders results, updating
+	// all callers. Its signature will certainly change in the future (pun
+	// intended).
 	ChangeSignature(context.Context, ChangeSignatureArgs) (*protocol.WorkspaceEdit, error)
 
+	// PropagateContext: Add a context.Context parameter to a function and its callers
+	//
+	// Adds a ctx parameter of type context.Context to the function
+	// whose signature encloses the location, and updates its callers.
+	// Each caller passes a context that is already in scope, or else
+	// gains a ctx parameter itself and passes it, transitively.
+	// Exported functions, methods, and main stop the propagation, and
+	// pass context.TODO() instead. The resolved edits are annotated by
+	// function, so that clients that support it may preview them.
+	PropagateContext(context.Context, PropagateContextArgs) (*protocol.WorkspaceEdit, error)
+
 	// DiagnoseFiles: Cause server to publish diagnostics for the specified files.
 	//
 	// This command is needed by the 'gopls {check,fix}' CLI subcommands.
@@ -284,6 +310,22 @@ type Interface interface {
 	// The machine architecture is determined by the view.
 	Assembly(_ context.Context, viewID, packageID, symbol string) error
 
+	// CgoFile: Browse the Go file generated by cgo from a file.
+	//
+	// This command opens, as a read-only virtual document, the Go
+	// file that cgo generates from the specified file. It requires
+	// client support for the workspace/textDocumentContent request.
+	CgoFile(_ context.Context, viewID string, uri protocol.DocumentURI) error
+
+	// Instantiation: Browse an instantiation of a generic function.
+	//
+	// This command opens, as a read-only virtual document, the
+	// declaration of the generic function or method instantiated at
+	// the specified location, with its type parameters replaced by
+	// the type arguments. It requires client support for the
+	// workspace/textDocumentContent request.
+	Instantiation(_ context.Context, viewID string, loc protocol.Location) error
+
 	// ClientOpenURL: Request that the client open a URL in a browser.
 	ClientOpenURL(_ context.Context, url string) error
 
@@ -415,6 +457,18 @@ type AddImportArgs struct {
 	URI protocol.DocumentURI
 }
 
+type MoveToPackageArgs struct {
+	// Location is the range of the declarations to move.
+	Location protocol.Location
+	// PackagePath is the destination package: either an import
+	// path, or a path relative to the package of the declarations,
+	// such as "./util" or "../other/util". It may denote an existing
+	// package of the workspace, or a new package in the module of
+	// the declarations, which is created. If empty, the user is
+	// asked to choose among a few candidates.
+	PackagePath string
+}
+
 type ListKnownPackagesResult struct {
 	// Packages is a list of packages relative
 	// to the URIArg passed by the command request.
@@ -591,6 +645,8 @@ type AddTelemetryCountersArgs struct {
 //   - If the element is an integer, it references a positional parameter in the
 //     old signature.
 //   - If the element is a string, it is parsed as a new field to add.
+//   - If the element is an object, its OldIndex, NewField, and Default
+//     properties describe the field (see [ChangeSignatureParam]).
 //
 // Suppose we have a function `F(a, b int) (string, error)`. Here are some
 // examples of refactoring this signature in practice, eliding the 'Location'
@@ -599,6 +655,12 @@ type AddTelemetryCountersArgs struct {
 //   - `{ "NewParams": [1, 0], "NewResults": [0, 1] }` flips the parameter order
 //   - `{ "NewParams": [0, 1, "a int"], "NewResults": [0, 1] }` adds a new field
 //   - `{ "NewParams": [1, 2], "NewResults": [1] }` drops the `error` result
+//   - `{ "NewParams": [{"NewField": "ctx context.Context", "Default": "context.TODO()"}, 0, 1] }`
+//     adds a first parameter, passing context.TODO() at each call site
+//   - `{ "NewParams": [{"OldIndex": 0, "NewField": "x int64"}, 1] }` renames
+//     the first parameter and changes its type
+//
+// If NewResults is omitted, the results are unchanged.
 type ChangeSignatureArgs struct {
 	// Location is any range inside the function signature. By convention, this
 	// is the same location provided in the codeAction request.
@@ -619,31 +681,70 @@ type ChangeSignatureArgs struct {
 }
 
 // ChangeSignatureParam implements the API described in the doc string of
-// [ChangeSignatureArgs]: a union of JSON int | string.
+// [ChangeSignatureArgs]: a union of JSON int | string | object.
+//
+// In object form, an OldIndex property references a field of the old
+// signature, which is renamed or retyped if NewField is also set.
+// Otherwise, the object describes a new field, and Default is the
+// expression passed for it at each call site (for a parameter) or
+// returned for it by each return statement (for a result). If Default
+// is empty, the zero value of the field's type is used.
 type ChangeSignatureParam struct {
-	OldIndex int
-	NewField string
+	OldIndex *int   // index of the field in the old signature, or nil for a new field
+	NewField string // new field (e.g. "x int"), or the new name and type of an old field
+	Default  string // for a new field, the expression of its value (e.g. "context.TODO()")
+}
+
+// OldField returns a ChangeSignatureParam that references the field
+// of the old signature at index i, unchanged.
+func OldField(i int) ChangeSignatureParam {
+	return ChangeSignatureParam{OldIndex: &i}
 }
 
 func (a *ChangeSignatureParam) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err == nil {
-		a.NewField = s
+		*a = ChangeSignatureParam{NewField: s}
 		return nil
 	}
 	var i int
 	if err := json.Unmarshal(b, &i); err == nil {
-		a.OldIndex = i
+		*a = OldField(i)
+		return nil
+	}
+	var obj struct {
+		OldIndex *int
+		NewField string
+		Default  string
+	}
+	if err := json.Unmarshal(b, &obj); err == nil {
+		*a = ChangeSignatureParam(obj)
 		return nil
 	}
-	return fmt.Errorf("must be int or string")
+	return fmt.Errorf("must be int, string, or object")
 }
 
 func (a ChangeSignatureParam) MarshalJSON() ([]byte, error) {
-	if a.NewField != "" {
+	switch {
+	case a.OldIndex != nil && a.NewField == "" && a.Default == "":
+		return json.Marshal(*a.OldIndex)
+	case a.OldIndex == nil && a.Default == "":
 		return json.Marshal(a.NewField)
 	}
-	return json.Marshal(a.OldIndex)
+	return json.Marshal(struct {
+		OldIndex *int   `json:",omitempty"`
+		NewField string `json:",omitempty"`
+		Default  string `json:",omitempty"`
+	}(a))
+}
+
+// PropagateContextArgs specifies a "propagate context" refactoring to perform.
+type PropagateContextArgs struct {
+	// Location is any range inside the function signature.
+	Location protocol.Location
+
+	// Whether to resolve and return the edits.
+	ResolveEdits bool
 }
 
 // DiagnoseFilesArgs specifies a set of files for which diagnostics are wanted.
@@ -77,6 +77,11 @@ var renameProp = map[prop]string{
 	{"ServerCapabilities", "codeActionProvider"}: "interface{}",
 
 	{"ServerCapabilities", "inlayHintProvider"}: "interface{}",
+
+	// Virtual documents (with the "gopls" scheme) are not DocumentURIs.
+	{"TextDocumentContentParams", "uri"}:        "URI",
+	{"TextDocumentContentRefreshParams", "uri"}: "URI",
+
 	// slightly tricky
 	{"ServerCapabilities", "renameProvider"}: "interface{}",
 	// slightly tricky
@@ -5304,7 +5304,7 @@ type TextDocumentContentOptions struct {
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification#textDocumentContentParams
 type TextDocumentContentParams struct {
 	// The uri of the text document.
-	URI DocumentURI `json:"uri"`
+	URI URI `json:"uri"`
 }
 
 // Parameters for the `workspace/textDocumentContent/refresh` request.
@@ -5315,7 +5315,7 @@ type TextDocumentContentParams struct {
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification#textDocumentContentRefreshParams
 type TextDocumentContentRefreshParams struct {
 	// The uri of the text document to refresh.
-	URI DocumentURI `json:"uri"`
+	URI URI `json:"uri"`
 }
 
 // Text document content provider registration options.
@@ -205,6 +205,12 @@ func URIFromPath(path string) DocumentURI {
 
 const fileScheme = "file"
 
+// GoplsScheme is the URI scheme of the virtual documents served by
+// gopls through the workspace/textDocumentContent request. Such URIs
+// are not DocumentURIs, and are rejected by [ParseDocumentURI], so
+// they appear only in fields of type [URI].
+const GoplsScheme = "gopls"
+
 // isWindowsDrivePath returns true if the file path is of the form used by
 // Windows. We check if the path begins with a drive letter, followed by a ":".
 // For example: C:/x/y/z.
@@ -115,6 +115,10 @@ func TestParseDocumentURI(t *testing.T) {
 			input: "https://go.dev/",
 			want:  "DocumentURI scheme is not 'file': https://go.dev/",
 		},
+		{
+			input: "gopls:///doc/example.com/a.go?view=1", // virtual document
+			want:  "DocumentURI scheme is not 'file': gopls:///doc/example.com/a.go?view=1",
+		},
 	} {
 		uri, err := protocol.ParseDocumentURI(test.input)
 		var got string
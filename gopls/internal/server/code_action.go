@@ -192,6 +192,8 @@ func (s *server) CodeAction(ctx context.Context, params *protocol.CodeActionPara
 					settings.GoDoc,
 					settings.GoFreeSymbols,
 					settings.GoAssembly,
+					settings.GoCgo,
+					settings.GoInstantiation,
 					settings.GoplsDocFeatures:
 					return false // read-only query
 				}
@@ -7,6 +7,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 
 	"golang.org/x/tools/gopls/internal/cache"
@@ -21,6 +22,9 @@ import (
 
 // CodeLens reports the set of available CodeLenses
 // (range-associated commands) in the given file.
+//
+// If the client can resolve the commands of code lenses lazily, they
+// are omitted, and computed by ResolveCodeLens.
 func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
 	ctx, done := event.Start(ctx, "lsp.Server.codeLens", label.URI.Of(params.TextDocument.URI))
 	defer done()
@@ -31,16 +35,12 @@ func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams)
 	}
 	defer release()
 
-	var lensFuncs map[settings.CodeLensSource]cache.CodeLensSourceFunc
-	switch snapshot.FileKind(fh) {
-	case file.Mod:
-		lensFuncs = mod.CodeLensSources()
-	case file.Go:
-		lensFuncs = golang.CodeLensSources()
-	default:
+	lensFuncs := codeLensSources(snapshot.FileKind(fh))
+	if lensFuncs == nil {
 		// Unsupported file kind for a code lens.
 		return nil, nil
 	}
+	lazy := slices.Contains(snapshot.Options().CodeLensResolveOptions, "command")
 	var lenses []protocol.CodeLens
 	for kind, lensFunc := range lensFuncs {
 		if !snapshot.Options().Codelenses[kind] {
@@ -62,5 +62,51 @@ func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams)
 		}
 		return a.Command.Command < b.Command.Command
 	})
+	if lazy {
+		for i := range lenses {
+			lenses[i].Data = codeLensData{Command: lenses[i].Command}
+			lenses[i].Command = nil
+		}
+	}
 	return lenses, nil
 }
+
+// codeLensData is the Data of a code lens whose command is resolved
+// lazily: it holds the command, which the lens functions compute
+// cheaply, so that resolving a lens needs no further work.
+type codeLensData struct {
+	Command *protocol.Command `json:"command"`
+}
+
+// ResolveCodeLens computes the command of a code lens returned by
+// CodeLens without one.
+func (s *server) ResolveCodeLens(ctx context.Context, lens *protocol.CodeLens) (*protocol.CodeLens, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.resolveCodeLens")
+	defer done()
+
+	if lens.Command != nil || lens.Data == nil {
+		return lens, nil
+	}
+	var data codeLensData
+	if err := unmarshalData(lens.Data, &data); err != nil {
+		return nil, err
+	}
+	if data.Command == nil {
+		return nil, fmt.Errorf("code lens has no command to resolve")
+	}
+	resolved := *lens
+	resolved.Command = data.Command
+	return &resolved, nil
+}
+
+// codeLensSources returns the sources of code lenses for files of the
+// given kind, or nil if code lenses are not supported for them.
+func codeLensSources(kind file.Kind) map[settings.CodeLensSource]cache.CodeLensSourceFunc {
+	switch kind {
+	case file.Mod:
+		return mod.CodeLensSources()
+	case file.Go:
+		return golang.CodeLensSources()
+	}
+	return nil
+}
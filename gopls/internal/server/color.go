@@ -0,0 +1,47 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/golang"
+	"golang.org/x/tools/gopls/internal/label"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+func (s *server) DocumentColor(ctx context.Context, params *protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.documentColor", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil // empty result
+	}
+	return golang.DocumentColors(ctx, snapshot, fh)
+}
+
+func (s *server) ColorPresentation(ctx context.Context, params *protocol.ColorPresentationParams) ([]protocol.ColorPresentation, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.colorPresentation", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil // empty result
+	}
+	return golang.ColorPresentations(ctx, snapshot, fh, params.Color, params.Range)
+}
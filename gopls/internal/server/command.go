@@ -307,6 +307,23 @@ func (c *commandHandler) AddTest(ctx context.Context, loc protocol.Location) (*p
 	return result, err
 }
 
+func (c *commandHandler) AddFuzzTest(ctx context.Context, loc protocol.Location) (*protocol.WorkspaceEdit, error) {
+	var result *protocol.WorkspaceEdit
+	err := c.run(ctx, commandConfig{
+		forURI: loc.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		if deps.snapshot.FileKind(deps.fh) != file.Go {
+			return fmt.Errorf("can't add fuzz test for non-Go file")
+		}
+		docedits, err := golang.AddFuzzTestForFunc(ctx, deps.snapshot, loc)
+		if err != nil {
+			return err
+		}
+		return applyChanges(ctx, c.s.client, docedits)
+	})
+	return result, err
+}
+
 // commandConfig configures common command set-up and execution.
 type commandConfig struct {
 	requireSave bool                 // whether all files must be saved for the command to work
@@ -711,16 +728,25 @@ func (c *commandHandler) Doc(ctx context.Context, args command.DocArgs) (protoco
 			return err
 		}
 
+		// Compute package path and optional symbol fragment
+		// (e.g. "#Buffer.Len") from the the selection.
+		pkgpath, fragment, _ := golang.DocFragment(pkg, pgf, start, end)
+
+		// Direct the client to open the virtual document, if supported.
+		if c.s.Options().TextDocumentContentSupported {
+			result = docURI(deps.snapshot.View().ID(), pkgpath)
+			if args.ShowDocument {
+				showDocumentImpl(ctx, c.s.client, result, &protocol.Range{}, c.s.Options())
+			}
+			return nil
+		}
+
 		// Start web server.
 		web, err := c.s.getWeb()
 		if err != nil {
 			return err
 		}
 
-		// Compute package path and optional symbol fragment
-		// (e.g. "#Buffer.Len") from the the selection.
-		pkgpath, fragment, _ := golang.DocFragment(pkg, pgf, start, end)
-
 		// Direct the client to open the /pkg page.
 		result = web.PkgURL(deps.snapshot.View().ID(), pkgpath, fragment)
 		if args.ShowDocument {
@@ -1134,6 +1160,42 @@ func (c *commandHandler) ExtractToNewFile(ctx context.Context, args protocol.Loc
 	})
 }
 
+func (c *commandHandler) MoveToPackage(ctx context.Context, args command.MoveToPackageArgs) error {
+	return c.run(ctx, commandConfig{
+		progress: "Move to another package",
+		forURI:   args.Location.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		dst := golang.PackagePath(args.PackagePath)
+		if dst == "" {
+			// Ask the user to choose the destination.
+			dsts, err := golang.MoveDestinations(ctx, deps.snapshot, deps.fh, args.Location.Range)
+			if err != nil {
+				return err
+			}
+			params := &protocol.ShowMessageRequestParams{
+				Type:    protocol.Info,
+				Message: "Move the selected declarations to package:",
+			}
+			for _, dst := range dsts {
+				params.Actions = append(params.Actions, protocol.MessageActionItem{Title: string(dst)})
+			}
+			item, err := c.s.client.ShowMessageRequest(ctx, params)
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				return nil // dismissed
+			}
+			dst = golang.PackagePath(item.Title)
+		}
+		changes, err := golang.MoveToPackage(ctx, deps.snapshot, deps.fh, args.Location.Range, dst)
+		if err != nil {
+			return err
+		}
+		return applyChanges(ctx, c.s.client, changes)
+	})
+}
+
 func (c *commandHandler) StartDebugging(ctx context.Context, args command.DebuggingArgs) (result command.DebuggingResult, _ error) {
 	addr := args.Addr
 	if addr == "" {
@@ -1622,16 +1684,7 @@ func (c *commandHandler) ChangeSignature(ctx context.Context, args command.Chang
 			return err
 		}
 
-		// For now, gopls only supports parameter permutation or removal.
-		var perm []int
-		for _, newParam := range args.NewParams {
-			if newParam.NewField != "" {
-				return fmt.Errorf("adding new parameters is currently unsupported")
-			}
-			perm = append(perm, newParam.OldIndex)
-		}
-
-		docedits, err := golang.ChangeSignature(ctx, deps.snapshot, pkg, pgf, args.Location.Range, perm)
+		docedits, err := golang.ChangeSignature(ctx, deps.snapshot, pkg, pgf, args.Location.Range, args.NewParams, args.NewResults)
 		if err != nil {
 			return err
 		}
@@ -1645,6 +1698,28 @@ func (c *commandHandler) ChangeSignature(ctx context.Context, args command.Chang
 	return result, err
 }
 
+func (c *commandHandler) PropagateContext(ctx context.Context, args command.PropagateContextArgs) (*protocol.WorkspaceEdit, error) {
+	var result *protocol.WorkspaceEdit
+	err := c.run(ctx, commandConfig{
+		progress: "Propagating context",
+		forURI:   args.Location.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		// Edits applied directly are not annotated, as applyChanges
+		// sends only the document changes.
+		annotate := args.ResolveEdits && deps.snapshot.Options().ChangeAnnotationSupported
+		wsedit, err := golang.PropagateContext(ctx, deps.snapshot, deps.fh, args.Location.Range, annotate)
+		if err != nil {
+			return err
+		}
+		if args.ResolveEdits {
+			result = wsedit
+			return nil
+		}
+		return applyChanges(ctx, c.s.client, wsedit.DocumentChanges)
+	})
+	return result, err
+}
+
 func (c *commandHandler) DiagnoseFiles(ctx context.Context, args command.DiagnoseFilesArgs) error {
 	return c.run(ctx, commandConfig{
 		progress: "Diagnose files",
@@ -1715,6 +1790,13 @@ func (c *commandHandler) FreeSymbols(ctx context.Context, viewID string, loc pro
 }
 
 func (c *commandHandler) Assembly(ctx context.Context, viewID, packageID, symbol string) error {
+	// Open the virtual document, if supported.
+	if c.s.Options().TextDocumentContentSupported {
+		uri := assemblyURI(viewID, packageID, symbol)
+		showDocumentImpl(ctx, c.s.client, uri, &protocol.Range{}, c.s.Options())
+		return nil
+	}
+
 	web, err := c.s.getWeb()
 	if err != nil {
 		return err
@@ -1724,6 +1806,22 @@ func (c *commandHandler) Assembly(ctx context.Context, viewID, packageID, symbol
 	return nil
 }
 
+func (c *commandHandler) CgoFile(ctx context.Context, viewID string, uri protocol.DocumentURI) error {
+	if !c.s.Options().TextDocumentContentSupported {
+		return fmt.Errorf("client does not support virtual documents")
+	}
+	showDocumentImpl(ctx, c.s.client, cgoURI(viewID, uri), &protocol.Range{}, c.s.Options())
+	return nil
+}
+
+func (c *commandHandler) Instantiation(ctx context.Context, viewID string, loc protocol.Location) error {
+	if !c.s.Options().TextDocumentContentSupported {
+		return fmt.Errorf("client does not support virtual documents")
+	}
+	showDocumentImpl(ctx, c.s.client, instanceURI(viewID, loc), &protocol.Range{}, c.s.Options())
+	return nil
+}
+
 func (c *commandHandler) ClientOpenURL(ctx context.Context, url string) error {
 	// Fall back to "Gopls: open your browser..." if we must send a showMessage
 	// request, since we don't know the context of this command.
@@ -6,9 +6,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
+	"golang.org/x/tools/gopls/internal/cache"
 	"golang.org/x/tools/gopls/internal/file"
 	"golang.org/x/tools/gopls/internal/golang"
 	"golang.org/x/tools/gopls/internal/golang/completion"
@@ -73,7 +76,14 @@ func (s *server) Completion(ctx context.Context, params *protocol.CompletionPara
 	options := snapshot.Options()
 	incompleteResults := options.DeepCompletion || options.Matcher == settings.Fuzzy
 
-	items, err := toProtocolCompletionItems(candidates, surrounding, options)
+	// Retain the candidates whose computation was partly deferred,
+	// along with their snapshot, until they can be resolved.
+	var id uint64
+	if slices.ContainsFunc(candidates, func(item completion.CompletionItem) bool { return item.Lazy() }) {
+		id = s.saveCompletionCandidates(snapshot, candidates, surrounding)
+	}
+
+	items, err := toProtocolCompletionItems(candidates, surrounding, options, id)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +112,116 @@ func (s *server) saveLastCompletion(uri protocol.DocumentURI, version int32, ite
 	s.efficacyItems = items
 }
 
-func toProtocolCompletionItems(candidates []completion.CompletionItem, surrounding *completion.Selection, options *settings.Options) ([]protocol.CompletionItem, error) {
+// completionCandidates holds the candidates of a completion list,
+// for resolving its items.
+type completionCandidates struct {
+	id          uint64
+	candidates  []completion.CompletionItem
+	surrounding *completion.Selection
+	options     *settings.Options
+	snapshot    *cache.Snapshot // the snapshot of the candidates, while acquired
+	release     func()          // releases the snapshot of the candidates
+}
+
+// completionItemData is the Data of a completion item with deferred
+// properties: it identifies the candidate from which it was computed.
+type completionItemData struct {
+	ID    uint64 `json:"completionID"`
+	Index int    `json:"index"`
+}
+
+// saveCompletionCandidates records the candidates of the latest
+// completion list and acquires their snapshot, releasing those of the
+// previous list. It returns the ID of the list.
+//
+// The resolve functions of the candidates need the snapshot, but
+// retaining it costs nothing while it is the current snapshot of its
+// view. Once it is superseded, by a change to a file or to the
+// configuration, the candidates are stale and are forgotten (see
+// forgetCompletionCandidates), releasing the snapshot.
+func (s *server) saveCompletionCandidates(snapshot *cache.Snapshot, candidates []completion.CompletionItem, surrounding *completion.Selection) uint64 {
+	s.completionMu.Lock()
+	defer s.completionMu.Unlock()
+	if s.completionCandidates != nil {
+		s.completionCandidates.release()
+	}
+	s.completionID++
+	s.completionCandidates = &completionCandidates{
+		id:          s.completionID,
+		candidates:  candidates,
+		surrounding: surrounding,
+		options:     snapshot.Options(),
+		snapshot:    snapshot,
+		release:     snapshot.Acquire(),
+	}
+	return s.completionID
+}
+
+// forgetCompletionCandidates releases the candidates of the latest
+// completion list, if any.
+func (s *server) forgetCompletionCandidates() {
+	s.completionMu.Lock()
+	defer s.completionMu.Unlock()
+	if s.completionCandidates != nil {
+		s.completionCandidates.release()
+		s.completionCandidates = nil
+	}
+}
+
+// ResolveCompletionItem computes the properties of a completion item
+// whose computation was deferred by Completion. Only items of the
+// latest completion list can be resolved: others are returned as is.
+func (s *server) ResolveCompletionItem(ctx context.Context, item *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.resolveCompletionItem")
+	defer done()
+
+	if item.Data == nil {
+		return item, nil
+	}
+	var data completionItemData
+	if err := unmarshalData(item.Data, &data); err != nil {
+		return nil, err
+	}
+
+	// Copy the candidate out of the latest list, and acquire its
+	// snapshot, so that the list may be replaced or forgotten while
+	// the candidate is resolved.
+	s.completionMu.Lock()
+	last := s.completionCandidates
+	if last == nil || last.id != data.ID || data.Index < 0 || data.Index >= len(last.candidates) {
+		s.completionMu.Unlock()
+		return item, nil // stale item
+	}
+	candidate, surrounding, options := last.candidates[data.Index], last.surrounding, last.options
+	release := last.snapshot.Acquire()
+	s.completionMu.Unlock()
+	defer release()
+
+	candidate, err := candidate.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := toProtocolCompletionItems([]completion.CompletionItem{candidate}, surrounding, options, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return item, nil
+	}
+	resolved := items[0]
+	// Preserve the properties that depend on the item's place in the list.
+	resolved.SortText = item.SortText
+	resolved.FilterText = item.FilterText
+	resolved.Preselect = item.Preselect
+	resolved.Data = item.Data
+	return &resolved, nil
+}
+
+// toProtocolCompletionItems converts completion candidates to protocol
+// completion items. If id is nonzero, the items of candidates with
+// deferred properties refer to the candidate in the completion list
+// with that ID, for later resolution.
+func toProtocolCompletionItems(candidates []completion.CompletionItem, surrounding *completion.Selection, options *settings.Options, id uint64) ([]protocol.CompletionItem, error) {
 	replaceRng, err := surrounding.Range()
 	if err != nil {
 		return nil, err
@@ -195,7 +314,20 @@ func toProtocolCompletionItems(candidates []completion.CompletionItem, surroundi
 			Tags:          protocol.NonNilSlice(candidate.Tags),
 			Deprecated:    candidate.Deprecated,
 		}
+		if id != 0 && candidate.Lazy() {
+			item.Data = completionItemData{ID: id, Index: i}
+		}
 		items = append(items, item)
 	}
 	return items, nil
 }
+
+// unmarshalData decodes the Data field of a protocol value, which is
+// unmarshaled from JSON as a generic value, into v.
+func unmarshalData(data any, v any) error {
+	msg, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return protocol.UnmarshalJSON(msg, v)
+}
@@ -59,3 +59,24 @@ func (s *server) TypeDefinition(ctx context.Context, params *protocol.TypeDefini
 		return nil, fmt.Errorf("can't find type definitions for file type %s", kind)
 	}
 }
+
+func (s *server) Declaration(ctx context.Context, params *protocol.DeclarationParams) (*protocol.Or_textDocument_declaration, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.declaration", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	switch kind := snapshot.FileKind(fh); kind {
+	case file.Go:
+		locs, err := golang.Declaration(ctx, snapshot, fh, params.Position)
+		if err != nil {
+			return nil, err
+		}
+		return &protocol.Or_textDocument_declaration{Value: protocol.Declaration(locs)}, nil
+	default:
+		return nil, fmt.Errorf("can't find declarations for file type %s", kind)
+	}
+}
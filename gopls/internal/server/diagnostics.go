@@ -11,11 +11,13 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/tools/gopls/internal/cache"
 	"golang.org/x/tools/gopls/internal/cache/metadata"
 	"golang.org/x/tools/gopls/internal/file"
@@ -35,13 +37,15 @@ import (
 // Diagnostic implements the textDocument/diagnostic LSP request, reporting
 // diagnostics for the given file.
 //
-// This is a work in progress.
+// The result ID of each report is derived from the set of diagnostics,
+// so if the diagnostics of the file are unchanged since the report
+// identified by params.PreviousResultID, Diagnostic reports that they
+// are unchanged rather than sending them again.
+//
 // TODO(rfindley):
 //   - support RelatedDocuments? If so, how? Maybe include other package diagnostics?
-//   - support resultID (=snapshot ID)
 //   - support multiple views
 //   - add orphaned file diagnostics
-//   - support go.mod, go.work files
 func (s *server) Diagnostic(ctx context.Context, params *protocol.DocumentDiagnosticParams) (*protocol.DocumentDiagnosticReport, error) {
 	ctx, done := event.Start(ctx, "server.Diagnostic")
 	defer done()
@@ -55,26 +59,298 @@ func (s *server) Diagnostic(ctx context.Context, params *protocol.DocumentDiagno
 	jsonrpc2.Async(ctx) // allow asynchronous collection of diagnostics
 
 	uri := fh.URI()
-	kind := snapshot.FileKind(fh)
 	var diagnostics []*cache.Diagnostic
-	switch kind {
+	switch snapshot.FileKind(fh) {
 	case file.Go:
 		diagnostics, err = golang.DiagnoseFile(ctx, snapshot, uri)
 		if err != nil {
 			return nil, err
 		}
+	case file.Mod, file.Work:
+		diagnostics = modFileDiagnostics(ctx, snapshot)[uri]
+	case file.Tmpl:
+		diagnostics = template.Diagnostics(snapshot)[uri]
 	default:
 		return nil, fmt.Errorf("pull diagnostics not supported for this file kind")
 	}
+	diagnostics, resultID := uniqueDiagnostics(diagnostics)
+	if resultID == params.PreviousResultID {
+		return &protocol.DocumentDiagnosticReport{
+			Value: protocol.RelatedUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: protocol.UnchangedDocumentDiagnosticReport{
+					Kind:     string(protocol.DiagnosticUnchanged),
+					ResultID: resultID,
+				},
+			},
+		}, nil
+	}
 	return &protocol.DocumentDiagnosticReport{
 		Value: protocol.RelatedFullDocumentDiagnosticReport{
 			FullDocumentDiagnosticReport: protocol.FullDocumentDiagnosticReport{
-				Items: toProtocolDiagnostics(diagnostics),
+				Kind:     string(protocol.DiagnosticFull),
+				ResultID: resultID,
+				Items:    toProtocolDiagnostics(diagnostics),
 			},
 		},
 	}, nil
 }
 
+// DiagnosticWorkspace implements the workspace/diagnostic LSP request,
+// reporting diagnostics for every file of every workspace package, and
+// for the go.mod and go.work files of each view.
+//
+// Packages are diagnosed in parallel. If the client provided a partial
+// result token, the reports for the files of each package are streamed
+// to the client as soon as that package has been diagnosed, and the
+// final response is empty. Files whose diagnostics are unchanged since
+// the result ID recorded in params.PreviousResultIds are reported as
+// unchanged.
+//
+// Unlike push diagnostics, which analyze only packages with open files,
+// the workspace report includes analysis diagnostics for all workspace
+// packages.
+func (s *server) DiagnosticWorkspace(ctx context.Context, params *protocol.WorkspaceDiagnosticParams) (*protocol.WorkspaceDiagnosticReport, error) {
+	ctx, done := event.Start(ctx, "server.DiagnosticWorkspace")
+	defer done()
+
+	jsonrpc2.Async(ctx) // allow asynchronous collection of diagnostics
+
+	previous := make(map[protocol.DocumentURI]string)
+	for _, prev := range params.PreviousResultIds {
+		previous[prev.URI] = prev.Value
+	}
+
+	// report sends (or accumulates) the reports for a batch of files,
+	// skipping any file already reported, for example by another view.
+	var (
+		mu       sync.Mutex
+		reported = make(map[protocol.DocumentURI]bool)
+		items    = []protocol.WorkspaceDocumentDiagnosticReport{}
+	)
+	report := func(snapshot *cache.Snapshot, diagnostics diagMap) error {
+		var batch []protocol.WorkspaceDocumentDiagnosticReport
+		mu.Lock()
+		for uri, diags := range moremaps.Sorted(diagnostics) {
+			if reported[uri] {
+				continue
+			}
+			reported[uri] = true
+			fh, err := snapshot.ReadFile(ctx, uri)
+			if err != nil {
+				mu.Unlock()
+				return err
+			}
+			diags, resultID := uniqueDiagnostics(diags)
+			var item protocol.WorkspaceDocumentDiagnosticReport
+			if resultID == previous[uri] {
+				item.Value = protocol.WorkspaceUnchangedDocumentDiagnosticReport{
+					URI:     uri,
+					Version: fh.Version(),
+					UnchangedDocumentDiagnosticReport: protocol.UnchangedDocumentDiagnosticReport{
+						Kind:     string(protocol.DiagnosticUnchanged),
+						ResultID: resultID,
+					},
+				}
+			} else {
+				item.Value = protocol.WorkspaceFullDocumentDiagnosticReport{
+					URI:     uri,
+					Version: fh.Version(),
+					FullDocumentDiagnosticReport: protocol.FullDocumentDiagnosticReport{
+						Kind:     string(protocol.DiagnosticFull),
+						ResultID: resultID,
+						Items:    toProtocolDiagnostics(diags),
+					},
+				}
+			}
+			batch = append(batch, item)
+		}
+		if params.PartialResultToken == nil {
+			items = append(items, batch...)
+		}
+		mu.Unlock()
+
+		if params.PartialResultToken != nil && len(batch) > 0 {
+			return s.client.Progress(ctx, &protocol.ProgressParams{
+				Token: *params.PartialResultToken,
+				Value: protocol.WorkspaceDiagnosticReportPartialResult{Items: batch},
+			})
+		}
+		return nil
+	}
+
+	for _, view := range s.session.Views() {
+		snapshot, release, err := view.Snapshot()
+		if err != nil {
+			continue // view is shut down
+		}
+		err = s.diagnoseWorkspace(ctx, snapshot, report)
+		release()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &protocol.WorkspaceDiagnosticReport{Items: items}, nil
+}
+
+// diagnoseWorkspace computes the diagnostics of the module files and
+// workspace packages of the snapshot, calling report with the
+// diagnostics of each package as soon as they are available.
+func (s *server) diagnoseWorkspace(ctx context.Context, snapshot *cache.Snapshot, report func(*cache.Snapshot, diagMap) error) error {
+	ctx, done := event.Start(ctx, "Server.diagnoseWorkspace", snapshot.Labels()...)
+	defer done()
+
+	workspacePkgs, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Report go.mod, go.work, and template diagnostics first,
+	// along with any initialization error.
+	diagnostics := modFileDiagnostics(ctx, snapshot)
+	if initialErr := snapshot.InitializationError(); initialErr != nil {
+		for uri, diags := range initialErr.Diagnostics {
+			diagnostics[uri] = append(diagnostics[uri], diags...)
+		}
+	}
+	for uri, diags := range template.Diagnostics(snapshot) {
+		diagnostics[uri] = append(diagnostics[uri], diags...)
+	}
+	if err := report(snapshot, diagnostics); err != nil {
+		return err
+	}
+
+	// Group the workspace packages by package path, so that a package
+	// and its test variant, which share files, are diagnosed together.
+	// As in [server.diagnose], each group is analyzed using its widest
+	// package.
+	groups := make(map[golang.PackagePath][]*metadata.Package)
+	for _, mp := range workspacePkgs {
+		if !slices.ContainsFunc(mp.CompiledGoFiles, func(uri protocol.DocumentURI) bool { return !snapshot.IgnoredFile(uri) }) {
+			continue
+		}
+		groups[mp.PkgPath] = append(groups[mp.PkgPath], mp)
+	}
+
+	// Diagnostics for files outside the group's package (for
+	// example, go.mod errors reported by go list) are reported at
+	// the end, once all groups that might contribute to them are done.
+	var (
+		othersMu sync.Mutex
+		others   = make(diagMap)
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for _, pkgs := range groups {
+		g.Go(func() error {
+			var (
+				ids    []metadata.PackageID
+				widest *metadata.Package
+			)
+			for _, mp := range pkgs {
+				ids = append(ids, mp.ID)
+				if widest == nil || len(mp.CompiledGoFiles) > len(widest.CompiledGoFiles) {
+					widest = mp
+				}
+			}
+			pkgDiags, err := snapshot.PackageDiagnostics(ctx, ids...)
+			if err != nil {
+				return err
+			}
+			analysisDiags, err := golang.Analyze(ctx, snapshot, map[metadata.PackageID]*metadata.Package{widest.ID: widest}, nil)
+			if err != nil {
+				return err
+			}
+
+			diagnostics := make(diagMap)
+			for _, mp := range pkgs {
+				for _, uri := range mp.CompiledGoFiles {
+					if !snapshot.IgnoredFile(uri) {
+						diagnostics[uri] = nil // report files without diagnostics too
+					}
+				}
+			}
+			othersMu.Lock()
+			for uri, tdiags := range pkgDiags {
+				if _, ok := diagnostics[uri]; ok {
+					diagnostics[uri] = golang.CombineDiagnostics(tdiags, analysisDiags[uri])
+				} else {
+					others[uri] = append(others[uri], tdiags...)
+				}
+			}
+			for uri, adiags := range analysisDiags {
+				if _, ok := pkgDiags[uri]; ok {
+					continue // combined above
+				}
+				if _, ok := diagnostics[uri]; ok {
+					diagnostics[uri] = adiags
+				} else {
+					others[uri] = append(others[uri], adiags...)
+				}
+			}
+			othersMu.Unlock()
+			return report(snapshot, diagnostics)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return report(snapshot, others)
+}
+
+// modFileDiagnostics returns the diagnostics of the go.mod and go.work
+// files of the snapshot. Errors computing them are logged, not returned.
+//
+// Unlike push diagnostics, these include the results of go mod tidy,
+// which may be slow.
+func modFileDiagnostics(ctx context.Context, snapshot *cache.Snapshot) diagMap {
+	diagnostics := make(diagMap)
+	for _, uri := range snapshot.View().ModFiles() {
+		diagnostics[uri] = nil // report files without diagnostics too
+	}
+	for _, source := range []struct {
+		operation string
+		diagnose  func(context.Context, *cache.Snapshot) (map[protocol.DocumentURI][]*cache.Diagnostic, error)
+	}{
+		{"diagnosing go.work file", work.Diagnostics},
+		{"diagnosing go.mod file", mod.ParseDiagnostics},
+		{"diagnosing go.mod upgrades", mod.UpgradeDiagnostics},
+		{"diagnosing vulnerabilities", mod.VulnerabilityDiagnostics},
+		{"running go mod tidy", mod.TidyDiagnostics},
+	} {
+		diagsByFile, err := source.diagnose(ctx, snapshot)
+		if err != nil {
+			if ctx.Err() == nil {
+				event.Error(ctx, "warning: while "+source.operation, err, snapshot.Labels()...)
+			}
+			continue
+		}
+		for uri, diags := range diagsByFile {
+			diagnostics[uri] = append(diagnostics[uri], diags...)
+		}
+	}
+	return diagnostics
+}
+
+// uniqueDiagnostics returns the sorted set of distinct diagnostics
+// in diags, and a result ID that identifies the set.
+func uniqueDiagnostics(diags []*cache.Diagnostic) ([]*cache.Diagnostic, string) {
+	var (
+		hash   file.Hash
+		seen   = make(map[file.Hash]bool)
+		unique []*cache.Diagnostic
+	)
+	for _, diag := range diags {
+		h := diag.Hash()
+		if !seen[h] {
+			seen[h] = true
+			hash.XORWith(h)
+			unique = append(unique, diag)
+		}
+	}
+	sortDiagnostics(unique)
+	return unique, hash.String()
+}
+
 // fileDiagnostics holds the current state of published diagnostics for a file.
 type fileDiagnostics struct {
 	publishedHash file.Hash // hash of the last set of diagnostics published for this URI
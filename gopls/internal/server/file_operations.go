@@ -0,0 +1,253 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file defines the handlers for the workspace/{will,did}*Files
+// requests and notifications, which are sent by the client when the
+// user creates, renames, or deletes files through the editor.
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/golang"
+	"golang.org/x/tools/gopls/internal/label"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+// fileOperationFilters are the filters of the workspace file
+// operations in which the server is interested: Go files, and
+// directories that may contain them. (Only the first filter
+// applies to the creation of files.)
+var fileOperationFilters = []protocol.FileOperationFilter{
+	{
+		Scheme: "file",
+		Pattern: protocol.FileOperationPattern{
+			Glob:    "**/*.go",
+			Matches: ptrTo(protocol.FilePattern),
+		},
+	},
+	{
+		Scheme: "file",
+		Pattern: protocol.FileOperationPattern{
+			Glob:    "**",
+			Matches: ptrTo(protocol.FolderPattern),
+		},
+	},
+}
+
+func ptrTo[T any](x T) *T { return &x }
+
+func (s *server) WillCreateFiles(ctx context.Context, params *protocol.CreateFilesParams) (*protocol.WorkspaceEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.willCreateFiles")
+	defer done()
+
+	// Populate each new Go file with a package clause.
+	var changes []protocol.DocumentChange
+	for _, f := range params.Files {
+		uri, err := protocol.ParseDocumentURI(f.URI)
+		if err != nil {
+			return nil, err
+		}
+		fileChanges, err := s.willCreateFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fileChanges...)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return protocol.NewWorkspaceEdit(changes...), nil
+}
+
+// willCreateFile returns the document changes that populate the
+// specified new file, if it is an empty Go file within some view and
+// the client supports file creation in workspace edits.
+func (s *server) willCreateFile(ctx context.Context, uri protocol.DocumentURI) ([]protocol.DocumentChange, error) {
+	// The edit must create the file before populating it.
+	if !slices.Contains(s.Options().SupportedResourceOperations, protocol.Create) {
+		return nil, nil
+	}
+	snapshot, release, err := s.session.SnapshotOf(ctx, uri)
+	if err != nil {
+		return nil, nil // not in any view
+	}
+	defer release()
+	fh, err := snapshot.ReadFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil
+	}
+	if content, err := fh.Content(); err == nil && len(content) > 0 {
+		return nil, nil // file already exists
+	}
+	text, err := golang.NewFileContent(ctx, snapshot, uri)
+	if err != nil {
+		event.Error(ctx, "computing new file content", err, label.URI.Of(uri))
+		return nil, nil
+	}
+	// The client creates the file after applying the edit,
+	// so create it first.
+	return []protocol.DocumentChange{
+		{
+			CreateFile: &protocol.CreateFile{
+				Kind:    "create",
+				URI:     uri,
+				Options: &protocol.CreateFileOptions{IgnoreIfExists: true},
+			},
+		},
+		protocol.DocumentChangeEdit(fh, []protocol.TextEdit{{NewText: text}}),
+	}, nil
+}
+
+func (s *server) DidCreateFiles(ctx context.Context, params *protocol.CreateFilesParams) error {
+	ctx, done := event.Start(ctx, "lsp.Server.didCreateFiles")
+	defer done()
+
+	// Treat the creations as changes on disk.
+	var modifications []file.Modification
+	for _, f := range params.Files {
+		uri, err := protocol.ParseDocumentURI(f.URI)
+		if err != nil {
+			return err
+		}
+		modifications = append(modifications, file.Modification{
+			URI:    uri,
+			Action: file.Create,
+			OnDisk: true,
+		})
+	}
+	return s.didModifyFiles(ctx, modifications, FromDidCreateFiles)
+}
+
+func (s *server) WillRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.willRenameFiles")
+	defer done()
+
+	// Group the renamings by the view that contains them.
+	type viewRenames struct {
+		view    *cache.View
+		renames []protocol.FileRename
+	}
+	var groups []*viewRenames
+	for _, r := range params.Files {
+		uri, err := protocol.ParseDocumentURI(r.OldURI)
+		if err != nil {
+			return nil, err
+		}
+		snapshot, release, err := s.session.SnapshotOf(ctx, uri)
+		if err != nil {
+			continue // not in any view
+		}
+		view := snapshot.View()
+		release()
+		var group *viewRenames
+		for _, g := range groups {
+			if g.view == view {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &viewRenames{view: view}
+			groups = append(groups, group)
+		}
+		group.renames = append(group.renames, r)
+	}
+
+	var changes []protocol.DocumentChange
+	for _, g := range groups {
+		viewChanges, err := willRenameFilesInView(ctx, g.view, g.renames)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, viewChanges...)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return protocol.NewWorkspaceEdit(changes...), nil
+}
+
+// willRenameFilesInView returns the document changes required by the
+// renamings of files within a single view.
+func willRenameFilesInView(ctx context.Context, view *cache.View, renames []protocol.FileRename) ([]protocol.DocumentChange, error) {
+	snapshot, release, err := view.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	edits, err := golang.RenameFiles(ctx, snapshot, renames)
+	if err != nil {
+		return nil, err
+	}
+	var changes []protocol.DocumentChange
+	for uri, e := range edits {
+		fh, err := snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, protocol.DocumentChangeEdit(fh, e))
+	}
+	return changes, nil
+}
+
+func (s *server) DidRenameFiles(ctx context.Context, params *protocol.RenameFilesParams) error {
+	ctx, done := event.Start(ctx, "lsp.Server.didRenameFiles")
+	defer done()
+
+	// Treat each renaming as the deletion of the old file(s)
+	// and creation of the new ones on disk. (By now, the
+	// renaming has occurred.)
+	var modifications []file.Modification
+	for _, r := range params.Files {
+		oldURI, err := protocol.ParseDocumentURI(r.OldURI)
+		if err != nil {
+			return err
+		}
+		newURI, err := protocol.ParseDocumentURI(r.NewURI)
+		if err != nil {
+			return err
+		}
+		oldPath, newPath := oldURI.Path(), newURI.Path()
+		err = filepath.WalkDir(newPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(newPath, path)
+			if err != nil {
+				return err
+			}
+			modifications = append(modifications,
+				file.Modification{
+					URI:    protocol.URIFromPath(filepath.Join(oldPath, rel)),
+					Action: file.Delete,
+					OnDisk: true,
+				},
+				file.Modification{
+					URI:    protocol.URIFromPath(path),
+					Action: file.Create,
+					OnDisk: true,
+				})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.didModifyFiles(ctx, modifications, FromDidRenameFiles)
+}
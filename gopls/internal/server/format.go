@@ -36,3 +36,48 @@ func (s *server) Formatting(ctx context.Context, params *protocol.DocumentFormat
 	}
 	return nil, nil // empty result
 }
+
+func (s *server) RangeFormatting(ctx context.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.rangeFormatting", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	return s.rangesFormatting(ctx, params.TextDocument.URI, []protocol.Range{params.Range})
+}
+
+func (s *server) RangesFormatting(ctx context.Context, params *protocol.DocumentRangesFormattingParams) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.rangesFormatting", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	return s.rangesFormatting(ctx, params.TextDocument.URI, params.Ranges)
+}
+
+func (s *server) rangesFormatting(ctx context.Context, uri protocol.DocumentURI, ranges []protocol.Range) ([]protocol.TextEdit, error) {
+	fh, snapshot, release, err := s.fileOf(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	switch snapshot.FileKind(fh) {
+	case file.Go:
+		return golang.RangeFormat(ctx, snapshot, fh, ranges)
+	}
+	return nil, nil // empty result
+}
+
+func (s *server) OnTypeFormatting(ctx context.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.onTypeFormatting", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	switch snapshot.FileKind(fh) {
+	case file.Go:
+		return golang.OnTypeFormat(ctx, snapshot, fh, params.Position, params.Ch)
+	}
+	return nil, nil // empty result
+}
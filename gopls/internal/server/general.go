@@ -114,7 +114,7 @@ func (s *server) Initialize(ctx context.Context, params *protocol.ParamInitializ
 		diagnosticProvider = &protocol.Or_ServerCapabilities_diagnosticProvider{
 			Value: protocol.DiagnosticOptions{
 				InterFileDependencies: true,
-				WorkspaceDiagnostics:  false, // we don't support workspace/diagnostic
+				WorkspaceDiagnostics:  true,
 			},
 		}
 	}
@@ -137,16 +137,30 @@ func (s *server) Initialize(ctx context.Context, params *protocol.ParamInitializ
 		Capabilities: protocol.ServerCapabilities{
 			CallHierarchyProvider: &protocol.Or_ServerCapabilities_callHierarchyProvider{Value: true},
 			CodeActionProvider:    codeActionProvider,
-			CodeLensProvider:      &protocol.CodeLensOptions{}, // must be non-nil to enable the code lens capability
+			ColorProvider:         &protocol.Or_ServerCapabilities_colorProvider{Value: true},
+			CodeLensProvider:      &protocol.CodeLensOptions{ResolveProvider: true},
 			CompletionProvider: &protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
+				ResolveProvider:   true,
 			},
+			DeclarationProvider:        &protocol.Or_ServerCapabilities_declarationProvider{Value: true},
 			DefinitionProvider:         &protocol.Or_ServerCapabilities_definitionProvider{Value: true},
 			TypeDefinitionProvider:     &protocol.Or_ServerCapabilities_typeDefinitionProvider{Value: true},
+			TypeHierarchyProvider:      &protocol.Or_ServerCapabilities_typeHierarchyProvider{Value: true},
+			MonikerProvider:            &protocol.Or_ServerCapabilities_monikerProvider{Value: true},
 			ImplementationProvider:     &protocol.Or_ServerCapabilities_implementationProvider{Value: true},
 			DocumentFormattingProvider: &protocol.Or_ServerCapabilities_documentFormattingProvider{Value: true},
-			DocumentSymbolProvider:     &protocol.Or_ServerCapabilities_documentSymbolProvider{Value: true},
-			WorkspaceSymbolProvider:    &protocol.Or_ServerCapabilities_workspaceSymbolProvider{Value: true},
+			DocumentRangeFormattingProvider: &protocol.Or_ServerCapabilities_documentRangeFormattingProvider{
+				Value: protocol.DocumentRangeFormattingOptions{RangesSupport: true},
+			},
+			DocumentOnTypeFormattingProvider: &protocol.DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: "}",
+				MoreTriggerCharacter:  []string{";", "\n"},
+			},
+			DocumentSymbolProvider: &protocol.Or_ServerCapabilities_documentSymbolProvider{Value: true},
+			WorkspaceSymbolProvider: &protocol.Or_ServerCapabilities_workspaceSymbolProvider{
+				Value: protocol.WorkspaceSymbolOptions{ResolveProvider: true},
+			},
 			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
 				Commands: protocol.NonNilSlice(options.SupportedCommands),
 			},
@@ -155,13 +169,14 @@ func (s *server) Initialize(ctx context.Context, params *protocol.ParamInitializ
 			DocumentHighlightProvider: &protocol.Or_ServerCapabilities_documentHighlightProvider{Value: true},
 			DocumentLinkProvider:      &protocol.DocumentLinkOptions{},
 			InlayHintProvider:         protocol.InlayHintOptions{},
+			InlineValueProvider:       &protocol.Or_ServerCapabilities_inlineValueProvider{Value: true},
 			DiagnosticProvider:        diagnosticProvider,
 			ReferencesProvider:        &protocol.Or_ServerCapabilities_referencesProvider{Value: true},
 			RenameProvider:            renameOpts,
 			SelectionRangeProvider:    &protocol.Or_ServerCapabilities_selectionRangeProvider{Value: true},
 			SemanticTokensProvider: protocol.SemanticTokensOptions{
 				Range: &protocol.Or_SemanticTokensOptions_range{Value: true},
-				Full:  &protocol.Or_SemanticTokensOptions_full{Value: true},
+				Full:  &protocol.Or_SemanticTokensOptions_full{Value: protocol.SemanticTokensFullDelta{Delta: true}},
 				Legend: protocol.SemanticTokensLegend{
 					TokenTypes:     protocol.NonNilSlice(options.SemanticTypes),
 					TokenModifiers: protocol.NonNilSlice(options.SemanticMods),
@@ -176,12 +191,22 @@ func (s *server) Initialize(ctx context.Context, params *protocol.ParamInitializ
 				Save: &protocol.SaveOptions{
 					IncludeText: false,
 				},
+				WillSaveWaitUntil: true,
 			},
 			Workspace: &protocol.WorkspaceOptions{
 				WorkspaceFolders: &protocol.WorkspaceFolders5Gn{
 					Supported:           true,
 					ChangeNotifications: "workspace/didChangeWorkspaceFolders",
 				},
+				TextDocumentContent: &protocol.Or_WorkspaceOptions_textDocumentContent{
+					Value: protocol.TextDocumentContentOptions{Scheme: protocol.GoplsScheme},
+				},
+				FileOperations: &protocol.FileOperationOptions{
+					WillCreate: &protocol.FileOperationRegistrationOptions{Filters: fileOperationFilters[:1]},
+					DidCreate:  &protocol.FileOperationRegistrationOptions{Filters: fileOperationFilters[:1]},
+					WillRename: &protocol.FileOperationRegistrationOptions{Filters: fileOperationFilters},
+					DidRename:  &protocol.FileOperationRegistrationOptions{Filters: fileOperationFilters},
+				},
 			},
 		},
 		ServerInfo: &protocol.ServerInfo{
@@ -627,6 +652,9 @@ func (s *server) Shutdown(ctx context.Context) error {
 			s.web.server.Shutdown(ctx)
 		}
 
+		// Release the snapshot of pending completion candidates.
+		s.forgetCompletionCandidates()
+
 		// drop all the active views
 		s.session.Shutdown(ctx)
 		s.state = serverShutDown
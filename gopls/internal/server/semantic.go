@@ -6,6 +6,7 @@ package server
 
 import (
 	"context"
+	"strconv"
 
 	"golang.org/x/tools/gopls/internal/file"
 	"golang.org/x/tools/gopls/internal/golang"
@@ -16,7 +17,31 @@ import (
 )
 
 func (s *server) SemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
-	return s.semanticTokens(ctx, params.TextDocument, nil)
+	tokens, err := s.semanticTokens(ctx, params.TextDocument, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.saveSemanticTokens(params.TextDocument.URI, tokens)
+	return tokens, nil
+}
+
+// SemanticTokensFullDelta returns the edits that transform the tokens
+// of a previous full (or delta) response into the current tokens of
+// the document. If the previous result is unknown, perhaps because it
+// was superseded, it returns the full set of tokens instead.
+func (s *server) SemanticTokensFullDelta(ctx context.Context, params *protocol.SemanticTokensDeltaParams) (interface{}, error) {
+	tokens, err := s.semanticTokens(ctx, params.TextDocument, nil)
+	if err != nil {
+		return nil, err
+	}
+	prev := s.saveSemanticTokens(params.TextDocument.URI, tokens)
+	if prev == nil || prev.ResultID != params.PreviousResultID {
+		return tokens, nil
+	}
+	return &protocol.SemanticTokensDelta{
+		ResultID: tokens.ResultID,
+		Edits:    semanticTokensEdits(prev.Data, tokens.Data),
+	}, nil
 }
 
 func (s *server) SemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
@@ -54,3 +79,55 @@ func (s *server) semanticTokens(ctx context.Context, td protocol.TextDocumentIde
 	// as it is not marked optional in the protocol (golang/go#67885).
 	return &protocol.SemanticTokens{Data: []uint32{}}, nil
 }
+
+// saveSemanticTokens records tokens as the latest full set of tokens
+// for the document, assigning them a fresh result ID, and returns the
+// previously recorded set, if any.
+func (s *server) saveSemanticTokens(uri protocol.DocumentURI, tokens *protocol.SemanticTokens) *protocol.SemanticTokens {
+	s.semanticTokensMu.Lock()
+	defer s.semanticTokensMu.Unlock()
+
+	s.semanticTokensID++
+	tokens.ResultID = strconv.FormatUint(s.semanticTokensID, 10)
+	prev := s.semanticTokensCache[uri]
+	s.semanticTokensCache[uri] = tokens
+	return prev
+}
+
+// forgetSemanticTokens discards the recorded tokens of a closed document.
+func (s *server) forgetSemanticTokens(uri protocol.DocumentURI) {
+	s.semanticTokensMu.Lock()
+	defer s.semanticTokensMu.Unlock()
+	delete(s.semanticTokensCache, uri)
+}
+
+// semanticTokensEdits returns the edits that transform the encoded
+// tokens old into new. Edits are aligned to whole tokens (5 integers),
+// and consist of at most a single replacement of the portion between
+// the common prefix and suffix, which suffices for the typical case
+// of a localized change to a large file.
+func semanticTokensEdits(old, new []uint32) []protocol.SemanticTokensEdit {
+	const tokenSize = 5
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	prefix -= prefix % tokenSize
+
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	suffix -= suffix % tokenSize
+
+	if prefix == len(old) && prefix == len(new) {
+		return []protocol.SemanticTokensEdit{} // unchanged
+	}
+	return []protocol.SemanticTokensEdit{{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(old) - prefix - suffix),
+		Data:        new[prefix : len(new)-suffix],
+	}}
+}
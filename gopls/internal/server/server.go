@@ -49,6 +49,7 @@ func New(session *cache.Session, client protocol.ClientCloser, options *settings
 		progress:            progress.NewTracker(client),
 		options:             options,
 		viewsToDiagnose:     make(map[*cache.View]uint64),
+		semanticTokensCache: make(map[protocol.DocumentURI]*protocol.SemanticTokens),
 	}
 }
 
@@ -135,6 +136,25 @@ type server struct {
 	efficacyItems   []protocol.CompletionItem
 	efficacyPos     protocol.Position
 
+	// Track the candidates of the most recent completion list
+	// whose computation was partly deferred, for resolving them.
+	completionMu         sync.Mutex
+	completionID         uint64 // last assigned completion ID
+	completionCandidates *completionCandidates
+
+	// Track the most recent full semantic tokens of each open
+	// document, for computing semantic token deltas.
+	semanticTokensMu    sync.Mutex
+	semanticTokensID    uint64 // last assigned result ID
+	semanticTokensCache map[protocol.DocumentURI]*protocol.SemanticTokens
+
+	// Track the virtual documents served to the client, to refresh
+	// them after changes. (The client does not report when it
+	// closes them, as their URIs are not DocumentURIs; see
+	// refreshVirtualDocuments.)
+	virtualDocsMu sync.Mutex
+	virtualDocs   map[protocol.URI]*virtualDoc
+
 	// Web server (for package documentation, etc) associated with this
 	// LSP server. Opened on demand, and closed during LSP Shutdown.
 	webOnce sync.Once
@@ -328,13 +348,7 @@ func (s *server) initWeb() (*web, error) {
 		defer release()
 
 		// Find package by path.
-		var found *metadata.Package
-		for _, mp := range snapshot.MetadataGraph().Packages {
-			if string(mp.PkgPath) == req.URL.Path && mp.ForTest == "" {
-				found = mp
-				break
-			}
-		}
+		found := packageOfPath(snapshot.MetadataGraph(), golang.PackagePath(req.URL.Path))
 		if found == nil {
 			// TODO(adonovan): what should we do for external test packages?
 			http.Error(w, "package not found", http.StatusNotFound)
@@ -512,6 +526,17 @@ func (w *web) assemblyURL(viewID, packageID, symbol string) protocol.URI {
 		"")
 }
 
+// packageOfPath returns the (non-test) package of the specified path,
+// or nil if there is none.
+func packageOfPath(graph *metadata.Graph, path golang.PackagePath) *metadata.Package {
+	for _, mp := range graph.Packages {
+		if mp.PkgPath == path && mp.ForTest == "" {
+			return mp
+		}
+	}
+	return nil
+}
+
 // url returns a URL by joining a relative path, an (encoded) query,
 // and an (unencoded) fragment onto the authenticated base URL of the
 // web server.
@@ -0,0 +1,317 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file defines the handler for the workspace/textDocumentContent
+// request, through which gopls serves read-only virtual documents
+// whose URIs have the "gopls" scheme:
+//
+//	gopls:///doc/PKGPATH.go?view=V                        documentation of a package
+//	gopls:///assembly/SYMBOL.s?view=V&pkg=ID              assembly listing of a function
+//	gopls:///cgo/FILE.go?view=V&file=URI                  Go file generated by cgo
+//	gopls:///instance.go?view=V&file=URI&line=L&col=C     instantiation of a generic function
+//
+// The first two are the counterparts of the /pkg and /assembly pages
+// of the web server (see server.go), for clients that support the
+// request.
+//
+// Virtual document URIs are not DocumentURIs (see
+// [protocol.GoplsScheme]): this handler is the only one that accepts
+// them, so the client cannot use the other features of gopls, such as
+// hover or navigation, within a virtual document.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/cache/metadata"
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/golang"
+	"golang.org/x/tools/gopls/internal/label"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// docURI returns the URI of the virtual document of the documentation
+// of the specified package.
+func docURI(viewID string, path golang.PackagePath) protocol.URI {
+	return virtualURI("doc/"+string(path)+".go", url.Values{"view": {viewID}})
+}
+
+// assemblyURI returns the URI of the virtual document of the assembly
+// listing of the specified function.
+func assemblyURI(viewID, packageID, symbol string) protocol.URI {
+	return virtualURI("assembly/"+symbol+".s", url.Values{"view": {viewID}, "pkg": {packageID}})
+}
+
+// cgoURI returns the URI of the virtual document of the Go file
+// generated by cgo from the specified file.
+func cgoURI(viewID string, uri protocol.DocumentURI) protocol.URI {
+	return virtualURI("cgo/"+filepath.Base(uri.Path()), url.Values{"view": {viewID}, "file": {string(uri)}})
+}
+
+// instanceURI returns the URI of the virtual document of the
+// instantiation of a generic function at the specified location.
+func instanceURI(viewID string, loc protocol.Location) protocol.URI {
+	pos := loc.Range.Start
+	return virtualURI("instance.go", url.Values{
+		"view": {viewID},
+		"file": {string(loc.URI)},
+		"line": {fmt.Sprint(pos.Line)},
+		"col":  {fmt.Sprint(pos.Character)},
+	})
+}
+
+func virtualURI(path string, query url.Values) protocol.URI {
+	u := url.URL{
+		Scheme:   protocol.GoplsScheme,
+		Path:     "/" + path,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+func (s *server) TextDocumentContent(ctx context.Context, params *protocol.TextDocumentContentParams) (*string, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.textDocumentContent", label.URI.Of(params.URI))
+	defer done()
+
+	content, doc, err := s.virtualDocument(ctx, params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the document, to refresh it after changes.
+	s.virtualDocsMu.Lock()
+	if s.virtualDocs == nil {
+		s.virtualDocs = make(map[protocol.URI]*virtualDoc)
+	}
+	s.virtualDocs[params.URI] = doc
+	s.virtualDocsMu.Unlock()
+
+	text := string(content)
+	return &text, nil
+}
+
+// A virtualDoc records a virtual document served to the client.
+type virtualDoc struct {
+	view  string                 // ID of the view of the document
+	files []protocol.DocumentURI // files from which the content is derived
+
+	// refreshed reports whether the client was asked to refresh the
+	// document and has not yet requested its content since.
+	refreshed bool
+}
+
+// newVirtualDoc returns a virtualDoc whose content is derived from the
+// files of the specified packages.
+func newVirtualDoc(snapshot *cache.Snapshot, pkgs ...*metadata.Package) *virtualDoc {
+	doc := &virtualDoc{view: snapshot.View().ID()}
+	for _, mp := range pkgs {
+		doc.files = append(doc.files, mp.CompiledGoFiles...)
+		doc.files = append(doc.files, mp.GoFiles...)
+	}
+	slices.Sort(doc.files)
+	doc.files = slices.Compact(doc.files)
+	return doc
+}
+
+// withImports returns the specified package followed by the packages
+// that it imports directly.
+func withImports(snapshot *cache.Snapshot, mp *metadata.Package) []*metadata.Package {
+	pkgs := []*metadata.Package{mp}
+	for _, id := range mp.DepsByPkgPath {
+		if dep := snapshot.Metadata(id); dep != nil {
+			pkgs = append(pkgs, dep)
+		}
+	}
+	return pkgs
+}
+
+// virtualDocument computes the content of the virtual document
+// denoted by a URI, and the record of the files it is derived from.
+func (s *server) virtualDocument(ctx context.Context, uri protocol.URI) ([]byte, *virtualDoc, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != protocol.GoplsScheme {
+		return nil, nil, fmt.Errorf("not a virtual document: %s", uri)
+	}
+	query := u.Query()
+
+	// Get snapshot of specified view.
+	view, err := s.session.View(query.Get("view"))
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot, release, err := view.Snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	switch kind {
+	case "doc":
+		mp := packageOfPath(snapshot.MetadataGraph(), golang.PackagePath(strings.TrimSuffix(rest, ".go")))
+		if mp == nil {
+			return nil, nil, fmt.Errorf("package not found: %s", rest)
+		}
+		pkgs, err := snapshot.TypeCheck(ctx, mp.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		content, err := golang.PackageDocText(pkgs[0])
+		return content, newVirtualDoc(snapshot, mp), err
+
+	case "assembly":
+		pkgID := metadata.PackageID(query.Get("pkg"))
+		symbol := strings.TrimSuffix(rest, ".s")
+		if pkgID == "" || symbol == "" {
+			return nil, nil, fmt.Errorf("assembly document requires pkg and symbol: %s", uri)
+		}
+		pkgs, err := snapshot.TypeCheck(ctx, pkgID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Functions of imported packages may be inlined.
+		content, err := golang.AssemblyText(ctx, snapshot, pkgs[0], symbol)
+		return content, newVirtualDoc(snapshot, withImports(snapshot, pkgs[0].Metadata())...), err
+
+	case "cgo":
+		file, err := protocol.ParseDocumentURI(query.Get("file"))
+		if err != nil {
+			return nil, nil, err
+		}
+		mps, err := snapshot.MetadataForFile(ctx, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		content, err := golang.CgoFileText(ctx, snapshot, file)
+		return content, newVirtualDoc(snapshot, mps...), err
+
+	case "instance.go":
+		file, err := protocol.ParseDocumentURI(query.Get("file"))
+		if err != nil {
+			return nil, nil, err
+		}
+		line, err1 := strconv.ParseUint(query.Get("line"), 10, 32)
+		col, err2 := strconv.ParseUint(query.Get("col"), 10, 32)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, nil, fmt.Errorf("invalid position: %v", err)
+		}
+		pkg, pgf, err := golang.NarrowestPackageForFile(ctx, snapshot, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		pos, err := pgf.PositionPos(protocol.Position{Line: uint32(line), Character: uint32(col)})
+		if err != nil {
+			return nil, nil, err
+		}
+		// The generic function may be declared in an imported package.
+		content, err := golang.InstantiationText(ctx, snapshot, pkg, pgf, pos)
+		return content, newVirtualDoc(snapshot, withImports(snapshot, pkg.Metadata())...), err
+	}
+	return nil, nil, fmt.Errorf("unknown virtual document: %s", uri)
+}
+
+// refreshVirtualDocuments asks the client to refresh the virtual
+// documents it has been served whose content may have changed after
+// the specified modifications, that is, those derived from files in
+// the modified directories.
+//
+// It also forgets the documents that the client has closed or that
+// can no longer be computed. As the client does not report when it
+// closes a virtual document, a document is deemed closed if the client
+// has not requested its content since it was last asked to refresh it.
+func (s *server) refreshVirtualDocuments(ctx context.Context, modifications []file.Modification) {
+	if !s.Options().TextDocumentContentSupported {
+		return
+	}
+	modified := make(map[protocol.DocumentURI]bool)
+	for _, mod := range modifications {
+		modified[mod.URI] = true
+		modified[mod.URI.Dir()] = true
+	}
+
+	docs := make(map[protocol.URI]*virtualDoc)
+	s.virtualDocsMu.Lock()
+	for uri, doc := range s.virtualDocs {
+		if doc.refreshed {
+			delete(s.virtualDocs, uri) // closed
+		} else {
+			docs[uri] = doc
+		}
+	}
+	s.virtualDocsMu.Unlock()
+
+	var (
+		forget  []protocol.URI
+		refresh []protocol.URI
+	)
+	for uri, doc := range docs {
+		switch {
+		case !s.virtualDocExists(ctx, doc):
+			forget = append(forget, uri)
+		case slices.ContainsFunc(doc.files, func(f protocol.DocumentURI) bool { return modified[f.Dir()] }):
+			refresh = append(refresh, uri)
+		}
+	}
+
+	// Documents served again in the meantime have new records.
+	s.virtualDocsMu.Lock()
+	for _, uri := range forget {
+		if s.virtualDocs[uri] == docs[uri] {
+			delete(s.virtualDocs, uri)
+		}
+	}
+	for _, uri := range refresh {
+		docs[uri].refreshed = true
+	}
+	s.virtualDocsMu.Unlock()
+	slices.Sort(refresh)
+
+	// The client may request the new content before it replies,
+	// so don't wait for it.
+	ctx = xcontext.Detach(ctx)
+	go func() {
+		for _, uri := range refresh {
+			if err := s.client.TextDocumentContentRefresh(ctx, &protocol.TextDocumentContentRefreshParams{URI: uri}); err != nil {
+				event.Error(ctx, "refreshing virtual document", err, label.URI.Of(uri))
+			}
+		}
+	}()
+}
+
+// virtualDocExists reports whether the view of a virtual document
+// still exists, and so does one of the files its content is derived
+// from.
+func (s *server) virtualDocExists(ctx context.Context, doc *virtualDoc) bool {
+	view, err := s.session.View(doc.view)
+	if err != nil {
+		return false
+	}
+	snapshot, release, err := view.Snapshot()
+	if err != nil {
+		return false
+	}
+	defer release()
+	return slices.ContainsFunc(doc.files, func(uri protocol.DocumentURI) bool {
+		fh, err := snapshot.ReadFile(ctx, uri)
+		if err != nil {
+			return false
+		}
+		_, err = fh.Content()
+		return err == nil
+	})
+}
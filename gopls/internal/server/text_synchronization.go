@@ -64,6 +64,12 @@ const (
 	// FromToggleGCDetails refers to state changes resulting from toggling
 	// gc_details on or off for a package.
 	FromToggleGCDetails
+
+	// FromDidRenameFiles is from a didRenameFiles notification.
+	FromDidRenameFiles
+
+	// FromDidCreateFiles is from a didCreateFiles notification.
+	FromDidCreateFiles
 )
 
 func (m ModificationSource) String() string {
@@ -86,6 +92,10 @@ func (m ModificationSource) String() string {
 		return "from check upgrades"
 	case FromResetGoModDiagnostics:
 		return "from resetting go.mod diagnostics"
+	case FromDidRenameFiles:
+		return "renamed files"
+	case FromDidCreateFiles:
+		return "created files"
 	default:
 		return "unknown file modification"
 	}
@@ -203,10 +213,31 @@ func (s *server) DidSave(ctx context.Context, params *protocol.DidSaveTextDocume
 	return s.didModifyFiles(ctx, []file.Modification{c}, FromDidSave)
 }
 
+func (s *server) WillSave(ctx context.Context, params *protocol.WillSaveTextDocumentParams) error {
+	return nil
+}
+
+func (s *server) WillSaveWaitUntil(ctx context.Context, params *protocol.WillSaveTextDocumentParams) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.willSaveWaitUntil", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil
+	}
+	return golang.WillSave(ctx, snapshot, fh)
+}
+
 func (s *server) DidClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) error {
 	ctx, done := event.Start(ctx, "lsp.Server.didClose", label.URI.Of(params.TextDocument.URI))
 	defer done()
 
+	s.forgetSemanticTokens(params.TextDocument.URI)
+
 	return s.didModifyFiles(ctx, []file.Modification{
 		{
 			URI:     params.TextDocument.URI,
@@ -258,6 +289,16 @@ func (s *server) didModifyFiles(ctx context.Context, modifications []file.Modifi
 		return err
 	}
 
+	// The snapshot of the pending completion candidates is no
+	// longer current, and neither are the candidates.
+	s.forgetCompletionCandidates()
+
+	// Virtual documents depend on saved files (assembly listings
+	// are compiled from them), so refresh them after saving.
+	if cause == FromDidSave || cause == FromDidChangeWatchedFiles || cause == FromDidRenameFiles || cause == FromDidCreateFiles {
+		s.refreshVirtualDocuments(ctx, modifications)
+	}
+
 	// golang/go#50267: diagnostics should be re-sent after each change.
 	for _, mod := range modifications {
 		s.mustPublishDiagnostics(mod.URI)
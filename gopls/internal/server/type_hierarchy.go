@@ -0,0 +1,60 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+
+	"golang.org/x/tools/gopls/internal/file"
+	"golang.org/x/tools/gopls/internal/golang"
+	"golang.org/x/tools/gopls/internal/label"
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/internal/event"
+)
+
+func (s *server) PrepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.prepareTypeHierarchy", label.URI.Of(params.TextDocument.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil // empty result
+	}
+	return golang.PrepareTypeHierarchy(ctx, snapshot, fh, params.Position)
+}
+
+func (s *server) Subtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.subtypes", label.URI.Of(params.Item.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.Item.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil // empty result
+	}
+	return golang.Subtypes(ctx, snapshot, fh, params.Item)
+}
+
+func (s *server) Supertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.supertypes", label.URI.Of(params.Item.URI))
+	defer done()
+
+	fh, snapshot, release, err := s.fileOf(ctx, params.Item.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, nil // empty result
+	}
+	return golang.Supertypes(ctx, snapshot, fh, params.Item)
+}
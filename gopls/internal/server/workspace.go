@@ -34,6 +34,7 @@ func (s *server) DidChangeWorkspaceFolders(ctx context.Context, params *protocol
 		}
 	}
 	s.addFolders(ctx, params.Event.Added)
+	s.forgetCompletionCandidates() // their snapshot may no longer be current
 	return nil
 }
 
@@ -120,6 +121,7 @@ func (s *server) DidChangeConfiguration(ctx context.Context, _ *protocol.DidChan
 		newFolders = append(newFolders, newFolder)
 	}
 	s.session.UpdateFolders(ctx, newFolders)
+	s.forgetCompletionCandidates() // their snapshot is no longer current
 
 	// The view set may have been updated above.
 	viewsToDiagnose := make(map[*cache.View][]protocol.DocumentURI)
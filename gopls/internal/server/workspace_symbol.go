@@ -6,8 +6,11 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"golang.org/x/tools/gopls/internal/cache"
+	"golang.org/x/tools/gopls/internal/file"
 	"golang.org/x/tools/gopls/internal/golang"
 	"golang.org/x/tools/gopls/internal/protocol"
 	"golang.org/x/tools/gopls/internal/telemetry"
@@ -39,3 +42,67 @@ func (s *server) Symbol(ctx context.Context, params *protocol.WorkspaceSymbolPar
 	}
 	return golang.WorkspaceSymbols(ctx, matcher, style, snapshots, params.Query)
 }
+
+// ResolveWorkspaceSymbol computes the range of a workspace symbol whose
+// location is only a URI. (The symbols reported by Symbol always have
+// complete locations, but clients may construct symbols of their own.)
+func (s *server) ResolveWorkspaceSymbol(ctx context.Context, sym *protocol.WorkspaceSymbol) (*protocol.WorkspaceSymbol, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.resolveWorkspaceSymbol")
+	defer done()
+
+	var uri protocol.DocumentURI
+	switch loc := sym.Location.Value.(type) {
+	case protocol.LocationUriOnly:
+		uri = loc.URI
+	case protocol.Location:
+		// A location without a range is decoded as a Location with
+		// an empty range, which no symbol has.
+		if loc.Range != (protocol.Range{}) {
+			return sym, nil // location is already complete
+		}
+		uri = loc.URI
+	default:
+		return nil, fmt.Errorf("invalid location for symbol %s", sym.Name)
+	}
+	fh, snapshot, release, err := s.fileOf(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if snapshot.FileKind(fh) != file.Go {
+		return nil, fmt.Errorf("can't resolve symbol in non-Go file %s", uri)
+	}
+
+	docSymbols, err := golang.DocumentSymbols(ctx, snapshot, fh)
+	if err != nil {
+		return nil, err
+	}
+	// Depending on the symbol style, the name of the workspace symbol
+	// may be qualified by its package and its type, and the name of a
+	// document symbol by its receiver: compare only their last segment.
+	lastSegment := func(name string) string {
+		return name[strings.LastIndexByte(name, '.')+1:]
+	}
+	name := lastSegment(sym.Name)
+	var find func([]protocol.DocumentSymbol) *protocol.DocumentSymbol
+	find = func(symbols []protocol.DocumentSymbol) *protocol.DocumentSymbol {
+		for i, ds := range symbols {
+			if ds.Kind == sym.Kind && lastSegment(ds.Name) == name {
+				return &symbols[i]
+			}
+			if found := find(ds.Children); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	found := find(docSymbols)
+	if found == nil {
+		return nil, fmt.Errorf("no symbol %s in %s", sym.Name, uri)
+	}
+	resolved := *sym
+	resolved.Location = protocol.OrPLocation_workspace_symbol{
+		Value: protocol.Location{URI: uri, Range: found.SelectionRange},
+	}
+	return &resolved, nil
+}
@@ -75,37 +75,55 @@ import "golang.org/x/tools/gopls/internal/protocol"
 // is not VS Code's default behavior; see editor.codeActionsOnSave.)
 const (
 	// source
-	GoAssembly    protocol.CodeActionKind = "source.assembly"
-	GoDoc         protocol.CodeActionKind = "source.doc"
-	GoFreeSymbols protocol.CodeActionKind = "source.freesymbols"
-	GoTest        protocol.CodeActionKind = "source.test"
-	AddTest       protocol.CodeActionKind = "source.addTest"
+	GoAssembly      protocol.CodeActionKind = "source.assembly"
+	GoCgo           protocol.CodeActionKind = "source.cgo"
+	GoDoc           protocol.CodeActionKind = "source.doc"
+	GoFreeSymbols   protocol.CodeActionKind = "source.freesymbols"
+	GoInstantiation protocol.CodeActionKind = "source.instantiation"
+	GoTest          protocol.CodeActionKind = "source.test"
+	AddTest         protocol.CodeActionKind = "source.addTest"
+	AddFuzzTest     protocol.CodeActionKind = "source.addFuzzTest"
 
 	// gopls
 	GoplsDocFeatures protocol.CodeActionKind = "gopls.doc.features"
 
 	// refactor.rewrite
+	RefactorRewriteAddTypeParam      protocol.CodeActionKind = "refactor.rewrite.addTypeParam"
 	RefactorRewriteChangeQuote       protocol.CodeActionKind = "refactor.rewrite.changeQuote"
+	RefactorRewriteDelegateMethods   protocol.CodeActionKind = "refactor.rewrite.delegateMethods"
+	RefactorRewriteEncapsulateField  protocol.CodeActionKind = "refactor.rewrite.encapsulateField"
 	RefactorRewriteFillStruct        protocol.CodeActionKind = "refactor.rewrite.fillStruct"
 	RefactorRewriteFillSwitch        protocol.CodeActionKind = "refactor.rewrite.fillSwitch"
+	RefactorRewriteFuncToMethod      protocol.CodeActionKind = "refactor.rewrite.funcToMethod"
+	RefactorRewriteIfToSwitch        protocol.CodeActionKind = "refactor.rewrite.ifToSwitch"
 	RefactorRewriteInvertIf          protocol.CodeActionKind = "refactor.rewrite.invertIf"
 	RefactorRewriteJoinLines         protocol.CodeActionKind = "refactor.rewrite.joinLines"
+	RefactorRewriteMethodToFunc      protocol.CodeActionKind = "refactor.rewrite.methodToFunc"
 	RefactorRewriteRemoveUnusedParam protocol.CodeActionKind = "refactor.rewrite.removeUnusedParam"
 	RefactorRewriteMoveParamLeft     protocol.CodeActionKind = "refactor.rewrite.moveParamLeft"
 	RefactorRewriteMoveParamRight    protocol.CodeActionKind = "refactor.rewrite.moveParamRight"
+	RefactorRewritePropagateContext  protocol.CodeActionKind = "refactor.rewrite.propagateContext"
 	RefactorRewriteSplitLines        protocol.CodeActionKind = "refactor.rewrite.splitLines"
+	RefactorRewriteSwitchToIf        protocol.CodeActionKind = "refactor.rewrite.switchToIf"
 
 	// refactor.inline
-	RefactorInlineCall protocol.CodeActionKind = "refactor.inline.call"
+	RefactorInlineCall     protocol.CodeActionKind = "refactor.inline.call"
+	RefactorInlineVariable protocol.CodeActionKind = "refactor.inline.variable"
+	RefactorInlineConstant protocol.CodeActionKind = "refactor.inline.constant"
 
 	// refactor.extract
-	RefactorExtractConstant    protocol.CodeActionKind = "refactor.extract.constant"
-	RefactorExtractConstantAll protocol.CodeActionKind = "refactor.extract.constant-all"
-	RefactorExtractFunction    protocol.CodeActionKind = "refactor.extract.function"
-	RefactorExtractMethod      protocol.CodeActionKind = "refactor.extract.method"
-	RefactorExtractVariable    protocol.CodeActionKind = "refactor.extract.variable"
-	RefactorExtractVariableAll protocol.CodeActionKind = "refactor.extract.variable-all"
-	RefactorExtractToNewFile   protocol.CodeActionKind = "refactor.extract.toNewFile"
+	RefactorExtractConstant     protocol.CodeActionKind = "refactor.extract.constant"
+	RefactorExtractConstantAll  protocol.CodeActionKind = "refactor.extract.constant-all"
+	RefactorExtractFunction     protocol.CodeActionKind = "refactor.extract.function"
+	RefactorExtractInterface    protocol.CodeActionKind = "refactor.extract.interface"
+	RefactorExtractInterfaceUse protocol.CodeActionKind = "refactor.extract.interface-use"
+	RefactorExtractMethod       protocol.CodeActionKind = "refactor.extract.method"
+	RefactorExtractVariable     protocol.CodeActionKind = "refactor.extract.variable"
+	RefactorExtractVariableAll  protocol.CodeActionKind = "refactor.extract.variable-all"
+	RefactorExtractToNewFile    protocol.CodeActionKind = "refactor.extract.toNewFile"
+
+	// refactor.move
+	RefactorMoveToPackage protocol.CodeActionKind = "refactor.move.toPackage"
 
 	// Note: add new kinds to:
 	// - the SupportedCodeActions map in default.go
@@ -50,24 +50,39 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 						protocol.SourceOrganizeImports:   true,
 						protocol.QuickFix:                true,
 						GoAssembly:                       true,
+						GoCgo:                            true,
 						GoDoc:                            true,
 						GoFreeSymbols:                    true,
+						GoInstantiation:                  true,
 						GoplsDocFeatures:                 true,
+						RefactorRewriteAddTypeParam:      true,
 						RefactorRewriteChangeQuote:       true,
+						RefactorRewriteDelegateMethods:   true,
+						RefactorRewriteEncapsulateField:  true,
 						RefactorRewriteFillStruct:        true,
 						RefactorRewriteFillSwitch:        true,
+						RefactorRewriteFuncToMethod:      true,
+						RefactorRewriteIfToSwitch:        true,
 						RefactorRewriteInvertIf:          true,
 						RefactorRewriteJoinLines:         true,
+						RefactorRewriteMethodToFunc:      true,
+						RefactorRewritePropagateContext:  true,
 						RefactorRewriteRemoveUnusedParam: true,
 						RefactorRewriteSplitLines:        true,
+						RefactorRewriteSwitchToIf:        true,
 						RefactorInlineCall:               true,
+						RefactorInlineVariable:           true,
+						RefactorInlineConstant:           true,
 						RefactorExtractConstant:          true,
 						RefactorExtractConstantAll:       true,
 						RefactorExtractFunction:          true,
+						RefactorExtractInterface:         true,
+						RefactorExtractInterfaceUse:      true,
 						RefactorExtractMethod:            true,
 						RefactorExtractVariable:          true,
 						RefactorExtractVariableAll:       true,
 						RefactorExtractToNewFile:         true,
+						RefactorMoveToPackage:            true,
 						// Not GoTest: it must be explicit in CodeActionParams.Context.Only
 					},
 					file.Mod: {
@@ -75,8 +75,12 @@ type ClientOptions struct {
 	CompletionTags                             bool
 	CompletionDeprecated                       bool
 	SupportedResourceOperations                []protocol.ResourceOperationKind
+	ChangeAnnotationSupported                  bool
 	CodeActionResolveOptions                   []string
+	CompletionResolveOptions                   []string
+	CodeLensResolveOptions                     []string
 	ShowDocumentSupported                      bool
+	TextDocumentContentSupported               bool
 }
 
 // ServerOptions holds LSP-specific configuration that is provided by the
@@ -416,6 +420,24 @@ type FormattingOptions struct {
 
 	// Gofumpt indicates if we should run gofumpt formatting.
 	Gofumpt bool
+
+	// OrganizeImportsOnSave causes gopls to format a Go file and
+	// organize its imports, as goimports does, when the editor is
+	// about to save it, if the editor supports the LSP
+	// textDocument/willSaveWaitUntil request.
+	OrganizeImportsOnSave bool `status:"experimental"`
+
+	// FixesOnSave is the list of analyzers whose suggested fixes are
+	// applied to a Go file when the editor is about to save it, if the
+	// editor supports the LSP textDocument/willSaveWaitUntil request.
+	// The fixes are applied before imports are organized, all in the
+	// same edit.
+	//
+	// Only analyzers whose fixes are safe to apply without review,
+	// such as "simplifycompositelit" or "modernize", should be listed.
+	// Fixes are applied only for enabled analyzers, and only if the
+	// package compiles.
+	FixesOnSave []string `status:"experimental"`
 }
 
 // Note: DiagnosticOptions must be comparable with reflect.DeepEqual.
@@ -855,6 +877,7 @@ func (o *Options) ForClientCapabilities(clientInfo *protocol.ClientInfo, caps pr
 	}
 	if caps.Workspace.WorkspaceEdit != nil {
 		o.SupportedResourceOperations = caps.Workspace.WorkspaceEdit.ResourceOperations
+		o.ChangeAnnotationSupported = caps.Workspace.WorkspaceEdit.ChangeAnnotationSupport != nil
 	}
 	// Check if the client supports snippets in completion items.
 	if c := caps.TextDocument.Completion; c.CompletionItem.SnippetSupport {
@@ -864,6 +887,8 @@ func (o *Options) ForClientCapabilities(clientInfo *protocol.ClientInfo, caps pr
 	if caps.Window.ShowDocument != nil {
 		o.ShowDocumentSupported = caps.Window.ShowDocument.Support
 	}
+	// Check if the client can open virtual documents served by gopls.
+	o.TextDocumentContentSupported = caps.Workspace.TextDocumentContent != nil
 	// Check if the client supports configuration messages.
 	o.ConfigurationSupported = caps.Workspace.Configuration
 	o.DynamicConfigurationSupported = caps.Workspace.DidChangeConfiguration.DynamicRegistration
@@ -903,6 +928,14 @@ func (o *Options) ForClientCapabilities(clientInfo *protocol.ClientInfo, caps pr
 	if caps.TextDocument.CodeAction.DataSupport && caps.TextDocument.CodeAction.ResolveSupport != nil {
 		o.CodeActionResolveOptions = caps.TextDocument.CodeAction.ResolveSupport.Properties
 	}
+	// Check which properties of completion items and code lenses the
+	// client can resolve lazily.
+	if rs := caps.TextDocument.Completion.CompletionItem.ResolveSupport; rs != nil {
+		o.CompletionResolveOptions = rs.Properties
+	}
+	if cl := caps.TextDocument.CodeLens; cl != nil && cl.ResolveSupport != nil {
+		o.CodeLensResolveOptions = cl.ResolveSupport.Properties
+	}
 }
 
 var codec = frob.CodecFor[*Options]()
@@ -1099,6 +1132,21 @@ func (o *Options) setOne(name string, value any) error {
 	case "gofumpt":
 		return setBool(&o.Gofumpt, value)
 
+	case "organizeImportsOnSave":
+		return setBool(&o.OrganizeImportsOnSave, value)
+
+	case "fixesOnSave":
+		names, err := asStringSlice(value)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if DefaultAnalyzers[name] == nil && StaticcheckAnalyzers[name] == nil {
+				return fmt.Errorf("unknown analyzer %q", name)
+			}
+		}
+		o.FixesOnSave = names
+
 	case "completeFunctionCalls":
 		return setBool(&o.CompleteFunctionCalls, value)
 
@@ -74,6 +74,41 @@ const (
 	}
 }
 
+func TestResolveCodeLens(t *testing.T) {
+	const workspace = `
+-- go.mod --
+module codelens.test
+
+go 1.12
+-- lib.go --
+package lib
+
+//` + `go:generate stringer -type=Number
+`
+	const capabilities = `{"textDocument": {"codeLens": {"resolveSupport": {"properties": ["command"]}}}}`
+	WithOptions(
+		CapabilitiesJSON([]byte(capabilities)),
+	).Run(t, workspace, func(t *testing.T, env *Env) {
+		env.OpenFile("lib.go")
+		lenses := env.CodeLens("lib.go")
+		if len(lenses) == 0 {
+			t.Fatal("no code lenses")
+		}
+		for _, lens := range lenses {
+			if lens.Command != nil {
+				t.Errorf("unresolved code lens has command %q, want none", lens.Command.Title)
+			}
+			resolved, err := env.Editor.Server.ResolveCodeLens(env.Ctx, &lens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.Command == nil || resolved.Command.Command != command.Generate.String() {
+				t.Errorf("resolved code lens has command %v, want %s", resolved.Command, command.Generate)
+			}
+		}
+	})
+}
+
 const proxyWithLatest = `
 -- golang.org/x/hello@v1.3.3/go.mod --
 module golang.org/x/hello
@@ -0,0 +1,157 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package completion
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+func TestCompletionResolve(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/lib.go --
+package lib
+
+// Greet returns a greeting.
+func Greet(name string) string { return "hello " + name }
+-- main.go --
+package main
+
+type T struct{}
+
+// Double doubles x.
+func (T) Double(x int) int { return 2 * x }
+
+// Deprecated: use Double.
+func (T) DoubleOld(x int) int { return 2 * x }
+
+type S struct{ Inner T }
+
+func _(s S) {
+	var _ int = s.Doub
+	_ = lib.Gree
+}
+`
+	const capabilities = `{
+	"textDocument": {
+		"completion": {
+			"completionItem": {
+				"snippetSupport": true,
+				"tagSupport": {"valueSet": [1]},
+				"resolveSupport": {"properties": ["documentation", "additionalTextEdits", "textEdit"]}
+			}
+		}
+	}
+}`
+	WithOptions(
+		CapabilitiesJSON([]byte(capabilities)),
+		Settings{"usePlaceholders": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		env.Await(env.DoneWithOpen())
+
+		find := func(list *protocol.CompletionList, label string) protocol.CompletionItem {
+			t.Helper()
+			for _, item := range list.Items {
+				if item.Label == label {
+					return item
+				}
+			}
+			t.Fatalf("no completion item with label %q", label)
+			return protocol.CompletionItem{}
+		}
+		resolve := func(item protocol.CompletionItem) *protocol.CompletionItem {
+			t.Helper()
+			resolved, err := env.Editor.Server.ResolveCompletionItem(env.Ctx, &item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return resolved
+		}
+		newText := func(item *protocol.CompletionItem) string {
+			switch edit := item.TextEdit.Value.(type) {
+			case protocol.TextEdit:
+				return edit.NewText
+			case protocol.InsertReplaceEdit:
+				return edit.NewText
+			}
+			return ""
+		}
+		documentation := func(item *protocol.CompletionItem) string {
+			if item.Documentation == nil {
+				return ""
+			}
+			switch doc := item.Documentation.Value.(type) {
+			case protocol.MarkupContent:
+				return doc.Value
+			case string:
+				return doc
+			}
+			return ""
+		}
+
+		// The import edits of an unimported package member are
+		// computed on resolution.
+		greet := find(env.Completion(env.RegexpSearch("main.go", `lib\.Gree()`)), "Greet")
+		if len(greet.AdditionalTextEdits) != 0 {
+			t.Errorf("unresolved item has %d additional edits, want none", len(greet.AdditionalTextEdits))
+		}
+		resolved := resolve(greet)
+		if len(resolved.AdditionalTextEdits) == 0 || !strings.Contains(resolved.AdditionalTextEdits[0].NewText, `"mod.com/lib"`) {
+			t.Errorf("resolved item has additional edits %v, want an import of mod.com/lib", resolved.AdditionalTextEdits)
+		}
+		if resolved.SortText != greet.SortText {
+			t.Errorf("resolved item has sort text %q, want %q", resolved.SortText, greet.SortText)
+		}
+
+		// The documentation and parameter snippet of a deep
+		// candidate are computed on resolution.
+		doubles := env.Completion(env.RegexpSearch("main.go", `s\.Doub()`))
+		double := find(doubles, "Inner.Double")
+		if doc := documentation(&double); doc != "" {
+			t.Errorf("unresolved item has documentation %q, want none", doc)
+		}
+		if got, want := newText(&double), "Inner.Double()"; got != want {
+			t.Errorf("unresolved deep item inserts %q, want %q", got, want)
+		}
+		resolved = resolve(double)
+		if doc := documentation(resolved); !strings.Contains(doc, "Double doubles x.") {
+			t.Errorf("resolved item has documentation %q, want the doc comment", doc)
+		}
+		if got, want := newText(resolved), "Inner.Double(${1:x int})"; got != want {
+			t.Errorf("resolved deep item inserts %q, want %q", got, want)
+		}
+
+		// Deprecation, which is determined from the doc comment,
+		// is reported even when the documentation is deferred.
+		old := find(doubles, "Inner.DoubleOld")
+		if doc := documentation(&old); doc != "" {
+			t.Errorf("unresolved item has documentation %q, want none", doc)
+		}
+		if !slices.Contains(old.Tags, protocol.ComplDeprecated) {
+			t.Errorf("unresolved item of deprecated method has tags %v, want Deprecated", old.Tags)
+		}
+
+		// Items of a previous completion list are not resolved.
+		if stale := resolve(greet); len(stale.AdditionalTextEdits) != 0 {
+			t.Errorf("stale item was resolved")
+		}
+
+		// Nor are items of a list computed before a change to a file,
+		// as their snapshot has been released.
+		env.RegexpReplace("main.go", `return 2 \* x`, "return x + x")
+		if stale := resolve(double); documentation(stale) != "" {
+			t.Errorf("item computed before a change was resolved")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+const pullProgram = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+const A = "abc" + 1
+-- b/b.go --
+package b
+
+const B = 1
+`
+
+func TestPullDiagnosticsResultID(t *testing.T) {
+	WithOptions(
+		Settings{"pullDiagnostics": true},
+	).Run(t, pullProgram, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+
+		full, ok := env.DocumentDiagnostics("a/a.go", "").(protocol.RelatedFullDocumentDiagnosticReport)
+		if !ok {
+			t.Fatalf("first report is not a full report")
+		}
+		if len(full.Items) != 1 || full.ResultID == "" {
+			t.Fatalf("got %d diagnostics with result ID %q, want 1 diagnostic with a result ID", len(full.Items), full.ResultID)
+		}
+
+		unchanged, ok := env.DocumentDiagnostics("a/a.go", full.ResultID).(protocol.RelatedUnchangedDocumentDiagnosticReport)
+		if !ok {
+			t.Fatalf("report with previous result ID is not an unchanged report")
+		}
+		if unchanged.ResultID != full.ResultID {
+			t.Errorf("unchanged report has result ID %q, want %q", unchanged.ResultID, full.ResultID)
+		}
+
+		// An edit that doesn't affect the diagnostics leaves them unchanged.
+		env.RegexpReplace("a/a.go", `\+ 1`, "+ 1 // comment")
+		if _, ok := env.DocumentDiagnostics("a/a.go", full.ResultID).(protocol.RelatedUnchangedDocumentDiagnosticReport); !ok {
+			t.Errorf("report after irrelevant edit is not an unchanged report")
+		}
+
+		env.RegexpReplace("a/a.go", `"abc" \+ 1`, "1")
+		fixed, ok := env.DocumentDiagnostics("a/a.go", full.ResultID).(protocol.RelatedFullDocumentDiagnosticReport)
+		if !ok {
+			t.Fatalf("report after fix is not a full report")
+		}
+		if len(fixed.Items) != 0 || fixed.ResultID == full.ResultID {
+			t.Errorf("got %d diagnostics with result ID %q, want 0 diagnostics and a new result ID", len(fixed.Items), fixed.ResultID)
+		}
+	})
+}
+
+func TestPullDiagnosticsWorkspace(t *testing.T) {
+	WithOptions(
+		// Partial results may be reordered with respect to the final
+		// response when forwarded through a remote gopls.
+		Modes(Default),
+		Settings{"pullDiagnostics": true},
+	).Run(t, pullProgram, func(t *testing.T, env *Env) {
+		// fullReports returns the number of diagnostics in each
+		// full report, and the previous result ID of each report.
+		fullReports := func(items []protocol.WorkspaceDocumentDiagnosticReport) (map[string]int, []protocol.PreviousResultID) {
+			counts := make(map[string]int)
+			var ids []protocol.PreviousResultID
+			for _, item := range items {
+				switch item := item.Value.(type) {
+				case protocol.WorkspaceFullDocumentDiagnosticReport:
+					counts[env.Sandbox.Workdir.URIToPath(item.URI)] = len(item.Items)
+					ids = append(ids, protocol.PreviousResultID{URI: item.URI, Value: item.ResultID})
+				case protocol.WorkspaceUnchangedDocumentDiagnosticReport:
+					ids = append(ids, protocol.PreviousResultID{URI: item.URI, Value: item.ResultID})
+				default:
+					t.Fatalf("unexpected report type %T", item)
+				}
+			}
+			return counts, ids
+		}
+
+		items, partials := env.WorkspaceDiagnostics(nil)
+		if partials < 2 {
+			t.Errorf("got %d partial results, want at least one per package", partials)
+		}
+		counts, ids := fullReports(items)
+		want := map[string]int{"go.mod": 0, "a/a.go": 1, "b/b.go": 0}
+		for file, n := range want {
+			if got, ok := counts[file]; !ok || got != n {
+				t.Errorf("full report for %s has %d diagnostics (present: %t), want %d", file, got, ok, n)
+			}
+		}
+
+		// Without a partial result token, the same reports are
+		// returned in the response.
+		report, err := env.Editor.Server.DiagnosticWorkspace(env.Ctx, &protocol.WorkspaceDiagnosticParams{
+			PreviousResultIds: []protocol.PreviousResultID{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Items) != len(items) {
+			t.Errorf("got %d reports without partial results, want %d", len(report.Items), len(items))
+		}
+
+		// With the previous result IDs, all reports are unchanged.
+		items, _ = env.WorkspaceDiagnostics(ids)
+		if counts, _ := fullReports(items); len(counts) != 0 {
+			t.Errorf("got full reports %v for unchanged workspace, want none", counts)
+		}
+
+		// After an edit, only the changed file has a full report.
+		env.OpenFile("b/b.go")
+		env.RegexpReplace("b/b.go", "1", `"1" + 1`)
+		items, _ = env.WorkspaceDiagnostics(ids)
+		counts, _ = fullReports(items)
+		if want := map[string]int{"b/b.go": 1}; len(counts) != 1 || counts["b/b.go"] != 1 {
+			t.Errorf("got full reports %v after edit, want %v", counts, want)
+		}
+	})
+}
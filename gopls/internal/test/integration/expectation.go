@@ -302,7 +302,7 @@ func ShownMessageRequest(messageRegexp string) Expectation {
 
 // DoneDiagnosingChanges expects that diagnostics are complete from common
 // change notifications: didOpen, didChange, didSave, didChangeWatchedFiles,
-// and didClose.
+// didClose, didChangeConfiguration, didRenameFiles, and didCreateFiles.
 //
 // This can be used when multiple notifications may have been sent, such as
 // when a didChange is immediately followed by a didSave. It is insufficient to
@@ -318,6 +318,8 @@ func (e *Env) DoneDiagnosingChanges() Expectation {
 		server.FromDidChangeWatchedFiles:  stats.DidChangeWatchedFiles,
 		server.FromDidClose:               stats.DidClose,
 		server.FromDidChangeConfiguration: stats.DidChangeConfiguration,
+		server.FromDidRenameFiles:         stats.DidRenameFiles,
+		server.FromDidCreateFiles:         stats.DidCreateFiles,
 	}
 
 	var expected []server.ModificationSource
@@ -350,6 +352,8 @@ func (e *Env) DoneDiagnosingChanges() Expectation {
 //   - textDocument/didClose
 //   - workspace/didChangeWatchedFiles
 //   - workspace/didChangeConfiguration
+//   - workspace/didRenameFiles
+//   - workspace/didCreateFiles
 func (e *Env) AfterChange(expectations ...Expectation) {
 	e.T.Helper()
 	e.OnceMet(
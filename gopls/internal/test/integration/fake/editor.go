@@ -49,9 +49,16 @@ type Editor struct {
 	watchPatterns            []*glob.Glob      // glob patterns to watch
 	suggestionUseReplaceMode bool
 
+	// partialResults holds the handlers for partial results of
+	// in-flight requests, keyed by partial result token.
+	partialResultsMu    sync.Mutex
+	partialResults      map[string]func(json.RawMessage)
+	nextPartialResultID int
+
 	// These fields are populated by Connect.
 	serverCapabilities protocol.ServerCapabilities
 	semTokOpts         protocol.SemanticTokensOptions
+	syncOpts           protocol.TextDocumentSyncOptions
 
 	// Call metrics for the purpose of expectations. This is done in an ad-hoc
 	// manner for now. Perhaps in the future we should do something more
@@ -63,7 +70,7 @@ type Editor struct {
 
 // CallCounts tracks the number of protocol notifications of different types.
 type CallCounts struct {
-	DidOpen, DidChange, DidSave, DidChangeWatchedFiles, DidClose, DidChangeConfiguration uint64
+	DidOpen, DidChange, DidSave, DidChangeWatchedFiles, DidClose, DidChangeConfiguration, DidRenameFiles, DidCreateFiles uint64
 }
 
 // buffer holds information about an open buffer in the editor.
@@ -172,7 +179,7 @@ func (e *Editor) Connect(ctx context.Context, connector servertest.Connector, ho
 	if e.config.MaxMessageDelay > 0 {
 		handler = DelayedHandler(e.config.MaxMessageDelay, handler)
 	}
-	conn.Go(bgCtx, protocol.Handlers(handler))
+	conn.Go(bgCtx, e.partialResultHandler(protocol.Handlers(handler)))
 
 	if err := e.initialize(ctx); err != nil {
 		return nil, err
@@ -181,6 +188,55 @@ func (e *Editor) Connect(ctx context.Context, connector servertest.Connector, ho
 	return e, nil
 }
 
+// partialResultHandler delivers partial results (progress notifications
+// bearing a partial result token of an in-flight request) to their
+// handler. Unlike other client messages, which are handled
+// asynchronously, partial results are handled before the next message
+// is read, so that they are all observed before the final response.
+func (e *Editor) partialResultHandler(handler jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		if req.Method() == "$/progress" {
+			var params struct {
+				Token protocol.ProgressToken `json:"token"`
+				Value json.RawMessage        `json:"value"`
+			}
+			if err := json.Unmarshal(req.Params(), &params); err == nil {
+				if token, ok := params.Token.(string); ok {
+					e.partialResultsMu.Lock()
+					h := e.partialResults[token]
+					e.partialResultsMu.Unlock()
+					if h != nil {
+						h(params.Value)
+						return reply(ctx, nil, nil)
+					}
+				}
+			}
+		}
+		return handler(ctx, reply, req)
+	}
+}
+
+// awaitPartialResults registers h as the handler for partial results
+// with a new token, which it returns along with a function to
+// unregister the handler. Partial results that arrive after the handler
+// is unregistered are discarded: when messages are forwarded through a
+// remote gopls, they may arrive after the final response.
+func (e *Editor) awaitPartialResults(h func(json.RawMessage)) (protocol.ProgressToken, func()) {
+	e.partialResultsMu.Lock()
+	defer e.partialResultsMu.Unlock()
+	e.nextPartialResultID++
+	token := fmt.Sprintf("partial-%d", e.nextPartialResultID)
+	if e.partialResults == nil {
+		e.partialResults = make(map[string]func(json.RawMessage))
+	}
+	e.partialResults[token] = h
+	return token, func() {
+		e.partialResultsMu.Lock()
+		defer e.partialResultsMu.Unlock()
+		e.partialResults[token] = func(json.RawMessage) {}
+	}
+}
+
 // DelayedHandler waits [0, maxDelay) before handling each message.
 func DelayedHandler(maxDelay time.Duration, handler jsonrpc2.Handler) jsonrpc2.Handler {
 	return func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
@@ -341,8 +397,13 @@ func (e *Editor) initialize(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("unmarshalling semantic tokens options: %v", err)
 		}
+		syncOpts, err := marshalUnmarshal[protocol.TextDocumentSyncOptions](resp.Capabilities.TextDocumentSync)
+		if err != nil {
+			return fmt.Errorf("unmarshalling text document sync options: %v", err)
+		}
 		e.serverCapabilities = resp.Capabilities
 		e.semTokOpts = semTokOpts
+		e.syncOpts = syncOpts
 
 		if err := e.Server.Initialized(ctx, &protocol.InitializedParams{}); err != nil {
 			return fmt.Errorf("initialized: %w", err)
@@ -388,14 +449,21 @@ func clientCapabilities(cfg EditorConfig) (protocol.ClientCapabilities, error) {
 	// The LSP tests have historically enabled this flag,
 	// but really we should test both ways for older editors.
 	capabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport = true
+	// Edits before saving are applied.
+	capabilities.TextDocument.Synchronization = &protocol.TextDocumentSyncClientCapabilities{
+		WillSave:          true,
+		WillSaveWaitUntil: true,
+	}
 	// Glob pattern watching is enabled.
 	capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration = true
-	// "rename" operations are used for package renaming.
+	// "rename" operations are used for package renaming, and "create"
+	// operations for populating new files.
 	//
-	// TODO(rfindley): add support for other resource operations (create, delete, ...)
+	// TODO(rfindley): add support for other resource operations (delete, ...)
 	capabilities.Workspace.WorkspaceEdit = &protocol.WorkspaceEditClientCapabilities{
 		ResourceOperations: []protocol.ResourceOperationKind{
 			"rename",
+			"create",
 		},
 	}
 
@@ -699,22 +767,31 @@ func (e *Editor) SaveBufferWithoutActions(ctx context.Context, path string) erro
 	if !ok {
 		return fmt.Errorf("unknown buffer: %q", path)
 	}
-	content := buf.text()
-	includeText := false
-	syncOptions, ok := e.serverCapabilities.TextDocumentSync.(protocol.TextDocumentSyncOptions)
-	if ok {
-		includeText = syncOptions.Save.IncludeText
-	}
+	includeText := e.syncOpts.Save != nil && e.syncOpts.Save.IncludeText
 
 	docID := e.TextDocumentIdentifier(buf.path)
 	if e.Server != nil {
-		if err := e.Server.WillSave(ctx, &protocol.WillSaveTextDocumentParams{
+		params := &protocol.WillSaveTextDocumentParams{
 			TextDocument: docID,
 			Reason:       protocol.Manual,
-		}); err != nil {
+		}
+		if err := e.Server.WillSave(ctx, params); err != nil {
 			return fmt.Errorf("WillSave: %w", err)
 		}
+		if e.syncOpts.WillSaveWaitUntil {
+			edits, err := e.Server.WillSaveWaitUntil(ctx, params)
+			if err != nil {
+				return fmt.Errorf("WillSaveWaitUntil: %w", err)
+			}
+			if len(edits) > 0 {
+				if err := e.editBufferLocked(ctx, path, edits); err != nil {
+					return fmt.Errorf("applying edits before save: %w", err)
+				}
+				buf = e.buffers[path]
+			}
+		}
 	}
+	content := buf.text()
 	if err := e.sandbox.Workdir.WriteFile(ctx, path, content); err != nil {
 		return fmt.Errorf("writing %q: %w", path, err)
 	}
@@ -933,6 +1010,27 @@ func (e *Editor) Definition(ctx context.Context, loc protocol.Location) (protoco
 	return e.extractFirstLocation(ctx, resp)
 }
 
+// Declaration jumps to the declaration of the symbol at the given
+// location in an open buffer.
+func (e *Editor) Declaration(ctx context.Context, loc protocol.Location) (protocol.Location, error) {
+	if err := e.checkBufferLocation(loc); err != nil {
+		return protocol.Location{}, err
+	}
+	params := &protocol.DeclarationParams{}
+	params.TextDocument.URI = loc.URI
+	params.Position = loc.Range.Start
+
+	resp, err := e.Server.Declaration(ctx, params)
+	if err != nil {
+		return protocol.Location{}, fmt.Errorf("declaration: %w", err)
+	}
+	var locs []protocol.Location
+	if resp != nil {
+		locs, _ = resp.Value.(protocol.Declaration)
+	}
+	return e.extractFirstLocation(ctx, locs)
+}
+
 // TypeDefinition jumps to the type definition of the symbol at the given
 // location in an open buffer.
 func (e *Editor) TypeDefinition(ctx context.Context, loc protocol.Location) (protocol.Location, error) {
@@ -1081,6 +1179,90 @@ func (e *Editor) Diagnostics(ctx context.Context, path string) ([]protocol.Diagn
 	return report.Items, nil
 }
 
+// DocumentDiagnostics invokes textDocument/diagnostic for the given
+// file, with the given previous result ID, and returns the resulting
+// report: either a [protocol.RelatedFullDocumentDiagnosticReport] or a
+// [protocol.RelatedUnchangedDocumentDiagnosticReport].
+func (e *Editor) DocumentDiagnostics(ctx context.Context, path, previousResultID string) (any, error) {
+	if e.Server == nil {
+		return nil, errors.New("not connected")
+	}
+	result, err := e.Server.Diagnostic(ctx, &protocol.DocumentDiagnosticParams{
+		TextDocument:     e.TextDocumentIdentifier(path),
+		PreviousResultID: previousResultID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The JSON decoder cannot distinguish the two kinds of report.
+	if full, ok := result.Value.(protocol.RelatedFullDocumentDiagnosticReport); ok && full.Kind == string(protocol.DiagnosticUnchanged) {
+		return protocol.RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: protocol.UnchangedDocumentDiagnosticReport{
+				Kind:     full.Kind,
+				ResultID: full.ResultID,
+			},
+		}, nil
+	}
+	return result.Value, nil
+}
+
+// WorkspaceDiagnostics invokes workspace/diagnostic with the given
+// previous result IDs, requesting partial results. It returns the
+// reports from all partial results and the final response, along with
+// the number of partial results. Each report is either a
+// [protocol.WorkspaceFullDocumentDiagnosticReport] or a
+// [protocol.WorkspaceUnchangedDocumentDiagnosticReport].
+func (e *Editor) WorkspaceDiagnostics(ctx context.Context, previous []protocol.PreviousResultID) ([]protocol.WorkspaceDocumentDiagnosticReport, int, error) {
+	if e.Server == nil {
+		return nil, 0, errors.New("not connected")
+	}
+	var (
+		mu       sync.Mutex
+		items    []protocol.WorkspaceDocumentDiagnosticReport
+		partials int
+		errs     []error
+	)
+	token, done := e.awaitPartialResults(func(data json.RawMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		var partial protocol.WorkspaceDiagnosticReportPartialResult
+		if err := json.Unmarshal(data, &partial); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		partials++
+		items = append(items, partial.Items...)
+	})
+	defer done()
+	report, err := e.Server.DiagnosticWorkspace(ctx, &protocol.WorkspaceDiagnosticParams{
+		PreviousResultIds:   protocol.NonNilSlice(previous),
+		PartialResultParams: protocol.PartialResultParams{PartialResultToken: &token},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) > 0 {
+		return nil, 0, errors.Join(errs...)
+	}
+	items = append(items, report.Items...)
+	// The JSON decoder cannot distinguish the two kinds of report.
+	for i, item := range items {
+		if full, ok := item.Value.(protocol.WorkspaceFullDocumentDiagnosticReport); ok && full.Kind == string(protocol.DiagnosticUnchanged) {
+			items[i].Value = protocol.WorkspaceUnchangedDocumentDiagnosticReport{
+				URI:     full.URI,
+				Version: full.Version,
+				UnchangedDocumentDiagnosticReport: protocol.UnchangedDocumentDiagnosticReport{
+					Kind:     full.Kind,
+					ResultID: full.ResultID,
+				},
+			}
+		}
+	}
+	return items, partials, nil
+}
+
 // GetQuickFixes returns the available quick fix code actions.
 func (e *Editor) GetQuickFixes(ctx context.Context, loc protocol.Location, diagnostics []protocol.Diagnostic) ([]protocol.CodeAction, error) {
 	return e.CodeActions(ctx, loc, diagnostics, protocol.QuickFix, protocol.SourceFixAll)
@@ -1480,6 +1662,75 @@ func (e *Editor) RenameFile(ctx context.Context, oldPath, newPath string) error
 	return nil
 }
 
+// MoveFile renames a file or directory as if by a user action in the
+// editor: it sends a workspace/willRenameFiles request and applies the
+// resulting edits, performs the renaming, then sends the
+// workspace/didRenameFiles notification.
+func (e *Editor) MoveFile(ctx context.Context, oldPath, newPath string) error {
+	params := &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: string(e.sandbox.Workdir.URI(oldPath)),
+			NewURI: string(e.sandbox.Workdir.URI(newPath)),
+		}},
+	}
+	if e.Server != nil {
+		wsedit, err := e.Server.WillRenameFiles(ctx, params)
+		if err != nil {
+			return err
+		}
+		if wsedit != nil {
+			if err := e.applyWorkspaceEdit(ctx, wsedit); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.RenameFile(ctx, oldPath, newPath); err != nil {
+		return err
+	}
+	if e.Server != nil {
+		e.callsMu.Lock()
+		e.calls.DidRenameFiles++
+		e.callsMu.Unlock()
+		return e.Server.DidRenameFiles(ctx, params)
+	}
+	return nil
+}
+
+// CreateFile creates an empty file as if by a user action in the
+// editor: it sends a workspace/willCreateFiles request and applies the
+// resulting edits, creates the file, then sends the
+// workspace/didCreateFiles notification.
+func (e *Editor) CreateFile(ctx context.Context, path string) error {
+	params := &protocol.CreateFilesParams{
+		Files: []protocol.FileCreate{{
+			URI: string(e.sandbox.Workdir.URI(path)),
+		}},
+	}
+	if e.Server != nil {
+		wsedit, err := e.Server.WillCreateFiles(ctx, params)
+		if err != nil {
+			return err
+		}
+		if wsedit != nil {
+			if err := e.applyWorkspaceEdit(ctx, wsedit); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := e.sandbox.Workdir.ReadFile(path); os.IsNotExist(err) {
+		if err := e.sandbox.Workdir.WriteFile(ctx, path, ""); err != nil {
+			return err
+		}
+	}
+	if e.Server != nil {
+		e.callsMu.Lock()
+		e.calls.DidCreateFiles++
+		e.callsMu.Unlock()
+		return e.Server.DidCreateFiles(ctx, params)
+	}
+	return nil
+}
+
 // renameBuffers renames in-memory buffers affected by the renaming of
 // oldPath->newPath, returning the resulting text documents that must be closed
 // and opened over the LSP.
@@ -1548,6 +1799,9 @@ func (e *Editor) applyWorkspaceEdit(ctx context.Context, wsedit *protocol.Worksp
 
 		case change.CreateFile != nil:
 			path := uriToPath(change.CreateFile.URI)
+			if opts := change.CreateFile.Options; opts != nil && opts.IgnoreIfExists && e.HasBuffer(path) {
+				continue
+			}
 			if err := e.CreateBuffer(ctx, path, ""); err != nil {
 				return err // e.g. already exists
 			}
@@ -1568,7 +1822,9 @@ func (e *Editor) applyWorkspaceEdit(ctx context.Context, wsedit *protocol.Worksp
 
 func (e *Editor) applyTextDocumentEdit(ctx context.Context, change protocol.TextDocumentEdit) error {
 	path := e.sandbox.Workdir.URIToPath(change.TextDocument.URI)
-	if ver := int32(e.BufferVersion(path)); ver != change.TextDocument.Version {
+	// Version 0 denotes a file that was not open, such as one
+	// created by a preceding change; treat it as unversioned.
+	if ver := int32(e.BufferVersion(path)); change.TextDocument.Version != 0 && ver != change.TextDocument.Version {
 		return fmt.Errorf("buffer versions for %q do not match: have %d, editing %d", path, ver, change.TextDocument.Version)
 	}
 	if !e.HasBuffer(path) {
@@ -0,0 +1,285 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/protocol/command"
+	"golang.org/x/tools/gopls/internal/test/compare"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+const changeSignatureFiles = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import "fmt"
+
+// Fetch fetches n items named by s.
+func Fetch(s string, n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("negative count %d", n)
+	}
+	return fmt.Sprint(s, n), nil
+}
+
+func Use() {
+	v, err := Fetch("x", 1)
+	fmt.Println(v, err)
+}
+-- b/b.go --
+package b
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/a"
+)
+
+func B(ctx context.Context) {
+	fmt.Println(a.Fetch("b", 2))
+}
+`
+
+// TestChangeSignature exercises the general form of the
+// gopls.change_signature command, which adds, renames, retypes and
+// reorders parameters and results.
+func TestChangeSignature(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       []command.ChangeSignatureParam
+		results      []command.ChangeSignatureParam
+		wantA, wantB string
+	}{
+		{
+			name: "add parameter",
+			params: []command.ChangeSignatureParam{
+				{NewField: "ctx context.Context", Default: "context.TODO()"},
+				command.OldField(0),
+				command.OldField(1),
+			},
+			wantA: `package a
+
+import (
+	"context"
+	"fmt"
+)
+
+// Fetch fetches n items named by s.
+func Fetch(ctx context.Context, s string, n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("negative count %d", n)
+	}
+	return fmt.Sprint(s, n), nil
+}
+
+func Use() {
+	v, err := Fetch(context.TODO(), "x", 1)
+	fmt.Println(v, err)
+}
+`,
+			wantB: `package b
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/a"
+)
+
+func B(ctx context.Context) {
+	fmt.Println(a.Fetch(context.TODO(), "b", 2))
+}
+`,
+		},
+		{
+			name: "rename and retype parameters",
+			params: []command.ChangeSignatureParam{
+				{OldIndex: oldIndex(0), NewField: "name string"},
+				{OldIndex: oldIndex(1), NewField: "count int64"},
+			},
+			wantA: `package a
+
+import "fmt"
+
+// Fetch fetches n items named by s.
+func Fetch(name string, count int64) (string, error) {
+	if count < 0 {
+		return "", fmt.Errorf("negative count %d", count)
+	}
+	return fmt.Sprint(name, count), nil
+}
+
+func Use() {
+	v, err := Fetch("x", int64(1))
+	fmt.Println(v, err)
+}
+`,
+		},
+		{
+			name:   "reorder results",
+			params: []command.ChangeSignatureParam{command.OldField(0), command.OldField(1)},
+			results: []command.ChangeSignatureParam{
+				command.OldField(1),
+				command.OldField(0),
+			},
+			wantA: `package a
+
+import "fmt"
+
+// Fetch fetches n items named by s.
+func Fetch(s string, n int) (error, string) {
+	if n < 0 {
+		return fmt.Errorf("negative count %d", n), ""
+	}
+	return nil, fmt.Sprint(s, n)
+}
+
+func Use() {
+	v, err := func() (string, error) {
+		r0, r1 := Fetch("x", 1)
+		return r1, r0
+	}()
+	fmt.Println(v, err)
+}
+`,
+		},
+		{
+			name:   "add result",
+			params: []command.ChangeSignatureParam{command.OldField(0), command.OldField(1)},
+			results: []command.ChangeSignatureParam{
+				command.OldField(0),
+				{NewField: "bool", Default: "true"},
+				command.OldField(1),
+			},
+			wantA: `package a
+
+import "fmt"
+
+// Fetch fetches n items named by s.
+func Fetch(s string, n int) (string, bool, error) {
+	if n < 0 {
+		return "", true, fmt.Errorf("negative count %d", n)
+	}
+	return fmt.Sprint(s, n), true, nil
+}
+
+func Use() {
+	v, err := func() (string, error) {
+		r0, _, r1 := Fetch("x", 1)
+		return r0, r1
+	}()
+	fmt.Println(v, err)
+}
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Run(t, changeSignatureFiles, func(t *testing.T, env *Env) {
+				env.OpenFile("a/a.go")
+				args, err := command.MarshalArgs(command.ChangeSignatureArgs{
+					Location:   env.RegexpSearch("a/a.go", `func (Fetch)`),
+					NewParams:  test.params,
+					NewResults: test.results,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				env.ExecuteCommand(&protocol.ExecuteCommandParams{
+					Command:   command.ChangeSignature.String(),
+					Arguments: args,
+				}, nil)
+				if got := env.BufferText("a/a.go"); got != test.wantA {
+					t.Errorf("a/a.go after change signature:\n%s", compare.Text(test.wantA, got))
+				}
+				if test.wantB != "" {
+					if got := env.BufferText("b/b.go"); got != test.wantB {
+						t.Errorf("b/b.go after change signature:\n%s", compare.Text(test.wantB, got))
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestChangeSignatureErrors(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func F(x, y int) int {
+	if x == 0 {
+		return y
+	}
+	return F(x-1, y)
+}
+
+func G(x, y int) int {
+	return x + y
+}
+`
+	tests := []struct {
+		name, fn string
+		params   []command.ChangeSignatureParam
+		wantErr  string
+	}{
+		{
+			name:    "recursive",
+			fn:      "F",
+			params:  []command.ChangeSignatureParam{{OldIndex: oldIndex(0), NewField: "n int"}, command.OldField(1)},
+			wantErr: "recursive function F",
+		},
+		{
+			name:    "rename conflict",
+			fn:      "G",
+			params:  []command.ChangeSignatureParam{{OldIndex: oldIndex(0), NewField: "y int"}, command.OldField(1)},
+			wantErr: "already used in G",
+		},
+		{
+			name:    "invalid field",
+			fn:      "G",
+			params:  []command.ChangeSignatureParam{{NewField: "a, b int"}, command.OldField(0), command.OldField(1)},
+			wantErr: "must declare a single field",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("a/a.go")
+				args, err := command.MarshalArgs(command.ChangeSignatureArgs{
+					Location:  env.RegexpSearch("a/a.go", `func (`+test.fn+`)`),
+					NewParams: test.params,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = env.Editor.ExecuteCommand(env.Ctx, &protocol.ExecuteCommandParams{
+					Command:   command.ChangeSignature.String(),
+					Arguments: args,
+				}, nil)
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("change signature of %s: got error %v, want %q", test.fn, err, test.wantErr)
+				}
+			})
+		})
+	}
+}
+
+// oldIndex returns a pointer to i, for the OldIndex of a
+// ChangeSignatureParam.
+func oldIndex(i int) *int { return &i }
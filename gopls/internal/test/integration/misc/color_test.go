@@ -0,0 +1,150 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+func TestDocumentColor(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+import "image/color"
+
+const half = 0x80
+
+type Color string
+
+var (
+	keyed      = color.RGBA{R: 0xff, G: half, A: 0xff}
+	positional = color.NRGBA{255, 0, 128, 255}
+	wide       = color.NRGBA64{0xFFFF, 0, 0, 0xFFFF}
+	premul     = color.RGBA{0x40, 0, 0, 0x80}
+	empty      = color.RGBA{}
+	hex        = Color("#F80")
+	longHex    Color = "#ff800080"
+
+	notConst = color.RGBA{R: uint8(len(hex))}
+	notColor = Color("#xyz")
+	notTyped = "#ff8000"
+)
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		colors, err := env.Editor.Server.DocumentColor(env.Ctx, &protocol.DocumentColorParams{
+			TextDocument: env.Editor.TextDocumentIdentifier("a.go"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byText := make(map[string]protocol.ColorInformation)
+		for _, c := range colors {
+			byText[env.BufferText("a.go")[offset(t, env, c.Range.Start):offset(t, env, c.Range.End)]] = c
+		}
+		if len(colors) != 7 {
+			t.Errorf("got %d colors, want 7: %v", len(colors), byText)
+		}
+
+		for _, test := range []struct {
+			text  string
+			color protocol.Color // color of the literal
+			pick  protocol.Color // new color
+			want  string         // presentation of the new color
+		}{
+			{
+				`color.RGBA{R: 0xff, G: half, A: 0xff}`,
+				protocol.Color{Red: 1, Green: 128.0 / 255, Alpha: 1},
+				protocol.Color{Red: 0, Green: 1, Blue: 1, Alpha: 1},
+				`color.RGBA{R: 0x00, G: 0xff, A: 0xff, B: 0xff}`,
+			},
+			{
+				`color.NRGBA{255, 0, 128, 255}`,
+				protocol.Color{Red: 1, Blue: 128.0 / 255, Alpha: 1},
+				protocol.Color{Red: 0, Green: 0, Blue: 1, Alpha: 0.5},
+				`color.NRGBA{0, 0, 255, 128}`,
+			},
+			{
+				`color.NRGBA64{0xFFFF, 0, 0, 0xFFFF}`,
+				protocol.Color{Red: 1, Alpha: 1},
+				protocol.Color{Green: 1, Alpha: 1},
+				`color.NRGBA64{0x0000, 0xFFFF, 0x0000, 0xFFFF}`,
+			},
+			{
+				`color.RGBA{0x40, 0, 0, 0x80}`,
+				protocol.Color{Red: 0x40 / float64(0x80), Alpha: 0x80 / float64(0xff)},
+				protocol.Color{Red: 1, Alpha: 0.5},
+				`color.RGBA{0x80, 0x00, 0x00, 0x80}`,
+			},
+			{
+				`color.RGBA{}`,
+				protocol.Color{},
+				protocol.Color{Blue: 1, Alpha: 1},
+				`color.RGBA{B: 255, A: 255}`,
+			},
+			{
+				`"#F80"`,
+				protocol.Color{Red: 1, Green: 0x88 / float64(0xff), Alpha: 1},
+				protocol.Color{Red: 1, Green: 0x12 / float64(0xff), Alpha: 1},
+				`"#FF1200"`,
+			},
+			{
+				`"#ff800080"`,
+				protocol.Color{Red: 1, Green: 0x80 / float64(0xff), Alpha: 0x80 / float64(0xff)},
+				protocol.Color{Red: 1, Green: 1, Blue: 1, Alpha: 1},
+				`"#ffffffff"`,
+			},
+		} {
+			c, ok := byText[test.text]
+			if !ok {
+				t.Errorf("no color for %s", test.text)
+				continue
+			}
+			if !closeColors(c.Color, test.color) {
+				t.Errorf("color of %s = %v, want %v", test.text, c.Color, test.color)
+			}
+			presentations, err := env.Editor.Server.ColorPresentation(env.Ctx, &protocol.ColorPresentationParams{
+				TextDocument: env.Editor.TextDocumentIdentifier("a.go"),
+				Color:        test.pick,
+				Range:        c.Range,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(presentations) != 1 || presentations[0].TextEdit == nil {
+				t.Fatalf("got presentations %v for %s, want one with an edit", presentations, test.text)
+			}
+			if got := presentations[0].TextEdit.NewText; got != test.want {
+				t.Errorf("presentation of %v for %s = %s, want %s", test.pick, test.text, got, test.want)
+			}
+		}
+	})
+}
+
+// offset returns the byte offset of a position in the buffer a.go.
+func offset(t *testing.T, env *Env, pos protocol.Position) int {
+	t.Helper()
+	m := protocol.NewMapper(env.Sandbox.Workdir.URI("a.go"), []byte(env.BufferText("a.go")))
+	off, err := m.PositionOffset(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return off
+}
+
+// closeColors reports whether two colors are equal, up to rounding.
+func closeColors(x, y protocol.Color) bool {
+	close := func(a, b float64) bool { return a-b < 1e-3 && b-a < 1e-3 }
+	return close(x.Red, y.Red) && close(x.Green, y.Green) && close(x.Blue, y.Blue) && close(x.Alpha, y.Alpha)
+}
@@ -688,3 +688,95 @@ func Foo() {
 		}
 	})
 }
+
+func TestDeclaration(t *testing.T) {
+	// This test cannot be expressed as a marker test because
+	// the expect package ignores markers (@loc) within a .s file.
+	const src = `
+-- go.mod --
+module mod.com
+
+-- a/a.go --
+package a
+
+import _ "unsafe"
+
+//go:linkname pulled mod.com/b.pullme
+func pulled() string
+
+func asm(int) int
+
+type I interface{ M() }
+
+type T struct{}
+
+func (T) M() {}
+
+var _ = asm(1)
+
+-- a/a.s --
+// assembly implementation
+TEXT ·asm(SB),$0
+	RET
+
+-- b/b.go --
+package b
+
+import _ "unsafe"
+
+func pullme() string { return "" }
+
+//go:linkname pushed mod.com/c.pushme
+func pushed() string { return "" }
+
+-- c/c.go --
+package c
+
+func pushme() string
+
+-- c/c.s --
+`
+	Run(t, src, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.OpenFile("b/b.go")
+		env.OpenFile("c/c.go")
+
+		for _, test := range []struct {
+			file, re string // source location
+			want     string // name of target file and location, or "def"
+		}{
+			// Linkname directive, at either argument.
+			{"a/a.go", `linkname (pulled)`, "b/b.go:pullme"},
+			{"a/a.go", `b.pullme`, "b/b.go:pullme"},
+			// Function that obtains its body from another package.
+			{"a/a.go", `func (pulled)`, "b/b.go:pullme"},
+			// Function that provides its body to another package.
+			{"b/b.go", `func (pushed)`, "c/c.go:pushme"},
+			// Function without body, implemented in assembly...
+			{"a/a.go", `func (asm)`, "a/a.s:·(asm)"},
+			// ... or by another package.
+			{"c/c.go", `pushme`, "b/b.go:linkname (pushed)"},
+			// Function obtained by another package.
+			{"b/b.go", `func (pullme)`, "a/a.go:linkname (pulled)"},
+			// Concrete method.
+			{"a/a.go", `\) (M)`, "a/a.go:interface{ (M)"},
+			// Other identifiers.
+			{"a/a.go", `asm\(1`, "def"},
+			{"a/a.go", `struct`, "def"},
+		} {
+			src := env.RegexpSearch(test.file, test.re)
+			got := env.GoToDeclaration(src)
+			var want protocol.Location
+			if test.want == "def" {
+				want = env.GoToDefinition(src)
+			} else {
+				file, re, _ := strings.Cut(test.want, ":")
+				env.OpenFile(file)
+				want = env.RegexpSearch(file, re)
+			}
+			if got != want {
+				t.Errorf("Declaration(%s: %q) = %v, want %v", test.file, test.re, got, want)
+			}
+		}
+	})
+}
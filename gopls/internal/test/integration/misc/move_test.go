@@ -0,0 +1,341 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/protocol/command"
+	"golang.org/x/tools/gopls/internal/settings"
+	"golang.org/x/tools/gopls/internal/test/compare"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+const moveFiles = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import "fmt"
+
+// Greeting is a greeting.
+type Greeting struct {
+	Name string
+}
+
+// String returns the greeting.
+func (g Greeting) String() string {
+	return fmt.Sprintf("%s%s%s", Prefix, g.Name, Suffix)
+}
+
+// Prefix and Suffix surround greetings.
+const (
+	Prefix = "hello, "
+	Suffix = "!"
+)
+
+func Hello() string {
+	return Prefix + shout("world")
+}
+
+func shout(s string) string { return s + "!" }
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+var B = a.Greeting{Name: "b"}.String() + a.Hello()
+-- c/c.go --
+package c
+
+import "example.com/a"
+
+var C = a.Prefix
+`
+
+// TestMoveToPackage exercises the "Move declarations to another
+// package" code action, moving a type and its method to a new package
+// chosen by the user.
+func TestMoveToPackage(t *testing.T) {
+	const dst = "example.com/a/greeting"
+	var choices []string
+	respond := func(params *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+		for _, item := range params.Actions {
+			choices = append(choices, item.Title)
+		}
+		return &protocol.MessageActionItem{Title: dst}, nil
+	}
+	WithOptions(
+		MessageResponder(respond),
+	).Run(t, moveFiles, func(t *testing.T, env *Env) {
+		choices = nil
+		env.OpenFile("a/a.go")
+		loc := env.RegexpSearch("a/a.go", `(?s)type Greeting.*return fmt[^\n]*\n}`)
+		action, err := codeActionByKind(env.CodeAction(loc, nil, 0), settings.RefactorMoveToPackage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   action.Command.Command,
+			Arguments: action.Command.Arguments,
+		}, nil)
+		if want := []string{dst, "example.com/b", "example.com/c"}; strings.Join(choices, " ") != strings.Join(want, " ") {
+			t.Errorf("destination choices = %v, want %v", choices, want)
+		}
+
+		const wantA = `package a
+
+// Prefix and Suffix surround greetings.
+const (
+	Prefix = "hello, "
+	Suffix = "!"
+)
+
+func Hello() string {
+	return Prefix + shout("world")
+}
+
+func shout(s string) string { return s + "!" }
+`
+		if got := env.BufferText("a/a.go"); got != wantA {
+			t.Errorf("a/a.go after move:\n%s", compare.Text(wantA, got))
+		}
+
+		const wantB = `package b
+
+import (
+	"example.com/a"
+	"example.com/a/greeting"
+)
+
+var B = greeting.Greeting{Name: "b"}.String() + a.Hello()
+`
+		if got := env.BufferText("b/b.go"); got != wantB {
+			t.Errorf("b/b.go after move:\n%s", compare.Text(wantB, got))
+		}
+
+		const wantGreeting = `package greeting
+
+import (
+	"fmt"
+
+	"example.com/a"
+)
+
+// Greeting is a greeting.
+type Greeting struct {
+	Name string
+}
+
+// String returns the greeting.
+func (g Greeting) String() string {
+	return fmt.Sprintf("%s%s%s", a.Prefix, g.Name, a.Suffix)
+}
+`
+		if got := env.BufferText("a/greeting/greeting.go"); got != wantGreeting {
+			t.Errorf("a/greeting/greeting.go after move:\n%s", compare.Text(wantGreeting, got))
+		}
+	})
+}
+
+// TestMoveToExistingPackage moves declarations to a package that
+// refers to them, and that the current package will import.
+func TestMoveToExistingPackage(t *testing.T) {
+	Run(t, moveFiles, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		args, err := command.MarshalArgs(command.MoveToPackageArgs{
+			Location:    env.RegexpSearch("a/a.go", `(const) \(`),
+			PackagePath: "example.com/c",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.MoveToPackage.String(),
+			Arguments: args,
+		}, nil)
+
+		const wantA = `package a
+
+import (
+	"fmt"
+
+	"example.com/c"
+)
+
+// Greeting is a greeting.
+type Greeting struct {
+	Name string
+}
+
+// String returns the greeting.
+func (g Greeting) String() string {
+	return fmt.Sprintf("%s%s%s", c.Prefix, g.Name, c.Suffix)
+}
+
+func Hello() string {
+	return c.Prefix + shout("world")
+}
+
+func shout(s string) string { return s + "!" }
+`
+		if got := env.BufferText("a/a.go"); got != wantA {
+			t.Errorf("a/a.go after move:\n%s", compare.Text(wantA, got))
+		}
+
+		const wantC = `package c
+
+var C = Prefix
+`
+		if got := env.BufferText("c/c.go"); got != wantC {
+			t.Errorf("c/c.go after move:\n%s", compare.Text(wantC, got))
+		}
+
+		const wantPrefix = `package c
+
+// Prefix and Suffix surround greetings.
+const (
+	Prefix = "hello, "
+	Suffix = "!"
+)
+`
+		if got := env.BufferText("c/prefix.go"); got != wantPrefix {
+			t.Errorf("c/prefix.go after move:\n%s", compare.Text(wantPrefix, got))
+		}
+	})
+}
+
+// TestMoveToRelativePackage moves declarations to a new package whose
+// path is relative to the current package.
+func TestMoveToRelativePackage(t *testing.T) {
+	Run(t, moveFiles, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		args, err := command.MarshalArgs(command.MoveToPackageArgs{
+			Location:    env.RegexpSearch("a/a.go", `(const) \(`),
+			PackagePath: "../b/affix",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.MoveToPackage.String(),
+			Arguments: args,
+		}, nil)
+
+		const wantC = `package c
+
+import "example.com/b/affix"
+
+var C = affix.Prefix
+`
+		if got := env.BufferText("c/c.go"); got != wantC {
+			t.Errorf("c/c.go after move:\n%s", compare.Text(wantC, got))
+		}
+
+		const wantPrefix = `package affix
+
+// Prefix and Suffix surround greetings.
+const (
+	Prefix = "hello, "
+	Suffix = "!"
+)
+`
+		if got := env.BufferText("b/affix/prefix.go"); got != wantPrefix {
+			t.Errorf("b/affix/prefix.go after move:\n%s", compare.Text(wantPrefix, got))
+		}
+	})
+}
+
+// TestMoveToPackageUnformatted checks that moving declarations does
+// not reformat the unrelated parts of a file that was not formatted.
+func TestMoveToPackageUnformatted(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+const Prefix = "hello, "
+-- b/b.go --
+package b
+
+import "example.com/a"
+
+var B   =   a.Prefix
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		args, err := command.MarshalArgs(command.MoveToPackageArgs{
+			Location:    env.RegexpSearch("a/a.go", `(const) Prefix`),
+			PackagePath: "example.com/c",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.MoveToPackage.String(),
+			Arguments: args,
+		}, nil)
+
+		const wantB = `package b
+
+import "example.com/c"
+
+var B   =   c.Prefix
+`
+		if got := env.BufferText("b/b.go"); got != wantB {
+			t.Errorf("b/b.go after move:\n%s", compare.Text(wantB, got))
+		}
+	})
+}
+
+// TestMoveToPackageErrors checks that declarations are not moved when
+// the move would break the program.
+func TestMoveToPackageErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		re      string // selected declarations
+		dst     string
+		wantErr string
+	}{
+		{"cycle", `(const) \(`, "example.com/b", "import cycle"},
+		{"method without type", `func \(g Greeting\)`, "example.com/b", "without its type"},
+		{"type without method", `(type) Greeting`, "example.com/c", "must be moved along with its type"},
+		{"refers to unexported", `func Hello`, "example.com/c", "unexported shout"},
+		{"referred to as unexported", `func shout`, "example.com/c", "unexported shout"},
+		{"outside the module", `(const) \(`, "../../other", "not in the module"},
+		{"invalid path", `(const) \(`, "./bad path", "invalid destination package"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			Run(t, moveFiles, func(t *testing.T, env *Env) {
+				env.OpenFile("a/a.go")
+				args, err := command.MarshalArgs(command.MoveToPackageArgs{
+					Location:    env.RegexpSearch("a/a.go", test.re),
+					PackagePath: test.dst,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = env.Editor.ExecuteCommand(env.Ctx, &protocol.ExecuteCommandParams{
+					Command:   command.MoveToPackage.String(),
+					Arguments: args,
+				}, nil)
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("MoveToPackage returned error %v, want %q", err, test.wantErr)
+				}
+				if got := env.BufferText("a/a.go"); !strings.Contains(got, "type Greeting") || !strings.Contains(got, "func shout") {
+					t.Errorf("a/a.go was modified:\n%s", got)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/protocol/command"
+	"golang.org/x/tools/gopls/internal/settings"
+	"golang.org/x/tools/gopls/internal/test/compare"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+// TestPropagateContext exercises the "Add context parameter" code
+// action, which adds a context.Context parameter to a function and
+// walks its callers upward.
+func TestPropagateContext(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+import "strings"
+
+func fetch(url string) string {
+	return strings.ToUpper(url)
+}
+
+func load(urls ...string) (res []string) {
+	for _, url := range urls {
+		res = append(res, fetch(url))
+	}
+	return res
+}
+
+func Load() []string {
+	return load("a", "b")
+}
+
+var v = fetch("v")
+-- a/b.go --
+package a
+
+import (
+	"context"
+	"strings"
+)
+
+func Handle(ctx context.Context) string {
+	return fetch("h") + run()
+}
+
+func run() string {
+	return strings.Join(load("r"), "")
+}
+`
+	const capabilities = `{"workspace": {"workspaceEdit": {"changeAnnotationSupport": {}}}}`
+	WithOptions(
+		CapabilitiesJSON([]byte(capabilities)),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		loc := env.RegexpSearch("a/a.go", `func (fetch)`)
+		action, err := codeActionByKind(env.CodeAction(loc, nil, 0), settings.RefactorRewritePropagateContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Add context parameter to fetch and its callers"; action.Title != want {
+			t.Errorf("action title = %q, want %q", action.Title, want)
+		}
+
+		// Check the annotations of the resolved edits.
+		args, err := command.MarshalArgs(command.PropagateContextArgs{Location: loc, ResolveEdits: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var edit protocol.WorkspaceEdit
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.PropagateContext.String(),
+			Arguments: args,
+		}, &edit)
+		var labels []string
+		for id, annot := range edit.ChangeAnnotations {
+			if id != annot.Label || !annot.NeedsConfirmation {
+				t.Errorf("annotation %q = %+v, want a label and confirmation", id, annot)
+			}
+			labels = append(labels, annot.Label)
+		}
+		sort.Strings(labels)
+		want := []string{
+			"Add ctx parameter to a.fetch",
+			"Add ctx parameter to a.load",
+			"Add ctx parameter to a.run",
+			"Import context in a.go",
+			"Pass context in a.Handle",
+			"Pass context in a.Load",
+			"Pass context in package initializers of a",
+		}
+		if strings.Join(labels, "\n") != strings.Join(want, "\n") {
+			t.Errorf("annotations:\n%s\nwant:\n%s", strings.Join(labels, "\n"), strings.Join(want, "\n"))
+		}
+
+		// Apply the edits.
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   action.Command.Command,
+			Arguments: action.Command.Arguments,
+		}, nil)
+		const wantA = `package a
+
+import (
+	"context"
+	"strings"
+)
+
+func fetch(ctx context.Context, url string) string {
+	return strings.ToUpper(url)
+}
+
+func load(ctx context.Context, urls ...string) (res []string) {
+	for _, url := range urls {
+		res = append(res, fetch(ctx, url))
+	}
+	return res
+}
+
+func Load() []string {
+	return load(context.TODO(), "a", "b")
+}
+
+var v = fetch(context.TODO(), "v")
+`
+		if got := env.BufferText("a/a.go"); got != wantA {
+			t.Errorf("a/a.go after propagating context:\n%s", compare.Text(wantA, got))
+		}
+		const wantB = `package a
+
+import (
+	"context"
+	"strings"
+)
+
+func Handle(ctx context.Context) string {
+	return fetch(ctx, "h") + run(ctx)
+}
+
+func run(ctx context.Context) string {
+	return strings.Join(load(ctx, "r"), "")
+}
+`
+		if got := env.BufferText("a/b.go"); got != wantB {
+			t.Errorf("a/b.go after propagating context:\n%s", compare.Text(wantB, got))
+		}
+	})
+}
+
+// TestPropagateContextMethods checks that unnamed parameters are named
+// and that unexported methods gain a context parameter unless they
+// are needed to satisfy an interface.
+func TestPropagateContextMethods(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+type T struct{}
+
+func fetch(int) {}
+
+func (T) get(n int) {
+	fetch(n)
+}
+
+type putter interface{ put() }
+
+func (T) put() {
+	fetch(2)
+}
+
+func Use(t T) {
+	t.get(1)
+	T.get(t, 2)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		loc := env.RegexpSearch("a/a.go", `func (fetch)`)
+		action, err := codeActionByKind(env.CodeAction(loc, nil, 0), settings.RefactorRewritePropagateContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The client does not support change annotations.
+		args, err := command.MarshalArgs(command.PropagateContextArgs{Location: loc, ResolveEdits: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var edit protocol.WorkspaceEdit
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.PropagateContext.String(),
+			Arguments: args,
+		}, &edit)
+		if len(edit.ChangeAnnotations) > 0 {
+			t.Errorf("got change annotations %v, want none", edit.ChangeAnnotations)
+		}
+		for _, change := range edit.DocumentChanges {
+			for _, e := range change.TextDocumentEdit.Edits {
+				if e, ok := e.Value.(protocol.AnnotatedTextEdit); ok && e.AnnotationID != nil {
+					t.Errorf("edit %v has annotation %q", e.TextEdit, *e.AnnotationID)
+				}
+			}
+		}
+
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   action.Command.Command,
+			Arguments: action.Command.Arguments,
+		}, nil)
+		const want = `package a
+
+import "context"
+
+type T struct{}
+
+func fetch(ctx context.Context, _ int) {}
+
+func (T) get(ctx context.Context, n int) {
+	fetch(ctx, n)
+}
+
+type putter interface{ put() }
+
+func (T) put() {
+	fetch(context.TODO(), 2)
+}
+
+func Use(t T) {
+	t.get(context.TODO(), 1)
+	T.get(t, context.TODO(), 2)
+}
+`
+		if got := env.BufferText("a/a.go"); got != want {
+			t.Errorf("a/a.go after propagating context:\n%s", compare.Text(want, got))
+		}
+	})
+}
+
+func TestPropagateContextErrors(t *testing.T) {
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func f() {}
+
+var g = f
+
+func h() {
+	ctx := 1
+	_ = ctx
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		for _, test := range []struct {
+			re, wantErr string
+		}{
+			{`func (f)`, "used as a value"},
+			{`func (h)`, "ctx"},
+		} {
+			loc := env.RegexpSearch("a/a.go", test.re)
+			args, err := command.MarshalArgs(command.PropagateContextArgs{Location: loc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = env.Editor.ExecuteCommand(env.Ctx, &protocol.ExecuteCommandParams{
+				Command:   command.PropagateContext.String(),
+				Arguments: args,
+			}, nil)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("PropagateContext(%s): got error %v, want containing %q", test.re, err, test.wantErr)
+			}
+		}
+	})
+}
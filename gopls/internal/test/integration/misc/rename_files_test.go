@@ -0,0 +1,294 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+func TestWillRenameFiles_Directory(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+package lib
+
+const A = 1
+-- lib/a_test.go --
+package lib_test
+
+import (
+	"testing"
+
+	"mod.com/lib"
+)
+
+func TestA(t *testing.T) { _ = lib.A }
+-- lib/nested/b.go --
+package nested
+
+const B = 1
+-- main.go --
+package main
+
+import (
+	"mod.com/lib"
+	"mod.com/lib/nested"
+)
+
+func main() {
+	println(lib.A, nested.B)
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		env.MoveFile("lib", "util")
+		env.AfterChange(NoDiagnostics())
+
+		env.RegexpSearch("util/a.go", "package util")
+		env.RegexpSearch("util/a_test.go", "package util_test")
+		env.RegexpSearch("util/a_test.go", `"mod.com/util"`)
+		env.RegexpSearch("util/a_test.go", `util\.A`)
+		env.RegexpSearch("util/nested/b.go", "package nested")
+		env.RegexpSearch("main.go", `"mod.com/util"`)
+		env.RegexpSearch("main.go", `"mod.com/util/nested"`)
+		env.RegexpSearch("main.go", `util\.A`)
+	})
+}
+
+func TestWillRenameFiles_File(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func A() {}
+-- a/extra.go --
+package a
+
+func Extra() {}
+-- b/b.go --
+package b
+
+func B() {}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.MoveFile("a/extra.go", "b/extra.go")
+		env.RegexpSearch("b/extra.go", "package b")
+		env.AfterChange(NoDiagnostics())
+	})
+}
+
+func TestWillRenameFiles_References(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+const A = 1
+-- a/extra.go --
+package a
+
+import "mod.com/b"
+
+func Extra() int { return A + b.B() }
+-- b/b.go --
+package b
+
+func B() int { return 0 }
+-- main.go --
+package main
+
+import "mod.com/a"
+
+func main() {
+	println(a.Extra())
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		env.MoveFile("a/extra.go", "b/extra.go")
+		env.AfterChange(NoDiagnostics())
+
+		env.RegexpSearch("b/extra.go", "package b")
+		env.RegexpSearch("b/extra.go", `"mod.com/a"`)
+		env.RegexpSearch("b/extra.go", `return a\.A \+ B\(\)`)
+		env.RegexpSearch("main.go", `"mod.com/b"`)
+		env.RegexpSearch("main.go", `b\.Extra\(\)`)
+	})
+}
+
+func TestWillRenameFiles_Errors(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/internal/x/x.go --
+package x
+
+const X = 1
+-- a/a.go --
+package a
+
+import "mod.com/a/internal/x"
+
+const A = x.X
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+const B = a.A
+
+const c = 1
+-- b/usea.go --
+package b
+
+import "mod.com/a"
+
+const C = a.A + c
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		for _, test := range []struct {
+			oldPath, newPath string
+			wantErr          string
+		}{
+			// a would no longer be allowed to import x.
+			{"a/internal/x", "b/internal/x", "not be allowed to import internal package"},
+			// a.go would import a/internal/x from outside a.
+			{"a/a.go", "b/a.go", "not be allowed to import internal package"},
+			// usea.go refers to unexported b.c.
+			{"b/usea.go", "a/usea.go", "unexported"},
+			// The package b already exists.
+			{"a", "b", "already exists"},
+		} {
+			params := &protocol.RenameFilesParams{
+				Files: []protocol.FileRename{{
+					OldURI: string(env.Sandbox.Workdir.URI(test.oldPath)),
+					NewURI: string(env.Sandbox.Workdir.URI(test.newPath)),
+				}},
+			}
+			_, err := env.Editor.Server.WillRenameFiles(env.Ctx, params)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("WillRenameFiles(%s -> %s): got error %v, want %q", test.oldPath, test.newPath, err, test.wantErr)
+			}
+		}
+	})
+}
+
+func TestWillRenameFiles_Cycle(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+const A = 1
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+const B = a.A
+-- c/c.go --
+package c
+
+import "mod.com/b"
+
+const C = b.B
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		// Moving c.go into package a would create the cycle a -> b -> a.
+		params := &protocol.RenameFilesParams{
+			Files: []protocol.FileRename{{
+				OldURI: string(env.Sandbox.Workdir.URI("c/c.go")),
+				NewURI: string(env.Sandbox.Workdir.URI("a/c.go")),
+			}},
+		}
+		_, err := env.Editor.Server.WillRenameFiles(env.Ctx, params)
+		if err == nil || !strings.Contains(err.Error(), "import cycle") {
+			t.Errorf("WillRenameFiles: got error %v, want import cycle", err)
+		}
+	})
+}
+
+func TestWillCreateFiles(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- lib/a.go --
+// Copyright 2023 The Authors.
+
+package lib
+
+const A = 1
+-- lib/b.go --
+// Copyright 2024 The Authors.
+
+//go:build linux
+
+package lib
+-- lib/a_test.go --
+// Copyright 2024 The Authors.
+
+package lib_test
+-- lib/gen.go --
+//go:build ignore
+
+package main
+-- mixed/a.go --
+// Copyright 2024 The Authors.
+
+package mixed
+-- mixed/b.go --
+package mixed
+-- my-tool/README --
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		for _, test := range []struct {
+			path, want string
+		}{
+			{"lib/c.go", "// Copyright 2024 The Authors.\n\npackage lib\n"},
+			{"lib/c_test.go", "// Copyright 2024 The Authors.\n\npackage lib_test\n"},
+			{"mixed/c_test.go", "package mixed\n"},
+			{"my-tool/main.go", "package mytool\n"},
+		} {
+			env.CreateFile(test.path)
+			if got := env.BufferText(test.path); got != test.want {
+				t.Errorf("new file %s contains %q, want %q", test.path, got, test.want)
+			}
+		}
+		env.AfterChange(NoDiagnostics(ForFile("lib/c.go")))
+	})
+
+	// Without support for creating files in workspace edits, new
+	// files are left empty.
+	const capabilities = `{"workspace": {"workspaceEdit": {"resourceOperations": ["rename"]}}}`
+	WithOptions(
+		CapabilitiesJSON([]byte(capabilities)),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.CreateFile("lib/c.go")
+		if got := env.ReadWorkspaceFile("lib/c.go"); got != "" {
+			t.Errorf("new file lib/c.go contains %q, want it empty", got)
+		}
+	})
+}
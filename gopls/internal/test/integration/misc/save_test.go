@@ -0,0 +1,100 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/test/compare"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+)
+
+func TestWillSaveWaitUntil(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+import (
+	"os"
+	"fmt"
+)
+
+type T struct{ X, Y int }
+
+var ts = []T{T{1, 2}, T{3, 4}}
+
+func _() {
+	fmt.Fprintln(os.Stdout, ts)
+}
+`
+	const want = `package a
+
+import (
+	"fmt"
+	"os"
+)
+
+type T struct{ X, Y int }
+
+var ts = []T{{1, 2}, {3, 4}}
+
+func _() {
+	fmt.Fprintln(os.Stdout, ts)
+}
+`
+
+	t.Run("default", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("a.go")
+			before := env.BufferText("a.go")
+			env.SaveBufferWithoutActions("a.go")
+			if got := env.BufferText("a.go"); got != before {
+				t.Errorf("saving changed the buffer:\n%s", compare.Text(before, got))
+			}
+		})
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		WithOptions(
+			Settings{
+				"organizeImportsOnSave": true,
+				"fixesOnSave":           []string{"simplifycompositelit"},
+			},
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("a.go")
+			env.SaveBufferWithoutActions("a.go")
+			if got := env.BufferText("a.go"); got != want {
+				t.Errorf("buffer after saving differs from want:\n%s", compare.Text(want, got))
+			}
+			if got := env.ReadWorkspaceFile("a.go"); got != want {
+				t.Errorf("file on disk after saving differs from want:\n%s", compare.Text(want, got))
+			}
+		})
+	})
+
+	// The imports of a package with errors are organized, but no
+	// fixes are applied.
+	t.Run("errors", func(t *testing.T) {
+		const typeError = "\nfunc g() (int, error) {\n\treturn nil\n}\n"
+		WithOptions(
+			Settings{
+				"organizeImportsOnSave": true,
+				"fixesOnSave":           []string{"simplifycompositelit", "fillreturns"},
+			},
+		).Run(t, files+typeError, func(t *testing.T, env *Env) {
+			env.OpenFile("a.go")
+			env.SaveBufferWithoutActions("a.go")
+			want := strings.Replace(want, "{{1, 2}, {3, 4}}", "{T{1, 2}, T{3, 4}}", 1) + typeError
+			if got := env.BufferText("a.go"); got != want {
+				t.Errorf("buffer after saving differs from want:\n%s", compare.Text(want, got))
+			}
+		})
+	})
+}
@@ -5,7 +5,9 @@
 package misc
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 
@@ -236,3 +238,106 @@ const bad = `
 		}
 	})
 }
+
+// Test that textDocument/semanticTokens/full/delta returns edits that
+// transform the previous result into the current tokens.
+func TestSemanticTokensDelta(t *testing.T) {
+	const src = `
+-- go.mod --
+module example.com
+
+go 1.21
+-- main.go --
+package main
+
+func f(x int) int { return x }
+
+func g() string { return "g" }
+
+func h() {}
+`
+	WithOptions(
+		Modes(Default),
+		Settings{"semanticTokens": true},
+	).Run(t, src, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		uri := env.Sandbox.Workdir.URI("main.go")
+		td := protocol.TextDocumentIdentifier{URI: uri}
+		full, err := env.Editor.Server.SemanticTokensFull(env.Ctx, &protocol.SemanticTokensParams{TextDocument: td})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if full.ResultID == "" {
+			t.Fatalf("SemanticTokensFull returned no result ID")
+		}
+
+		// delta requests tokens relative to the given result,
+		// and decodes the response.
+		delta := func(prev string) (*protocol.SemanticTokensDelta, *protocol.SemanticTokens) {
+			resp, err := env.Editor.Server.SemanticTokensFullDelta(env.Ctx, &protocol.SemanticTokensDeltaParams{
+				TextDocument:     td,
+				PreviousResultID: prev,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := fields["edits"]; ok {
+				var d protocol.SemanticTokensDelta
+				if err := json.Unmarshal(data, &d); err != nil {
+					t.Fatal(err)
+				}
+				return &d, nil
+			}
+			var tokens protocol.SemanticTokens
+			if err := json.Unmarshal(data, &tokens); err != nil {
+				t.Fatal(err)
+			}
+			return nil, &tokens
+		}
+
+		// Edit the middle function, and check that applying the
+		// delta to the previous tokens yields the current ones.
+		env.RegexpReplace("main.go", `"g"`, `"g" + "h"`)
+		d, _ := delta(full.ResultID)
+		if d == nil {
+			t.Fatalf("SemanticTokensFullDelta(%q) returned full tokens, want delta", full.ResultID)
+		}
+		if len(d.Edits) != 1 {
+			t.Fatalf("got %d edits, want 1", len(d.Edits))
+		}
+		got := slices.Clone(full.Data)
+		for _, edit := range d.Edits {
+			got = slices.Replace(got, int(edit.Start), int(edit.Start+edit.DeleteCount), edit.Data...)
+		}
+		if e := d.Edits[0]; int(e.DeleteCount) >= len(full.Data) {
+			t.Errorf("delta edit replaces all %d elements of previous tokens", e.DeleteCount)
+		}
+
+		// An unchanged document has an empty delta.
+		d2, _ := delta(d.ResultID)
+		if d2 == nil || len(d2.Edits) != 0 {
+			t.Errorf("SemanticTokensFullDelta of unchanged document = %+v, want no edits", d2)
+		}
+
+		want, err := env.Editor.Server.SemanticTokensFull(env.Ctx, &protocol.SemanticTokensParams{TextDocument: td})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want.Data, got); diff != "" {
+			t.Errorf("tokens after applying delta do not match (-want +got):\n%s", diff)
+		}
+
+		// An unknown previous result yields the full set of tokens.
+		if _, tokens := delta("unknown"); tokens == nil || !slices.Equal(tokens.Data, want.Data) {
+			t.Errorf("SemanticTokensFullDelta with unknown result ID = %+v, want full tokens", tokens)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/internal/protocol"
+	"golang.org/x/tools/gopls/internal/settings"
+	. "golang.org/x/tools/gopls/internal/test/integration"
+	"golang.org/x/tools/internal/testenv"
+)
+
+// TestVirtualDocuments exercises the virtual documents served through
+// the workspace/textDocumentContent request to clients that support
+// it, in place of the pages of the web server.
+func TestVirtualDocuments(t *testing.T) {
+	testenv.NeedsGoCommand1Point(t, 22) // for up-to-date assembly listing
+
+	const files = `
+-- go.mod --
+module example.com
+
+go 1.18
+
+-- a/a.go --
+// Package a is a package.
+package a
+
+// F returns its argument.
+func F(x int) int {
+	return x
+}
+
+func f() {
+	println("hello")
+}
+
+type (
+	// T is a type.
+	T int
+
+	t int
+)
+
+// M is a method.
+func (T) M() {}
+
+type S struct {
+	X int // X is exported.
+	y int
+}
+
+func Map[T any](s []T, f func(T) string) []string { return nil }
+
+type Stack[E any] []E
+
+func (s *Stack[E]) Push(x E) {}
+
+func _() {
+	var s Stack[S]
+	s.Push(S{})
+	Map([]t{}, func(t) string { return "" })
+}
+`
+	WithOptions(
+		CapabilitiesJSON([]byte(virtualDocumentCapabilities)),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+
+		// Package documentation.
+		doc := openVirtualDocument(t, env, env.RegexpSearch("a/a.go", "F"), settings.GoDoc)
+		for _, want := range []string{
+			"// Package a is a package.\npackage a // import \"example.com/a\"\n",
+			"// F returns its argument.\nfunc F(x int) int\n",
+			"// T is a type.\ntype T int\n",
+			"type S struct {\n\tX int // X is exported.\n\t// contains filtered or unexported fields\n}\n",
+			"// M is a method.\nfunc (T) M()\n",
+		} {
+			if !strings.Contains(doc, want) {
+				t.Errorf("package documentation does not contain %q:\n%s", want, doc)
+			}
+		}
+		for _, unwanted := range []string{"func f()", "type t", "y int", "return x"} {
+			if strings.Contains(doc, unwanted) {
+				t.Errorf("package documentation contains unexported declaration or body %q:\n%s", unwanted, doc)
+			}
+		}
+
+		// Assembly listing.
+		listing := openVirtualDocument(t, env, env.RegexpSearch("a/a.go", "println"), settings.GoAssembly)
+		checkMatch(t, true, []byte(listing), `TEXT.*example.com/a.f`)
+		checkMatch(t, false, []byte(listing), `TEXT.*example.com/a.F`)
+		checkMatch(t, true, []byte(listing), `\tL0010\t`) // println line
+
+		// Instantiations of a generic function and a method.
+		inst := openVirtualDocument(t, env, env.RegexpSearch("a/a.go", `(Map)\(\[`), settings.GoInstantiation)
+		if want := "func Map(s []t, f func(t) string) []string {"; !strings.Contains(inst, want) {
+			t.Errorf("instantiation does not contain %q:\n%s", want, inst)
+		}
+		inst = openVirtualDocument(t, env, env.RegexpSearch("a/a.go", `s\.(Push)`), settings.GoInstantiation)
+		if want := "func (s *Stack[S]) Push(x S) {"; !strings.Contains(inst, want) {
+			t.Errorf("instantiation does not contain %q:\n%s", want, inst)
+		}
+	})
+}
+
+// TestVirtualCgoDocument exercises the virtual document of the Go file
+// generated by cgo.
+func TestVirtualCgoDocument(t *testing.T) {
+	testenv.NeedsTool(t, "cgo")
+
+	const files = `
+-- go.mod --
+module example.com
+
+-- a/a.go --
+package a
+
+// int add(int x, int y) { return x + y; }
+import "C"
+
+func Add(x, y int) int {
+	return int(C.add(C.int(x), C.int(y)))
+}
+`
+	WithOptions(
+		CapabilitiesJSON([]byte(virtualDocumentCapabilities)),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		generated := openVirtualDocument(t, env, env.RegexpSearch("a/a.go", "Add"), settings.GoCgo)
+		checkMatch(t, true, []byte(generated), `_Cfunc_add`)
+	})
+}
+
+const virtualDocumentCapabilities = `{"workspace": {"textDocumentContent": {}}}`
+
+// openVirtualDocument executes the command of the code action of the
+// given kind at loc, and returns the content of the virtual document
+// that it shows.
+func openVirtualDocument(t *testing.T, env *Env, loc protocol.Location, kind protocol.CodeActionKind) string {
+	t.Helper()
+	action, err := codeActionByKind(env.CodeAction(loc, nil, 0), kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectDocs := env.Awaiter.ListenToShownDocuments()
+	var result any
+	env.ExecuteCommand(&protocol.ExecuteCommandParams{
+		Command:   action.Command.Command,
+		Arguments: action.Command.Arguments,
+	}, &result)
+	doc := shownDocument(t, collectDocs(), "gopls:")
+	if doc == nil {
+		t.Fatalf("no showDocument call had 'gopls:' prefix")
+	}
+	if doc.External {
+		t.Errorf("virtual document %s shown externally", doc.URI)
+	}
+
+	// Virtual document URIs are not DocumentURIs.
+	if _, err := protocol.ParseDocumentURI(doc.URI); err == nil {
+		t.Errorf("virtual document URI %s is a DocumentURI", doc.URI)
+	}
+
+	content, err := env.Editor.Server.TextDocumentContent(env.Ctx, &protocol.TextDocumentContentParams{URI: doc.URI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *content
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/gopls/internal/protocol"
 	. "golang.org/x/tools/gopls/internal/test/integration"
 	"golang.org/x/tools/gopls/internal/settings"
 )
@@ -102,6 +103,52 @@ const (
 	})
 }
 
+func TestResolveWorkspaceSymbol(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.17
+-- a/a.go --
+package a
+
+type T struct{}
+
+func (T) Method() {}
+`
+
+	Run(t, files, func(t *testing.T, env *Env) {
+		uri := env.Sandbox.Workdir.URI("a/a.go")
+		for _, test := range []struct {
+			name string
+			kind protocol.SymbolKind
+			want string
+		}{
+			{"T", protocol.Struct, `type (T)`},
+			{"a.T.Method", protocol.Method, `\) (Method)`},
+		} {
+			sym := &protocol.WorkspaceSymbol{
+				Location: protocol.OrPLocation_workspace_symbol{Value: protocol.LocationUriOnly{URI: uri}},
+				BaseSymbolInformation: protocol.BaseSymbolInformation{
+					Name: test.name,
+					Kind: test.kind,
+				},
+			}
+			resolved, err := env.Editor.Server.ResolveWorkspaceSymbol(env.Ctx, sym)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc, ok := resolved.Location.Value.(protocol.Location)
+			if !ok {
+				t.Fatalf("resolved symbol %s has location %T, want a Location", test.name, resolved.Location.Value)
+			}
+			if want := env.RegexpSearch("a/a.go", test.want); loc.Range != want.Range {
+				t.Errorf("resolved symbol %s has range %v, want %v", test.name, loc.Range, want.Range)
+			}
+		}
+	})
+}
+
 func checkSymbols(env *Env, query string, want ...string) {
 	env.T.Helper()
 	var got []string
@@ -202,6 +202,15 @@ func (e *Env) GoToDefinition(loc protocol.Location) protocol.Location {
 	return loc
 }
 
+func (e *Env) GoToDeclaration(loc protocol.Location) protocol.Location {
+	e.T.Helper()
+	loc, err := e.Editor.Declaration(e.Ctx, loc)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return loc
+}
+
 func (e *Env) TypeDefinition(loc protocol.Location) protocol.Location {
 	e.T.Helper()
 	loc, err := e.Editor.TypeDefinition(e.Ctx, loc)
@@ -256,6 +265,30 @@ func (e *Env) Diagnostics(name string) []protocol.Diagnostic {
 	return diags
 }
 
+// DocumentDiagnostics returns the textDocument/diagnostic report for
+// the given file, calling t.Fatal on any error. See
+// [fake.Editor.DocumentDiagnostics].
+func (e *Env) DocumentDiagnostics(name, previousResultID string) any {
+	e.T.Helper()
+	report, err := e.Editor.DocumentDiagnostics(e.Ctx, name, previousResultID)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return report
+}
+
+// WorkspaceDiagnostics returns the workspace/diagnostic reports, and
+// the number of partial results that delivered them, calling t.Fatal
+// on any error. See [fake.Editor.WorkspaceDiagnostics].
+func (e *Env) WorkspaceDiagnostics(previous []protocol.PreviousResultID) ([]protocol.WorkspaceDocumentDiagnosticReport, int) {
+	e.T.Helper()
+	items, partials, err := e.Editor.WorkspaceDiagnostics(e.Ctx, previous)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return items, partials
+}
+
 // GetQuickFixes returns the available quick fix code actions, calling t.Fatal
 // on any error.
 func (e *Env) GetQuickFixes(path string, diagnostics []protocol.Diagnostic) []protocol.CodeAction {
@@ -511,6 +544,22 @@ func (e *Env) RenameFile(oldPath, newPath string) {
 	}
 }
 
+// MoveFile wraps Editor.MoveFile, calling t.Fatal on any error.
+func (e *Env) MoveFile(oldPath, newPath string) {
+	e.T.Helper()
+	if err := e.Editor.MoveFile(e.Ctx, oldPath, newPath); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// CreateFile wraps Editor.CreateFile, calling t.Fatal on any error.
+func (e *Env) CreateFile(path string) {
+	e.T.Helper()
+	if err := e.Editor.CreateFile(e.Ctx, path); err != nil {
+		e.T.Fatal(err)
+	}
+}
+
 // SignatureHelp wraps Editor.SignatureHelp, calling t.Fatal on error
 func (e *Env) SignatureHelp(loc protocol.Location) *protocol.SignatureHelp {
 	e.T.Helper()
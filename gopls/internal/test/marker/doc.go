@@ -222,6 +222,24 @@ Here is the list of supported action markers:
     (These locations are the declarations of the functions enclosing
     the calls, not the calls themselves.)
 
+  - inlinevalues(stopped location, golden): makes a
+    textDocument/inlineValue request for the entire file, as if a
+    debugger were stopped at the given location, and compares the file,
+    annotated with the resulting values, against the golden file. A
+    variable lookup of x appears as [x], and an expression e to be
+    evaluated as {e}, in place of the corresponding source text.
+
+  - moniker(src location, identifier, kind, unique string): makes a
+    textDocument/moniker request at the src location and checks that
+    the result is a single moniker with the given identifier (or one
+    that matches it entirely, if it is a regexp), kind, and uniqueness
+    level. An empty identifier asserts that there is no moniker.
+
+  - ontypeformat(location, ch string, golden): performs a
+    textDocument/onTypeFormatting request as if the character ch had
+    just been typed at the end of the location, and compares the
+    formatted file (or error) against the golden file, like format.
+
   - outgoingcalls(src location, want ...location): makes a
     callHierarchy/outgoingCalls query at the src location, and checks that
     the set of call.To locations matches want.
@@ -243,6 +261,10 @@ Here is the list of supported action markers:
     (Failures in the computation to offer a fix do not generally result
     in LSP errors, so this marker is not appropriate for testing them.)
 
+  - rangeformat(golden, ranges ...location): like format, but performs
+    a textDocument/rangeFormatting request for a single range, or a
+    textDocument/rangesFormatting request for several.
+
   - rank(location, ...string OR completionItem): executes a
     textDocument/completion request at the given location, and verifies that
     each expected completion item occurs in the results, in the expected order.
@@ -275,6 +297,15 @@ Here is the list of supported action markers:
     case the item's label is used). It checks that the resulting snippet
     matches the provided snippet.
 
+  - subtypes(src location, want ...location): makes a
+    textDocument/prepareTypeHierarchy request at the src location,
+    followed by a typeHierarchy/subtypes request for the resulting item,
+    and checks that the set of locations of the resulting items
+    matches want.
+
+  - supertypes(src location, want ...location): like subtypes,
+    but makes a typeHierarchy/supertypes request.
+
   - symbol(golden): makes a textDocument/documentSymbol request
     for the enclosing file, formats the response with one symbol
     per line, sorts it, and compares against the named golden file.
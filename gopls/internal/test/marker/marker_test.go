@@ -584,8 +584,12 @@ var actionMarkerFuncs = map[string]func(marker){
 	"implementation":   actionMarkerFunc(implementationMarker),
 	"incomingcalls":    actionMarkerFunc(incomingCallsMarker),
 	"inlayhints":       actionMarkerFunc(inlayhintsMarker),
+	"inlinevalues":     actionMarkerFunc(inlineValuesMarker),
+	"moniker":          actionMarkerFunc(monikerMarker),
+	"ontypeformat":     actionMarkerFunc(onTypeFormatMarker),
 	"outgoingcalls":    actionMarkerFunc(outgoingCallsMarker),
 	"preparerename":    actionMarkerFunc(prepareRenameMarker, "span"),
+	"rangeformat":      actionMarkerFunc(rangeFormatMarker),
 	"rank":             actionMarkerFunc(rankMarker),
 	"refs":             actionMarkerFunc(refsMarker),
 	"rename":           actionMarkerFunc(renameMarker),
@@ -593,6 +597,8 @@ var actionMarkerFuncs = map[string]func(marker){
 	"selectionrange":   actionMarkerFunc(selectionRangeMarker),
 	"signature":        actionMarkerFunc(signatureMarker),
 	"snippet":          actionMarkerFunc(snippetMarker),
+	"subtypes":         actionMarkerFunc(subtypesMarker),
+	"supertypes":       actionMarkerFunc(supertypesMarker),
 	"quickfix":         actionMarkerFunc(quickfixMarker),
 	"quickfixerr":      actionMarkerFunc(quickfixErrMarker),
 	"symbol":           actionMarkerFunc(symbolMarker),
@@ -1709,6 +1715,80 @@ func formatMarker(mark marker, golden *Golden) {
 	edits, err := mark.server().Formatting(mark.ctx(), &protocol.DocumentFormattingParams{
 		TextDocument: mark.document(),
 	})
+	compareFormatting(mark, edits, err, golden)
+}
+
+func monikerMarker(mark marker, src protocol.Location, identifier any, kind, unique string) {
+	monikers, err := mark.server().Moniker(mark.ctx(), &protocol.MonikerParams{
+		TextDocumentPositionParams: protocol.LocationTextDocumentPositionParams(src),
+	})
+	if err != nil {
+		mark.errorf("moniker request failed: %v", err)
+		return
+	}
+	if identifier == "" {
+		if len(monikers) > 0 {
+			mark.errorf("got monikers %v, want none", monikers)
+		}
+		return
+	}
+	if len(monikers) != 1 {
+		mark.errorf("got %d monikers, want 1", len(monikers))
+		return
+	}
+	m := monikers[0]
+	switch identifier := identifier.(type) {
+	case string:
+		if m.Identifier != identifier {
+			mark.errorf("got moniker identifier %q, want %q", m.Identifier, identifier)
+		}
+	case *regexp.Regexp:
+		if loc := identifier.FindStringIndex(m.Identifier); loc == nil || loc[0] != 0 || loc[1] != len(m.Identifier) {
+			mark.errorf("got moniker identifier %q, want match for %#q", m.Identifier, identifier)
+		}
+	default:
+		mark.errorf("invalid moniker identifier argument %T (want string or regexp)", identifier)
+	}
+	if got := fmt.Sprintf("%s %s", *m.Kind, m.Unique); got != kind+" "+unique {
+		mark.errorf("got moniker kind and uniqueness %q, want %q", got, kind+" "+unique)
+	}
+}
+
+func onTypeFormatMarker(mark marker, loc protocol.Location, ch string, golden *Golden) {
+	edits, err := mark.server().OnTypeFormatting(mark.ctx(), &protocol.DocumentOnTypeFormattingParams{
+		TextDocument: mark.document(),
+		Position:     loc.Range.End,
+		Ch:           ch,
+	})
+	compareFormatting(mark, edits, err, golden)
+}
+
+func rangeFormatMarker(mark marker, golden *Golden, locs ...protocol.Location) {
+	var (
+		edits []protocol.TextEdit
+		err   error
+	)
+	if len(locs) == 1 {
+		edits, err = mark.server().RangeFormatting(mark.ctx(), &protocol.DocumentRangeFormattingParams{
+			TextDocument: mark.document(),
+			Range:        locs[0].Range,
+		})
+	} else {
+		ranges := make([]protocol.Range, len(locs))
+		for i, loc := range locs {
+			ranges[i] = loc.Range
+		}
+		edits, err = mark.server().RangesFormatting(mark.ctx(), &protocol.DocumentRangesFormattingParams{
+			TextDocument: mark.document(),
+			Ranges:       ranges,
+		})
+	}
+	compareFormatting(mark, edits, err, golden)
+}
+
+// compareFormatting applies the result of a formatting request to the
+// current file and compares the result, or the error, with golden.
+func compareFormatting(mark marker, edits []protocol.TextEdit, err error, golden *Golden) {
 	var got []byte
 	if err != nil {
 		got = []byte(err.Error() + "\n") // all golden content is newline terminated
@@ -2453,6 +2533,46 @@ func callHierarchy(mark marker, src protocol.Location, getCalls callHierarchyFun
 	}
 }
 
+func subtypesMarker(mark marker, src protocol.Location, want ...protocol.Location) {
+	typeHierarchy(mark, src, want, func(item protocol.TypeHierarchyItem) ([]protocol.TypeHierarchyItem, error) {
+		return mark.server().Subtypes(mark.ctx(), &protocol.TypeHierarchySubtypesParams{Item: item})
+	})
+}
+
+func supertypesMarker(mark marker, src protocol.Location, want ...protocol.Location) {
+	typeHierarchy(mark, src, want, func(item protocol.TypeHierarchyItem) ([]protocol.TypeHierarchyItem, error) {
+		return mark.server().Supertypes(mark.ctx(), &protocol.TypeHierarchySupertypesParams{Item: item})
+	})
+}
+
+type typeHierarchyFunc = func(protocol.TypeHierarchyItem) ([]protocol.TypeHierarchyItem, error)
+
+func typeHierarchy(mark marker, src protocol.Location, want []protocol.Location, getTypes typeHierarchyFunc) {
+	items, err := mark.server().PrepareTypeHierarchy(mark.ctx(), &protocol.TypeHierarchyPrepareParams{
+		TextDocumentPositionParams: protocol.LocationTextDocumentPositionParams(src),
+	})
+	if err != nil {
+		mark.errorf("PrepareTypeHierarchy failed: %v", err)
+		return
+	}
+	if nitems := len(items); nitems != 1 {
+		mark.errorf("PrepareTypeHierarchy returned %d items, want exactly 1", nitems)
+		return
+	}
+	related, err := getTypes(items[0])
+	if err != nil {
+		mark.errorf("type hierarchy failed: %v", err)
+		return
+	}
+	got := []protocol.Location{}
+	for _, item := range related {
+		got = append(got, protocol.Location{URI: item.URI, Range: item.SelectionRange})
+	}
+	if err := compareLocations(mark, got, want); err != nil {
+		mark.errorf("type hierarchy: %v", err)
+	}
+}
+
 func inlayhintsMarker(mark marker, g *Golden) {
 	hints := mark.run.env.InlayHints(mark.path())
 
@@ -2482,6 +2602,64 @@ func inlayhintsMarker(mark marker, g *Golden) {
 	compareGolden(mark, got, g)
 }
 
+// inlineValuesMarker requests the inline values of the entire file, for
+// a debugger stopped at the given location, and compares the file,
+// annotated with the values, against the golden content. A variable
+// lookup of x is shown as [x], and an evaluated expression e as {e}.
+func inlineValuesMarker(mark marker, stopped protocol.Location, g *Golden) {
+	m := mark.mapper()
+	end, err := m.OffsetPosition(len(m.Content))
+	if err != nil {
+		mark.errorf("OffsetPosition: %v", err)
+		return
+	}
+	values, err := mark.server().InlineValue(mark.ctx(), &protocol.InlineValueParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: mark.uri()},
+		Range:        protocol.Range{End: end},
+		Context:      protocol.InlineValueContext{StoppedLocation: stopped.Range},
+	})
+	if err != nil {
+		mark.errorf("InlineValue failed: %v", err)
+		return
+	}
+
+	var edits []protocol.TextEdit
+	for _, v := range values {
+		// The JSON decoder cannot distinguish the kinds of inline
+		// value, so decode them afresh. (A variable lookup may be
+		// decoded as an expression without one, losing its name.)
+		data, err := json.Marshal(v.Value)
+		if err != nil {
+			mark.errorf("marshaling inline value: %v", err)
+			return
+		}
+		var value struct {
+			Range      protocol.Range
+			Expression string
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			mark.errorf("unmarshaling inline value: %v", err)
+			return
+		}
+		start, end, err := m.RangeOffsets(value.Range)
+		if err != nil {
+			mark.errorf("RangeOffsets: %v", err)
+			return
+		}
+		text := fmt.Sprintf("[%s]", m.Content[start:end])
+		if value.Expression != "" {
+			text = fmt.Sprintf("{%s}", value.Expression)
+		}
+		edits = append(edits, protocol.TextEdit{Range: value.Range, NewText: text})
+	}
+	got, _, err := protocol.ApplyEdits(m, edits)
+	if err != nil {
+		mark.errorf("ApplyProtocolEdits: %v", err)
+		return
+	}
+	compareGolden(mark, got, g)
+}
+
 func prepareRenameMarker(mark marker, src protocol.Location, placeholder string) {
 	params := &protocol.PrepareRenameParams{
 		TextDocumentPositionParams: protocol.LocationTextDocumentPositionParams(src),
@@ -0,0 +1,606 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inline
+
+// This file defines the inlining of local variables and constants,
+// which replaces each reference to a name by its initializer.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// InlineVariable replaces each reference to the local variable v
+// within file by the variable's initializer, and deletes the
+// declaration of v. The file must be the one that declares v, and
+// content must be its source.
+//
+// The variable must be declared alone, with an initializer, by a short
+// variable declaration or a var declaration within a block, and it
+// must not be assigned or have its address taken after its
+// declaration.
+//
+// The transformation is rejected if it might change the meaning of
+// the program: if the free names of the initializer would refer to
+// different objects at some reference; if the initializer is
+// referenced more than once but is not duplicable; or if the
+// initializer is impure and a reference is within a loop, a
+// conditional statement, or a function literal, where the initializer
+// might be evaluated more than once or not at all, or the effects of
+// the statements between the declaration and the references (see
+// [calleefx]) could change its value or be reordered with its own
+// effects. If the initializer is
+// constant, each reference must also satisfy the constraints of
+// "fallible constant" expressions (see [falcon]).
+func InlineVariable(fset *token.FileSet, info *types.Info, file *ast.File, content []byte, v *types.Var, opts *Options) (*Result, error) {
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	if v.IsField() || isPkgLevel(v) {
+		return nil, fmt.Errorf("%s is not a local variable", v.Name())
+	}
+	b, err := findBinding(info, file, v)
+	if err != nil {
+		return nil, err
+	}
+	if b.stmt == nil {
+		return nil, fmt.Errorf("cannot inline %s: not declared by a statement", v.Name())
+	}
+	uses := findUses(info, file, v)
+	if len(uses) == 0 {
+		return nil, fmt.Errorf("cannot inline %s: it is not referenced", v.Name())
+	}
+
+	// Find the enclosing function (outermost, so that the escape
+	// analysis sees any updates from nested function literals) and
+	// the list of statements that contains the declaration.
+	var root ast.Node
+	for _, n := range b.path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			root = n
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("cannot inline %s: not within a function", v.Name())
+	}
+	var list []ast.Stmt
+	switch parent := b.path[slices.Index(b.path, ast.Node(b.stmt))+1].(type) {
+	case *ast.BlockStmt:
+		list = parent.List
+	case *ast.CaseClause:
+		list = parent.Body
+	case *ast.CommClause:
+		list = parent.Body
+	default:
+		return nil, fmt.Errorf("cannot inline %s: it is declared in the header of a statement", v.Name())
+	}
+	i := slices.Index(list, b.stmt)
+
+	// The variable must not be updated after its declaration.
+	updated := make(map[*types.Var]bool)
+	escape(info, root, func(v *types.Var, _ bool) { updated[v] = true })
+	ast.Inspect(root, func(n ast.Node) bool {
+		if rng, ok := n.(*ast.RangeStmt); ok && rng.Tok == token.ASSIGN {
+			for _, e := range []ast.Expr{rng.Key, rng.Value} {
+				if id, ok := e.(*ast.Ident); ok {
+					if v, ok := info.Uses[id].(*types.Var); ok {
+						updated[v] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	if updated[v] {
+		return nil, fmt.Errorf("cannot inline %s: it is assigned after its declaration, or its address is taken", v.Name())
+	}
+	if len(uses) > 1 && !duplicable(info, b.init) {
+		return nil, fmt.Errorf("cannot inline %s: its initializer is not duplicable and it is referenced %d times", v.Name(), len(uses))
+	}
+
+	// The free names of the initializer must have the same
+	// meaning at each reference.
+	free := freeObjects(info, b.init, b.typ)
+	for _, use := range uses {
+		if obj := shadowedFree(free, v.Pkg(), use.Pos()); obj != nil {
+			return nil, fmt.Errorf("cannot inline %s: %s would refer to a different declaration at the reference on line %d",
+				v.Name(), obj.Name(), fset.Position(use.Pos()).Line)
+		}
+	}
+
+	// Unless the initializer is pure, the intervening statements
+	// must not change its value, nor must its evaluation be
+	// reordered with other effects.
+	assign1 := func(v *types.Var) bool { return !updated[v] }
+	if !pure(info, assign1, b.init) {
+		// Each reference must be evaluated once, unconditionally.
+		for _, use := range uses {
+			if reason := repeatedOrConditional(file, list, use); reason != "" {
+				return nil, fmt.Errorf("cannot inline %s: its impure initializer would be evaluated %s", v.Name(), reason)
+			}
+		}
+		last := len(list) - 1
+		for last > i && !within(uses[len(uses)-1].Pos(), list[last]) {
+			last--
+		}
+		if last == i {
+			return nil, fmt.Errorf("internal error: references to %s not found in block", v.Name())
+		}
+		initFx := calleefx(info, &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: b.init}}}, nil)
+		fx := calleefx(info, &ast.BlockStmt{List: list[i+1 : last+1]}, map[*types.Var]*paramInfo{
+			v: {Name: v.Name(), Refs: make([]refInfo, len(uses))},
+		})
+		logf("initializer effects %v, intervening effects %v", initFx, fx)
+		first := slices.Index(fx, 0)
+		switch {
+		case first < 0:
+			return nil, fmt.Errorf("cannot inline %s: its impure initializer would be evaluated within a function literal", v.Name())
+		case slices.Contains(fx[:first], winf):
+			return nil, fmt.Errorf("cannot inline %s: its initializer may be affected by the statements before its first reference", v.Name())
+		case slices.Contains(initFx, winf) && first > 0:
+			return nil, fmt.Errorf("cannot inline %s: the effects of its initializer would be reordered with those of the statements before its first reference", v.Name())
+		case len(uses) > 1 && slices.Contains(fx, winf):
+			return nil, fmt.Errorf("cannot inline %s: its initializer may be affected by the statements between its references", v.Name())
+		}
+	}
+
+	// A constant initializer must be a valid operand at each
+	// reference: consider "i := -1; _ = s[i]".
+	if tv := info.Types[b.init]; tv.Value != nil && isBasic(v.Type(), types.IsConstType) {
+		decl, ok := root.(*ast.FuncDecl)
+		if !ok {
+			lit := root.(*ast.FuncLit)
+			decl = &ast.FuncDecl{Name: ast.NewIdent("_"), Type: lit.Type, Body: lit.Body}
+		}
+		params := map[*types.Var]*paramInfo{v: {Name: v.Name()}}
+		res := falcon(logf, fset, params, info, decl)
+		if err := checkConstant(logf, res, v.Name(), params[v].FalconType, tv.Value); err != nil {
+			return nil, fmt.Errorf("cannot inline %s: %v", v.Name(), err)
+		}
+	}
+
+	return b.replace(fset, info, file, content, v, uses, true)
+}
+
+// InlineConstant replaces each reference to the constant c within
+// file by the constant's initializer. The file must be type-checked
+// as part of the package that declares c, and content must be its
+// source. If c is a local constant, and declFile, the file declaring
+// c, is the same as file, the declaration is deleted too.
+//
+// The constant must be declared with an explicit value expression
+// that does not depend on iota. The free names of the initializer must
+// have the same meaning at each reference; imports are added to file
+// as needed.
+func InlineConstant(fset *token.FileSet, info *types.Info, declFile, file *ast.File, content []byte, c *types.Const, opts *Options) (*Result, error) {
+	b, err := findBinding(info, declFile, c)
+	if err != nil {
+		return nil, err
+	}
+	uses := findUses(info, file, c)
+	if len(uses) == 0 {
+		return nil, fmt.Errorf("cannot inline %s: it is not referenced", c.Name())
+	}
+
+	free := freeObjects(info, b.init, b.typ)
+	if obj, ok := free["iota"]; ok && obj.Parent() == types.Universe {
+		return nil, fmt.Errorf("cannot inline %s: its value depends on iota", c.Name())
+	}
+	// Imports of the declaring file missing from file are added.
+	var imports []*types.PkgName
+	for _, use := range uses {
+		for name, obj := range free {
+			_, found := c.Pkg().Scope().Innermost(use.Pos()).LookupParent(name, use.Pos())
+			if found == obj {
+				continue
+			}
+			if pkgname, ok := obj.(*types.PkgName); ok {
+				if found == nil {
+					if !slices.Contains(imports, pkgname) {
+						imports = append(imports, pkgname)
+					}
+					continue
+				}
+				if other, ok := found.(*types.PkgName); ok && other.Imported() == pkgname.Imported() {
+					continue
+				}
+			}
+			return nil, fmt.Errorf("cannot inline %s: %s would refer to a different declaration at the reference on line %d",
+				c.Name(), name, fset.Position(use.Pos()).Line)
+		}
+	}
+
+	remove := file == declFile && !isPkgLevel(c) && b.removable()
+	res, err := b.replace(fset, info, file, content, c, uses, remove)
+	if err != nil || len(imports) == 0 {
+		return res, err
+	}
+
+	// Add the missing imports.
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Name() < imports[j].Name() })
+	fset2 := token.NewFileSet()
+	f, err := parser.ParseFile(fset2, "", res.Content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: inlining produced invalid code: %v", err)
+	}
+	for _, pkgname := range imports {
+		name := pkgname.Name()
+		if name == pkgname.Imported().Name() {
+			name = ""
+		}
+		astutil.AddNamedImport(fset2, f, name, pkgname.Imported().Path())
+	}
+	var out bytes.Buffer
+	if err := format.Node(&out, fset2, f); err != nil {
+		return nil, err
+	}
+	res.Content = out.Bytes()
+	return res, nil
+}
+
+// A binding describes the declaration of a variable or constant with
+// an initializer.
+type binding struct {
+	path []ast.Node     // path from the declaring identifier to the root of the file
+	init ast.Expr       // initializer
+	typ  ast.Expr       // declared type, or nil
+	spec *ast.ValueSpec // declaring spec, or nil for a short variable declaration
+	stmt ast.Stmt       // declaring statement, or nil for a package-level declaration
+}
+
+// findBinding returns the binding of obj, declared in file.
+func findBinding(info *types.Info, file *ast.File, obj types.Object) (*binding, error) {
+	path, _ := astutil.PathEnclosingInterval(file, obj.Pos(), obj.Pos())
+	if id, ok := path[0].(*ast.Ident); !ok || info.Defs[id] != obj {
+		return nil, fmt.Errorf("declaration of %s not found", obj.Name())
+	}
+	b := &binding{path: path}
+	switch parent := path[1].(type) {
+	case *ast.AssignStmt:
+		if parent.Tok != token.DEFINE || len(parent.Rhs) != len(parent.Lhs) {
+			return nil, fmt.Errorf("cannot inline %s: it has no initializer", obj.Name())
+		}
+		if len(parent.Lhs) > 1 {
+			return nil, fmt.Errorf("cannot inline %s: it is declared along with other variables", obj.Name())
+		}
+		b.init = parent.Rhs[0]
+		b.stmt = parent
+
+	case *ast.ValueSpec:
+		if len(parent.Values) != len(parent.Names) {
+			return nil, fmt.Errorf("cannot inline %s: it has no explicit initializer", obj.Name())
+		}
+		if _, ok := obj.(*types.Var); ok && len(parent.Names) > 1 {
+			return nil, fmt.Errorf("cannot inline %s: it is declared along with other variables", obj.Name())
+		}
+		b.init = parent.Values[slices.Index(parent.Names, path[0].(*ast.Ident))]
+		b.typ = parent.Type
+		b.spec = parent
+		if stmt, ok := path[3].(*ast.DeclStmt); ok {
+			b.stmt = stmt
+		}
+
+	default:
+		return nil, fmt.Errorf("cannot inline %s: it has no initializer", obj.Name())
+	}
+	return b, nil
+}
+
+// removable reports whether the declaring spec of the binding can be
+// deleted without affecting the other specs of its declaration.
+func (b *binding) removable() bool {
+	if b.spec == nil {
+		return true
+	}
+	if len(b.spec.Names) > 1 {
+		return false
+	}
+	decl := b.path[2].(*ast.GenDecl)
+	if decl.Tok == token.CONST {
+		// Specs that follow an implicit repetition of a previous
+		// one, or that use iota, depend on their position.
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			if len(spec.Values) == 0 {
+				return false
+			}
+			iota := false
+			ast.Inspect(spec, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+					iota = true
+				}
+				return !iota
+			})
+			if iota {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// replace replaces each of the uses of obj in file by the initializer
+// of the binding, and deletes the declaration if remove is set.
+func (b *binding) replace(fset *token.FileSet, info *types.Info, file *ast.File, content []byte, obj types.Object, uses []*ast.Ident, remove bool) (*Result, error) {
+	text, err := formatExpr(fset, b.init)
+	if err != nil {
+		return nil, err
+	}
+	// Preserve the type of an explicitly typed declaration.
+	// (The recorded type of an untyped constant initializer is that
+	// of the declaration, so it too needs a conversion.)
+	var replacement ast.Expr = b.init
+	if b.typ != nil && (!types.Identical(info.TypeOf(b.init), obj.Type()) || isUntypedConstant(info, b.init)) {
+		typ, err := formatExpr(fset, b.typ)
+		if err != nil {
+			return nil, err
+		}
+		switch b.typ.(type) {
+		case *ast.StarExpr, *ast.FuncType, *ast.ChanType:
+			typ = "(" + typ + ")"
+		}
+		text = typ + "(" + text + ")"
+		replacement = convert(b.typ, b.init)
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	tokFile := fset.File(file.FileStart)
+	var edits []edit
+	for _, use := range uses {
+		text := text
+		path, _ := astutil.PathEnclosingInterval(file, use.Pos(), use.End())
+		if needsParens(path, use, replacement) || rightOperand(path, use, replacement) {
+			text = "(" + text + ")"
+		}
+		edits = append(edits, edit{tokFile.Offset(use.Pos()), tokFile.Offset(use.End()), text})
+	}
+	if remove {
+		var del ast.Node = b.stmt
+		if b.spec != nil {
+			decl := b.path[2].(*ast.GenDecl)
+			switch {
+			case len(decl.Specs) > 1:
+				del = b.spec
+			case b.stmt == nil:
+				del = decl
+			}
+		}
+		start, end := del.Pos(), del.End()
+		switch del := del.(type) {
+		case *ast.ValueSpec:
+			if del.Doc != nil {
+				start = del.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if del.Doc != nil {
+				start = del.Doc.Pos()
+			}
+		}
+		startOffset, endOffset := wholeLines(content, tokFile.Offset(start), tokFile.Offset(end))
+		edits = append(edits, edit{startOffset, endOffset, ""})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		out.Write(content[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+	out.Write(content[last:])
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("internal error: inlining produced invalid code: %v", err)
+	}
+	return &Result{Content: formatted}, nil
+}
+
+// repeatedOrConditional returns a description of the context in which
+// the reference use, within one of the statements of list, might be
+// evaluated more than once, or not at all, each time the statements
+// are executed; or "" if it is evaluated exactly once.
+func repeatedOrConditional(file *ast.File, list []ast.Stmt, use *ast.Ident) string {
+	path, _ := astutil.PathEnclosingInterval(file, use.Pos(), use.End())
+	for j := 1; j < len(path); j++ {
+		child := path[j-1]
+		switch n := path[j].(type) {
+		case *ast.FuncLit:
+			return "within a function literal"
+		case *ast.ForStmt:
+			if child == n.Cond || child == n.Post || child == n.Body {
+				return "repeatedly within a loop"
+			}
+		case *ast.RangeStmt:
+			if child == n.Body {
+				return "repeatedly within a loop"
+			}
+		case *ast.IfStmt:
+			if child == n.Body || child == n.Else {
+				return "conditionally within an if statement"
+			}
+		case *ast.SwitchStmt:
+			if child == n.Body {
+				return "conditionally within a switch statement"
+			}
+		case *ast.TypeSwitchStmt:
+			if child == n.Body {
+				return "conditionally within a switch statement"
+			}
+		case *ast.SelectStmt:
+			if child == n.Body {
+				return "conditionally within a select statement"
+			}
+		case *ast.BinaryExpr:
+			if (n.Op == token.LAND || n.Op == token.LOR) && child == n.Y {
+				return fmt.Sprintf("conditionally as an operand of %s", n.Op)
+			}
+		case ast.Stmt:
+			if slices.Contains(list, n) {
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// isUntypedConstant reports whether e is a constant expression other
+// than a conversion, whose type is thus inferred from its context.
+func isUntypedConstant(info *types.Info, e ast.Expr) bool {
+	if info.Types[e].Value == nil {
+		return false
+	}
+	if call, ok := ast.Unparen(e).(*ast.CallExpr); ok && info.Types[call.Fun].IsType() {
+		return false
+	}
+	return true
+}
+
+// rightOperand reports whether the old node, the right operand of a
+// binary expression, must be parenthesized when replaced by the new
+// binary expression of the same precedence, as in x - (y - z).
+// (Substitution of a call never has this problem, so [needsParens]
+// does not consider it.)
+func rightOperand(path []ast.Node, old, new ast.Node) bool {
+	i := slices.Index(path, old)
+	parent, ok := path[i+1].(*ast.BinaryExpr)
+	if !ok || parent.Y != old {
+		return false
+	}
+	child, ok := new.(*ast.BinaryExpr)
+	return ok && child.Op.Precedence() == parent.Op.Precedence()
+}
+
+// findUses returns the references to obj within file, in order.
+func findUses(info *types.Info, file *ast.File, obj types.Object) []*ast.Ident {
+	var uses []*ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			uses = append(uses, id)
+		}
+		return true
+	})
+	return uses
+}
+
+// freeObjects returns the objects, keyed by name, referenced by the
+// free identifiers of the given expressions, ignoring field and
+// method selectors and struct literal keys.
+func freeObjects(info *types.Info, exprs ...ast.Expr) map[string]types.Object {
+	free := make(map[string]types.Object)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if obj := info.Uses[n]; obj != nil && !slices.ContainsFunc(exprs, func(e ast.Expr) bool { return e != nil && within(obj.Pos(), e) }) {
+				free[n.Name] = obj
+			}
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok {
+				if v, ok := info.Uses[key].(*types.Var); ok && v.IsField() {
+					ast.Inspect(n.Value, visit)
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for _, e := range exprs {
+		if e != nil {
+			ast.Inspect(e, visit)
+		}
+	}
+	return free
+}
+
+// shadowedFree returns the first of the free objects that is not
+// visible under its name at pos, or nil if all are visible.
+func shadowedFree(free map[string]types.Object, pkg *types.Package, pos token.Pos) types.Object {
+	scope := pkg.Scope().Innermost(pos)
+	for name, obj := range free {
+		if _, found := scope.LookupParent(name, pos); found != obj {
+			return obj
+		}
+	}
+	return nil
+}
+
+// checkConstant checks whether the constraints of the falcon analysis
+// are satisfied when the named variable has the given constant value.
+func checkConstant(logf logger, res falconResult, name, falconType string, value constant.Value) error {
+	if falconType == "" || len(res.Constraints) == 0 {
+		return nil
+	}
+	// Create a dummy package, as in checkFalconConstraints.
+	pkg := types.NewPackage("falcon", "falcon")
+	for _, typ := range res.Types {
+		pkg.Scope().Insert(types.NewTypeName(token.NoPos, pkg, typ.Name, types.Typ[typ.Kind]))
+	}
+	t := pkg.Scope().Lookup(falconType).Type()
+	pkg.Scope().Insert(types.NewConst(token.NoPos, pkg, name, t, value))
+	logf("falcon env: const %s %s = %v", name, falconType, value)
+
+	fset := token.NewFileSet()
+	for _, falcon := range res.Constraints {
+		expr, err := parser.ParseExprFrom(fset, "falcon", falcon, 0)
+		if err != nil {
+			panic(fmt.Sprintf("failed to parse falcon constraint %s: %v", falcon, err))
+		}
+		if err := types.CheckExpr(fset, pkg, token.NoPos, expr, nil); err != nil {
+			return fmt.Errorf("a reference would become an invalid constant expression (%v)", err)
+		}
+		logf("falcon: constraint %s satisfied", falcon)
+	}
+	return nil
+}
+
+// formatExpr returns the formatted source of e.
+func formatExpr(fset *token.FileSet, e ast.Expr) (string, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, e); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// wholeLines extends the range [start, end) of content to include its
+// complete lines, if nothing else appears on them but a trailing
+// line comment.
+func wholeLines(content []byte, start, end int) (int, int) {
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	if len(bytes.TrimSpace(content[lineStart:start])) > 0 {
+		return start, end
+	}
+	rest := content[end:]
+	eol := bytes.IndexByte(rest, '\n')
+	if eol < 0 {
+		eol = len(rest)
+	} else {
+		eol++
+	}
+	if trailing := bytes.TrimSpace(rest[:eol]); len(trailing) > 0 && !bytes.HasPrefix(trailing, []byte("//")) {
+		return start, end
+	}
+	return lineStart, end + eol
+}
@@ -0,0 +1,472 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inline_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/refactor/inline"
+)
+
+func TestInlineVariable(t *testing.T) {
+	for _, test := range []struct {
+		descr, src, want string // want is the result, or "error: regexp"
+	}{
+		{
+			"Basic.",
+			`func f() int {
+	x := 1
+	return x + 1
+}`,
+			`func f() int {
+	return 1 + 1
+}`,
+		},
+		{
+			"Var declaration with conversion.",
+			`func f() {
+	var x int64 = 1
+	g(x)
+}
+
+func g(any) {}`,
+			`func f() {
+	g(int64(1))
+}
+
+func g(any) {}`,
+		},
+		{
+			"Multiple references to a non-duplicable initializer.",
+			`func f(a, b int) int {
+	x := a - b
+	return 2 - x*2 - x
+}`,
+			`error: not duplicable`,
+		},
+		{
+			"Parens are added as needed.",
+			`func f(a, b int) (int, int) {
+	x := a - b
+	y := a + b
+	return x * 2, 1 - y
+}`,
+			`func f(a, b int) (int, int) {
+	y := a + b
+	return (a - b) * 2, 1 - y
+}`,
+		},
+		{
+			"Multiple references to a duplicable initializer.",
+			`func f(a int) int {
+	x := a
+	return x + x
+}`,
+			`func f(a int) int {
+	return a + a
+}`,
+		},
+		{
+			"Assigned after its declaration.",
+			`func f() int {
+	x := 1
+	x++
+	return x
+}`,
+			`error: assigned after its declaration`,
+		},
+		{
+			"Address taken.",
+			`func f() *int {
+	x := 1
+	return &x
+}`,
+			`error: address is taken`,
+		},
+		{
+			"Free name is shadowed at the reference.",
+			`func f(a int) int {
+	x := a
+	{
+		a := 2
+		return x + a
+	}
+}`,
+			`error: a would refer to a different declaration`,
+		},
+		{
+			"Impure initializer is affected by an intervening call.",
+			`var g int
+
+func h() { g++ }
+
+func f() int {
+	x := g
+	h()
+	return x
+}`,
+			`error: may be affected by the statements before its first reference`,
+		},
+		{
+			"Impure initializer with no intervening effects.",
+			`var g int
+
+func f() {
+	x := g
+	println(x)
+}`,
+			`var g int
+
+func f() {
+	println(g)
+}`,
+		},
+		{
+			"Initializer effects would be reordered.",
+			`var y int
+
+func g() int { return 0 }
+
+func f() int {
+	x := g()
+	return y + x
+}`,
+			`error: effects of its initializer would be reordered`,
+		},
+		{
+			"Initializer effects are not reordered.",
+			`func g() int { return 0 }
+
+func f() int {
+	// comment
+	x := g()
+	return x + 1
+}`,
+			`func g() int { return 0 }
+
+func f() int {
+	// comment
+	return g() + 1
+}`,
+		},
+		{
+			"Impure initializer evaluated within a function literal.",
+			`var g int
+
+func f() func() int {
+	x := g
+	return func() int { return x }
+}`,
+			`error: within a function literal`,
+		},
+		{
+			"Impure initializer evaluated in a loop condition.",
+			`func g() int { return 0 }
+
+func f() {
+	x := g()
+	for i := 0; i < x; i++ {
+	}
+}`,
+			`error: repeatedly within a loop`,
+		},
+		{
+			"Impure initializer evaluated in a loop post statement.",
+			`func g() int { return 0 }
+
+func f() {
+	x := g()
+	for i := 0; i < 10; i += x {
+	}
+}`,
+			`error: repeatedly within a loop`,
+		},
+		{
+			"Impure initializer evaluated in a loop body.",
+			`func g() int { return 0 }
+
+func f() {
+	x := g()
+	for {
+		println(x)
+	}
+}`,
+			`error: repeatedly within a loop`,
+		},
+		{
+			"Impure initializer evaluated in a range loop body.",
+			`func g() int { return 0 }
+
+func f(s []int) {
+	x := g()
+	for range s {
+		println(x)
+	}
+}`,
+			`error: repeatedly within a loop`,
+		},
+		{
+			"Impure initializer evaluated once in a loop header.",
+			`func g() int { return 0 }
+
+func f() {
+	x := g()
+	for i := x; i < 10; i++ {
+	}
+}`,
+			`func g() int { return 0 }
+
+func f() {
+	for i := g(); i < 10; i++ {
+	}
+}`,
+		},
+		{
+			"Impure initializer evaluated once as a range operand.",
+			`func g() []int { return nil }
+
+func f() {
+	x := g()
+	for range x {
+	}
+}`,
+			`func g() []int { return nil }
+
+func f() {
+	for range g() {
+	}
+}`,
+		},
+		{
+			"Impure initializer evaluated in an if body.",
+			`func g() int { return 0 }
+
+func f(b bool) {
+	x := g()
+	if b {
+		println(x)
+	}
+}`,
+			`error: conditionally within an if statement`,
+		},
+		{
+			"Impure initializer evaluated in a switch case.",
+			`func g() int { return 0 }
+
+func f(n int) {
+	x := g()
+	switch n {
+	case 1:
+		println(x)
+	}
+}`,
+			`error: conditionally within a switch statement`,
+		},
+		{
+			"Impure initializer evaluated in the right operand of &&.",
+			`func g() int { return 0 }
+
+func f(c bool) bool {
+	x := g()
+	return c && x > 0
+}`,
+			`error: conditionally as an operand of &&`,
+		},
+		{
+			"Impure initializer evaluated in the left operand of ||.",
+			`func g() int { return 0 }
+
+func f(c bool) bool {
+	x := g()
+	return x > 0 || c
+}`,
+			`func g() int { return 0 }
+
+func f(c bool) bool {
+	return g() > 0 || c
+}`,
+		},
+		{
+			"Impure initializer evaluated in an if condition.",
+			`func g() int { return 0 }
+
+func f() {
+	x := g()
+	if x > 0 {
+		println()
+	}
+}`,
+			`func g() int { return 0 }
+
+func f() {
+	if g() > 0 {
+		println()
+	}
+}`,
+		},
+		{
+			"Fallible constant.",
+			`func f(s string) byte {
+	x := -1
+	return s[x]
+}`,
+			`error: invalid constant expression`,
+		},
+		{
+			"Declared in a statement header.",
+			`func f() int {
+	if x := 1; x > 0 {
+		return x
+	}
+	return 0
+}`,
+			`error: header of a statement`,
+		},
+	} {
+		t.Run(test.descr, func(t *testing.T) {
+			runVariableTest(t, test.src, test.want, func(fset *token.FileSet, info *types.Info, file *ast.File, content []byte, obj types.Object) (*inline.Result, error) {
+				return inline.InlineVariable(fset, info, file, content, obj.(*types.Var), &inline.Options{Logf: t.Logf})
+			})
+		})
+	}
+}
+
+func TestInlineConstant(t *testing.T) {
+	for _, test := range []struct {
+		descr, src, want string // want is the result, or "error: regexp"
+	}{
+		{
+			"Package-level constant.",
+			`const x = 2 - 1
+
+func f() int { return 3 - x }`,
+			`const x = 2 - 1
+
+func f() int { return 3 - (2 - 1) }`,
+		},
+		{
+			"Typed constant.",
+			`const x int32 = 1
+
+func f() any { return x }`,
+			`const x int32 = 1
+
+func f() any { return int32(1) }`,
+		},
+		{
+			"Local constant is deleted.",
+			`func f() int {
+	const (
+		a = 0
+		x = 1
+	)
+	return x + a
+}`,
+			`func f() int {
+	const (
+		a = 0
+	)
+	return 1 + a
+}`,
+		},
+		{
+			"Implicit iota.",
+			`const (
+	a = iota
+	x
+)
+
+func f() int { return x }`,
+			`error: no explicit initializer`,
+		},
+		{
+			"Explicit iota.",
+			`const (
+	a = iota
+	x = iota * 2
+)
+
+func f() int { return x }`,
+			`error: depends on iota`,
+		},
+		{
+			"Free name is shadowed at the reference.",
+			`const y = 2
+
+const x = y
+
+func f() int {
+	y := 3
+	return x + y
+}`,
+			`error: y would refer to a different declaration`,
+		},
+	} {
+		t.Run(test.descr, func(t *testing.T) {
+			runVariableTest(t, test.src, test.want, func(fset *token.FileSet, info *types.Info, file *ast.File, content []byte, obj types.Object) (*inline.Result, error) {
+				return inline.InlineConstant(fset, info, file, file, content, obj.(*types.Const), &inline.Options{Logf: t.Logf})
+			})
+		})
+	}
+}
+
+// runVariableTest applies the inlining operation to the first
+// declaration named x in src, the declarations of a file, and checks
+// the result against want.
+func runVariableTest(t *testing.T, src, want string, inline func(*token.FileSet, *types.Info, *ast.File, []byte, types.Object) (*inline.Result, error)) {
+	content := []byte("package p\n\n" + src + "\n")
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := &types.Config{Importer: importer.Default(), Error: func(err error) { t.Error(err) }}
+	if _, err := conf.Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	var obj types.Object
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "x" && info.Defs[id] != nil {
+			obj = info.Defs[id]
+		}
+		return obj == nil
+	})
+	if obj == nil {
+		t.Fatalf("no declaration of x: %s", src)
+	}
+
+	res, err := inline(fset, info, file, content, obj)
+	if rest, ok := strings.CutPrefix(want, "error: "); ok {
+		if err == nil {
+			t.Fatalf("unexpected success: want error matching %q", rest)
+		}
+		if !regexp.MustCompile(rest).MatchString(err.Error()) {
+			t.Fatalf("wrong error: %s (want match for %q)", err, rest)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantContent := "package p\n\n" + want + "\n"
+	if got := string(res.Content); got != wantContent {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantContent)
+	}
+}